@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/gorilla/mux"
+)
+
+// accountDeletionView is a user's pending or scheduled account deletion.
+type accountDeletionView struct {
+	Status       string     `json:"status"` // "Pending" until confirmed by email, then "Scheduled"
+	RequestedAt  time.Time  `json:"requestedAt"`
+	ConfirmBy    time.Time  `json:"confirmBy"`
+	ScheduledFor *time.Time `json:"scheduledFor,omitempty"`
+}
+
+func writeAccountDeletion(w http.ResponseWriter, status int, deletion *database.AccountDeletion) {
+	response, marshalErr := json.Marshal(accountDeletionView{
+		Status:       deletion.Status.String(),
+		RequestedAt:  deletion.RequestedAt,
+		ConfirmBy:    deletion.ConfirmBy,
+		ScheduledFor: deletion.ScheduledFor,
+	})
+	if marshalErr != nil {
+		http.Error(w, marshalErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// UserDeleteUserHandler starts deleting the user's account by emailing them a confirmation link.
+// DELETE /api/user
+func (hs *HttpServer) UserDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserDeleteUserHandler")
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	deletion, err := hs.service.RequestAccountDeletion(r.Context(), userID)
+	switch {
+	case err == nil:
+		writeAccountDeletion(w, http.StatusAccepted, deletion)
+	case errors.Is(err, service.ErrAccountDeletionScheduled):
+		http.Error(w, "Account deletion is already scheduled", http.StatusConflict)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrEmailSendingDisabled):
+		http.Error(w, "Account deletion needs email confirmation, which is unavailable", http.StatusServiceUnavailable)
+	default:
+		slog.ErrorContext(r.Context(), "Error requesting account deletion", "error", err)
+		http.Error(w, "Failed to request account deletion", http.StatusInternalServerError)
+	}
+}
+
+// UserGetAccountDeletionHandler returns the user's pending or scheduled account deletion.
+// GET /api/user/deletion
+func (hs *HttpServer) UserGetAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserGetAccountDeletionHandler")
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	deletion, err := hs.service.GetAccountDeletion(r.Context(), userID)
+	switch {
+	case err == nil:
+		writeAccountDeletion(w, http.StatusOK, deletion)
+	case errors.Is(err, service.ErrAccountDeletionNotFound):
+		http.Error(w, "No account deletion requested", http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to get account deletion", http.StatusInternalServerError)
+	}
+}
+
+// UserCancelAccountDeletionHandler cancels the user's pending or scheduled account deletion.
+// DELETE /api/user/deletion
+func (hs *HttpServer) UserCancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserCancelAccountDeletionHandler")
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	err = hs.service.CancelAccountDeletion(r.Context(), userID)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, service.ErrAccountDeletionNotFound):
+		http.Error(w, "No account deletion requested", http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to cancel account deletion", http.StatusInternalServerError)
+	}
+}
+
+// AccountDeletionConfirmHandler confirms an account deletion from the emailed link, which is
+// opened without the auth cookie, and schedules it for the end of the grace period.
+// GET /api/user/deletion/confirm/{confirmation_code}
+func (hs *HttpServer) AccountDeletionConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	deletion, err := hs.service.ConfirmAccountDeletion(r.Context(), mux.Vars(r)["confirmation_code"])
+	switch {
+	case err == nil:
+		writeAccountDeletion(w, http.StatusOK, deletion)
+	case errors.Is(err, service.ErrAccountDeletionNotFound):
+		http.Error(w, "Confirmation link not found or cancelled", http.StatusNotFound)
+	case errors.Is(err, service.ErrAccountDeletionExpired):
+		http.Error(w, "Confirmation link has expired", http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to confirm account deletion", http.StatusInternalServerError)
+	}
+}
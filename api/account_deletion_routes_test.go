@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserDeleteUserHandler_NeedsEmail(t *testing.T) {
+	token := "delete-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	// The test server has no email sender, so the deletion could never be confirmed
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d got %d: %s", http.StatusServiceUnavailable, rr.Code, rr.Body.String())
+	}
+}
+
+func TestAccountDeletionRoutes_NotFound(t *testing.T) {
+	token := "delete-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	for _, tc := range []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/api/user/deletion"},
+		{http.MethodDelete, "/api/user/deletion"},
+		{http.MethodGet, "/api/user/deletion/confirm/unknown"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected status %d got %d", tc.method, tc.path, http.StatusNotFound, rr.Code)
+		}
+	}
+}
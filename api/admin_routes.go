@@ -0,0 +1,245 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (hs *HttpServer) setupAdminRoutes(r *mux.Router) {
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(hs.requireAdmin)
+
+	// Undo soft deletes. Restoring a user or candidate also restores what was deleted with it.
+	admin.HandleFunc("/users/{id}/restore", hs.adminRestoreHandler("user", hs.dbDriver.RestoreUser)).Methods("POST")
+	admin.HandleFunc("/candidates/{id}/restore", hs.adminRestoreHandler("candidate", hs.dbDriver.RestoreCandidate)).Methods("POST")
+	admin.HandleFunc("/referrers/{id}/restore", hs.adminRestoreHandler("referrer", hs.dbDriver.RestoreReferrer)).Methods("POST")
+	admin.HandleFunc("/referral_requests/{id}/restore", hs.adminRestoreHandler("referral request", hs.dbDriver.RestoreReferralRequest)).Methods("POST")
+	admin.HandleFunc("/companies/{id}/restore", hs.adminRestoreHandler("company", hs.dbDriver.RestoreCompany)).Methods("POST")
+
+	// Fold duplicate companies together and manage the other names companies go by
+	admin.HandleFunc("/companies/merges", hs.AdminGetCompanyMergesHandler).Methods("GET")
+	admin.HandleFunc("/companies/{id}/merge", hs.AdminMergeCompanyHandler).Methods("POST")
+	admin.HandleFunc("/companies/{id}/aliases", hs.AdminCreateCompanyAliasHandler).Methods("POST")
+	admin.HandleFunc("/companies/{id}/aliases/{alias_id}", hs.AdminDeleteCompanyAliasHandler).Methods("DELETE")
+
+	admin.HandleFunc("/stats/companies", hs.AdminGetCompanyResponseStatsHandler).Methods("GET")
+}
+
+const (
+	defaultStatsDays = 90
+	maxStatsDays     = 3650
+)
+
+// requireAdmin only lets through requests from signed-in users with admin rights, which are
+// granted with the `user promote-admin` command.
+func (hs *HttpServer) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := hs.GetUserIDFromContext(r)
+		if err != nil {
+			http.Error(w, "User not authenticated", http.StatusUnauthorized)
+			return
+		}
+		if user := hs.dbDriver.GetUserById(userID); user == nil || !user.IsAdmin {
+			slog.WarnContext(r.Context(), "Non-admin user called an admin endpoint", "route", routeTemplate(r))
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminRestoreHandler returns a handler that restores the soft-deleted record of the given kind
+// whose ID is in the path, responding 204 on success.
+func (hs *HttpServer) adminRestoreHandler(kind string, restore func(id uint64) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.DebugContext(r.Context(), "Called adminRestoreHandler", "kind", kind)
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		err = restore(id)
+		switch {
+		case err == nil:
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "No deleted "+kind+" with this ID", http.StatusNotFound)
+			return
+		case errors.Is(err, database.ErrRestoreParentDeleted), errors.Is(err, database.ErrRestoreConflict), errors.Is(err, database.ErrRestoreMerged),
+			errors.Is(err, database.ErrRestoreEmailTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		default:
+			slog.ErrorContext(r.Context(), "Error restoring record", "kind", kind, "id", id, "error", err)
+			http.Error(w, "Failed to restore "+kind, http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Admin restored record", "kind", kind, "id", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AdminMergeCompanyHandler merges the company in the path into the target company, moving its
+// referrers, referral requests, job postings, domains and aliases, and returns the merge record.
+// POST /api/admin/companies/{id}/merge
+func (hs *HttpServer) AdminMergeCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called AdminMergeCompanyHandler")
+	sourceID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var body api_objects.AdminViewCompanyMergeRequest
+	if !decodeAndValidate(w, r, &body) {
+		return
+	}
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	merge, err := hs.service.MergeCompanies(r.Context(), sourceID, body.TargetCompanyId, &userID)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrCompanyMergeIntoSelf):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrCompanyNotFound):
+		http.Error(w, "Company not found", http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "Failed to merge companies", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, merge)
+}
+
+// AdminGetCompanyMergesHandler lists the company merges done so far, latest first.
+// GET /api/admin/companies/merges
+func (hs *HttpServer) AdminGetCompanyMergesHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called AdminGetCompanyMergesHandler")
+	merges, err := hs.dbDriver.GetCompanyMerges()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting company merges", "error", err)
+		http.Error(w, "Failed to get company merges", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, merges)
+}
+
+// AdminCreateCompanyAliasHandler adds another name the company in the path goes by.
+// POST /api/admin/companies/{id}/aliases
+func (hs *HttpServer) AdminCreateCompanyAliasHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called AdminCreateCompanyAliasHandler")
+	companyID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var body api_objects.AdminViewCompanyAlias
+	if !decodeAndValidate(w, r, &body) {
+		return
+	}
+
+	alias, err := hs.dbDriver.CreateCompanyAlias(companyID, body.Alias)
+	switch {
+	case err == nil:
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Company not found", http.StatusNotFound)
+		return
+	case errors.Is(err, database.ErrInvalidCompanyAlias):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, database.ErrCompanyAliasTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		slog.ErrorContext(r.Context(), "Error creating company alias", "company_id", companyID, "error", err)
+		http.Error(w, "Failed to create company alias", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "Admin added company alias", "company_id", companyID, "alias", alias.Alias)
+	writeJSON(w, alias)
+}
+
+// AdminDeleteCompanyAliasHandler removes one of the company's aliases, responding 204.
+// DELETE /api/admin/companies/{id}/aliases/{alias_id}
+func (hs *HttpServer) AdminDeleteCompanyAliasHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called AdminDeleteCompanyAliasHandler")
+	vars := mux.Vars(r)
+	companyID, companyErr := strconv.ParseUint(vars["id"], 10, 64)
+	aliasID, aliasErr := strconv.ParseUint(vars["alias_id"], 10, 64)
+	if companyErr != nil || aliasErr != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := hs.dbDriver.DeleteCompanyAlias(companyID, aliasID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Company alias not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error deleting company alias", "company_id", companyID, "alias_id", aliasID, "error", err)
+		http.Error(w, "Failed to delete company alias", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "Admin deleted company alias", "company_id", companyID, "alias_id", aliasID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminGetCompanyResponseStatsHandler reports, for each company, how long referral requests
+// created in the last days days took to be first opened by a referrer and to be claimed.
+// GET /api/admin/stats/companies?days={days}
+func (hs *HttpServer) AdminGetCompanyResponseStatsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called AdminGetCompanyResponseStatsHandler")
+	days := defaultStatsDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxStatsDays {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	stats, err := hs.service.GetCompanyResponseStats(r.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		http.Error(w, "Failed to compute statistics", http.StatusInternalServerError)
+		return
+	}
+
+	hours := func(d time.Duration, present bool) *float64 {
+		if !present {
+			return nil
+		}
+		h := d.Hours()
+		return &h
+	}
+	result := make([]api_objects.AdminViewCompanyResponseStats, 0, len(stats))
+	for _, company := range stats {
+		result = append(result, api_objects.AdminViewCompanyResponseStats{
+			CompanyId:               company.CompanyId,
+			CompanyName:             company.CompanyName,
+			Requests:                company.Requests,
+			Viewed:                  company.Viewed,
+			Claimed:                 company.Claimed,
+			MedianHoursToFirstView:  hours(company.MedianTimeToFirstView, company.Viewed > 0),
+			AverageHoursToFirstView: hours(company.AverageTimeToFirstView, company.Viewed > 0),
+			MedianHoursToClaim:      hours(company.MedianTimeToClaim, company.Claimed > 0),
+			AverageHoursToClaim:     hours(company.AverageTimeToClaim, company.Claimed > 0),
+		})
+	}
+	writeJSON(w, result)
+}
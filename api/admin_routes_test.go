@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+// setupAdminTestServer is setupTestServer with a migrated database whose first user, the one
+// signed in with token, has the given admin rights.
+func setupAdminTestServer(t *testing.T, token string, isAdmin bool) *HttpServer {
+	t.Helper()
+	hs := setupTestServer(1, token)
+	if _, err := hs.dbDriver.CreateUser(&database.User{FirstName: "Admin", LastName: "User", Email: "admin@example.com", IsAdmin: isAdmin}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return hs
+}
+
+func TestAdminRestoreUser(t *testing.T) {
+	token := "admin-tok"
+	hs := setupAdminTestServer(t, token, true)
+	user, err := hs.dbDriver.CreateUser(&database.User{FirstName: "Deleted", LastName: "User", Email: "deleted@example.com"})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if err := hs.dbDriver.DeleteUser(user); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/2/restore", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if hs.dbDriver.GetUserByEmail("deleted@example.com") == nil {
+		t.Errorf("expected the user to be restored")
+	}
+
+	// Restoring again finds nothing deleted
+	rr = httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestAdminRoutes_RequireAdmin(t *testing.T) {
+	token := "user-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/companies/1/restore", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status %d got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestAdminCompanyResponseStats(t *testing.T) {
+	token := "admin-tok"
+	hs := setupAdminTestServer(t, token, true)
+	inherited, _ := seedVisibilityRequests(t, hs)
+	referrer := hs.dbDriver.GetReferrerByUserId(1)
+	if err := hs.dbDriver.RecordReferralRequestView(inherited, referrer.ReferrerId, time.Now()); err != nil {
+		t.Fatalf("failed to record view: %v", err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/api/admin/stats/companies")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var stats []api_objects.AdminViewCompanyResponseStats
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Requests != 2 || stats[0].Viewed != 1 || stats[0].MedianHoursToFirstView == nil ||
+		stats[0].Claimed != 0 || stats[0].MedianHoursToClaim != nil {
+		t.Errorf("expected two requests, one viewed and none claimed, got %+v", stats)
+	}
+
+	if rr := get("/api/admin/stats/companies?days=0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestAdminCompanyResponseStats_RequiresAdmin(t *testing.T) {
+	token := "user-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/stats/companies", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status %d got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestAdminMergeCompany(t *testing.T) {
+	token := "admin-tok"
+	hs := setupAdminTestServer(t, token, true)
+	source, err := hs.dbDriver.CreateCompany(&database.Company{Name: "Globex", AddedByUserId: 1})
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	target, err := hs.dbDriver.CreateCompany(&database.Company{Name: "Globex Corporation International", AddedByUserId: 1})
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send(http.MethodPost, fmt.Sprintf("/api/admin/companies/%d/aliases", target.Id), `{"alias": "Globex Intl"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var alias database.CompanyAlias
+	if err := json.Unmarshal(rr.Body.Bytes(), &alias); err != nil {
+		t.Fatalf("failed to decode alias: %v", err)
+	}
+	if rr := send(http.MethodPost, fmt.Sprintf("/api/admin/companies/%d/aliases", source.Id), `{"alias": "globex intl"}`); rr.Code != http.StatusConflict {
+		t.Errorf("expected a taken alias to get status %d, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := send(http.MethodDelete, fmt.Sprintf("/api/admin/companies/%d/aliases/%d", target.Id, alias.Id), ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+
+	if rr := send(http.MethodPost, fmt.Sprintf("/api/admin/companies/%d/merge", source.Id), fmt.Sprintf(`{"target_company_id": %d}`, source.Id)); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected merging into itself to get status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := send(http.MethodPost, fmt.Sprintf("/api/admin/companies/%d/merge", source.Id), `{"target_company_id": 99}`); rr.Code != http.StatusNotFound {
+		t.Errorf("expected a missing target to get status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+	rr = send(http.MethodPost, fmt.Sprintf("/api/admin/companies/%d/merge", source.Id), fmt.Sprintf(`{"target_company_id": %d}`, target.Id))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = send(http.MethodGet, "/api/admin/companies/merges", "")
+	var merges []database.CompanyMerge
+	if err := json.Unmarshal(rr.Body.Bytes(), &merges); err != nil {
+		t.Fatalf("failed to decode merges: %v", err)
+	}
+	if len(merges) != 1 || merges[0].SourceCompanyId != source.Id || merges[0].MergedByUserId == nil || *merges[0].MergedByUserId != 1 {
+		t.Errorf("expected the merge to be recorded as done by the admin, got %+v", merges)
+	}
+	if rr := send(http.MethodPost, fmt.Sprintf("/api/admin/companies/%d/restore", source.Id), ""); rr.Code != http.StatusConflict {
+		t.Errorf("expected restoring a merged company to get status %d, got %d", http.StatusConflict, rr.Code)
+	}
+}
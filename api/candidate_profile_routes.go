@@ -0,0 +1,353 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSkillSearchLimit = 20
+	maxSkillSearchLimit     = 100
+)
+
+// candidateForProfile returns the authenticated user's candidate, or writes the error response
+// and returns nil.
+func (hs *HttpServer) candidateForProfile(w http.ResponseWriter, r *http.Request) *database.Candidate {
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return nil
+	}
+	candidate := hs.dbDriver.GetCandidateByUserId(userID)
+	if candidate == nil {
+		http.Error(w, "Candidate not found", http.StatusNotFound)
+		return nil
+	}
+	return candidate
+}
+
+// profileRowId parses the id of an education entry or position from the URL.
+func profileRowId(w http.ResponseWriter, r *http.Request, name string) (uint64, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	response, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+// writeProfileRowError writes the response for a failed update or delete of a profile row.
+func writeProfileRowError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	slog.ErrorContext(r.Context(), "Error saving candidate profile", "error", err)
+	http.Error(w, "Failed to save candidate profile", http.StatusInternalServerError)
+}
+
+// UserGetCandidateProfileHandler returns the candidate's skills, education, positions, desired
+// roles, work authorizations and preferences.
+// GET /api/user/candidate/profile
+func (hs *HttpServer) UserGetCandidateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserGetCandidateProfileHandler")
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	candidate := hs.dbDriver.GetCandidateProfile(userID)
+	if candidate == nil {
+		http.Error(w, "Candidate not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, api_objects.ConvertCandidateToUserViewCandidateProfile(*candidate))
+}
+
+// UserSetCandidateSkillsHandler replaces the candidate's skills. Skills not yet in the
+// vocabulary are added to it.
+// PUT /api/user/candidate/skills
+func (hs *HttpServer) UserSetCandidateSkillsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserSetCandidateSkillsHandler")
+	var request api_objects.UserViewCandidateSkills
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	skills, err := hs.dbDriver.SetCandidateSkills(candidate.CandidateId, request.Skills)
+	if err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	result := api_objects.UserViewCandidateSkills{Skills: make([]string, 0, len(skills))}
+	for _, skill := range skills {
+		result.Skills = append(result.Skills, skill.Name)
+	}
+	writeJSON(w, result)
+}
+
+// UserSearchSkillsHandler suggests skills from the vocabulary starting with the q parameter.
+// GET /api/skills?q={prefix}&limit={limit}
+func (hs *HttpServer) UserSearchSkillsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserSearchSkillsHandler")
+	if _, err := hs.GetUserIDFromContext(r); err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	limit := defaultSkillSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxSkillSearchLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	skills, err := hs.dbDriver.SearchSkills(r.URL.Query().Get("q"), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error searching skills", "error", err)
+		http.Error(w, "Failed to search skills", http.StatusInternalServerError)
+		return
+	}
+	names := make([]string, 0, len(skills))
+	for _, skill := range skills {
+		names = append(names, skill.Name)
+	}
+	writeJSON(w, names)
+}
+
+// UserCreateCandidateEducationHandler adds an education entry to the candidate's profile.
+// POST /api/user/candidate/education
+func (hs *HttpServer) UserCreateCandidateEducationHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserCreateCandidateEducationHandler")
+	var request api_objects.UserViewEducation
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	request.Id = 0
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	education := api_objects.ConvertUserViewEducationToCandidateEducation(request, candidate.CandidateId)
+	created, err := hs.dbDriver.CreateCandidateEducation(&education)
+	if err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	writeJSON(w, api_objects.ConvertCandidateEducationToUserViewEducation(*created))
+}
+
+// UserUpdateCandidateEducationHandler replaces one of the candidate's education entries.
+// PUT /api/user/candidate/education/{education_id}
+func (hs *HttpServer) UserUpdateCandidateEducationHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserUpdateCandidateEducationHandler")
+	id, ok := profileRowId(w, r, "education_id")
+	if !ok {
+		return
+	}
+	var request api_objects.UserViewEducation
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	request.Id = id
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	education := api_objects.ConvertUserViewEducationToCandidateEducation(request, candidate.CandidateId)
+	updated, err := hs.dbDriver.UpdateCandidateEducation(candidate.CandidateId, &education)
+	if err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	writeJSON(w, api_objects.ConvertCandidateEducationToUserViewEducation(*updated))
+}
+
+// UserDeleteCandidateEducationHandler removes one of the candidate's education entries.
+// DELETE /api/user/candidate/education/{education_id}
+func (hs *HttpServer) UserDeleteCandidateEducationHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserDeleteCandidateEducationHandler")
+	id, ok := profileRowId(w, r, "education_id")
+	if !ok {
+		return
+	}
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	if err := hs.dbDriver.DeleteCandidateEducation(candidate.CandidateId, id); err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UserCreateCandidatePositionHandler adds a past or current position to the candidate's profile.
+// POST /api/user/candidate/positions
+func (hs *HttpServer) UserCreateCandidatePositionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserCreateCandidatePositionHandler")
+	var request api_objects.UserViewPosition
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	request.Id = 0
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	position := api_objects.ConvertUserViewPositionToCandidatePosition(request, candidate.CandidateId)
+	created, err := hs.dbDriver.CreateCandidatePosition(&position)
+	if err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	writeJSON(w, api_objects.ConvertCandidatePositionToUserViewPosition(*created))
+}
+
+// UserUpdateCandidatePositionHandler replaces one of the candidate's positions.
+// PUT /api/user/candidate/positions/{position_id}
+func (hs *HttpServer) UserUpdateCandidatePositionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserUpdateCandidatePositionHandler")
+	id, ok := profileRowId(w, r, "position_id")
+	if !ok {
+		return
+	}
+	var request api_objects.UserViewPosition
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	request.Id = id
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	position := api_objects.ConvertUserViewPositionToCandidatePosition(request, candidate.CandidateId)
+	updated, err := hs.dbDriver.UpdateCandidatePosition(candidate.CandidateId, &position)
+	if err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	writeJSON(w, api_objects.ConvertCandidatePositionToUserViewPosition(*updated))
+}
+
+// UserDeleteCandidatePositionHandler removes one of the candidate's positions.
+// DELETE /api/user/candidate/positions/{position_id}
+func (hs *HttpServer) UserDeleteCandidatePositionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserDeleteCandidatePositionHandler")
+	id, ok := profileRowId(w, r, "position_id")
+	if !ok {
+		return
+	}
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	if err := hs.dbDriver.DeleteCandidatePosition(candidate.CandidateId, id); err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UserSetCandidateDesiredRolesHandler replaces the roles the candidate is looking for.
+// PUT /api/user/candidate/desired_roles
+func (hs *HttpServer) UserSetCandidateDesiredRolesHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserSetCandidateDesiredRolesHandler")
+	var request api_objects.UserViewCandidateDesiredRoles
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	roles, err := hs.dbDriver.SetCandidateDesiredRoles(candidate.CandidateId, request.DesiredRoles)
+	if err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	result := api_objects.UserViewCandidateDesiredRoles{DesiredRoles: make([]string, 0, len(roles))}
+	for _, role := range roles {
+		result.DesiredRoles = append(result.DesiredRoles, role.Title)
+	}
+	writeJSON(w, result)
+}
+
+// UserSetCandidateWorkAuthorizationsHandler replaces the countries the candidate can work in and
+// whether they need visa sponsorship there.
+// PUT /api/user/candidate/work_authorizations
+func (hs *HttpServer) UserSetCandidateWorkAuthorizationsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserSetCandidateWorkAuthorizationsHandler")
+	var request api_objects.UserViewCandidateWorkAuthorizations
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	authorizations := api_objects.ConvertUserViewWorkAuthorizationsToCandidateWorkAuthorizations(request.WorkAuthorizations)
+	if _, err := hs.dbDriver.SetCandidateWorkAuthorizations(candidate.CandidateId, authorizations); err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	if request.WorkAuthorizations == nil {
+		request.WorkAuthorizations = make([]api_objects.UserViewWorkAuthorization, 0)
+	}
+	writeJSON(w, request)
+}
+
+// UserSetCandidatePreferencesHandler sets the candidate's remote work and relocation preferences.
+// PUT /api/user/candidate/preferences
+func (hs *HttpServer) UserSetCandidatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserSetCandidatePreferencesHandler")
+	var request api_objects.UserViewCandidatePreferences
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	candidate := hs.candidateForProfile(w, r)
+	if candidate == nil {
+		return
+	}
+
+	preferences := api_objects.ConvertUserViewCandidatePreferencesToCandidatePreferences(request, candidate.CandidateId)
+	saved, err := hs.dbDriver.SaveCandidatePreferences(&preferences)
+	if err != nil {
+		writeProfileRowError(w, r, err)
+		return
+	}
+	writeJSON(w, api_objects.ConvertCandidatePreferencesToUserViewCandidatePreferences(saved))
+}
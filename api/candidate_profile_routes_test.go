@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+func TestCandidateProfileRoutes(t *testing.T) {
+	token := "profile-tok"
+	hs := setupAdminTestServer(t, token, false)
+	if _, err := hs.dbDriver.CreateCandidate(&database.Candidate{UserId: 1, WorkExperience: 3}); err != nil {
+		t.Fatalf("failed to create candidate: %v", err)
+	}
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPut, "/api/user/candidate/skills", `{"skills": ["Go", "PostgreSQL"]}`},
+		{http.MethodPost, "/api/user/candidate/education", `{"school": "State University", "degree": "BSc", "startYear": 2014, "endYear": 2018}`},
+		{http.MethodPost, "/api/user/candidate/positions", `{"title": "Engineer", "companyName": "Acme", "startDate": "2018-09"}`},
+		{http.MethodPut, "/api/user/candidate/desired_roles", `{"desiredRoles": ["Backend Engineer"]}`},
+		{http.MethodPut, "/api/user/candidate/work_authorizations", `{"workAuthorizations": [{"country": "CA", "needsSponsorship": true}]}`},
+		{http.MethodPut, "/api/user/candidate/preferences", `{"remotePreference": "hybrid", "openToRelocation": true}`},
+	} {
+		if rr := send(tc.method, tc.path, tc.body); rr.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected status %d got %d: %s", tc.method, tc.path, http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := send(http.MethodGet, "/api/user/candidate/profile", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var profile api_objects.UserViewCandidateProfile
+	if err := json.Unmarshal(rr.Body.Bytes(), &profile); err != nil {
+		t.Fatalf("failed to decode profile: %v", err)
+	}
+	if len(profile.Skills) != 2 || profile.Skills[0] != "go" {
+		t.Errorf("expected the normalized skills, got %v", profile.Skills)
+	}
+	if len(profile.Education) != 1 || len(profile.Positions) != 1 || profile.Positions[0].StartDate != "2018-09" {
+		t.Errorf("expected the education and position, got %+v, %+v", profile.Education, profile.Positions)
+	}
+	if profile.Preferences.RemotePreference != "hybrid" || len(profile.WorkAuthorizations) != 1 || len(profile.DesiredRoles) != 1 {
+		t.Errorf("expected the preferences, work authorizations and desired roles, got %+v", profile)
+	}
+
+	rr = send(http.MethodGet, "/api/skills?q=po", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "postgresql") {
+		t.Errorf("expected the skill to be suggested, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	path := fmt.Sprintf("/api/user/candidate/education/%d", profile.Education[0].Id)
+	if rr := send(http.MethodDelete, path, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := send(http.MethodDelete, path, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected deleting twice to give %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestCandidateProfileRoutes_ValidationErrors(t *testing.T) {
+	token := "profile-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	for _, tc := range []struct {
+		path, body, field string
+	}{
+		{"/api/user/candidate/positions", `{"title": "Engineer", "companyName": "Acme", "startDate": "2020-05", "endDate": "2019-01"}`, "endDate"},
+		{"/api/user/candidate/positions", `{"title": "Engineer", "companyName": "Acme", "startDate": "May 2020"}`, "startDate"},
+		{"/api/user/candidate/education", `{"school": "State University", "startYear": 2018, "endYear": 2014}`, "endYear"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected status %d got %d", tc.body, http.StatusUnprocessableEntity, rr.Code)
+		}
+		if resp := decodeValidationResponse(t, rr); resp.Fields[tc.field] == "" {
+			t.Errorf("%s: expected a validation error for %s, got %v", tc.body, tc.field, resp.Fields)
+		}
+	}
+}
+
+func TestReferrerViews_CandidateProfileFollowsVisibility(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+	inherited, _ := seedVisibilityRequests(t, hs)
+	candidateId := hs.dbDriver.GetReferralRequestById(inherited).CandidateID
+	if _, err := hs.dbDriver.SetCandidateSkills(candidateId, []string{"go"}); err != nil {
+		t.Fatalf("failed to set skills: %v", err)
+	}
+	if _, err := hs.dbDriver.CreateCandidateEducation(&database.CandidateEducation{CandidateId: candidateId, School: "State University"}); err != nil {
+		t.Fatalf("failed to create education: %v", err)
+	}
+	get := func(method, path string) api_objects.ReferrerViewCandidate {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected status %d got %d: %s", method, path, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var request api_objects.ReferrerViewReferralRequest
+		if err := json.Unmarshal(rr.Body.Bytes(), &request); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return request.Candidate
+	}
+
+	anonymous := get(http.MethodGet, fmt.Sprintf("/api/referrer/referral_requests/%d", inherited))
+	if len(anonymous.Skills) != 1 || len(anonymous.Education) != 0 {
+		t.Errorf("expected skills but no education for an anonymous candidate, got %+v", anonymous)
+	}
+	claimed := get(http.MethodPost, fmt.Sprintf("/api/referrer/refer/%d", inherited))
+	if len(claimed.Education) != 1 || claimed.Education[0].School != "State University" {
+		t.Errorf("expected the claiming referrer to see the education, got %+v", claimed)
+	}
+}
@@ -2,8 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
-	"log"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,13 +15,11 @@ import (
 
 // CandidateCreateReferralRequestHandler handles the creation of a referral request by a candidate
 func (hs *HttpServer) CandidateCreateReferralRequestHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called CandidateCreateReferralRequestHandler")
+	slog.DebugContext(r.Context(), "Called CandidateCreateReferralRequestHandler")
 
-	// Step 1: Decode the incoming JSON request into a ReferralRequest struct
+	// Step 1: Decode and validate the incoming JSON request into a ReferralRequest struct
 	var request api_objects.CandidateViewReferralRequest
-	err := json.NewDecoder(r.Body).Decode(&request)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &request) {
 		return
 	}
 
@@ -39,10 +39,10 @@ func (hs *HttpServer) CandidateCreateReferralRequestHandler(w http.ResponseWrite
 
 	referralRequest := api_objects.ConvertCandidateViewReferralRequestToDbReferralRequest(request, candidate.CandidateId, time.Now(), time.Now(), nil)
 
-	// Step 4: Create the referral request in the database
-	createdRequest, err := hs.dbDriver.CreateReferralRequest(&referralRequest)
+	// Step 4: Create the referral request, enforcing the duplicate and rate rules
+	createdRequest, err := hs.service.CreateReferralRequest(r.Context(), candidate.CandidateId, &referralRequest)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeReferralRequestError(w, r, err)
 		return
 	}
 
@@ -60,11 +60,10 @@ func (hs *HttpServer) CandidateCreateReferralRequestHandler(w http.ResponseWrite
 
 // CandidateUpdateReferralRequestHandler handles updating a referral request by a candidate
 func (hs *HttpServer) CandidateUpdateReferralRequestHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called CandidateUpdateReferralRequestHandler")
+	slog.DebugContext(r.Context(), "Called CandidateUpdateReferralRequestHandler")
 
 	var requestUpdate api_objects.CandidateViewReferralRequest
-	if err := json.NewDecoder(r.Body).Decode(&requestUpdate); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &requestUpdate) {
 		return
 	}
 
@@ -93,9 +92,15 @@ func (hs *HttpServer) CandidateUpdateReferralRequestHandler(w http.ResponseWrite
 	}
 
 	updatedRequest := api_objects.ConvertCandidateViewReferralRequestToDbReferralRequest(requestUpdate, candidate.CandidateId, existingRequest.CreatedAt, time.Now(), nil)
-	dbResult, dbUpdateErr := hs.dbDriver.UpdateReferralRequest(&updatedRequest)
-	if dbUpdateErr != nil {
-		http.Error(w, dbUpdateErr.Error(), http.StatusInternalServerError)
+	// Its status, the referrer it's assigned to and the job posting it came from aren't the
+	// candidate's to change
+	updatedRequest.Status = existingRequest.Status
+	updatedRequest.ReferrerId = existingRequest.ReferrerId
+	updatedRequest.ClaimedAt = existingRequest.ClaimedAt
+	updatedRequest.JobPostingId = existingRequest.JobPostingId
+	dbResult, updateErr := hs.service.UpdateReferralRequest(r.Context(), candidate.CandidateId, &updatedRequest)
+	if updateErr != nil {
+		writeReferralRequestError(w, r, updateErr)
 		return
 	}
 
@@ -111,7 +116,7 @@ func (hs *HttpServer) CandidateUpdateReferralRequestHandler(w http.ResponseWrite
 
 // CandidateDeleteReferralRequestHandler handles the deletion of a referral request by a candidate
 func (hs *HttpServer) CandidateDeleteReferralRequestHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called CandidateDeleteReferralRequestHandler")
+	slog.DebugContext(r.Context(), "Called CandidateDeleteReferralRequestHandler")
 
 	// Authenticate and retrieve the candidate/user ID
 	userID, userIdRetrievalErr := hs.GetUserIDFromContext(r)
@@ -150,7 +155,7 @@ func (hs *HttpServer) CandidateDeleteReferralRequestHandler(w http.ResponseWrite
 
 // CandidateGetReferralRequestHandler handles fetching a specific referral request by its ID
 func (hs *HttpServer) CandidateGetReferralRequestHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called CandidateGetReferralRequestHandler")
+	slog.DebugContext(r.Context(), "Called CandidateGetReferralRequestHandler")
 
 	userID, userIdRetrievalErr := hs.GetUserIDFromContext(r)
 	if userIdRetrievalErr != nil {
@@ -189,7 +194,7 @@ func (hs *HttpServer) CandidateGetReferralRequestHandler(w http.ResponseWriter,
 
 // CandidateGetAllReferralRequestsHandler handles fetching all referral requests for a candidate
 func (hs *HttpServer) CandidateGetAllReferralRequestsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called CandidateGetAllReferralRequestsHandler")
+	slog.DebugContext(r.Context(), "Called CandidateGetAllReferralRequestsHandler")
 	userID, userIdRetrievalErr := hs.GetUserIDFromContext(r)
 	if userIdRetrievalErr != nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
@@ -218,3 +223,22 @@ func (hs *HttpServer) CandidateGetAllReferralRequestsHandler(w http.ResponseWrit
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(response)
 }
+
+// writeReferralRequestError maps errors from the referral request service methods to HTTP responses
+func writeReferralRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrDuplicateReferralRequest):
+		http.Error(w, err.Error(), http.StatusConflict) // 409
+	case errors.Is(err, service.ErrTooManyOpenRequestsForCompany):
+		http.Error(w, err.Error(), http.StatusConflict) // 409
+	case errors.Is(err, service.ErrTooManyOpenRequests):
+		http.Error(w, err.Error(), http.StatusTooManyRequests) // 429
+	case errors.Is(err, service.ErrReferralRequestCooldown):
+		http.Error(w, err.Error(), http.StatusTooManyRequests) // 429
+	case errors.Is(err, service.ErrInvalidJobLink), errors.Is(err, service.ErrJobLinkOtherCompany):
+		http.Error(w, err.Error(), http.StatusBadRequest) // 400
+	default:
+		slog.ErrorContext(r.Context(), "Error saving referral request", "error", err)
+		http.Error(w, "Failed to save referral request", http.StatusInternalServerError)
+	}
+}
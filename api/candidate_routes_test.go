@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+func TestCandidateCreateReferralRequest_CanonicalizesJobLinks(t *testing.T) {
+	token := "candidate-tok"
+	hs := setupAdminTestServer(t, token, false)
+	company, err := hs.dbDriver.CreateCompany(&database.Company{Name: "Acme Corp", AddedByUserId: 1,
+		Domains: []database.CompanyDomainAssociation{{Domain: "acme.example"}}})
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	if _, err := hs.dbDriver.CreateCandidate(&database.Candidate{UserId: 1, ResumeUrl: "https://example.com/resume.pdf"}); err != nil {
+		t.Fatalf("failed to create candidate: %v", err)
+	}
+	create := func(link string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"company_id": %d, "job_title": "Engineer", "job_links": [%q], "referral_type": "Full-Time"}`, company.Id, link)
+		req := httptest.NewRequest(http.MethodPost, "/api/candidate/referral_request/create", strings.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := create("https://jobs.lever.co/globex/4f8e0c6a-0d1b-4a55-9f33-2c1e5b7a9d10"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a link to another company's board to get status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	if rr := create("https://careers.globex.example/jobs/1"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a link off the company's domains to get status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+
+	rr := create("https://jobs.lever.co/acme/4F8E0C6A-0D1B-4A55-9F33-2C1E5B7A9D10/apply?lever-source=LinkedIn&utm_medium=social")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var request api_objects.CandidateViewReferralRequest
+	if err := json.Unmarshal(rr.Body.Bytes(), &request); err != nil {
+		t.Fatalf("failed to decode referral request: %v", err)
+	}
+	want := api_objects.GeneralViewJobLink{Link: "https://jobs.lever.co/acme/4f8e0c6a-0d1b-4a55-9f33-2c1e5b7a9d10", ATS: "lever", ATSJobId: "4f8e0c6a-0d1b-4a55-9f33-2c1e5b7a9d10"}
+	if len(request.JobLinkDetails) != 1 || request.JobLinkDetails[0] != want || len(request.JobLinks) != 1 || request.JobLinks[0] != want.Link {
+		t.Errorf("expected the canonical link %+v, got %v and %+v", want, request.JobLinks, request.JobLinkDetails)
+	}
+
+	// The same posting without the /apply suffix is a duplicate
+	if rr := create("https://jobs.lever.co/acme/4f8e0c6a-0d1b-4a55-9f33-2c1e5b7a9d10"); rr.Code != http.StatusConflict {
+		t.Errorf("expected status %d got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+}
+
+func TestCandidateUpdateReferralRequest_KeepsStatus(t *testing.T) {
+	token := "candidate-tok"
+	hs := setupAdminTestServer(t, token, false)
+	company, err := hs.dbDriver.CreateCompany(&database.Company{Name: "Acme Corp", AddedByUserId: 1})
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	candidate, err := hs.dbDriver.CreateCandidate(&database.Candidate{UserId: 1, ResumeUrl: "https://example.com/resume.pdf"})
+	if err != nil {
+		t.Fatalf("failed to create candidate: %v", err)
+	}
+	request, err := hs.dbDriver.CreateReferralRequest(&database.ReferralRequest{CandidateID: candidate.CandidateId, CompanyID: company.Id,
+		PrimaryJobTitleSeeking: "Engineer", ReferralType: database.FullTime, Status: database.ReferralSubmissionRejected})
+	if err != nil {
+		t.Fatalf("failed to create referral request: %v", err)
+	}
+
+	// Reopening a rejected request would skip the rejection cooldown
+	body := fmt.Sprintf(`{"id": %d, "company_id": %d, "job_title": "Senior Engineer", "referral_type": "Full-Time", "status": "Referral Requested"}`,
+		request.ReferralRequestId, company.Id)
+	req := httptest.NewRequest(http.MethodPut, "/api/candidate/referral_request/update", strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	updated := hs.dbDriver.GetReferralRequestById(request.ReferralRequestId)
+	if updated.PrimaryJobTitleSeeking != "Senior Engineer" {
+		t.Errorf("expected the job title to be updated, got %q", updated.PrimaryJobTitleSeeking)
+	}
+	if updated.Status != database.ReferralSubmissionRejected {
+		t.Errorf("expected the status to stay %q, got %q", database.ReferralSubmissionRejected, updated.Status)
+	}
+}
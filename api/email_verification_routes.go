@@ -4,15 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/Suhaibinator/muslim-referrals-backend/service"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/mail"
 
 	"github.com/gorilla/mux"
 )
 
 type EmailVerificationRequestPayload struct {
-	Email string `json:"email"`
+	Email string `json:"email" validate:"required,email"`
 }
 
 // EmailVerificationRequestHandler handles the creation of a new email verification request.
@@ -20,31 +19,19 @@ type EmailVerificationRequestPayload struct {
 func (hs *HttpServer) EmailVerificationRequestHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := hs.GetUserIDFromContext(r)
 	if err != nil {
-		log.Printf("Error getting user ID from context: %v", err)
+		slog.WarnContext(r.Context(), "Error getting user ID from context", "error", err)
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	var payload EmailVerificationRequestPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		log.Printf("Error decoding request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &payload) {
 		return
 	}
 
-	if payload.Email == "" {
-		http.Error(w, "Email is required", http.StatusBadRequest)
-		return
-	}
-
-	if _, err := mail.ParseAddress(payload.Email); err != nil {
-		http.Error(w, "Invalid email format", http.StatusBadRequest)
-		return
-	}
-
-	err = hs.service.RequestEmailVerification(userID, payload.Email)
+	err = hs.service.RequestEmailVerification(r.Context(), userID, payload.Email)
 	if err != nil {
-		log.Printf("Error requesting email verification for user %d, email %s: %v", userID, payload.Email, err)
+		slog.ErrorContext(r.Context(), "Error requesting email verification", "email", payload.Email, "error", err)
 		// Don't expose internal error details directly
 		http.Error(w, "Failed to process verification request", http.StatusInternalServerError)
 		return
@@ -64,10 +51,10 @@ func (hs *HttpServer) EmailVerificationVerifyHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	err := hs.service.VerifyEmail(verificationCode)
+	err := hs.service.VerifyEmail(r.Context(), verificationCode)
 
 	if err != nil {
-		log.Printf("Error verifying email with code %s: %v", verificationCode, err)
+		slog.WarnContext(r.Context(), "Error verifying email", "verification_code", verificationCode, "error", err)
 		switch {
 		case errors.Is(err, service.ErrVerificationNotFound):
 			http.Error(w, "Verification code not found", http.StatusNotFound) // 404
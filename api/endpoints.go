@@ -1,88 +1,187 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
 	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/logging"
 	"github.com/Suhaibinator/muslim-referrals-backend/service"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 type HttpServer struct {
-	Router   *mux.Router
-	dbDriver *database.DbDriver
-	service  *service.Service
+	Router      *mux.Router
+	server      *http.Server
+	config      *config.Config
+	dbDriver    *database.DbDriver
+	service     *service.Service
+	rateLimiter *RateLimiter
 }
 
-// CORS middleware function
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		// If it's an OPTIONS request, return 200
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+// corsMiddleware returns the CORS middleware for the configured origins. A "*" entry allows
+// any origin; otherwise the request's Origin is echoed back only if it is in the list, and
+// credentials (the auth cookie) are allowed.
+func corsMiddleware(allowedOrigins []string) mux.MiddlewareFunc {
+	allowAny := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAny = true
 		}
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
 
-		// Call the next handler
-		next.ServeHTTP(w, r)
-	})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Set CORS headers
+			origin := r.Header.Get("Origin")
+			if allowAny {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else if origin != "" && allowed[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+			w.Header().Set("Access-Control-Expose-Headers", "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After, X-Request-ID")
+
+			// If it's an OPTIONS request, return 200
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			// Call the next handler
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
-func NewHttpServer(service *service.Service, dbd *database.DbDriver) *HttpServer {
+func NewHttpServer(cfg *config.Config, service *service.Service, dbd *database.DbDriver) *HttpServer {
 	router := mux.NewRouter() // Create a new mux Router
 
-	router.Use(corsMiddleware) // Use the CORS middleware
+	router.Use(requestIDMiddleware)
+	router.Use(corsMiddleware(cfg.Server.CORSOrigins)) // Use the CORS middleware
+	router.Use(metricsMiddleware)
+	router.Use(loggingMiddleware)
 
 	httpServer := &HttpServer{
-		Router:   router,
-		dbDriver: dbd,
-		service:  service,
+		Router:      router,
+		config:      cfg,
+		dbDriver:    dbd,
+		service:     service,
+		rateLimiter: NewRateLimiter(NewMemoryRateLimitStore(), cfg.Server.TrustedProxies, service.CachedUserIDForToken),
 	}
 
 	httpServer.SetupRoutes() // Setup routes with handlers that have access to the DbDriver
 
+	httpServer.server = &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Server.Port),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
 	return httpServer
 }
 
-// Logging middleware function
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware gives every request an ID, reusing the caller's X-Request-ID when it
+// looks sane so that IDs can be correlated with upstream proxies. The ID is echoed in the
+// response and attached to every log record made with the request's context.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+	for _, c := range requestID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// loggingMiddleware logs one line per completed request, naming its route template rather than
+// the path so that codes in links stay out of the logs.
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		log.Printf("Started %s %s | %s", r.Method, r.URL.Path, r.Header.Get("x-forwarded-for"))
-		next.ServeHTTP(w, r)
-		log.Printf("Completed [%s] %s in %v", r.Method, r.URL.Path, time.Since(start))
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		slog.InfoContext(r.Context(), "Request completed",
+			"method", r.Method,
+			"route", routeTemplate(r),
+			"status", recorder.status,
+			"duration", time.Since(start),
+		)
 	})
 }
 
 func (hs *HttpServer) setupUserRoutes(r *mux.Router) {
-	r.Use(loggingMiddleware)
-
 	r.HandleFunc("/user/update", hs.UserUpdateUserHandler).Methods("PUT")
 
 	// For all these requests, we have access to the user_id
 	r.HandleFunc("/user", hs.UserGetUserHandler).Methods("GET")
+	r.HandleFunc("/user/export", hs.UserExportDataHandler).Methods("GET")
+	r.HandleFunc("/user/export/{export_id}", hs.UserGetDataExportHandler).Methods("GET")
+	r.HandleFunc("/user", hs.UserDeleteUserHandler).Methods("DELETE")
+	r.HandleFunc("/user/deletion", hs.UserGetAccountDeletionHandler).Methods("GET")
+	r.HandleFunc("/user/deletion", hs.UserCancelAccountDeletionHandler).Methods("DELETE")
 	r.HandleFunc("/user/company/create", hs.UserCreateCompanyHandler).Methods("POST")
 	r.HandleFunc("/user/company/get/all", hs.UserGetAllCompaniesHandler).Methods("GET")
+	r.HandleFunc("/user/company/suggestions", hs.UserGetCompanySuggestionsHandler).Methods("GET")
 	r.HandleFunc("/user/company/get/{company_id}", hs.UserGetCompanyHandler).Methods("GET")
+	r.HandleFunc("/user/company/get/{company_id}/referrers", hs.UserGetCompanyReferrersHandler).Methods("GET")
 
 	// Get my own data
 	r.HandleFunc("/user/referrer/create", hs.UserCreateReferrerHandler).Methods("POST")
 	r.HandleFunc("/user/referrer/update", hs.UserUpdateReferrerHandler).Methods("PUT")
 	r.HandleFunc("/user/referrer/get", hs.UserGetReferrerHandler).Methods("GET")
 	r.HandleFunc("/user/referrer/delete", hs.UserDeleteReferrerHandler).Methods("DELETE")
+	r.HandleFunc("/user/referrer/availability", hs.UserSetReferrerAvailabilityHandler).Methods("PUT")
 
 	r.HandleFunc("/user/candidate/create", hs.UserCreateCandidateHandler).Methods("POST")
 	r.HandleFunc("/user/candidate/update", hs.UserUpdateCandidateHandler).Methods("PUT")
 	r.HandleFunc("/user/candidate/get", hs.UserGetCandidateHandler).Methods("GET")
 	r.HandleFunc("/user/candidate/delete", hs.UserDeleteCandidateHandler).Methods("DELETE")
+
+	// The rest of the candidate profile
+	r.HandleFunc("/user/candidate/profile", hs.UserGetCandidateProfileHandler).Methods("GET")
+	r.HandleFunc("/user/candidate/skills", hs.UserSetCandidateSkillsHandler).Methods("PUT")
+	r.HandleFunc("/user/candidate/education", hs.UserCreateCandidateEducationHandler).Methods("POST")
+	r.HandleFunc("/user/candidate/education/{education_id}", hs.UserUpdateCandidateEducationHandler).Methods("PUT")
+	r.HandleFunc("/user/candidate/education/{education_id}", hs.UserDeleteCandidateEducationHandler).Methods("DELETE")
+	r.HandleFunc("/user/candidate/positions", hs.UserCreateCandidatePositionHandler).Methods("POST")
+	r.HandleFunc("/user/candidate/positions/{position_id}", hs.UserUpdateCandidatePositionHandler).Methods("PUT")
+	r.HandleFunc("/user/candidate/positions/{position_id}", hs.UserDeleteCandidatePositionHandler).Methods("DELETE")
+	r.HandleFunc("/user/candidate/desired_roles", hs.UserSetCandidateDesiredRolesHandler).Methods("PUT")
+	r.HandleFunc("/user/candidate/work_authorizations", hs.UserSetCandidateWorkAuthorizationsHandler).Methods("PUT")
+	r.HandleFunc("/user/candidate/preferences", hs.UserSetCandidatePreferencesHandler).Methods("PUT")
+	r.HandleFunc("/skills", hs.UserSearchSkillsHandler).Methods("GET")
 }
 
 func (hs *HttpServer) setupReferrerRoutes(r *mux.Router) {
@@ -90,11 +189,19 @@ func (hs *HttpServer) setupReferrerRoutes(r *mux.Router) {
 	// For all these requests, we have access to the referrer_id
 	r.HandleFunc("/referrer/referral_requests/all", hs.ReferrerGetAllReferralRequestsHandler).Methods("GET")
 	r.HandleFunc("/referrer/referral_requests/company/{company_id}", hs.ReferrerGetReferralRequestsByCompanyHandler).Methods("GET")
+	// Before {request_id}, which would match it too
+	r.HandleFunc("/referrer/referral_requests/recommended", hs.ReferrerGetRecommendedReferralRequestsHandler).Methods("GET")
 	r.HandleFunc("/referrer/referral_requests/{request_id}", hs.ReferrerGetReferralRequestHandler).Methods("GET")
 
+	r.HandleFunc("/referrer/refer/{referral_request_id}", hs.ReferrerClaimReferralRequestHandler).Methods("POST")
+
+	r.HandleFunc("/referrer/job_postings", hs.ReferrerGetJobPostingsHandler).Methods("GET")
+	r.HandleFunc("/referrer/job_postings", hs.ReferrerCreateJobPostingHandler).Methods("POST")
+	r.HandleFunc("/referrer/job_postings/{job_posting_id}", hs.ReferrerUpdateJobPostingHandler).Methods("PUT")
+	r.HandleFunc("/referrer/job_postings/{job_posting_id}", hs.ReferrerDeleteJobPostingHandler).Methods("DELETE")
+
 	// TODO: Implement this, discuss with PM
 	// r.HandleFunc("/referrer/refer/{referral_request_id}", hs.ReferrerDeleteReferral).Methods("DELETE")
-	// r.HandleFunc("/referrer/refer/{referral_request_id}", hs.ReferrerCreateReferral).Methods("POST")
 }
 
 func (hs *HttpServer) setupCandidateRoutes(r *mux.Router) {
@@ -106,30 +213,54 @@ func (hs *HttpServer) setupCandidateRoutes(r *mux.Router) {
 
 	r.HandleFunc("/candidate/referral_request/get/all", hs.CandidateGetAllReferralRequestsHandler).Methods("GET")
 	r.HandleFunc("/candidate/referral_request/get/{referral_request_id}", hs.CandidateGetReferralRequestHandler).Methods("GET")
+
+	// Openings posted by referrers; applying creates a referral request assigned to the referrer
+	r.HandleFunc("/candidate/job_postings", hs.CandidateSearchJobPostingsHandler).Methods("GET")
+	r.HandleFunc("/candidate/job_postings/{job_posting_id}", hs.CandidateGetJobPostingHandler).Methods("GET")
+	r.HandleFunc("/candidate/job_postings/{job_posting_id}/apply", hs.CandidateApplyForJobPostingHandler).Methods("POST")
 }
 
 func (hs *HttpServer) setupLoginRoutes(r *mux.Router) {
-	r.HandleFunc("/login", hs.LoginHandler).Methods("GET")
+	r.Handle("/login", hs.rateLimiter.Limit(newRateLimitPolicy("login", hs.config.RateLimits.Login))(http.HandlerFunc(hs.LoginHandler))).Methods("GET")
 }
 
 func (hs *HttpServer) setupEmailVerificationRoutes(r *mux.Router) {
 	// POST requires auth (handled inside handler), GET does not
-	r.HandleFunc("/email-verification", hs.EmailVerificationRequestHandler).Methods("POST")
-	r.HandleFunc("/email-verification/verify/{verification_code}", hs.EmailVerificationVerifyHandler).Methods("GET")
+	r.Handle("/email-verification",
+		hs.rateLimiter.Limit(newRateLimitPolicy("email-verification", hs.config.RateLimits.EmailVerification))(http.HandlerFunc(hs.EmailVerificationRequestHandler))).Methods("POST")
+	r.Handle("/email-verification/verify/{verification_code}",
+		hs.rateLimiter.Limit(newRateLimitPolicy("email-verification-verify", hs.config.RateLimits.VerifyEmail))(http.HandlerFunc(hs.EmailVerificationVerifyHandler))).Methods("GET")
+}
+
+func (hs *HttpServer) setupAccountDeletionConfirmRoutes(r *mux.Router) {
+	// Opened from the confirmation email, so it needs no auth
+	r.Handle("/user/deletion/confirm/{confirmation_code}",
+		hs.rateLimiter.Limit(newRateLimitPolicy("account-deletion-confirm", hs.config.RateLimits.AccountDeletion))(http.HandlerFunc(hs.AccountDeletionConfirmHandler))).Methods("GET")
 }
 
 func (hs *HttpServer) SetupRoutes() {
 
+	// API routes with a rate limit of their own, matched first so they aren't also charged to
+	// the limit shared by the rest of the API
+	limitedApiRouter := hs.Router.PathPrefix("/api").Subrouter()
+	hs.setupEmailVerificationRoutes(limitedApiRouter)
+	hs.setupAccountDeletionConfirmRoutes(limitedApiRouter)
+
 	// Set up your API routes
 	apiRouter := hs.Router.PathPrefix("/api").Subrouter()
+	apiRouter.Use(hs.rateLimiter.Limit(newRateLimitPolicy("api", hs.config.RateLimits.API)))
 	hs.setupUserRoutes(apiRouter)
 	hs.setupCandidateRoutes(apiRouter)
 	hs.setupReferrerRoutes(apiRouter)
-	hs.setupEmailVerificationRoutes(apiRouter) // Add email verification routes
+	hs.setupAdminRoutes(apiRouter)
 
 	// Set up the login route
 	hs.setupLoginRoutes(hs.Router)
 
+	// Health checks and metrics sit outside /api so they need no user auth and aren't rate limited
+	hs.setupHealthRoutes(hs.Router)
+	hs.setupMetricsRoutes(hs.Router)
+
 	// Serve static files from ./frontend_build for all other routes
 	staticFileDirectory := http.Dir("./frontend_build")
 	staticFileHandler := http.FileServer(staticFileDirectory)
@@ -142,19 +273,44 @@ func (hs *HttpServer) GetUserIDFromContext(r *http.Request) (uint64, error) {
 	// Get the user_id from the context
 	authToken, err := r.Cookie("auth")
 	if err != nil || authToken == nil {
-		log.Printf("[GetUserIDFromContext] Error getting auth token: %v\n", err)
+		slog.DebugContext(r.Context(), "No auth token on request", "error", err)
 		return 0, err
 	}
 	authTokenValue := authToken.Value
 	userId, _, err := hs.service.GetUserIdFromTokenDigest(r.Context(), authTokenValue)
+	if err == nil {
+		logging.SetUserID(r.Context(), userId)
+	}
 	return userId, err
 }
 
-func (hs *HttpServer) StartServer(port string) {
-	// Start the server on port
-	log.Printf("Starting server on port %s\n", port)
-	err := http.ListenAndServe(fmt.Sprintf(":%s", port), hs.Router)
+// StartServer listens on the configured port and serves requests until Shutdown is called.
+// It returns nil after a graceful shutdown and the listener error otherwise.
+func (hs *HttpServer) StartServer() error {
+	listener, err := net.Listen("tcp", hs.server.Addr)
 	if err != nil {
-		log.Fatalf("Failed to start server: %v\n", err)
+		return fmt.Errorf("failed to listen on %s: %w", hs.server.Addr, err)
+	}
+	return hs.Serve(listener)
+}
+
+// Serve serves requests on an existing listener until Shutdown is called.
+func (hs *HttpServer) Serve(listener net.Listener) error {
+	slog.Info("Starting server", "address", listener.Addr().String())
+	err := hs.server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting new connections and waits for in-flight requests to finish,
+// or for ctx to expire, whichever comes first.
+func (hs *HttpServer) Shutdown(ctx context.Context) error {
+	slog.Info("Shutting down HTTP server, waiting for in-flight requests")
+	if err := hs.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
 	}
+	slog.Info("HTTP server stopped")
+	return nil
 }
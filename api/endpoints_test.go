@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/logging"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+)
+
+func TestShutdown_DrainsInFlightRequests(t *testing.T) {
+	hs := setupTestServer(1, "tok")
+
+	handlerStarted := make(chan struct{})
+	releaseHandler := make(chan struct{})
+	hs.server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(handlerStarted)
+		<-releaseHandler
+		w.Write([]byte("done"))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- hs.Serve(listener) }()
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+	<-handlerStarted
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownDone <- hs.Shutdown(ctx)
+	}()
+
+	select {
+	case <-shutdownDone:
+		t.Fatal("Shutdown returned before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(releaseHandler)
+
+	if err := <-shutdownDone; err != nil {
+		t.Errorf("Shutdown returned error: %v", err)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("Serve returned error after graceful shutdown: %v", err)
+	}
+	resp := <-responses
+	if resp.err != nil || resp.body != "done" {
+		t.Errorf("expected in-flight request to complete, got body %q err %v", resp.body, resp.err)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	hs := setupTestServer(1, "tok")
+
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Header().Get("X-Request-ID") == "" {
+		t.Error("expected a generated X-Request-ID header")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "lb-abc123")
+	rr = httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+	if got := rr.Header().Get("X-Request-ID"); got != "lb-abc123" {
+		t.Errorf("expected the caller's request ID to be echoed, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+	rr = httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+	if got := rr.Header().Get("X-Request-ID"); got == "" || got == "bad id\nwith newline" {
+		t.Errorf("expected an invalid request ID to be replaced, got %q", got)
+	}
+}
+
+func TestLogging_LeavesCodesOutOfLogs(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.NewLogger(&logs, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	cfg := config.Default()
+	cfg.Database.Path = ":memory:"
+	cfg.RateLimits.VerifyEmail = config.RateLimitConfig{Limit: 1, Window: time.Minute}
+	db := database.NewDbDriver(cfg.Database)
+	hs := NewHttpServer(cfg, service.NewService(cfg, service.NewDatabaseOperations(db), nil), db)
+
+	// The second request is over the limit, so the rate limiter logs it too
+	for range 2 {
+		hs.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/email-verification/verify/secret-code-123", nil))
+	}
+
+	if strings.Contains(logs.String(), "secret-code-123") {
+		t.Errorf("expected the verification code to stay out of the logs, got:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "/api/email-verification/verify/{verification_code}") {
+		t.Errorf("expected the route template in the logs, got:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "Rate limit exceeded") {
+		t.Errorf("expected the rate limiter to log the second request, got:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/gorilla/mux"
+)
+
+// exportRetryAfter is how long clients are told to wait before polling a pending export again.
+const exportRetryAfter = 5 * time.Second
+
+// exportStatusView is the body of a 202 response for an export still being built.
+type exportStatusView struct {
+	Id        string    `json:"id"`
+	Status    string    `json:"status"`
+	Format    string    `json:"format"`
+	StartedAt time.Time `json:"startedAt"`
+	Url       string    `json:"url"` // Where to download the export once it is ready
+}
+
+// UserExportDataHandler exports everything stored about the user as a JSON file or, with
+// ?format=zip, a ZIP archive. Exports that aren't ready within export.sync_wait are finished
+// in the background: the response is then 202 with the URL to fetch them from.
+func (hs *HttpServer) UserExportDataHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserExportDataHandler")
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	format, err := service.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := hs.service.RequestUserDataExport(r.Context(), userID, format)
+	if err != nil {
+		writeExportError(w, r, err)
+		return
+	}
+	writeExportStatus(w, status)
+}
+
+// UserGetDataExportHandler downloads an export started by UserExportDataHandler, responding
+// 202 while it is still being built.
+func (hs *HttpServer) UserGetDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserGetDataExportHandler")
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	status, err := hs.service.GetUserDataExport(r.Context(), userID, mux.Vars(r)["export_id"])
+	if err != nil {
+		writeExportError(w, r, err)
+		return
+	}
+	writeExportStatus(w, status)
+}
+
+// writeExportStatus sends the archive if the export is ready and a 202 pointing at it otherwise.
+func writeExportStatus(w http.ResponseWriter, status *service.UserDataExportStatus) {
+	url := "/api/user/export/" + status.ID
+	if status.Archive == nil {
+		response, marshalErr := json.Marshal(exportStatusView{
+			Id:        status.ID,
+			Status:    "pending",
+			Format:    string(status.Format),
+			StartedAt: status.StartedAt,
+			Url:       url,
+		})
+		if marshalErr != nil {
+			http.Error(w, marshalErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", url)
+		w.Header().Set("Retry-After", strconv.Itoa(int(exportRetryAfter.Seconds())))
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(response)
+		return
+	}
+
+	w.Header().Set("Content-Type", status.Archive.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": status.Archive.Filename}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(status.Archive.Data)))
+	// Personal data mustn't be kept by shared caches
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(status.Archive.Data)
+}
+
+func writeExportError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrExportNotFound):
+		http.Error(w, "Export not found or expired", http.StatusNotFound)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	default:
+		slog.ErrorContext(r.Context(), "Error exporting user data", "error", err)
+		http.Error(w, "Failed to export user data", http.StatusInternalServerError)
+	}
+}
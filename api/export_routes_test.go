@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserExportDataHandler_JSON(t *testing.T) {
+	token := "export-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/export", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if disposition := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment") {
+		t.Errorf("expected the export to be an attachment, got %q", disposition)
+	}
+	var export struct {
+		User struct {
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &export); err != nil {
+		t.Fatalf("failed to decode export: %v", err)
+	}
+	if export.User.Email != "admin@example.com" {
+		t.Errorf("expected the signed-in user's export, got %q", export.User.Email)
+	}
+}
+
+func TestUserExportDataHandler_InvalidFormat(t *testing.T) {
+	token := "export-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/export?format=pdf", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestUserGetDataExportHandler_NotFound(t *testing.T) {
+	token := "export-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/export/unknown", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d got %d", http.StatusNotFound, rr.Code)
+	}
+}
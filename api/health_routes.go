@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/buildinfo"
+	"github.com/Suhaibinator/muslim-referrals-backend/migrations"
+
+	"github.com/gorilla/mux"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+type ReadinessCheck struct {
+	Status string `json:"status"` // "ok" or "failed"
+	Detail string `json:"detail,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                    `json:"status"` // "ready" or "not ready"
+	Checks map[string]ReadinessCheck `json:"checks"`
+}
+
+type VersionResponse struct {
+	buildinfo.Info
+	SchemaVersion string `json:"schema_version"`
+}
+
+func (hs *HttpServer) setupHealthRoutes(r *mux.Router) {
+	r.HandleFunc("/healthz", hs.HealthzHandler).Methods("GET")
+	r.HandleFunc("/readyz", hs.ReadyzHandler).Methods("GET")
+	r.HandleFunc("/version", hs.VersionHandler).Methods("GET")
+}
+
+// HealthzHandler reports that the process is up and serving requests.
+// GET /healthz
+func (hs *HttpServer) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// ReadyzHandler reports whether the server can handle traffic: the database is reachable,
+// its schema is at the migration version this build expects, and email sending is configured.
+// GET /readyz
+func (hs *HttpServer) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+	defer cancel()
+
+	checks := map[string]ReadinessCheck{
+		"database":   hs.checkDatabase(ctx),
+		"migrations": hs.checkMigrations(ctx),
+		"email":      hs.checkEmail(),
+	}
+
+	response := ReadinessResponse{Status: "ready", Checks: checks}
+	statusCode := http.StatusOK
+	for name, check := range checks {
+		if check.Status != "ok" {
+			slog.WarnContext(r.Context(), "Readiness check failed", "check", name, "detail", check.Detail)
+			response.Status = "not ready"
+			statusCode = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (hs *HttpServer) checkDatabase(ctx context.Context) ReadinessCheck {
+	if err := hs.dbDriver.Ping(ctx); err != nil {
+		return ReadinessCheck{Status: "failed", Detail: err.Error()}
+	}
+	return ReadinessCheck{Status: "ok"}
+}
+
+func (hs *HttpServer) checkMigrations(ctx context.Context) ReadinessCheck {
+	expected := migrations.LatestVersion(hs.dbDriver.Dialect())
+	current, err := hs.dbDriver.SchemaVersion(ctx)
+	if err != nil {
+		return ReadinessCheck{Status: "failed", Detail: err.Error()}
+	}
+	if current == "" {
+		// The server records a baseline for declaratively applied schemas when it starts
+		return ReadinessCheck{Status: "failed", Detail: "no migrations have been recorded, expected " + expected}
+	}
+	if current != expected {
+		return ReadinessCheck{Status: "failed", Detail: "database is at revision " + current + ", expected " + expected}
+	}
+	return ReadinessCheck{Status: "ok", Detail: "revision " + current}
+}
+
+func (hs *HttpServer) checkEmail() ReadinessCheck {
+	if !hs.service.EmailSendingEnabled() {
+		return ReadinessCheck{Status: "failed", Detail: "no email transport configured"}
+	}
+	return ReadinessCheck{Status: "ok"}
+}
+
+// VersionHandler reports the build that is running and the schema version it expects.
+// GET /version
+func (hs *HttpServer) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VersionResponse{
+		Info:          buildinfo.Get(),
+		SchemaVersion: migrations.LatestVersion(hs.dbDriver.Dialect()),
+	})
+}
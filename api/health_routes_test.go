@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/migrations"
+)
+
+func TestHealthzHandler(t *testing.T) {
+	hs := setupTestServer(1, "tok")
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestReadyzHandler_EmailNotConfigured(t *testing.T) {
+	hs := setupTestServer(1, "tok")
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	var resp ReadinessResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Checks["database"].Status != "ok" {
+		t.Errorf("expected database check ok, got %+v", resp.Checks["database"])
+	}
+	if resp.Checks["migrations"].Status != "ok" {
+		t.Errorf("expected migrations check ok, got %+v", resp.Checks["migrations"])
+	}
+	if resp.Checks["email"].Status != "failed" {
+		t.Errorf("expected email check failed, got %+v", resp.Checks["email"])
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	hs := setupTestServer(1, "tok")
+
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rr.Code)
+	}
+	var resp VersionResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.SchemaVersion == "" || resp.SchemaVersion != migrations.LatestVersion(hs.dbDriver.Dialect()) {
+		t.Errorf("expected schema version of the newest migration, got %q", resp.SchemaVersion)
+	}
+	if resp.GitSHA == "" || resp.GoVersion == "" {
+		t.Errorf("expected build info to be filled in, got %+v", resp)
+	}
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultJobPostingLimit = 50
+	maxJobPostingLimit     = 200
+)
+
+// ReferrerGetJobPostingsHandler lists the referrer's job postings, expired ones included,
+// newest first.
+// GET /api/referrer/job_postings
+func (hs *HttpServer) ReferrerGetJobPostingsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called ReferrerGetJobPostingsHandler")
+
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	referrer := hs.dbDriver.GetReferrerByUserId(userID)
+	if referrer == nil || referrer.ReferrerId == 0 {
+		http.Error(w, "Referrer not found or unauthorized", http.StatusForbidden)
+		return
+	}
+
+	postings := hs.dbDriver.GetJobPostingsByReferrerId(referrer.ReferrerId)
+	result := make([]api_objects.ReferrerViewJobPosting, 0, len(postings))
+	for i := range postings {
+		result = append(result, *api_objects.ConvertDbJobPostingToReferrerViewJobPosting(&postings[i]))
+	}
+	writeJSON(w, result)
+}
+
+// ReferrerCreateJobPostingHandler posts a job opening at the referrer's company. The referrer
+// must have verified their corporate email.
+// POST /api/referrer/job_postings
+func (hs *HttpServer) ReferrerCreateJobPostingHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called ReferrerCreateJobPostingHandler")
+
+	var request api_objects.ReferrerViewJobPosting
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	posting := api_objects.ConvertReferrerViewJobPostingToDbJobPosting(request)
+	created, err := hs.service.CreateJobPosting(r.Context(), userID, &posting)
+	if err != nil {
+		writeJobPostingError(w, r, err)
+		return
+	}
+	writeJSON(w, api_objects.ConvertDbJobPostingToReferrerViewJobPosting(created))
+}
+
+// ReferrerUpdateJobPostingHandler updates one of the referrer's job postings.
+// PUT /api/referrer/job_postings/{job_posting_id}
+func (hs *HttpServer) ReferrerUpdateJobPostingHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called ReferrerUpdateJobPostingHandler")
+
+	jobPostingID, err := parseUint64FromString(mux.Vars(r)["job_posting_id"])
+	if err != nil {
+		http.Error(w, "Invalid job posting ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var request api_objects.ReferrerViewJobPosting
+	if !decodeAndValidate(w, r, &request) {
+		return
+	}
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	posting := api_objects.ConvertReferrerViewJobPostingToDbJobPosting(request)
+	posting.JobPostingId = jobPostingID
+	updated, err := hs.service.UpdateJobPosting(r.Context(), userID, &posting)
+	if err != nil {
+		writeJobPostingError(w, r, err)
+		return
+	}
+	writeJSON(w, api_objects.ConvertDbJobPostingToReferrerViewJobPosting(updated))
+}
+
+// ReferrerDeleteJobPostingHandler takes down one of the referrer's job postings. Referral
+// requests candidates made through it are kept.
+// DELETE /api/referrer/job_postings/{job_posting_id}
+func (hs *HttpServer) ReferrerDeleteJobPostingHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called ReferrerDeleteJobPostingHandler")
+
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	jobPostingID, err := parseUint64FromString(mux.Vars(r)["job_posting_id"])
+	if err != nil {
+		http.Error(w, "Invalid job posting ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	referrer := hs.dbDriver.GetReferrerByUserId(userID)
+	if referrer == nil || referrer.ReferrerId == 0 {
+		http.Error(w, "Referrer not found or unauthorized", http.StatusForbidden)
+		return
+	}
+
+	if err := hs.dbDriver.DeleteJobPosting(referrer.ReferrerId, jobPostingID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Job posting not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error deleting job posting", "job_posting_id", jobPostingID, "error", err)
+		http.Error(w, "Failed to delete job posting", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CandidateSearchJobPostingsHandler lists the job postings candidates can apply to, newest
+// first, optionally filtered by company, referral type, location and title.
+// GET /api/candidate/job_postings?company_id=&referral_type=&location=&q=&limit=
+func (hs *HttpServer) CandidateSearchJobPostingsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called CandidateSearchJobPostingsHandler")
+
+	if _, err := hs.GetUserIDFromContext(r); err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := database.JobPostingFilter{
+		ReferralType: database.ReferralType(query.Get("referral_type")),
+		Location:     query.Get("location"),
+		Query:        query.Get("q"),
+		Limit:        defaultJobPostingLimit,
+	}
+	if raw := query.Get("company_id"); raw != "" {
+		companyID, err := parseUint64FromString(raw)
+		if err != nil {
+			http.Error(w, "Invalid company ID: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.CompanyId = companyID
+	}
+	switch filter.ReferralType {
+	case "", database.Internship, database.FullTime, database.PartTime, database.Contract:
+	default:
+		http.Error(w, "Invalid referral_type", http.StatusBadRequest)
+		return
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxJobPostingLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	now := time.Now()
+	postings, err := hs.dbDriver.SearchJobPostings(filter, now)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error searching job postings", "error", err)
+		http.Error(w, "Failed to list job postings", http.StatusInternalServerError)
+		return
+	}
+	result := make([]api_objects.CandidateViewJobPosting, 0, len(postings))
+	for i := range postings {
+		result = append(result, api_objects.ConvertDbJobPostingToCandidateViewJobPosting(&postings[i], now))
+	}
+	writeJSON(w, result)
+}
+
+// CandidateGetJobPostingHandler returns a job posting candidates can still apply to.
+// GET /api/candidate/job_postings/{job_posting_id}
+func (hs *HttpServer) CandidateGetJobPostingHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called CandidateGetJobPostingHandler")
+
+	if _, err := hs.GetUserIDFromContext(r); err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	jobPostingID, err := parseUint64FromString(mux.Vars(r)["job_posting_id"])
+	if err != nil {
+		http.Error(w, "Invalid job posting ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	posting := hs.dbDriver.GetJobPostingById(jobPostingID)
+	if posting == nil || !posting.IsOpen(now) || posting.Referrer.ReferrerId == 0 {
+		http.Error(w, "Job posting not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, api_objects.ConvertDbJobPostingToCandidateViewJobPosting(posting, now))
+}
+
+// CandidateApplyForJobPostingHandler applies for a referral to a posted job. It creates a
+// referral request for the job, already assigned to the referrer who posted it.
+// POST /api/candidate/job_postings/{job_posting_id}/apply
+func (hs *HttpServer) CandidateApplyForJobPostingHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called CandidateApplyForJobPostingHandler")
+
+	jobPostingID, err := parseUint64FromString(mux.Vars(r)["job_posting_id"])
+	if err != nil {
+		http.Error(w, "Invalid job posting ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var application api_objects.CandidateViewJobPostingApplication
+	if !decodeAndValidate(w, r, &application) {
+		return
+	}
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	candidate := hs.dbDriver.GetCandidateByUserId(userID)
+	if candidate == nil || candidate.UserId != userID {
+		http.Error(w, "Candidate not found or unauthorized", http.StatusForbidden)
+		return
+	}
+
+	request := database.ReferralRequest{Summary: application.Summary}
+	if application.Visibility != "" {
+		visibility := database.CandidateVisibility(application.Visibility)
+		request.Visibility = &visibility
+	}
+	created, err := hs.service.ApplyForJobPosting(r.Context(), userID, candidate.CandidateId, jobPostingID, &request)
+	if err != nil {
+		writeJobPostingError(w, r, err)
+		return
+	}
+	writeJSON(w, api_objects.ConvertDbReferralRequestToCandidateViewReferralRequest(created))
+}
+
+// writeJobPostingError maps errors from the job posting service methods to HTTP responses.
+// Applying can also fail the referral request rules, which writeReferralRequestError handles.
+func writeJobPostingError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrReferrerNotFound):
+		http.Error(w, "Referrer not found or unauthorized", http.StatusForbidden) // 403
+	case errors.Is(err, service.ErrReferrerNotVerified), errors.Is(err, service.ErrApplyForOwnJobPosting):
+		http.Error(w, err.Error(), http.StatusForbidden) // 403
+	case errors.Is(err, service.ErrInvalidJobPostingExpiry):
+		http.Error(w, err.Error(), http.StatusBadRequest) // 400
+	case errors.Is(err, service.ErrJobPostingNotFound):
+		http.Error(w, "Job posting not found", http.StatusNotFound) // 404
+	case errors.Is(err, service.ErrJobPostingExpired), errors.Is(err, service.ErrJobPostingReferrerAbsent):
+		http.Error(w, err.Error(), http.StatusGone) // 410
+	default:
+		writeReferralRequestError(w, r, err)
+	}
+}
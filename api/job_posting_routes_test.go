@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+func TestJobPostingRoutes(t *testing.T) {
+	referrerToken, candidateToken := "referrer-tok", "candidate-tok"
+	hs := setupAdminTestServer(t, referrerToken, false)
+	company, err := hs.dbDriver.CreateCompany(&database.Company{Name: "Example", AddedByUserId: 1})
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	if _, err := hs.dbDriver.CreateReferrer(&database.Referrer{UserId: 1, CompanyId: company.Id, CorporateEmail: "admin@corp.example"}); err != nil {
+		t.Fatalf("failed to create referrer: %v", err)
+	}
+	candidateUser, err := hs.dbDriver.CreateUser(&database.User{FirstName: "Amina", LastName: "Khan", Email: "amina@example.com"})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := hs.dbDriver.CreateCandidate(&database.Candidate{UserId: candidateUser.Id, ResumeUrl: "https://example.com/resume.pdf"}); err != nil {
+		t.Fatalf("failed to create candidate: %v", err)
+	}
+	hs.service.SetUserIDForToken(candidateToken, candidateUser.Id)
+	send := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	body := `{"title": "Backend Engineer", "link": "https://jobs.example.com/123", "location": "Toronto", "referral_type": "Full-Time"}`
+	if rr := send(referrerToken, http.MethodPost, "/api/referrer/job_postings", body); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected an unverified referrer to get status %d, got %d: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+	if _, err := hs.dbDriver.CreateEmailVerification(&database.EmailVerification{ID: "v1", Email: "admin@corp.example", UserID: 1,
+		VerificationCode: "code", ExpiresAt: time.Now(), Status: database.EmailVerificationStatusVerified}); err != nil {
+		t.Fatalf("failed to create email verification: %v", err)
+	}
+	rr := send(referrerToken, http.MethodPost, "/api/referrer/job_postings", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var posting api_objects.ReferrerViewJobPosting
+	if err := json.Unmarshal(rr.Body.Bytes(), &posting); err != nil {
+		t.Fatalf("failed to decode job posting: %v", err)
+	}
+	if posting.JobPostingId == 0 || posting.ExpiresAt == nil || posting.ExpiresAt.Before(time.Now().Add(29*24*time.Hour)) {
+		t.Errorf("expected the posting to get the default lifetime, got %+v", posting)
+	}
+
+	rr = send(candidateToken, http.MethodGet, "/api/candidate/job_postings?location=toronto&referral_type=Full-Time", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var postings []api_objects.CandidateViewJobPosting
+	if err := json.Unmarshal(rr.Body.Bytes(), &postings); err != nil {
+		t.Fatalf("failed to decode job postings: %v", err)
+	}
+	if len(postings) != 1 || postings[0].JobPostingId != posting.JobPostingId || postings[0].Title != "Backend Engineer" {
+		t.Errorf("expected the posting to be listed, got %+v", postings)
+	}
+	if strings.Contains(rr.Body.String(), "corp.example") {
+		t.Errorf("expected the listing not to reveal who the referrer is, got %s", rr.Body.String())
+	}
+	if rr := send(candidateToken, http.MethodGet, "/api/candidate/job_postings?limit=0", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// Applying creates a request already assigned to the referrer, which they can then claim
+	path := fmt.Sprintf("/api/candidate/job_postings/%d/apply", posting.JobPostingId)
+	rr = send(candidateToken, http.MethodPost, path, `{"description": "Five years of Go", "visibility": "full"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var request api_objects.CandidateViewReferralRequest
+	if err := json.Unmarshal(rr.Body.Bytes(), &request); err != nil {
+		t.Fatalf("failed to decode referral request: %v", err)
+	}
+	if request.JobPostingId == nil || *request.JobPostingId != posting.JobPostingId || request.PrimaryJobTitleSeeking != "Backend Engineer" {
+		t.Errorf("expected a request for the posted job, got %+v", request)
+	}
+	if rr := send(candidateToken, http.MethodPost, path, `{}`); rr.Code != http.StatusConflict {
+		t.Errorf("expected a second application to get status %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if rr := send(referrerToken, http.MethodPost, path, `{}`); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the referrer without a candidate profile to get status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	if rr := send(referrerToken, http.MethodPost, fmt.Sprintf("/api/referrer/refer/%d", request.ReferralRequestId), ""); rr.Code != http.StatusOK {
+		t.Errorf("expected the referrer to claim the request, got status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	update := fmt.Sprintf(`{"title": "Staff Engineer", "link": "https://jobs.example.com/123", "referral_type": "Full-Time", "expires_at": %q}`, expiresAt)
+	if rr := send(referrerToken, http.MethodPut, fmt.Sprintf("/api/referrer/job_postings/%d", posting.JobPostingId), update); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send(referrerToken, http.MethodPut, fmt.Sprintf("/api/referrer/job_postings/%d", posting.JobPostingId),
+		`{"title": "Staff Engineer", "link": "https://jobs.example.com/123", "referral_type": "Full-Time", "expires_at": "2020-01-01T00:00:00Z"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected an expiry in the past to get status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := send(referrerToken, http.MethodDelete, fmt.Sprintf("/api/referrer/job_postings/%d", posting.JobPostingId), ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := send(candidateToken, http.MethodGet, fmt.Sprintf("/api/candidate/job_postings/%d", posting.JobPostingId), ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := send(referrerToken, http.MethodGet, "/api/referrer/job_postings", ""); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("expected no postings left, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
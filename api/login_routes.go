@@ -2,10 +2,12 @@ package api
 
 import (
 	"github.com/Suhaibinator/muslim-referrals-backend/config"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
 	"net/http"
 
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 )
 
 func (hs *HttpServer) LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -31,6 +33,10 @@ func (hs *HttpServer) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	base64Token := base64.StdEncoding.EncodeToString(tokenBytes)
 	_, newUser, errorRetrievingUserFromToken := hs.service.GetUserIdFromTokenDigest(r.Context(), base64Token)
 
+	if errors.Is(errorRetrievingUserFromToken, service.ErrUserDeleted) {
+		http.Error(w, errorRetrievingUserFromToken.Error(), http.StatusForbidden)
+		return
+	}
 	if errorRetrievingUserFromToken != nil {
 		http.Error(w, "Failed to retrieve user from token: "+errorRetrievingUserFromToken.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/metrics"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware_UsesRouteTemplate(t *testing.T) {
+	token := "tok-metrics"
+	hs := setupTestServer(1, token)
+	route := "/api/user/company/get/{company_id}"
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/company/get/42", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	counter := metrics.HTTPRequestsTotal.WithLabelValues(route, http.MethodGet, strconv.Itoa(rr.Code))
+	if got := testutil.ToFloat64(counter); got < 1 {
+		t.Errorf("expected request to be counted under route template %s, got %v", route, got)
+	}
+	rawPathCount := testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues("/api/user/company/get/42", http.MethodGet, strconv.Itoa(rr.Code)))
+	if rawPathCount != 0 {
+		t.Errorf("expected no series for the raw path, got %v", rawPathCount)
+	}
+}
+
+// setupMetricsTestServer is setupTestServer with a metrics token configured.
+func setupMetricsTestServer(metricsToken string) *HttpServer {
+	cfg := config.Default()
+	cfg.Database.Path = ":memory:"
+	cfg.Server.MetricsToken = metricsToken
+	db := database.NewDbDriver(cfg.Database)
+	return NewHttpServer(cfg, service.NewService(cfg, service.NewDatabaseOperations(db), nil), db)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	hs := setupMetricsTestServer("scrape-tok")
+
+	// Make a request first so the HTTP metrics have at least one series
+	hs.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-tok")
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `muslim_referrals_http_requests_total{code="200",method="GET",route="/healthz"}`) {
+		t.Errorf("expected /healthz request in metrics output")
+	}
+}
+
+func TestMetricsEndpoint_RequiresToken(t *testing.T) {
+	hs := setupMetricsTestServer("scrape-tok")
+	for _, authorization := range []string{"", "Bearer wrong", "scrape-tok"} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: expected status %d got %d", authorization, http.StatusUnauthorized, rr.Code)
+		}
+	}
+
+	// Without a token configured, metrics aren't served at all
+	hs = setupTestServer(1, "tok")
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code == http.StatusOK {
+		t.Errorf("expected /metrics not to be served without a metrics token")
+	}
+}
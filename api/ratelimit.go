@@ -0,0 +1,249 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+)
+
+// RateLimitPolicy describes a token bucket: Limit requests may be made in a burst,
+// and the bucket refills at Limit tokens per Window.
+type RateLimitPolicy struct {
+	Name   string // Namespaces the buckets so routes with different policies don't share tokens
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // Time until the next token is available, only set when not allowed
+}
+
+// RateLimitStore holds the token buckets. The in-memory implementation is enough for a
+// single instance; a shared store (e.g. Redis) can be swapped in behind this interface.
+type RateLimitStore interface {
+	Take(key string, policy RateLimitPolicy, now time.Time) RateLimitResult
+}
+
+func newRateLimitPolicy(name string, limit config.RateLimitConfig) RateLimitPolicy {
+	return RateLimitPolicy{Name: name, Limit: limit.Limit, Window: limit.Window}
+}
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+	window   time.Duration // The policy's, after which an idle bucket is full again
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore. Buckets that have been idle long
+// enough to refill completely are swept periodically, since they carry no state.
+type MemoryRateLimitStore struct {
+	mu            sync.Mutex
+	buckets       map[string]*tokenBucket
+	sweepInterval time.Duration
+	lastSweep     time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:       make(map[string]*tokenBucket),
+		sweepInterval: time.Minute,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, policy RateLimitPolicy, now time.Time) RateLimitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	limit := float64(policy.Limit)
+	refillPerSecond := limit / policy.Window.Seconds()
+
+	bucketKey := policy.Name + "|" + key
+	bucket, ok := s.buckets[bucketKey]
+	if !ok {
+		bucket = &tokenBucket{tokens: limit, lastSeen: now, window: policy.Window}
+		s.buckets[bucketKey] = bucket
+	} else {
+		elapsed := now.Sub(bucket.lastSeen).Seconds()
+		if elapsed > 0 {
+			bucket.tokens = math.Min(limit, bucket.tokens+elapsed*refillPerSecond)
+		}
+		bucket.lastSeen = now
+		bucket.window = policy.Window
+	}
+
+	result := RateLimitResult{Limit: policy.Limit}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / refillPerSecond)
+	}
+	result.Remaining = int(math.Floor(bucket.tokens))
+	result.ResetAfter = secondsToDuration((limit - bucket.tokens) / refillPerSecond)
+	return result
+}
+
+// sweep drops the buckets of every policy that would have refilled completely by now. It must
+// be called with s.mu held.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.lastSeen) >= bucket.window {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+// RateLimiter applies RateLimitPolicies to requests, keyed by authenticated user when
+// possible and by client IP otherwise.
+type RateLimiter struct {
+	store          RateLimitStore
+	trustedProxies []*net.IPNet
+	userIDForToken func(token string) (uint64, bool)
+	now            func() time.Time
+}
+
+// NewRateLimiter creates a RateLimiter. trustedProxies is a list of IPs or CIDR ranges whose
+// X-Forwarded-For header is honoured; userIDForToken resolves the auth cookie to a user without
+// making any outbound calls, returning false if the token isn't known yet.
+func NewRateLimiter(store RateLimitStore, trustedProxies []string, userIDForToken func(token string) (uint64, bool)) *RateLimiter {
+	return &RateLimiter{
+		store:          store,
+		trustedProxies: parseTrustedProxies(trustedProxies),
+		userIDForToken: userIDForToken,
+		now:            time.Now,
+	}
+}
+
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			slog.Warn("Ignoring invalid trusted proxy", "proxy", proxy, "error", err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func (rl *RateLimiter) isTrustedProxy(ip net.IP) bool {
+	for _, network := range rl.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client that made the request. X-Forwarded-For is
+// only consulted when the direct peer is a trusted proxy, and is walked right to left so that
+// a client can't spoof its address by prepending entries.
+func (rl *RateLimiter) ClientIP(r *http.Request) string {
+	remoteHost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteHost = r.RemoteAddr
+	}
+	remoteIP := net.ParseIP(remoteHost)
+	if remoteIP == nil || !rl.isTrustedProxy(remoteIP) {
+		return remoteHost
+	}
+
+	forwardedFor := r.Header.Values("X-Forwarded-For")
+	var hops []string
+	for _, header := range forwardedFor {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hopIP := net.ParseIP(hops[i])
+		if hopIP == nil {
+			// A malformed entry means everything to its left is untrustworthy
+			break
+		}
+		if !rl.isTrustedProxy(hopIP) {
+			return hopIP.String()
+		}
+	}
+	return remoteHost
+}
+
+// clientKey identifies who a request should be charged to.
+func (rl *RateLimiter) clientKey(r *http.Request) string {
+	if rl.userIDForToken != nil {
+		if authCookie, err := r.Cookie("auth"); err == nil && authCookie.Value != "" {
+			if userID, ok := rl.userIDForToken(authCookie.Value); ok {
+				return "user:" + strconv.FormatUint(userID, 10)
+			}
+		}
+	}
+	return "ip:" + rl.ClientIP(r)
+}
+
+// Limit returns middleware enforcing the given policy.
+func (rl *RateLimiter) Limit(policy RateLimitPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := rl.clientKey(r)
+			result := rl.store.Take(key, policy, rl.now())
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Window)))
+
+			if !result.Allowed {
+				slog.WarnContext(r.Context(), "Rate limit exceeded", "policy", policy.Name, "client", key, "method", r.Method, "route", routeTemplate(r))
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				http.Error(w, "Too many requests, please try again later", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+)
+
+func TestMemoryRateLimitStore_TakeAndRefill(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{Name: "test", Limit: 2, Window: 2 * time.Second}
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 2; i++ {
+		if result := store.Take("k", policy, now); !result.Allowed {
+			t.Fatalf("request %d should have been allowed", i)
+		}
+	}
+
+	result := store.Take("k", policy, now)
+	if result.Allowed {
+		t.Fatal("third request should have been rejected")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s, got %v", result.RetryAfter)
+	}
+
+	// Other keys and other policies have their own buckets
+	if result := store.Take("other", policy, now); !result.Allowed {
+		t.Error("a different key should not share the bucket")
+	}
+	if result := store.Take("k", RateLimitPolicy{Name: "other", Limit: 1, Window: time.Second}, now); !result.Allowed {
+		t.Error("a different policy should not share the bucket")
+	}
+
+	// One token refills per second
+	result = store.Take("k", policy, now.Add(time.Second))
+	if !result.Allowed {
+		t.Fatal("request should be allowed after the bucket refilled a token")
+	}
+	if result.Remaining != 0 {
+		t.Errorf("expected 0 remaining, got %d", result.Remaining)
+	}
+}
+
+func TestMemoryRateLimitStore_SweepsEveryPolicy(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	short := RateLimitPolicy{Name: "short", Limit: 1, Window: time.Second}
+	long := RateLimitPolicy{Name: "long", Limit: 1, Window: time.Hour}
+	now := time.Unix(1_700_000_000, 0)
+	store.Take("a", short, now)
+	store.Take("b", long, now)
+
+	// Only the long policy is used from now on, and the sweep still drops the short one's idle
+	// bucket, but not its own bucket that hasn't refilled yet
+	store.Take("c", long, now.Add(2*time.Minute))
+	if _, ok := store.buckets["short|a"]; ok {
+		t.Error("expected the idle bucket of the other policy to be swept")
+	}
+	if _, ok := store.buckets["long|b"]; !ok {
+		t.Error("expected a bucket still refilling to be kept")
+	}
+}
+
+func TestRateLimiter_ClientIP(t *testing.T) {
+	rl := NewRateLimiter(NewMemoryRateLimitStore(), []string{"10.0.0.0/8", "192.168.1.1"}, nil)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		{"direct client ignores header", "203.0.113.5:1234", "1.2.3.4", "203.0.113.5"},
+		{"trusted proxy uses header", "10.1.2.3:80", "198.51.100.7", "198.51.100.7"},
+		{"skips trusted hops from the right", "10.1.2.3:80", "198.51.100.7, 192.168.1.1, 10.9.9.9", "198.51.100.7"},
+		{"spoofed left-most entry is ignored", "10.1.2.3:80", "1.1.1.1, 198.51.100.7", "198.51.100.7"},
+		{"only trusted hops falls back to peer", "10.1.2.3:80", "10.2.2.2", "10.1.2.3"},
+		{"malformed entry stops the walk", "10.1.2.3:80", "198.51.100.7, garbage", "10.1.2.3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/login", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			if got := rl.ClientIP(req); got != tc.expectedIP {
+				t.Errorf("expected %s got %s", tc.expectedIP, got)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_MiddlewareHeadersAndRejection(t *testing.T) {
+	userIDs := map[string]uint64{"tok-rl": 42}
+	rl := NewRateLimiter(NewMemoryRateLimitStore(), nil, func(token string) (uint64, bool) {
+		id, ok := userIDs[token]
+		return id, ok
+	})
+	policy := RateLimitPolicy{Name: "test", Limit: 1, Window: time.Minute}
+	handler := rl.Limit(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	newRequest := func(remoteAddr, token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		}
+		return req
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("203.0.113.5:1", "tok-rl"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", rr.Code)
+	}
+	if rr.Header().Get("RateLimit-Limit") != "1" || rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected rate limit headers: %v", rr.Header())
+	}
+
+	// Same user from a different IP shares the user's bucket
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("203.0.113.99:1", "tok-rl"))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for the same user, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected Retry-After of 60, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	// An anonymous request from the first IP is keyed by IP, not by the user
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("203.0.113.5:1", ""))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected anonymous request to use its own bucket, got %d", rr.Code)
+	}
+}
+
+func TestSetupRoutes_DedicatedPoliciesSkipAPILimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Path = ":memory:"
+	cfg.RateLimits.API = config.RateLimitConfig{Limit: 1, Window: time.Minute}
+	db := database.NewDbDriver(cfg.Database)
+	hs := NewHttpServer(cfg, service.NewService(cfg, service.NewDatabaseOperations(db), nil), db)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	for path, limit := range map[string]config.RateLimitConfig{
+		"/api/email-verification/verify/nope": cfg.RateLimits.VerifyEmail,
+		"/api/user/deletion/confirm/nope":     cfg.RateLimits.AccountDeletion,
+	} {
+		for range 2 {
+			rr := get(path)
+			if rr.Code == http.StatusTooManyRequests {
+				t.Fatalf("%s was charged to the api limit", path)
+			}
+			if got := rr.Header().Get("RateLimit-Limit"); got != strconv.Itoa(limit.Limit) {
+				t.Errorf("%s: expected RateLimit-Limit %d, got %q", path, limit.Limit, got)
+			}
+		}
+	}
+
+	// The api bucket is still full, and empties after one request
+	if rr := get("/api/user"); rr.Code == http.StatusTooManyRequests || rr.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("expected the api limit on /api/user, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := get("/api/user"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 once the api limit is used up, got %d", rr.Code)
+	}
+}
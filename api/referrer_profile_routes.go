@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// UserSetReferrerAvailabilityHandler turns the referrer's vacation mode on until the given time,
+// or off when unavailableUntil is null or in the past. Unavailable referrers are left out of
+// matching.
+// PUT /api/user/referrer/availability
+func (hs *HttpServer) UserSetReferrerAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserSetReferrerAvailabilityHandler")
+
+	var availability api_objects.UserViewReferrerAvailability
+	if !decodeAndValidate(w, r, &availability) {
+		return
+	}
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	referrer := hs.dbDriver.GetReferrerByUserId(userID)
+	if referrer == nil || referrer.ReferrerId == 0 {
+		http.Error(w, "Referrer not found", http.StatusNotFound)
+		return
+	}
+
+	until := availability.UnavailableUntil
+	if until != nil && !until.After(time.Now()) {
+		until = nil
+	}
+	if err := hs.dbDriver.SetReferrerUnavailableUntil(referrer.ReferrerId, until); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Referrer not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error setting referrer availability", "referrer_id", referrer.ReferrerId, "error", err)
+		http.Error(w, "Failed to set availability", http.StatusInternalServerError)
+		return
+	}
+	referrer.UnavailableUntil = until
+	writeJSON(w, api_objects.ConvertReferrerToUserViewReferrer(*referrer))
+}
+
+// UserGetCompanyReferrersHandler lists what the company's referrers can refer for, without
+// saying who they are, so candidates can tell whether asking for a referral is worth it.
+// GET /api/user/company/get/{company_id}/referrers
+func (hs *HttpServer) UserGetCompanyReferrersHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserGetCompanyReferrersHandler")
+
+	if _, err := hs.GetUserIDFromContext(r); err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	companyID, err := parseUint64FromString(mux.Vars(r)["company_id"])
+	if err != nil {
+		http.Error(w, "Invalid company ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if company := hs.dbDriver.GetCompanyById(companyID); company == nil || company.Id == 0 {
+		http.Error(w, "Company not found", http.StatusNotFound)
+		return
+	}
+
+	referrers, err := hs.dbDriver.GetReferrersByCompanyId(companyID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing company referrers", "company_id", companyID, "error", err)
+		http.Error(w, "Failed to list referrers", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	result := make([]api_objects.CandidateViewReferrerProfile, 0, len(referrers))
+	for i := range referrers {
+		result = append(result, api_objects.ConvertDbReferrerToCandidateViewReferrerProfile(&referrers[i], now))
+	}
+	writeJSON(w, result)
+}
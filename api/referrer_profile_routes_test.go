@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+)
+
+func TestReferrerProfileRoutes(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+	inherited, nameOnly := seedVisibilityRequests(t, hs)
+	referrer := hs.dbDriver.GetReferrerByUserId(1)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	body := fmt.Sprintf(`{"id": %d, "companyId": %d, "corporateEmail": "admin@corp.example", "team": "Payments", "seniority": "staff",
+		"jobFamilies": ["engineering", "data"], "locations": ["Toronto", "Remote"], "weeklyCapacity": 1}`, referrer.ReferrerId, referrer.CompanyId)
+	if rr := send(http.MethodPut, "/api/user/referrer/update", body); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	until := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	if rr := send(http.MethodPut, "/api/user/referrer/availability", fmt.Sprintf(`{"unavailableUntil": %q}`, until)); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr := send(http.MethodGet, fmt.Sprintf("/api/user/company/get/%d/referrers", referrer.CompanyId), "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var referrers []api_objects.CandidateViewReferrerProfile
+	if err := json.Unmarshal(rr.Body.Bytes(), &referrers); err != nil {
+		t.Fatalf("failed to decode referrers: %v", err)
+	}
+	if len(referrers) != 1 || referrers[0].Team != "Payments" || referrers[0].Seniority != "staff" ||
+		len(referrers[0].JobFamilies) != 2 || len(referrers[0].Locations) != 2 || referrers[0].Available {
+		t.Errorf("expected the unavailable referrer's profile, got %+v", referrers)
+	}
+	if strings.Contains(rr.Body.String(), "corp.example") {
+		t.Errorf("expected the directory not to reveal who the referrers are, got %s", rr.Body.String())
+	}
+
+	// A weekly capacity of one allows one claim
+	if rr := send(http.MethodPost, fmt.Sprintf("/api/referrer/refer/%d", inherited), ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := send(http.MethodPost, fmt.Sprintf("/api/referrer/refer/%d", nameOnly), ""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d got %d: %s", http.StatusTooManyRequests, rr.Code, rr.Body.String())
+	}
+}
+
+func TestReferrerProfileRoutes_ValidationErrors(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+
+	for _, tc := range []struct {
+		body, field string
+	}{
+		{`{"companyId": 1, "seniority": "intern"}`, "seniority"},
+		{`{"companyId": 1, "jobFamilies": ["astronaut"]}`, "jobFamilies[0]"},
+		{`{"companyId": 1, "weeklyCapacity": -1}`, "weeklyCapacity"},
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/api/user/referrer/update", strings.NewReader(tc.body))
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected status %d got %d", tc.body, http.StatusUnprocessableEntity, rr.Code)
+		}
+		if resp := decodeValidationResponse(t, rr); resp.Fields[tc.field] == "" {
+			t.Errorf("%s: expected a validation error for %s, got %v", tc.body, tc.field, resp.Fields)
+		}
+	}
+}
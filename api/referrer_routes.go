@@ -2,17 +2,26 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
-	"log"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+const (
+	defaultRecommendationLimit = 20
+	maxRecommendationLimit     = 100
+)
+
 // ReferrerGetAllReferralRequestsHandler handles fetching all referral requests for a referrer
 func (hs *HttpServer) ReferrerGetAllReferralRequestsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called ReferrerGetAllReferralRequestsHandler")
+	slog.DebugContext(r.Context(), "Called ReferrerGetAllReferralRequestsHandler")
 
 	userID, userIdRetrievalErr := hs.GetUserIDFromContext(r)
 	if userIdRetrievalErr != nil {
@@ -26,12 +35,39 @@ func (hs *HttpServer) ReferrerGetAllReferralRequestsHandler(w http.ResponseWrite
 		return
 	}
 
-	referralRequests := hs.dbDriver.GetReferralRequestsByCompanyId(referrer.CompanyId)
+	hs.writeFairReferralRequestListing(w, r, referrer, hs.dbDriver.GetReferralRequestsByCompanyId(referrer.CompanyId))
+}
 
-	result := make([]api_objects.ReferrerViewReferralRequest, 0)
+// writeFairReferralRequestListing writes the referral requests in the fair order, leaving out
+// stale ones unless the include_stale query parameter is true, and ones with no location matching
+// the country, region and remote query parameters when given.
+func (hs *HttpServer) writeFairReferralRequestListing(w http.ResponseWriter, r *http.Request, referrer *database.Referrer, referralRequests []database.ReferralRequest) {
+	query := r.URL.Query()
+	includeStale := false
+	if raw := query.Get("include_stale"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "Invalid include_stale", http.StatusBadRequest)
+			return
+		}
+		includeStale = parsed
+	}
+	locationFilter, err := service.ParseLocationFilter(query.Get("country"), query.Get("region"), query.Get("remote"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	referralRequests = service.FilterReferralRequestsByLocation(referralRequests, locationFilter)
 
-	for _, referralRequest := range referralRequests {
-		result = append(result, *api_objects.ConvertDbReferralRequestToReferrerViewReferralRequest(&referralRequest))
+	ordered, err := hs.service.OrderReferralRequestsFairly(r.Context(), referralRequests, includeStale)
+	if err != nil {
+		http.Error(w, "Failed to list referral requests", http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]api_objects.ReferrerViewReferralRequest, 0, len(ordered))
+	for _, referralRequest := range ordered {
+		result = append(result, *api_objects.ConvertDbReferralRequestToReferrerViewReferralRequest(&referralRequest, referrer.ReferrerId))
 	}
 
 	response, err := json.Marshal(result)
@@ -46,7 +82,7 @@ func (hs *HttpServer) ReferrerGetAllReferralRequestsHandler(w http.ResponseWrite
 
 // ReferrerGetReferralRequestsHandler handles fetching referral requests for a referrer based on specific criteria
 func (hs *HttpServer) ReferrerGetReferralRequestHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called ReferrerGetReferralRequestsHandler")
+	slog.DebugContext(r.Context(), "Called ReferrerGetReferralRequestsHandler")
 
 	userID, err := hs.GetUserIDFromContext(r)
 	if err != nil {
@@ -70,8 +106,21 @@ func (hs *HttpServer) ReferrerGetReferralRequestHandler(w http.ResponseWriter, r
 
 	// Fetch referral requests based on the given status and referrer ID
 	referralRequest := hs.dbDriver.GetReferralRequestById(referralRequestId)
+	if referralRequest == nil {
+		http.Error(w, "Referral request not found", http.StatusNotFound)
+		return
+	}
+	if referralRequest.CompanyID != referrer.CompanyId {
+		http.Error(w, "Referrer not found or unauthorized", http.StatusForbidden)
+		return
+	}
+	// Other referrers' recommendations favour requests nobody has opened, so failing to record
+	// this only skews the ranking
+	if err := hs.dbDriver.RecordReferralRequestView(referralRequestId, referrer.ReferrerId, time.Now()); err != nil {
+		slog.WarnContext(r.Context(), "Error recording referral request view", "referral_request_id", referralRequestId, "referrer_id", referrer.ReferrerId, "error", err)
+	}
 
-	result := api_objects.ConvertDbReferralRequestToReferrerViewReferralRequest(referralRequest)
+	result := api_objects.ConvertDbReferralRequestToReferrerViewReferralRequest(referralRequest, referrer.ReferrerId)
 
 	response, err := json.Marshal(result)
 	if err != nil {
@@ -85,7 +134,7 @@ func (hs *HttpServer) ReferrerGetReferralRequestHandler(w http.ResponseWriter, r
 
 // ReferrerGetReferralRequestsByCompanyHandler handles fetching referral requests for a referrer based on the company
 func (hs *HttpServer) ReferrerGetReferralRequestsByCompanyHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called ReferrerGetReferralRequestsByCompanyHandler")
+	slog.DebugContext(r.Context(), "Called ReferrerGetReferralRequestsByCompanyHandler")
 
 	userID, err := hs.GetUserIDFromContext(r)
 	if err != nil {
@@ -105,16 +154,105 @@ func (hs *HttpServer) ReferrerGetReferralRequestsByCompanyHandler(w http.Respons
 		return
 	}
 
-	referralRequests := hs.dbDriver.GetReferralRequestsByCompanyId(company_id)
+	hs.writeFairReferralRequestListing(w, r, referrer, hs.dbDriver.GetReferralRequestsByCompanyId(company_id))
+}
+
+// ReferrerGetRecommendedReferralRequestsHandler lists the open referral requests at the
+// referrer's company that best match their profile, best first, with why each was picked.
+// GET /api/referrer/referral_requests/recommended?limit={limit}
+func (hs *HttpServer) ReferrerGetRecommendedReferralRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called ReferrerGetRecommendedReferralRequestsHandler")
 
-	result := make([]api_objects.ReferrerViewReferralRequest, 0)
-	for _, referralRequest := range referralRequests {
-		result = append(result, *api_objects.ConvertDbReferralRequestToReferrerViewReferralRequest(&referralRequest))
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+	limit := defaultRecommendationLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxRecommendationLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
 	}
 
-	response, err := json.Marshal(result)
+	recommendations, err := hs.service.RecommendReferralRequests(r.Context(), userID, limit)
 	if err != nil {
-		http.Error(w, "Error marshaling referral requests", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrReferrerNotFound) {
+			http.Error(w, "Referrer not found or unauthorized", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to recommend referral requests", http.StatusInternalServerError)
+		return
+	}
+
+	// Recommended requests are open, so nobody has claimed them and the viewer is just a referrer
+	// at the company
+	result := make([]api_objects.ReferrerViewRecommendation, 0, len(recommendations))
+	for _, rec := range recommendations {
+		families := make([]string, 0, len(rec.MatchedJobFamilies))
+		for _, family := range rec.MatchedJobFamilies {
+			families = append(families, string(family))
+		}
+		locations := rec.MatchedLocations
+		if locations == nil {
+			locations = []string{}
+		}
+		result = append(result, api_objects.ReferrerViewRecommendation{
+			ReferralRequest:    *api_objects.ConvertDbReferralRequestToReferrerViewReferralRequest(&rec.Request, 0),
+			Score:              rec.Score,
+			MatchedJobFamilies: families,
+			TeamMatch:          rec.TeamMatch,
+			MatchedLocations:   locations,
+			ReferralTypeMatch:  rec.ReferralTypeMatch,
+			AgeDays:            int(rec.Age / (24 * time.Hour)),
+			OtherViewers:       rec.OtherViewers,
+			Reasons:            rec.Reasons,
+		})
+	}
+	writeJSON(w, result)
+}
+
+// ReferrerClaimReferralRequestHandler lets a referrer take on a referral request at their company
+// and mark the candidate as referred.
+// POST /api/referrer/refer/{referral_request_id}
+func (hs *HttpServer) ReferrerClaimReferralRequestHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called ReferrerClaimReferralRequestHandler")
+
+	userID, err := hs.GetUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	referralRequestId, err := strconv.ParseUint(mux.Vars(r)["referral_request_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid referral request ID", http.StatusBadRequest)
+		return
+	}
+
+	claimed, err := hs.service.ClaimReferralRequest(r.Context(), userID, referralRequestId)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrReferrerNotFound), errors.Is(err, service.ErrReferralRequestOtherCompany):
+			http.Error(w, "Referrer not found or unauthorized", http.StatusForbidden) // 403
+		case errors.Is(err, service.ErrReferralRequestNotFound):
+			http.Error(w, "Referral request not found", http.StatusNotFound) // 404
+		case errors.Is(err, service.ErrReferralRequestAlreadyClaimed):
+			http.Error(w, err.Error(), http.StatusConflict) // 409
+		case errors.Is(err, service.ErrReferrerAtCapacity):
+			http.Error(w, err.Error(), http.StatusTooManyRequests) // 429
+		default:
+			http.Error(w, "Failed to claim referral request", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response, err := json.Marshal(api_objects.ConvertDbReferralRequestToReferrerViewReferralRequest(claimed, *claimed.ReferrerId))
+	if err != nil {
+		http.Error(w, "Error marshaling referral request", http.StatusInternalServerError)
 		return
 	}
 
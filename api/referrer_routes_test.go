@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+// seedVisibilityRequests makes user 1 a referrer at a company where a candidate, whose profile is
+// anonymous, asked for two referrals: one with their own visibility and one showing their name.
+func seedVisibilityRequests(t *testing.T, hs *HttpServer) (inherited, nameOnly uint64) {
+	t.Helper()
+	company, err := hs.dbDriver.CreateCompany(&database.Company{Name: "Example", AddedByUserId: 1})
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	if _, err := hs.dbDriver.CreateReferrer(&database.Referrer{UserId: 1, CompanyId: company.Id, CorporateEmail: "admin@corp.example"}); err != nil {
+		t.Fatalf("failed to create referrer: %v", err)
+	}
+	user, err := hs.dbDriver.CreateUser(&database.User{FirstName: "Amina", LastName: "Khan", Email: "amina@example.com", PhoneNumber: "+15555550100"})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	candidate, err := hs.dbDriver.CreateCandidate(&database.Candidate{UserId: user.Id, WorkExperience: 5,
+		ResumeUrl: "https://example.com/resume.pdf", Visibility: database.VisibilityAnonymous})
+	if err != nil {
+		t.Fatalf("failed to create candidate: %v", err)
+	}
+
+	nameOnlyVisibility := database.VisibilityNameOnly
+	var ids []uint64
+	for _, visibility := range []*database.CandidateVisibility{nil, &nameOnlyVisibility} {
+		request, err := hs.dbDriver.CreateReferralRequest(&database.ReferralRequest{CandidateID: candidate.CandidateId, CompanyID: company.Id,
+			PrimaryJobTitleSeeking: "Engineer", ReferralType: database.FullTime, Status: database.ReferralRequested, Visibility: visibility})
+		if err != nil {
+			t.Fatalf("failed to create referral request: %v", err)
+		}
+		ids = append(ids, request.ReferralRequestId)
+	}
+	return ids[0], ids[1]
+}
+
+func TestReferrerViews_RespectCandidateVisibility(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+	inherited, nameOnly := seedVisibilityRequests(t, hs)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/referrer/referral_requests/all", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var requests []api_objects.ReferrerViewReferralRequest
+	if err := json.Unmarshal(rr.Body.Bytes(), &requests); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	candidates := make(map[uint64]api_objects.ReferrerViewCandidate)
+	for _, request := range requests {
+		candidates[request.ReferralRequestId] = request.Candidate
+	}
+	if c := candidates[inherited]; c.Visibility != "anonymous" || c.FirstName != "" || c.ResumeUrl != "" || c.WorkExperience != 5 {
+		t.Errorf("expected only the work experience of an anonymous candidate, got %+v", c)
+	}
+	if c := candidates[nameOnly]; c.Visibility != "name_only" || c.FirstName != "Amina" || c.ResumeUrl != "" || c.Email != "" {
+		t.Errorf("expected the name but no resume or contact details, got %+v", c)
+	}
+}
+
+func TestReferrerClaim_ReleasesContactDetails(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+	inherited, _ := seedVisibilityRequests(t, hs)
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/referrer/refer/%d", inherited), nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var claimed api_objects.ReferrerViewReferralRequest
+	if err := json.Unmarshal(rr.Body.Bytes(), &claimed); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	c := claimed.Candidate
+	if c.Visibility != "full" || c.FirstName != "Amina" || c.ResumeUrl == "" || c.Email != "amina@example.com" || c.PhoneNumber == "" {
+		t.Errorf("expected the claiming referrer to see the full profile and contact details, got %+v", c)
+	}
+}
+
+func TestReferrerGetReferralRequestHandler_NotFound(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+	seedVisibilityRequests(t, hs)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/referrer/referral_requests/99", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestReferrerRecommendedReferralRequests(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+	inherited, nameOnly := seedVisibilityRequests(t, hs)
+	referrer := hs.dbDriver.GetReferrerByUserId(1)
+	referrer.JobFamilies = []database.ReferrerJobFamily{{JobFamily: database.JobFamilyEngineering}}
+	if _, err := hs.dbDriver.UpdateReferrer(1, referrer); err != nil {
+		t.Fatalf("failed to update referrer: %v", err)
+	}
+	other, err := hs.dbDriver.CreateUser(&database.User{FirstName: "Other", LastName: "Referrer", Email: "other@example.com"})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	otherReferrer, err := hs.dbDriver.CreateReferrer(&database.Referrer{UserId: other.Id, CompanyId: referrer.CompanyId, CorporateEmail: "other@corp.example"})
+	if err != nil {
+		t.Fatalf("failed to create referrer: %v", err)
+	}
+	if err := hs.dbDriver.RecordReferralRequestView(inherited, otherReferrer.ReferrerId, time.Now()); err != nil {
+		t.Fatalf("failed to record view: %v", err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/api/referrer/referral_requests/recommended")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var recommendations []api_objects.ReferrerViewRecommendation
+	if err := json.Unmarshal(rr.Body.Bytes(), &recommendations); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	// Both are engineering requests, but another referrer already opened the first
+	if len(recommendations) != 2 || recommendations[0].ReferralRequest.ReferralRequestId != nameOnly ||
+		recommendations[1].OtherViewers != 1 {
+		t.Fatalf("expected the unopened request first, got %+v", recommendations)
+	}
+	if best := recommendations[0]; len(best.MatchedJobFamilies) != 1 || len(best.Reasons) == 0 || best.ReferralRequest.Candidate.FirstName != "Amina" {
+		t.Errorf("expected the explanation and the name-only candidate, got %+v", best)
+	}
+
+	// Opening a request counts as a view for the other referrer's ranking
+	if rr := get(fmt.Sprintf("/api/referrer/referral_requests/%d", nameOnly)); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rr.Code)
+	}
+	if counts, err := hs.dbDriver.CountReferralRequestViewers([]uint64{nameOnly}, otherReferrer.ReferrerId); err != nil || counts[nameOnly] != 1 {
+		t.Errorf("expected the view to be recorded, got %v, %v", counts, err)
+	}
+
+	if rr := get("/api/referrer/referral_requests/recommended?limit=0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestReferrerListings_FairOrder(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+	inherited, nameOnly := seedVisibilityRequests(t, hs)
+	referrer := hs.dbDriver.GetReferrerByUserId(1)
+	if err := hs.dbDriver.RecordReferralRequestView(inherited, referrer.ReferrerId, time.Now()); err != nil {
+		t.Fatalf("failed to record view: %v", err)
+	}
+	list := func(path string) []uint64 {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d got %d: %s", path, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var requests []api_objects.ReferrerViewReferralRequest
+		if err := json.Unmarshal(rr.Body.Bytes(), &requests); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		ids := make([]uint64, 0, len(requests))
+		for _, request := range requests {
+			ids = append(ids, request.ReferralRequestId)
+		}
+		return ids
+	}
+
+	// The request nobody has opened comes first, on both listings
+	companyPath := fmt.Sprintf("/api/referrer/referral_requests/company/%d", referrer.CompanyId)
+	for _, path := range []string{"/api/referrer/referral_requests/all", companyPath} {
+		if ids := list(path); len(ids) != 2 || ids[0] != nameOnly || ids[1] != inherited {
+			t.Errorf("%s: expected the unopened request first, got %v", path, ids)
+		}
+	}
+
+	// Once it has waited longer than stale_after, it ages out unless asked for
+	stale := hs.dbDriver.GetReferralRequestById(nameOnly)
+	stale.CreatedAt = time.Now().Add(-hs.config.ReferralRequests.StaleAfter - time.Hour)
+	if _, err := hs.dbDriver.UpdateReferralRequest(stale); err != nil {
+		t.Fatalf("failed to update referral request: %v", err)
+	}
+	if ids := list("/api/referrer/referral_requests/all"); len(ids) != 1 || ids[0] != inherited {
+		t.Errorf("expected the stale request to be left out, got %v", ids)
+	}
+	if ids := list("/api/referrer/referral_requests/all?include_stale=true"); len(ids) != 2 || ids[1] != nameOnly {
+		t.Errorf("expected the stale request last, got %v", ids)
+	}
+}
+
+func TestReferrerListings_LocationFilter(t *testing.T) {
+	token := "referrer-tok"
+	hs := setupAdminTestServer(t, token, false)
+	inNewYork, remote := seedVisibilityRequests(t, hs)
+	setLocations := func(id uint64, locations ...database.ReferralRequestLocationAssociation) {
+		request := hs.dbDriver.GetReferralRequestById(id)
+		request.Locations = locations
+		if _, err := hs.dbDriver.UpdateReferralRequest(request); err != nil {
+			t.Fatalf("failed to update referral request: %v", err)
+		}
+	}
+	setLocations(inNewYork, database.ReferralRequestLocationAssociation{Location: "NYC", City: "New York", Region: "NY", Country: "US"})
+	// As left by the backfill for text it didn't recognize
+	setLocations(remote, database.ReferralRequestLocationAssociation{Location: "Remote (Canada) - EST hours"})
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/referrer/referral_requests/all?include_stale=true&"+query, nil)
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for query, want := range map[string][]uint64{
+		"country=US":              {inNewYork},
+		"country=canada":          {remote},
+		"region=new%20york":       {inNewYork},
+		"remote=true":             {remote},
+		"remote=false":            {inNewYork},
+		"remote=true&country=USA": {},
+	} {
+		rr := list(query)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d got %d: %s", query, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var requests []api_objects.ReferrerViewReferralRequest
+		if err := json.Unmarshal(rr.Body.Bytes(), &requests); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		ids := make([]uint64, 0, len(requests))
+		for _, request := range requests {
+			ids = append(ids, request.ReferralRequestId)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("%s: expected %v, got %v", query, want, ids)
+		}
+	}
+
+	for _, query := range []string{"country=Atlantis", "region=XX", "country=US&region=ON", "remote=maybe"} {
+		if rr := list(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
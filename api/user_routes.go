@@ -2,9 +2,11 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
-	"log"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,7 +16,7 @@ import (
 
 // UserUpdateUserHandler handles user creation
 func (hs *HttpServer) UserUpdateUserHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserUpdateUserHandler")
+	slog.DebugContext(r.Context(), "Called UserUpdateUserHandler")
 	userId, err := hs.GetUserIDFromContext(r)
 	if err != nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
@@ -27,10 +29,8 @@ func (hs *HttpServer) UserUpdateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	requestUser := api_objects.UserViewUser{}
-	// Convert the request body to a UserViewUser object
-	unmarshalErr := json.NewDecoder(r.Body).Decode(&requestUser)
-	if unmarshalErr != nil {
-		http.Error(w, unmarshalErr.Error(), http.StatusBadRequest)
+	// Convert the request body to a UserViewUser object and validate it
+	if !decodeAndValidate(w, r, &requestUser) {
 		return
 	}
 	requestUser.Id = userDbModel.Id
@@ -38,6 +38,8 @@ func (hs *HttpServer) UserUpdateUserHandler(w http.ResponseWriter, r *http.Reque
 
 	// Convert the UserViewUser object to a User object
 	user := api_objects.ConvertUserViewUserToUser(requestUser, time.Now(), time.Now(), nil)
+	// Admin rights are only changed with the `user promote-admin` command, never by the user
+	user.IsAdmin = userDbModel.IsAdmin
 	// Create the user in the database
 	userUpdateErr := hs.dbDriver.UpdateUser(&user)
 	if userUpdateErr != nil {
@@ -58,7 +60,7 @@ func (hs *HttpServer) UserUpdateUserHandler(w http.ResponseWriter, r *http.Reque
 
 // UserGetUserHandler handles fetching the user details
 func (hs *HttpServer) UserGetUserHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Called UserGetUserHandler")
+	slog.DebugContext(r.Context(), "Called UserGetUserHandler")
 
 	userID, userIdRetrievalError := hs.GetUserIDFromContext(r)
 	if userIdRetrievalError != nil {
@@ -84,14 +86,16 @@ func (hs *HttpServer) UserGetUserHandler(w http.ResponseWriter, r *http.Request)
 	w.Write(response)
 }
 
-// UserCreateCompanyHandler handles company creation for a user
+// UserCreateCompanyHandler handles company creation for a user. A company that already exists by
+// domain, name or alias isn't created, and neither is one whose name is close to an existing
+// company's unless ignore_similar=true; both respond 409 with the existing companies.
+// POST /api/user/company/create?ignore_similar={true|false}
 func (hs *HttpServer) UserCreateCompanyHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserCreateCompanyHandler")
+	slog.DebugContext(r.Context(), "Called UserCreateCompanyHandler")
 
 	var requestCompany api_objects.UserViewCompany
 	// Convert the request body to a CompanyView object
-	if err := json.NewDecoder(r.Body).Decode(&requestCompany); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &requestCompany) {
 		return
 	}
 	requestCompany.Id = 0
@@ -106,10 +110,19 @@ func (hs *HttpServer) UserCreateCompanyHandler(w http.ResponseWriter, r *http.Re
 	// Convert the CompanyView object to a Company domain object
 	company := api_objects.ConvertUserViewCompanyToCompany(requestCompany, userID, time.Now(), time.Now(), nil)
 
-	// Create the company in the database
-	createdCompany, creationErr := hs.dbDriver.CreateCompany(&company)
+	ignoreSimilar, _ := strconv.ParseBool(r.URL.Query().Get("ignore_similar"))
+	createdCompany, matches, creationErr := hs.service.CreateCompany(r.Context(), &company, ignoreSimilar)
+	if errors.Is(creationErr, service.ErrCompanyExists) || errors.Is(creationErr, service.ErrSimilarCompanies) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(api_objects.UserViewCompanyConflict{
+			Error:       creationErr.Error(),
+			Suggestions: convertCompanyMatchesToSuggestions(matches),
+		})
+		return
+	}
 	if creationErr != nil {
-		http.Error(w, creationErr.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to create company", http.StatusInternalServerError)
 		return
 	}
 
@@ -125,9 +138,35 @@ func (hs *HttpServer) UserCreateCompanyHandler(w http.ResponseWriter, r *http.Re
 	w.Write(response)
 }
 
+// UserGetCompanySuggestionsHandler lists the existing companies a company with the given name
+// and domains may duplicate, for checking before creating one.
+// GET /api/user/company/suggestions?name={name}&domain={domain}&domain={domain}
+func (hs *HttpServer) UserGetCompanySuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Called UserGetCompanySuggestionsHandler")
+	query := r.URL.Query()
+	if query.Get("name") == "" && len(query["domain"]) == 0 {
+		http.Error(w, "name or domain is required", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, convertCompanyMatchesToSuggestions(hs.service.FindSimilarCompanies(query.Get("name"), query["domain"])))
+}
+
+func convertCompanyMatchesToSuggestions(matches []service.CompanyMatch) []api_objects.UserViewCompanySuggestion {
+	suggestions := make([]api_objects.UserViewCompanySuggestion, 0, len(matches))
+	for _, match := range matches {
+		suggestions = append(suggestions, api_objects.UserViewCompanySuggestion{
+			Company: api_objects.ConvertCompanyToUserViewCompany(match.Company),
+			Reason:  string(match.Reason),
+			Matched: match.Matched,
+			Score:   match.Score,
+		})
+	}
+	return suggestions
+}
+
 // UserGetAllCompaniesHandler handles fetching all companies for a user
 func (hs *HttpServer) UserGetAllCompaniesHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserGetAllCompaniesHandler")
+	slog.DebugContext(r.Context(), "Called UserGetAllCompaniesHandler")
 
 	_, err := hs.GetUserIDFromContext(r)
 	if err != nil {
@@ -149,7 +188,7 @@ func (hs *HttpServer) UserGetAllCompaniesHandler(w http.ResponseWriter, r *http.
 
 // UserGetCompanyHandler handles fetching a specific company for a user
 func (hs *HttpServer) UserGetCompanyHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserGetCompanyHandler")
+	slog.DebugContext(r.Context(), "Called UserGetCompanyHandler")
 
 	vars := mux.Vars(r)
 	companyIdString := vars["company_id"]
@@ -183,12 +222,11 @@ func (hs *HttpServer) UserGetCompanyHandler(w http.ResponseWriter, r *http.Reque
 
 // UserCreateReferrerHandler handles the creation of a referrer
 func (hs *HttpServer) UserCreateReferrerHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserCreateReferrerHandler")
+	slog.DebugContext(r.Context(), "Called UserCreateReferrerHandler")
 
 	// Decode the request body into UserViewReferrer struct
 	var requestReferrer api_objects.UserViewReferrer
-	if err := json.NewDecoder(r.Body).Decode(&requestReferrer); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &requestReferrer) {
 		return
 	}
 	requestReferrer.ReferrerId = 0
@@ -227,12 +265,11 @@ func (hs *HttpServer) UserCreateReferrerHandler(w http.ResponseWriter, r *http.R
 
 // UserUpdateReferrerHandler handles updating a referrer
 func (hs *HttpServer) UserUpdateReferrerHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserUpdateReferrerHandler")
+	slog.DebugContext(r.Context(), "Called UserUpdateReferrerHandler")
 
 	// Decode the request body
 	var updateReferrer api_objects.UserViewReferrer
-	if err := json.NewDecoder(r.Body).Decode(&updateReferrer); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &updateReferrer) {
 		return
 	}
 
@@ -270,7 +307,7 @@ func (hs *HttpServer) UserUpdateReferrerHandler(w http.ResponseWriter, r *http.R
 
 // UserGetReferrerHandler handles fetching referrer details
 func (hs *HttpServer) UserGetReferrerHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserGetReferrerHandler")
+	slog.DebugContext(r.Context(), "Called UserGetReferrerHandler")
 
 	// Authenticate and retrieve user ID
 	userID, authErr := hs.GetUserIDFromContext(r)
@@ -303,7 +340,7 @@ func (hs *HttpServer) UserGetReferrerHandler(w http.ResponseWriter, r *http.Requ
 
 // UserDeleteReferrerHandler handles deleting a referrer
 func (hs *HttpServer) UserDeleteReferrerHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserDeleteReferrerHandler")
+	slog.DebugContext(r.Context(), "Called UserDeleteReferrerHandler")
 
 	// Authenticate and retrieve user ID
 	userID, authErr := hs.GetUserIDFromContext(r)
@@ -332,11 +369,10 @@ func (hs *HttpServer) UserDeleteReferrerHandler(w http.ResponseWriter, r *http.R
 
 // UserCreateCandidateHandler handles creating a candidate
 func (hs *HttpServer) UserCreateCandidateHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserCreateCandidateHandler")
+	slog.DebugContext(r.Context(), "Called UserCreateCandidateHandler")
 
 	var requestCandidate api_objects.UserViewCandidate
-	if err := json.NewDecoder(r.Body).Decode(&requestCandidate); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &requestCandidate) {
 		return
 	}
 
@@ -368,11 +404,10 @@ func (hs *HttpServer) UserCreateCandidateHandler(w http.ResponseWriter, r *http.
 
 // UserUpdateCandidateHandler handles updating candidate information
 func (hs *HttpServer) UserUpdateCandidateHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserUpdateCandidateHandler")
+	slog.DebugContext(r.Context(), "Called UserUpdateCandidateHandler")
 
 	var updateCandidate api_objects.UserViewCandidate
-	if err := json.NewDecoder(r.Body).Decode(&updateCandidate); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeAndValidate(w, r, &updateCandidate) {
 		return
 	}
 
@@ -383,6 +418,13 @@ func (hs *HttpServer) UserUpdateCandidateHandler(w http.ResponseWriter, r *http.
 	}
 
 	candidateDbObject := api_objects.ConvertUserViewCandidateToCandidate(updateCandidate, userID, time.Now(), time.Now(), nil)
+	// Leaving out the visibility keeps the current one
+	if candidateDbObject.Visibility == "" {
+		candidateDbObject.Visibility = database.VisibilityFull
+		if existing := hs.dbDriver.GetCandidateByUserId(userID); existing != nil && existing.Visibility != "" {
+			candidateDbObject.Visibility = existing.Visibility
+		}
+	}
 
 	updatedCandidate, updateErr := hs.dbDriver.UpdateCandidate(userID, &candidateDbObject)
 	if updateErr != nil {
@@ -404,7 +446,7 @@ func (hs *HttpServer) UserUpdateCandidateHandler(w http.ResponseWriter, r *http.
 
 // UserGetCandidateHandler handles fetching candidate details
 func (hs *HttpServer) UserGetCandidateHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserGetCandidateHandler")
+	slog.DebugContext(r.Context(), "Called UserGetCandidateHandler")
 
 	userID, authErr := hs.GetUserIDFromContext(r)
 	if authErr != nil {
@@ -432,7 +474,7 @@ func (hs *HttpServer) UserGetCandidateHandler(w http.ResponseWriter, r *http.Req
 
 // UserDeleteCandidateHandler handles deleting a candidate
 func (hs *HttpServer) UserDeleteCandidateHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Called UserDeleteCandidateHandler")
+	slog.DebugContext(r.Context(), "Called UserDeleteCandidateHandler")
 
 	userID, authErr := hs.GetUserIDFromContext(r)
 	if authErr != nil {
@@ -1,26 +1,39 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
 	"github.com/Suhaibinator/muslim-referrals-backend/database"
 	"github.com/Suhaibinator/muslim-referrals-backend/service"
 	"github.com/gorilla/mux"
-	"golang.org/x/oauth2"
 )
 
-// helper to create http server with in-memory db and prepopulated cache
+// helper to create http server with a migrated in-memory db and prepopulated cache
 
 func setupTestServer(userID uint64, token string) *HttpServer {
-	db := database.NewDbDriver(":memory:")
-	svc := service.NewService(&oauth2.Config{}, db, nil)
+	cfg := config.Default()
+	cfg.Database.Path = ":memory:"
+	db := database.NewDbDriver(cfg.Database)
+	migrator, err := db.Migrator()
+	if err != nil {
+		panic(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		panic(err)
+	}
+	svc := service.NewService(cfg, service.NewDatabaseOperations(db), nil)
 
 	// Seed the service cache directly using the exported helper
 	svc.SetUserIDForToken(token, userID)
 
-	return NewHttpServer(svc, db)
+	return NewHttpServer(cfg, svc, db)
 }
 
 func TestUserGetCandidateHandler_NotFound(t *testing.T) {
@@ -69,3 +82,54 @@ func TestUserGetCompanyHandler_NotFound(t *testing.T) {
 		t.Errorf("expected status %d got %d", http.StatusNotFound, rr.Code)
 	}
 }
+
+func TestUserCreateCompany_SuggestsExistingCompanies(t *testing.T) {
+	token := "user-tok"
+	hs := setupAdminTestServer(t, token, false)
+	existing, err := hs.dbDriver.CreateCompany(&database.Company{Name: "Globex Corporation", AddedByUserId: 1,
+		Domains: []database.CompanyDomainAssociation{{Domain: "globex.example"}}})
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	create := func(query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/company/create"+query, strings.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		rr := httptest.NewRecorder()
+		hs.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	conflict := func(rr *httptest.ResponseRecorder, reason string) {
+		t.Helper()
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("expected status %d got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+		}
+		var response api_objects.UserViewCompanyConflict
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(response.Suggestions) != 1 || response.Suggestions[0].Company.Id != existing.Id || response.Suggestions[0].Reason != reason {
+			t.Errorf("expected company %d to be suggested for its %s, got %+v", existing.Id, reason, response.Suggestions)
+		}
+	}
+
+	conflict(create("", `{"name": "GLOBEX, Inc."}`), "name")
+	conflict(create("?ignore_similar=true", `{"name": "Initech", "domains": ["globex.example"]}`), "domain")
+	conflict(create("", `{"name": "Globex Labs"}`), "similar_name")
+
+	rr := create("?ignore_similar=true", `{"name": "Globex Labs"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/company/suggestions?name=globex+corp", nil)
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr = httptest.NewRecorder()
+	hs.Router.ServeHTTP(rr, req)
+	var suggestions []api_objects.UserViewCompanySuggestion
+	if err := json.Unmarshal(rr.Body.Bytes(), &suggestions); err != nil {
+		t.Fatalf("failed to decode suggestions: %v", err)
+	}
+	if len(suggestions) != 2 || suggestions[0].Company.Id != existing.Id || suggestions[0].Score != 1 {
+		t.Errorf("expected the exact match first and the similar company after it, got %+v", suggestions)
+	}
+}
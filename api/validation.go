@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api_objects"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// requestValidator runs the `validate` struct tags declared on request payloads.
+// Field names in errors are reported using their JSON names so clients can map
+// them back to the fields they sent.
+var requestValidator = newRequestValidator()
+
+func newRequestValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterStructValidation(validateEducationYears, api_objects.UserViewEducation{})
+	v.RegisterStructValidation(validatePositionDates, api_objects.UserViewPosition{})
+	return v
+}
+
+// validateEducationYears rejects an education entry that ends before it starts.
+func validateEducationYears(sl validator.StructLevel) {
+	education := sl.Current().Interface().(api_objects.UserViewEducation)
+	if education.StartYear != nil && education.EndYear != nil && *education.EndYear < *education.StartYear {
+		sl.ReportError(education.EndYear, "endYear", "EndYear", "gtefield", "startYear")
+	}
+}
+
+// validatePositionDates rejects a position that ends before it starts. Dates that don't parse
+// are already reported by their datetime tags.
+func validatePositionDates(sl validator.StructLevel) {
+	position := sl.Current().Interface().(api_objects.UserViewPosition)
+	if position.EndDate == "" {
+		return
+	}
+	start, startErr := time.Parse(api_objects.PositionDateLayout, position.StartDate)
+	end, endErr := time.Parse(api_objects.PositionDateLayout, position.EndDate)
+	if startErr == nil && endErr == nil && end.Before(start) {
+		sl.ReportError(position.EndDate, "endDate", "EndDate", "gtefield", "startDate")
+	}
+}
+
+// ValidationErrorResponse is the body returned with a 422 when a payload fails validation.
+type ValidationErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields"`
+}
+
+// decodeAndValidate decodes the JSON request body into dst and validates it.
+// On failure it writes the error response (400 for malformed JSON, 422 for
+// validation failures) and returns false.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return validatePayload(w, r, dst)
+}
+
+// validatePayload validates an already decoded payload and writes a 422 response
+// listing every invalid field if validation fails.
+func validatePayload(w http.ResponseWriter, r *http.Request, payload interface{}) bool {
+	err := requestValidator.Struct(payload)
+	if err == nil {
+		return true
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		slog.ErrorContext(r.Context(), "Unexpected validation error", "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+
+	response := ValidationErrorResponse{
+		Error:  "Validation failed",
+		Fields: make(map[string]string, len(validationErrs)),
+	}
+	for _, fieldErr := range validationErrs {
+		response.Fields[validationFieldPath(fieldErr)] = validationMessage(fieldErr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(response)
+	return false
+}
+
+// validationFieldPath strips the top-level struct name from the namespace,
+// e.g. "UserViewUser.firstName" becomes "firstName" and nested slice
+// elements are reported as "job_links[1]".
+func validationFieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if idx := strings.Index(namespace, "."); idx >= 0 {
+		return namespace[idx+1:]
+	}
+	return fieldErr.Field()
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "e164":
+		return "must be a phone number in E.164 format, e.g. +14155552671"
+	case "numeric":
+		return "must contain only digits"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "fqdn":
+		return "must be a valid domain name"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+	case "min":
+		if fieldErr.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at least %s item(s)", fieldErr.Param())
+		}
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "max":
+		if fieldErr.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at most %s item(s)", fieldErr.Param())
+		}
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fieldErr.Param())
+	case "gtefield":
+		return fmt.Sprintf("must not be before %s", fieldErr.Param())
+	case "unique":
+		return "must not contain duplicates"
+	case "datetime":
+		return fmt.Sprintf("must be a date in the %s format", fieldErr.Param())
+	case "iso3166_1_alpha2":
+		return "must be a two-letter ISO 3166 country code"
+	default:
+		return fmt.Sprintf("failed the %q check", fieldErr.Tag())
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeValidationResponse(t *testing.T, rr *httptest.ResponseRecorder) ValidationErrorResponse {
+	t.Helper()
+	var resp ValidationErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode validation response %q: %v", rr.Body.String(), err)
+	}
+	return resp
+}
+
+func TestUserUpdateUserHandler_ValidationErrors(t *testing.T) {
+	token := "tok-validation-1"
+	hs := setupTestServer(10, token)
+
+	body := `{"firstName": "", "lastName": "Doe", "phoneNumber": "555-1234", "linkedIn": "not a url"}`
+	req := httptest.NewRequest(http.MethodPut, "/api/user/update", strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+
+	hs.UserUpdateUserHandler(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	resp := decodeValidationResponse(t, rr)
+	for _, field := range []string{"firstName", "phoneNumber", "linkedIn"} {
+		if _, ok := resp.Fields[field]; !ok {
+			t.Errorf("expected validation error for %s, got %v", field, resp.Fields)
+		}
+	}
+	if _, ok := resp.Fields["lastName"]; ok {
+		t.Errorf("did not expect validation error for lastName, got %v", resp.Fields)
+	}
+}
+
+func TestCandidateCreateReferralRequestHandler_ValidationErrors(t *testing.T) {
+	token := "tok-validation-2"
+	hs := setupTestServer(11, token)
+
+	body := `{"company_id": 0, "job_title": "Engineer", "job_links": ["https://example.com/job", "nope"], "referral_type": "Permanent"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/candidate/referral_request/create", strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+
+	hs.CandidateCreateReferralRequestHandler(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	resp := decodeValidationResponse(t, rr)
+	for _, field := range []string{"company_id", "job_links[1]", "referral_type"} {
+		if _, ok := resp.Fields[field]; !ok {
+			t.Errorf("expected validation error for %s, got %v", field, resp.Fields)
+		}
+	}
+}
+
+func TestUserCreateCompanyHandler_MalformedJSON(t *testing.T) {
+	token := "tok-validation-3"
+	hs := setupTestServer(12, token)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/company/create", strings.NewReader(`{"name":`))
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+
+	hs.UserCreateCompanyHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestEmailVerificationRequestHandler_InvalidEmail(t *testing.T) {
+	token := "tok-validation-4"
+	hs := setupTestServer(13, token)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/email-verification", strings.NewReader(`{"email": "not-an-email"}`))
+	req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+	rr := httptest.NewRecorder()
+
+	hs.EmailVerificationRequestHandler(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	resp := decodeValidationResponse(t, rr)
+	if _, ok := resp.Fields["email"]; !ok {
+		t.Errorf("expected validation error for email, got %v", resp.Fields)
+	}
+}
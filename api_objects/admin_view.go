@@ -0,0 +1,27 @@
+package api_objects
+
+// AdminView represents the fields that only admins will be able to see
+
+// AdminViewCompanyResponseStats is how quickly referrers at a company respond to referral
+// requests. The times are null when no request has been viewed or claimed.
+type AdminViewCompanyResponseStats struct {
+	CompanyId               uint64   `json:"company_id"`
+	CompanyName             string   `json:"company_name"`
+	Requests                int      `json:"requests"`
+	Viewed                  int      `json:"viewed"`
+	Claimed                 int      `json:"claimed"`
+	MedianHoursToFirstView  *float64 `json:"median_hours_to_first_view"`
+	AverageHoursToFirstView *float64 `json:"average_hours_to_first_view"`
+	MedianHoursToClaim      *float64 `json:"median_hours_to_claim"`
+	AverageHoursToClaim     *float64 `json:"average_hours_to_claim"`
+}
+
+// AdminViewCompanyMergeRequest names the company to merge the one in the path into.
+type AdminViewCompanyMergeRequest struct {
+	TargetCompanyId uint64 `json:"target_company_id" validate:"required"`
+}
+
+// AdminViewCompanyAlias is another name for a company.
+type AdminViewCompanyAlias struct {
+	Alias string `json:"alias" validate:"required,max=200"`
+}
@@ -24,6 +24,34 @@ func ConvertDbReferrerToCandidateViewReferrer(dbReferrer *database.Referrer) *Ca
 	}
 }
 
+// CandidateViewReferrerProfile is what candidates see of a company's referrers before asking
+// for a referral: what they can refer for, but not who they are.
+type CandidateViewReferrerProfile struct {
+	Team        string   `json:"team"`
+	Seniority   string   `json:"seniority"`
+	JobFamilies []string `json:"jobFamilies"`
+	Locations   []string `json:"locations"`
+	Available   bool     `json:"available"` // False while in vacation mode
+}
+
+func ConvertDbReferrerToCandidateViewReferrerProfile(dbReferrer *database.Referrer, now time.Time) CandidateViewReferrerProfile {
+	jobFamilies := make([]string, 0, len(dbReferrer.JobFamilies))
+	for _, family := range dbReferrer.JobFamilies {
+		jobFamilies = append(jobFamilies, string(family.JobFamily))
+	}
+	locations := make([]string, 0, len(dbReferrer.Locations))
+	for _, location := range dbReferrer.Locations {
+		locations = append(locations, location.Location)
+	}
+	return CandidateViewReferrerProfile{
+		Team:        dbReferrer.Team,
+		Seniority:   string(dbReferrer.Seniority),
+		JobFamilies: jobFamilies,
+		Locations:   locations,
+		Available:   dbReferrer.IsAvailable(now),
+	}
+}
+
 type CandidateViewCandidate struct {
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
@@ -39,15 +67,19 @@ func ConvertDbCandidateToCandidateViewCandidate(dbCandidate *database.Candidate)
 type CandidateViewReferralRequest struct {
 	ReferralRequestId      uint64                 `json:"id"`
 	Candidate              CandidateViewCandidate `json:"candidate"`
-	CompanyID              uint64                 `json:"company_id"`
+	CompanyID              uint64                 `json:"company_id" validate:"required"`
 	Company                GeneralViewCompany     `json:"company"`
-	PrimaryJobTitleSeeking string                 `json:"job_title"`
-	JobLinks               []string               `json:"job_links"`
-	Summary                string                 `json:"description"`
-	Locations              []string               `json:"locations"`
-	ReferralType           string                 `json:"referral_type"`
+	PrimaryJobTitleSeeking string                 `json:"job_title" validate:"required,max=200"`
+	JobLinks               []string               `json:"job_links" validate:"max=10,unique,dive,required,url"`
+	JobLinkDetails         []GeneralViewJobLink   `json:"job_link_details,omitempty"` // Read-only, the canonical links
+	Summary                string                 `json:"description" validate:"max=5000"`
+	Locations              []string               `json:"locations" validate:"max=10,unique,dive,required,max=100"`
+	LocationDetails        []GeneralViewLocation  `json:"location_details,omitempty"` // Read-only, where the locations were placed
+	ReferralType           string                 `json:"referral_type" validate:"required,oneof=Internship Full-Time Part-Time Contract"`
 	ReferrerViewReferrer   *CandidateViewReferrer `json:"referrer"`
 	Status                 string                 `json:"status"`
+	Visibility             string                 `json:"visibility,omitempty" validate:"omitempty,oneof=anonymous name_only full"` // Empty uses the candidate's visibility
+	JobPostingId           *uint64                `json:"job_posting_id,omitempty"`                                                 // Read-only, set when applied for through a job posting
 }
 
 func ConvertDbReferralRequestToCandidateViewReferralRequest(dbReferralRequest *database.ReferralRequest) *CandidateViewReferralRequest {
@@ -62,6 +94,11 @@ func ConvertDbReferralRequestToCandidateViewReferralRequest(dbReferralRequest *d
 		locations = append(locations, location.Location)
 	}
 
+	var visibility database.CandidateVisibility
+	if dbReferralRequest.Visibility != nil {
+		visibility = *dbReferralRequest.Visibility
+	}
+
 	return &CandidateViewReferralRequest{
 		ReferralRequestId:      dbReferralRequest.ReferralRequestId,
 		Candidate:              *ConvertDbCandidateToCandidateViewCandidate(&dbReferralRequest.Candidate),
@@ -69,11 +106,15 @@ func ConvertDbReferralRequestToCandidateViewReferralRequest(dbReferralRequest *d
 		Company:                *ConvertDbCompanyToGeneralViewCompany(&dbReferralRequest.Company),
 		PrimaryJobTitleSeeking: dbReferralRequest.PrimaryJobTitleSeeking,
 		JobLinks:               jobLinks,
+		JobLinkDetails:         ConvertDbJobLinksToGeneralViewJobLinks(dbReferralRequest.JobLinks),
 		Summary:                dbReferralRequest.Summary,
 		Locations:              locations,
+		LocationDetails:        ConvertDbLocationsToGeneralViewLocations(dbReferralRequest.Locations),
 		ReferralType:           string(dbReferralRequest.ReferralType),
 		ReferrerViewReferrer:   ConvertDbReferrerToCandidateViewReferrer(dbReferralRequest.Referrer),
 		Status:                 string(dbReferralRequest.Status),
+		Visibility:             string(visibility),
+		JobPostingId:           dbReferralRequest.JobPostingId,
 	}
 }
 
@@ -89,6 +130,12 @@ func ConvertCandidateViewReferralRequestToDbReferralRequest(candidateViewReferra
 		locations = append(locations, database.ReferralRequestLocationAssociation{Location: location})
 	}
 
+	var visibility *database.CandidateVisibility
+	if candidateViewReferralRequest.Visibility != "" {
+		v := database.CandidateVisibility(candidateViewReferralRequest.Visibility)
+		visibility = &v
+	}
+
 	return database.ReferralRequest{
 		ReferralRequestId:      candidateViewReferralRequest.ReferralRequestId,
 		CandidateID:            candidateId,
@@ -99,8 +146,47 @@ func ConvertCandidateViewReferralRequestToDbReferralRequest(candidateViewReferra
 		Locations:              locations,
 		ReferralType:           database.ReferralType(candidateViewReferralRequest.ReferralType),
 		Status:                 database.ReferralStatus(candidateViewReferralRequest.Status),
+		Visibility:             visibility,
 		CreatedAt:              createdAt,
 		UpdatedAt:              updatedAt,
-		DeletedAt:              deletedAt,
+		DeletedAt:              toDeletedAt(deletedAt),
 	}
 }
+
+// CandidateViewJobPosting is a job opening candidates can apply to for a referral. The referrer
+// who posted it is described the way the company's referrer directory does, without saying who
+// they are.
+type CandidateViewJobPosting struct {
+	JobPostingId uint64                       `json:"id"`
+	Company      GeneralViewCompany           `json:"company"`
+	Title        string                       `json:"title"`
+	Link         string                       `json:"link"`
+	Location     string                       `json:"location"`
+	ReferralType string                       `json:"referral_type"`
+	Description  string                       `json:"description"`
+	ExpiresAt    time.Time                    `json:"expires_at"`
+	PostedAt     time.Time                    `json:"posted_at"`
+	Referrer     CandidateViewReferrerProfile `json:"referrer"`
+}
+
+func ConvertDbJobPostingToCandidateViewJobPosting(dbJobPosting *database.JobPosting, now time.Time) CandidateViewJobPosting {
+	return CandidateViewJobPosting{
+		JobPostingId: dbJobPosting.JobPostingId,
+		Company:      *ConvertDbCompanyToGeneralViewCompany(&dbJobPosting.Company),
+		Title:        dbJobPosting.Title,
+		Link:         dbJobPosting.Link,
+		Location:     dbJobPosting.Location,
+		ReferralType: string(dbJobPosting.ReferralType),
+		Description:  dbJobPosting.Description,
+		ExpiresAt:    dbJobPosting.ExpiresAt,
+		PostedAt:     dbJobPosting.CreatedAt,
+		Referrer:     ConvertDbReferrerToCandidateViewReferrerProfile(&dbJobPosting.Referrer, now),
+	}
+}
+
+// CandidateViewJobPostingApplication is what a candidate adds when applying for a job posting;
+// the job itself comes from the posting.
+type CandidateViewJobPostingApplication struct {
+	Summary    string `json:"description" validate:"max=5000"`
+	Visibility string `json:"visibility,omitempty" validate:"omitempty,oneof=anonymous name_only full"` // Empty uses the candidate's visibility
+}
@@ -1,6 +1,12 @@
 package api_objects
 
-import "github.com/Suhaibinator/muslim-referrals-backend/database"
+import (
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+
+	"gorm.io/gorm"
+)
 
 // GeneralView represents the fields that the general user will be able to see
 
@@ -15,3 +21,46 @@ func ConvertDbCompanyToGeneralViewCompany(dbCompany *database.Company) *GeneralV
 		Name: dbCompany.Name,
 	}
 }
+
+// toDeletedAt converts the optional deletion time taken by the Convert functions into the
+// soft-delete column type used by the database models.
+func toDeletedAt(deletedAt *time.Time) gorm.DeletedAt {
+	if deletedAt == nil {
+		return gorm.DeletedAt{}
+	}
+	return gorm.DeletedAt{Time: *deletedAt, Valid: true}
+}
+
+// GeneralViewJobLink is a referral request's job link with the applicant tracking system and
+// job ID found in it, which referrers often need to submit the referral.
+type GeneralViewJobLink struct {
+	Link     string `json:"link"`
+	ATS      string `json:"ats,omitempty"`
+	ATSJobId string `json:"ats_job_id,omitempty"`
+}
+
+func ConvertDbJobLinksToGeneralViewJobLinks(dbJobLinks []database.ReferralRequestJobLinksAssociation) []GeneralViewJobLink {
+	jobLinks := make([]GeneralViewJobLink, 0, len(dbJobLinks))
+	for _, jobLink := range dbJobLinks {
+		jobLinks = append(jobLinks, GeneralViewJobLink{Link: jobLink.JobLink, ATS: string(jobLink.ATS), ATSJobId: jobLink.ATSJobId})
+	}
+	return jobLinks
+}
+
+// GeneralViewLocation is a referral request's location as entered, with the place it was
+// normalized to. The place fields are empty when the location couldn't be placed.
+type GeneralViewLocation struct {
+	Location string `json:"location"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Remote   bool   `json:"remote"`
+}
+
+func ConvertDbLocationsToGeneralViewLocations(dbLocations []database.ReferralRequestLocationAssociation) []GeneralViewLocation {
+	locations := make([]GeneralViewLocation, 0, len(dbLocations))
+	for _, location := range dbLocations {
+		locations = append(locations, GeneralViewLocation{Location: location.Location, City: location.City, Region: location.Region, Country: location.Country, Remote: location.Remote})
+	}
+	return locations
+}
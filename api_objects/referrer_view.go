@@ -1,23 +1,125 @@
 package api_objects
 
-import "github.com/Suhaibinator/muslim-referrals-backend/database"
+import (
+	"strings"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
 
 // ReferrerView represents the fields that the referrer will be able to see
 
+// ReferrerViewCandidate only carries what the candidate's visibility allows; the referrer who
+// claimed the request also gets the full profile and the candidate's contact details. Skills,
+// desired roles, work authorizations and preferences are shown at every visibility, while
+// education and past positions, which name schools and employers, need the full profile.
 type ReferrerViewCandidate struct {
-	FirstName      string `json:"firstName"`
-	LastName       string `json:"lastName"`
-	WorkExperience int    `json:"workExperience"`
-	ResumeUrl      string `json:"resumeUrl"`
+	Visibility         string                            `json:"visibility"`
+	FirstName          string                            `json:"firstName,omitempty"`
+	LastName           string                            `json:"lastName,omitempty"`
+	WorkExperience     int                               `json:"workExperience"`
+	Skills             []string                          `json:"skills,omitempty"`
+	DesiredRoles       []string                          `json:"desiredRoles,omitempty"`
+	WorkAuthorizations []ReferrerViewWorkAuthorization   `json:"workAuthorizations,omitempty"`
+	Preferences        *ReferrerViewCandidatePreferences `json:"preferences,omitempty"`
+	Education          []ReferrerViewEducation           `json:"education,omitempty"`
+	Positions          []ReferrerViewPosition            `json:"positions,omitempty"`
+	ResumeUrl          string                            `json:"resumeUrl,omitempty"`
+	LinkedIn           string                            `json:"linkedIn,omitempty"`
+	Github             string                            `json:"github,omitempty"`
+	Website            string                            `json:"website,omitempty"`
+	Email              string                            `json:"email,omitempty"`
+	PhoneNumber        string                            `json:"phoneNumber,omitempty"`
+	PhoneExt           string                            `json:"phoneExt,omitempty"`
+}
+
+type ReferrerViewWorkAuthorization struct {
+	Country          string `json:"country"`
+	NeedsSponsorship bool   `json:"needsSponsorship"`
+}
+
+type ReferrerViewCandidatePreferences struct {
+	RemotePreference string `json:"remotePreference"`
+	OpenToRelocation bool   `json:"openToRelocation"`
+}
+
+type ReferrerViewEducation struct {
+	School       string `json:"school"`
+	Degree       string `json:"degree,omitempty"`
+	FieldOfStudy string `json:"fieldOfStudy,omitempty"`
+	StartYear    *int   `json:"startYear,omitempty"`
+	EndYear      *int   `json:"endYear,omitempty"`
 }
 
-func ConvertDbCandidateToReferrerViewCandidate(dbCandidate *database.Candidate) *ReferrerViewCandidate {
-	return &ReferrerViewCandidate{
-		FirstName:      dbCandidate.User.FirstName,
-		LastName:       dbCandidate.User.LastName,
+type ReferrerViewPosition struct {
+	Title       string `json:"title"`
+	CompanyName string `json:"companyName"`
+	StartDate   string `json:"startDate"`
+	EndDate     string `json:"endDate,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func ConvertDbCandidateToReferrerViewCandidate(dbCandidate *database.Candidate, visibility database.CandidateVisibility, claimedByViewer bool) *ReferrerViewCandidate {
+	if claimedByViewer {
+		visibility = database.VisibilityFull
+	}
+	result := &ReferrerViewCandidate{
+		Visibility:     string(visibility),
 		WorkExperience: dbCandidate.WorkExperience,
-		ResumeUrl:      dbCandidate.ResumeUrl,
+		Skills:         dbCandidate.SkillNames(),
+		DesiredRoles:   dbCandidate.DesiredRoleTitles(),
+	}
+	for _, authorization := range dbCandidate.WorkAuthorizations {
+		result.WorkAuthorizations = append(result.WorkAuthorizations, ReferrerViewWorkAuthorization{
+			Country:          authorization.Country,
+			NeedsSponsorship: authorization.NeedsSponsorship,
+		})
+	}
+	if dbCandidate.Preferences != nil {
+		result.Preferences = &ReferrerViewCandidatePreferences{
+			RemotePreference: string(dbCandidate.Preferences.RemotePreference),
+			OpenToRelocation: dbCandidate.Preferences.OpenToRelocation,
+		}
+	}
+	switch visibility {
+	case database.VisibilityNameOnly, database.VisibilityFull:
+		result.FirstName = dbCandidate.User.FirstName
+		result.LastName = dbCandidate.User.LastName
+	default:
+		result.Visibility = string(database.VisibilityAnonymous)
+	}
+	if visibility == database.VisibilityFull {
+		user := ConvertUserToUserViewUser(dbCandidate.User)
+		result.ResumeUrl = dbCandidate.ResumeUrl
+		result.LinkedIn = user.LinkedIn
+		result.Github = user.Github
+		result.Website = user.Website
+		for _, education := range dbCandidate.Education {
+			result.Education = append(result.Education, ReferrerViewEducation{
+				School:       education.School,
+				Degree:       education.Degree,
+				FieldOfStudy: education.FieldOfStudy,
+				StartYear:    education.StartYear,
+				EndYear:      education.EndYear,
+			})
+		}
+		for _, position := range dbCandidate.Positions {
+			view := ConvertCandidatePositionToUserViewPosition(position)
+			result.Positions = append(result.Positions, ReferrerViewPosition{
+				Title:       view.Title,
+				CompanyName: view.CompanyName,
+				StartDate:   view.StartDate,
+				EndDate:     view.EndDate,
+				Description: view.Description,
+			})
+		}
 	}
+	if claimedByViewer {
+		result.Email = dbCandidate.User.Email
+		result.PhoneNumber = dbCandidate.User.PhoneNumber
+		result.PhoneExt = dbCandidate.User.PhoneExt
+	}
+	return result
 }
 
 type ReferrerViewReferrer struct {
@@ -44,14 +146,32 @@ type ReferrerViewReferralRequest struct {
 	Company                GeneralViewCompany    `json:"company"`
 	PrimaryJobTitleSeeking string                `json:"job_title"`
 	JobLinks               []string              `json:"job_links"`
+	JobLinkDetails         []GeneralViewJobLink  `json:"job_link_details"`
 	Summary                string                `json:"description"`
 	Locations              []string              `json:"locations"`
+	LocationDetails        []GeneralViewLocation `json:"location_details"`
 	ReferralType           string                `json:"referral_type"`
 	ReferrerViewReferrer   *ReferrerViewReferrer `json:"referrer"`
 	Status                 string                `json:"status"`
 }
 
-func ConvertDbReferralRequestToReferrerViewReferralRequest(dbReferralRequest *database.ReferralRequest) *ReferrerViewReferralRequest {
+// ReferrerViewRecommendation is an open referral request recommended to the referrer, with the
+// signals it was ranked by.
+type ReferrerViewRecommendation struct {
+	ReferralRequest    ReferrerViewReferralRequest `json:"referral_request"`
+	Score              float64                     `json:"score"`
+	MatchedJobFamilies []string                    `json:"matched_job_families"`
+	TeamMatch          bool                        `json:"team_match"`
+	MatchedLocations   []string                    `json:"matched_locations"`
+	ReferralTypeMatch  bool                        `json:"referral_type_match"`
+	AgeDays            int                         `json:"age_days"`
+	OtherViewers       int64                       `json:"other_viewers"`
+	Reasons            []string                    `json:"reasons"`
+}
+
+// ConvertDbReferralRequestToReferrerViewReferralRequest converts a referral request for the
+// referrer viewerReferrerId, hiding what the candidate chose not to show them.
+func ConvertDbReferralRequestToReferrerViewReferralRequest(dbReferralRequest *database.ReferralRequest, viewerReferrerId uint64) *ReferrerViewReferralRequest {
 
 	var jobLinks []string
 	for _, jobLink := range dbReferralRequest.JobLinks {
@@ -63,17 +183,66 @@ func ConvertDbReferralRequestToReferrerViewReferralRequest(dbReferralRequest *da
 		locations = append(locations, location.Location)
 	}
 
+	claimedByViewer := dbReferralRequest.ReferrerId != nil && *dbReferralRequest.ReferrerId == viewerReferrerId
+
 	return &ReferrerViewReferralRequest{
 		ReferralRequestId:      dbReferralRequest.ReferralRequestId,
-		Candidate:              *ConvertDbCandidateToReferrerViewCandidate(&dbReferralRequest.Candidate),
+		Candidate:              *ConvertDbCandidateToReferrerViewCandidate(&dbReferralRequest.Candidate, dbReferralRequest.EffectiveVisibility(), claimedByViewer),
 		CompanyID:              dbReferralRequest.CompanyID,
 		Company:                *ConvertDbCompanyToGeneralViewCompany(&dbReferralRequest.Company),
 		PrimaryJobTitleSeeking: dbReferralRequest.PrimaryJobTitleSeeking,
 		JobLinks:               jobLinks,
+		JobLinkDetails:         ConvertDbJobLinksToGeneralViewJobLinks(dbReferralRequest.JobLinks),
 		Summary:                dbReferralRequest.Summary,
 		Locations:              locations,
+		LocationDetails:        ConvertDbLocationsToGeneralViewLocations(dbReferralRequest.Locations),
 		ReferralType:           string(dbReferralRequest.ReferralType),
 		ReferrerViewReferrer:   ConvertDbReferrerToReferrerViewReferrer(dbReferralRequest.Referrer),
 		Status:                 string(dbReferralRequest.Status),
 	}
 }
+
+// ReferrerViewJobPosting is a job opening as the referrer who posted it sees and edits it.
+// Leaving out expires_at gives the posting the default lifetime.
+type ReferrerViewJobPosting struct {
+	JobPostingId uint64             `json:"id"`
+	Company      GeneralViewCompany `json:"company"`
+	Title        string             `json:"title" validate:"required,max=200"`
+	Link         string             `json:"link" validate:"required,url,max=2000"`
+	Location     string             `json:"location" validate:"max=100"`
+	ReferralType string             `json:"referral_type" validate:"required,oneof=Internship Full-Time Part-Time Contract"`
+	Description  string             `json:"description" validate:"max=5000"`
+	ExpiresAt    *time.Time         `json:"expires_at,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+func ConvertDbJobPostingToReferrerViewJobPosting(dbJobPosting *database.JobPosting) *ReferrerViewJobPosting {
+	expiresAt := dbJobPosting.ExpiresAt
+	return &ReferrerViewJobPosting{
+		JobPostingId: dbJobPosting.JobPostingId,
+		Company:      *ConvertDbCompanyToGeneralViewCompany(&dbJobPosting.Company),
+		Title:        dbJobPosting.Title,
+		Link:         dbJobPosting.Link,
+		Location:     dbJobPosting.Location,
+		ReferralType: string(dbJobPosting.ReferralType),
+		Description:  dbJobPosting.Description,
+		ExpiresAt:    &expiresAt,
+		CreatedAt:    dbJobPosting.CreatedAt,
+	}
+}
+
+func ConvertReferrerViewJobPostingToDbJobPosting(jobPosting ReferrerViewJobPosting) database.JobPosting {
+	var expiresAt time.Time
+	if jobPosting.ExpiresAt != nil {
+		expiresAt = *jobPosting.ExpiresAt
+	}
+	return database.JobPosting{
+		JobPostingId: jobPosting.JobPostingId,
+		Title:        strings.TrimSpace(jobPosting.Title),
+		Link:         strings.TrimSpace(jobPosting.Link),
+		Location:     strings.TrimSpace(jobPosting.Location),
+		ReferralType: database.ReferralType(jobPosting.ReferralType),
+		Description:  jobPosting.Description,
+		ExpiresAt:    expiresAt,
+	}
+}
@@ -1,6 +1,7 @@
 package api_objects
 
 import (
+	"strings"
 	"time"
 
 	"github.com/Suhaibinator/muslim-referrals-backend/database"
@@ -10,14 +11,14 @@ import (
 
 type UserViewUser struct {
 	Id          uint64 `json:"id"`
-	FirstName   string `json:"firstName"`
-	LastName    string `json:"lastName"`
-	Email       string `json:"email"`
-	PhoneNumber string `json:"phoneNumber"`
-	PhoneExt    string `json:"phoneExt"`
-	LinkedIn    string `json:"linkedIn"`
-	Github      string `json:"github"`
-	Website     string `json:"website"`
+	FirstName   string `json:"firstName" validate:"required,max=100"`
+	LastName    string `json:"lastName" validate:"required,max=100"`
+	Email       string `json:"email" validate:"omitempty,email"`
+	PhoneNumber string `json:"phoneNumber" validate:"omitempty,e164"`
+	PhoneExt    string `json:"phoneExt" validate:"omitempty,numeric,max=10"`
+	LinkedIn    string `json:"linkedIn" validate:"omitempty,url"`
+	Github      string `json:"github" validate:"omitempty,url"`
+	Website     string `json:"website" validate:"omitempty,url"`
 }
 
 func ConvertUserToUserViewUser(user database.User) UserViewUser {
@@ -67,14 +68,15 @@ func ConvertUserViewUserToUser(user UserViewUser, createdAt, updatedAt time.Time
 		Website:     Website,
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
-		DeletedAt:   deletedAt,
+		DeletedAt:   toDeletedAt(deletedAt),
 	}
 }
 
 type UserViewCompany struct {
 	Id      uint64   `json:"id"`
-	Name    string   `json:"name"`
-	Domains []string `json:"domains"`
+	Name    string   `json:"name" validate:"required,max=200"`
+	Domains []string `json:"domains" validate:"omitempty,max=20,unique,dive,required,fqdn"`
+	Aliases []string `json:"aliases,omitempty"` // Read-only, other names the company goes by
 }
 
 func ConvertUserViewCompanyToCompany(company UserViewCompany, userid uint64, createdAt, updatedAt time.Time, deletedAt *time.Time) database.Company {
@@ -90,7 +92,7 @@ func ConvertUserViewCompanyToCompany(company UserViewCompany, userid uint64, cre
 		IsSupported:   false,
 		CreatedAt:     createdAt,
 		UpdatedAt:     updatedAt,
-		DeletedAt:     deletedAt,
+		DeletedAt:     toDeletedAt(deletedAt),
 	}
 }
 
@@ -99,46 +101,106 @@ func ConvertCompanyToUserViewCompany(company database.Company) UserViewCompany {
 	for _, domain := range company.Domains {
 		domains = append(domains, domain.Domain)
 	}
+	var aliases []string
+	for _, alias := range company.Aliases {
+		aliases = append(aliases, alias.Alias)
+	}
 	return UserViewCompany{
 		Id:      company.Id,
 		Name:    company.Name,
 		Domains: domains,
+		Aliases: aliases,
 	}
 }
 
+// UserViewCompanySuggestion is an existing company that a company being created may duplicate.
+type UserViewCompanySuggestion struct {
+	Company UserViewCompany `json:"company"`
+	Reason  string          `json:"reason"`  // domain, name, alias or similar_name
+	Matched string          `json:"matched"` // The domain, name or alias that matched
+	Score   float64         `json:"score"`   // 1 for exact matches, less for similar names
+}
+
+// UserViewCompanyConflict is the response when a company isn't created because it may already
+// exist, with the companies it may duplicate.
+type UserViewCompanyConflict struct {
+	Error       string                      `json:"error"`
+	Suggestions []UserViewCompanySuggestion `json:"suggestions"`
+}
+
 type UserViewReferrer struct {
-	ReferrerId     uint64 `json:"id"`
-	UserId         uint64 `json:"userId"`
-	CompanyId      uint64 `json:"companyId"`
-	CorporateEmail string `json:"corporateEmail"`
+	ReferrerId       uint64     `json:"id"`
+	UserId           uint64     `json:"userId"`
+	CompanyId        uint64     `json:"companyId" validate:"required"`
+	CorporateEmail   string     `json:"corporateEmail" validate:"omitempty,email"`
+	Team             string     `json:"team" validate:"max=100"`
+	Seniority        string     `json:"seniority" validate:"omitempty,oneof=junior mid senior staff principal manager director executive"`
+	JobFamilies      []string   `json:"jobFamilies" validate:"max=12,unique,dive,oneof=engineering data product design marketing sales operations finance people legal support other"`
+	Locations        []string   `json:"locations" validate:"max=20,unique,dive,required,max=100"`
+	WeeklyCapacity   int        `json:"weeklyCapacity" validate:"min=0,max=100"` // 0 for no limit
+	UnavailableUntil *time.Time `json:"unavailableUntil,omitempty"`              // Read-only, set through the availability endpoint
 }
 
 func ConvertReferrerToUserViewReferrer(referrer database.Referrer) UserViewReferrer {
+	jobFamilies := make([]string, 0, len(referrer.JobFamilies))
+	for _, family := range referrer.JobFamilies {
+		jobFamilies = append(jobFamilies, string(family.JobFamily))
+	}
+	locations := make([]string, 0, len(referrer.Locations))
+	for _, location := range referrer.Locations {
+		locations = append(locations, location.Location)
+	}
 	return UserViewReferrer{
-		ReferrerId:     referrer.ReferrerId,
-		UserId:         referrer.UserId,
-		CompanyId:      referrer.CompanyId,
-		CorporateEmail: referrer.CorporateEmail,
+		ReferrerId:       referrer.ReferrerId,
+		UserId:           referrer.UserId,
+		CompanyId:        referrer.CompanyId,
+		CorporateEmail:   referrer.CorporateEmail,
+		Team:             referrer.Team,
+		Seniority:        string(referrer.Seniority),
+		JobFamilies:      jobFamilies,
+		Locations:        locations,
+		WeeklyCapacity:   referrer.WeeklyCapacity,
+		UnavailableUntil: referrer.UnavailableUntil,
 	}
 }
 
 func ConvertUserViewReferrerToReferrer(referrer UserViewReferrer, userId uint64, createdAt, updatedAt time.Time, deletedAt *time.Time) database.Referrer {
+	jobFamilies := make([]database.ReferrerJobFamily, 0, len(referrer.JobFamilies))
+	for _, family := range referrer.JobFamilies {
+		jobFamilies = append(jobFamilies, database.ReferrerJobFamily{ReferrerId: referrer.ReferrerId, JobFamily: database.JobFamily(family)})
+	}
+	locations := make([]database.ReferrerLocation, 0, len(referrer.Locations))
+	for _, location := range referrer.Locations {
+		locations = append(locations, database.ReferrerLocation{ReferrerId: referrer.ReferrerId, Location: strings.TrimSpace(location)})
+	}
 	return database.Referrer{
 		ReferrerId:     referrer.ReferrerId,
 		UserId:         userId,
 		CompanyId:      referrer.CompanyId,
 		CorporateEmail: referrer.CorporateEmail,
+		Team:           strings.TrimSpace(referrer.Team),
+		Seniority:      database.Seniority(referrer.Seniority),
+		JobFamilies:    jobFamilies,
+		Locations:      locations,
+		WeeklyCapacity: referrer.WeeklyCapacity,
 		CreatedAt:      createdAt,
 		UpdatedAt:      updatedAt,
-		DeletedAt:      deletedAt,
+		DeletedAt:      toDeletedAt(deletedAt),
 	}
 }
 
+// UserViewReferrerAvailability turns vacation mode on until the given time, or off when it's
+// left out.
+type UserViewReferrerAvailability struct {
+	UnavailableUntil *time.Time `json:"unavailableUntil"`
+}
+
 type UserViewCandidate struct {
 	CandidateId    uint64 `json:"id"`
 	UserId         uint64 `json:"userId"`
-	WorkExperience int    `json:"workExperience"`
-	ResumeUrl      string `json:"resumeUrl"`
+	WorkExperience int    `json:"workExperience" validate:"min=0,max=80"`
+	ResumeUrl      string `json:"resumeUrl" validate:"omitempty,url"`
+	Visibility     string `json:"visibility" validate:"omitempty,oneof=anonymous name_only full"`
 }
 
 func ConvertCandidateToUserViewCandidate(candidate database.Candidate) UserViewCandidate {
@@ -147,6 +209,7 @@ func ConvertCandidateToUserViewCandidate(candidate database.Candidate) UserViewC
 		UserId:         candidate.UserId,
 		WorkExperience: candidate.WorkExperience,
 		ResumeUrl:      candidate.ResumeUrl,
+		Visibility:     string(candidate.Visibility),
 	}
 }
 
@@ -156,8 +219,176 @@ func ConvertUserViewCandidateToCandidate(candidate UserViewCandidate, userId uin
 		UserId:         userId,
 		WorkExperience: candidate.WorkExperience,
 		ResumeUrl:      candidate.ResumeUrl,
+		Visibility:     database.CandidateVisibility(candidate.Visibility),
 		CreatedAt:      createdAt,
 		UpdatedAt:      updatedAt,
-		DeletedAt:      deletedAt,
+		DeletedAt:      toDeletedAt(deletedAt),
+	}
+}
+
+// UserViewCandidateProfile is the rest of the candidate's profile, edited piece by piece.
+type UserViewCandidateProfile struct {
+	Skills             []string                     `json:"skills"`
+	Education          []UserViewEducation          `json:"education"`
+	Positions          []UserViewPosition           `json:"positions"`
+	DesiredRoles       []string                     `json:"desiredRoles"`
+	WorkAuthorizations []UserViewWorkAuthorization  `json:"workAuthorizations"`
+	Preferences        UserViewCandidatePreferences `json:"preferences"`
+}
+
+func ConvertCandidateToUserViewCandidateProfile(candidate database.Candidate) UserViewCandidateProfile {
+	profile := UserViewCandidateProfile{
+		Skills:             make([]string, 0, len(candidate.Skills)),
+		Education:          make([]UserViewEducation, 0, len(candidate.Education)),
+		Positions:          make([]UserViewPosition, 0, len(candidate.Positions)),
+		DesiredRoles:       make([]string, 0, len(candidate.DesiredRoles)),
+		WorkAuthorizations: make([]UserViewWorkAuthorization, 0, len(candidate.WorkAuthorizations)),
+		Preferences:        ConvertCandidatePreferencesToUserViewCandidatePreferences(candidate.Preferences),
+	}
+	profile.Skills = append(profile.Skills, candidate.SkillNames()...)
+	profile.DesiredRoles = append(profile.DesiredRoles, candidate.DesiredRoleTitles()...)
+	for _, education := range candidate.Education {
+		profile.Education = append(profile.Education, ConvertCandidateEducationToUserViewEducation(education))
+	}
+	for _, position := range candidate.Positions {
+		profile.Positions = append(profile.Positions, ConvertCandidatePositionToUserViewPosition(position))
+	}
+	for _, authorization := range candidate.WorkAuthorizations {
+		profile.WorkAuthorizations = append(profile.WorkAuthorizations, UserViewWorkAuthorization{
+			Country:          authorization.Country,
+			NeedsSponsorship: authorization.NeedsSponsorship,
+		})
+	}
+	return profile
+}
+
+type UserViewCandidateSkills struct {
+	Skills []string `json:"skills" validate:"max=50,dive,required,max=50"`
+}
+
+type UserViewCandidateDesiredRoles struct {
+	DesiredRoles []string `json:"desiredRoles" validate:"max=10,unique,dive,required,max=200"`
+}
+
+type UserViewEducation struct {
+	Id           uint64 `json:"id"`
+	School       string `json:"school" validate:"required,max=200"`
+	Degree       string `json:"degree" validate:"max=100"`
+	FieldOfStudy string `json:"fieldOfStudy" validate:"max=100"`
+	StartYear    *int   `json:"startYear,omitempty" validate:"omitempty,min=1900,max=2100"`
+	EndYear      *int   `json:"endYear,omitempty" validate:"omitempty,min=1900,max=2100"`
+}
+
+func ConvertCandidateEducationToUserViewEducation(education database.CandidateEducation) UserViewEducation {
+	return UserViewEducation{
+		Id:           education.Id,
+		School:       education.School,
+		Degree:       education.Degree,
+		FieldOfStudy: education.FieldOfStudy,
+		StartYear:    education.StartYear,
+		EndYear:      education.EndYear,
+	}
+}
+
+func ConvertUserViewEducationToCandidateEducation(education UserViewEducation, candidateId uint64) database.CandidateEducation {
+	return database.CandidateEducation{
+		Id:           education.Id,
+		CandidateId:  candidateId,
+		School:       education.School,
+		Degree:       education.Degree,
+		FieldOfStudy: education.FieldOfStudy,
+		StartYear:    education.StartYear,
+		EndYear:      education.EndYear,
+	}
+}
+
+// PositionDateLayout is the layout of position dates, which only have a year and month.
+const PositionDateLayout = "2006-01"
+
+type UserViewPosition struct {
+	Id          uint64 `json:"id"`
+	Title       string `json:"title" validate:"required,max=200"`
+	CompanyName string `json:"companyName" validate:"required,max=200"`
+	StartDate   string `json:"startDate" validate:"required,datetime=2006-01"`
+	EndDate     string `json:"endDate,omitempty" validate:"omitempty,datetime=2006-01"` // Empty for the current position
+	Description string `json:"description" validate:"max=2000"`
+}
+
+func ConvertCandidatePositionToUserViewPosition(position database.CandidatePosition) UserViewPosition {
+	var endDate string
+	if position.EndDate != nil {
+		endDate = position.EndDate.Format(PositionDateLayout)
+	}
+	return UserViewPosition{
+		Id:          position.Id,
+		Title:       position.Title,
+		CompanyName: position.CompanyName,
+		StartDate:   position.StartDate.Format(PositionDateLayout),
+		EndDate:     endDate,
+		Description: position.Description,
+	}
+}
+
+// ConvertUserViewPositionToCandidatePosition expects the dates to have been validated.
+func ConvertUserViewPositionToCandidatePosition(position UserViewPosition, candidateId uint64) database.CandidatePosition {
+	startDate, _ := time.Parse(PositionDateLayout, position.StartDate)
+	var endDate *time.Time
+	if position.EndDate != "" {
+		parsed, _ := time.Parse(PositionDateLayout, position.EndDate)
+		endDate = &parsed
+	}
+	return database.CandidatePosition{
+		Id:          position.Id,
+		CandidateId: candidateId,
+		Title:       position.Title,
+		CompanyName: position.CompanyName,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Description: position.Description,
+	}
+}
+
+type UserViewWorkAuthorization struct {
+	Country          string `json:"country" validate:"required,iso3166_1_alpha2"`
+	NeedsSponsorship bool   `json:"needsSponsorship"`
+}
+
+type UserViewCandidateWorkAuthorizations struct {
+	WorkAuthorizations []UserViewWorkAuthorization `json:"workAuthorizations" validate:"max=50,unique=Country,dive"`
+}
+
+func ConvertUserViewWorkAuthorizationsToCandidateWorkAuthorizations(authorizations []UserViewWorkAuthorization) []database.CandidateWorkAuthorization {
+	result := make([]database.CandidateWorkAuthorization, 0, len(authorizations))
+	for _, authorization := range authorizations {
+		result = append(result, database.CandidateWorkAuthorization{
+			Country:          authorization.Country,
+			NeedsSponsorship: authorization.NeedsSponsorship,
+		})
+	}
+	return result
+}
+
+type UserViewCandidatePreferences struct {
+	RemotePreference string `json:"remotePreference" validate:"required,oneof=any onsite hybrid remote"`
+	OpenToRelocation bool   `json:"openToRelocation"`
+}
+
+// ConvertCandidatePreferencesToUserViewCandidatePreferences returns the defaults for a candidate
+// who hasn't set their preferences.
+func ConvertCandidatePreferencesToUserViewCandidatePreferences(preferences *database.CandidatePreferences) UserViewCandidatePreferences {
+	if preferences == nil {
+		return UserViewCandidatePreferences{RemotePreference: string(database.RemoteAny)}
+	}
+	return UserViewCandidatePreferences{
+		RemotePreference: string(preferences.RemotePreference),
+		OpenToRelocation: preferences.OpenToRelocation,
+	}
+}
+
+func ConvertUserViewCandidatePreferencesToCandidatePreferences(preferences UserViewCandidatePreferences, candidateId uint64) database.CandidatePreferences {
+	return database.CandidatePreferences{
+		CandidateId:      candidateId,
+		RemotePreference: database.RemotePreference(preferences.RemotePreference),
+		OpenToRelocation: preferences.OpenToRelocation,
 	}
 }
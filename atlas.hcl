@@ -1,3 +1,6 @@
+// One environment per database dialect, each with its own migration directory:
+//   atlas migrate diff --env gorm           (SQLite)
+//   atlas migrate diff --env gorm_postgres  (PostgreSQL, DATABASE_URL must be set)
 data "external_schema" "gorm" {
   program = [
     "go",
@@ -6,7 +9,18 @@ data "external_schema" "gorm" {
     "ariga.io/atlas-provider-gorm",
     "load",
     "--path", "./database",
-    "--dialect", "sqlite", // | postgres | sqlite | sqlserver
+    "--dialect", "sqlite",
+  ]
+}
+data "external_schema" "gorm_postgres" {
+  program = [
+    "go",
+    "run",
+    "-mod=mod",
+    "ariga.io/atlas-provider-gorm",
+    "load",
+    "--path", "./database",
+    "--dialect", "postgres",
   ]
 }
 env "gorm" {
@@ -15,11 +29,27 @@ env "gorm" {
   dev = "sqlite://file?mode=memory"
 
   migration {
-    dir = "file://./migrations"
+    dir = "file://./migrations/sqlite"
   }
   format {
     migrate {
       diff = "{{ sql . \"  \" }}"
     }
   }
-}
\ No newline at end of file
+}
+env "gorm_postgres" {
+  src = data.external_schema.gorm_postgres.url
+  // Bind the URL to a schema (e.g. ?search_path=public) so the migrations and the
+  // atlas_schema_revisions table are created in it
+  url = getenv("DATABASE_URL")
+  dev = "docker://postgres/16/dev?search_path=public"
+
+  migration {
+    dir = "file://./migrations/postgres"
+  }
+  format {
+    migrate {
+      diff = "{{ sql . \"  \" }}"
+    }
+  }
+}
@@ -0,0 +1,51 @@
+// Package buildinfo reports which build of the server is running.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// GitSHA and BuildTime are set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/Suhaibinator/muslim-referrals-backend/buildinfo.GitSHA=$(git rev-parse HEAD) \
+//		-X github.com/Suhaibinator/muslim-referrals-backend/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When they aren't, the VCS information recorded by the Go toolchain is used if available.
+var (
+	GitSHA    string
+	BuildTime string
+)
+
+type Info struct {
+	GitSHA    string `json:"git_sha"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+func Get() Info {
+	info := Info{
+		GitSHA:    GitSHA,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.GitSHA == "":
+				info.GitSHA = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+
+	if info.GitSHA == "" {
+		info.GitSHA = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
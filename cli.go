@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/logging"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+)
+
+const usage = `usage: muslim-referrals [command] [flags]
+
+Commands:
+  serve    run the HTTP server (the default when no command is given)
+  migrate  show, apply or revert database migrations
+  seed     fill an empty database with realistic fake data
+  user     grant or revoke admin rights
+  company  merge duplicate companies
+  export   print everything stored about a user as JSON
+  reap     run the expiry jobs once
+
+Every command accepts the server's flags, e.g. -config or -db.
+Run "muslim-referrals <command> -h" for details.`
+
+// commands maps each subcommand to its implementation, which returns the process exit code.
+var commands = map[string]func(args []string) int{
+	"serve":   runServe,
+	"migrate": runMigrate,
+	"seed":    runSeed,
+	"user":    runUser,
+	"company": runCompany,
+	"export":  runExport,
+	"reap":    runReap,
+}
+
+// run dispatches to the subcommand named by args[0] and returns the process exit code.
+func run(args []string) int {
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && !isHelpFlag(args[0])) {
+		// Without a command the server runs, as it did before there were subcommands
+		return runServe(args)
+	}
+	if args[0] == "help" || isHelpFlag(args[0]) {
+		fmt.Println(usage)
+		return 0
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s\n", args[0], usage)
+		return 2
+	}
+	return command(args[1:])
+}
+
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// loadCommandConfig parses args into the command's own flags, defined on fs, and the server's
+// configuration flags, then sets up logging. On failure it reports the problem and returns a nil
+// config along with the exit code to use.
+func loadCommandConfig(fs *flag.FlagSet, args []string, commandUsage string) (*config.Config, int) {
+	fs.SetOutput(io.Discard)
+	cfg, err := config.LoadWithFlags(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Println(commandUsage)
+		return nil, 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n\n%s\n", err, commandUsage)
+		return nil, 2
+	}
+	if err := logging.Setup(os.Stderr, cfg.Logging.Level); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %v\n", err)
+		return nil, 1
+	}
+	return cfg, 0
+}
+
+// openDatabase connects to the configured database and makes sure its schema matches this build,
+// applying pending migrations if auto_migrate is on.
+func openDatabase(ctx context.Context, cfg *config.Config) (*database.DbDriver, error) {
+	db := database.NewDbDriver(cfg.Database)
+	migrator, err := db.Migrator()
+	if err != nil {
+		db.CloseDatabase()
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	// Refuses a schema this build doesn't match (pending, failed, edited or newer migrations)
+	if err := migrator.EnsureSchema(ctx, cfg.Database.AutoMigrate); err != nil {
+		db.CloseDatabase()
+		return nil, fmt.Errorf("database schema check failed: %w", err)
+	}
+	return db, nil
+}
+
+// newCommandService builds the service for a one-off command. Commands never send email.
+func newCommandService(cfg *config.Config, db *database.DbDriver) *service.Service {
+	return service.NewService(cfg, service.NewDatabaseOperations(db), nil)
+}
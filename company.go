@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const companyUsage = `usage: muslim-referrals company merge [flags] <source-id> <target-id>
+
+  merge  move the source company's referrers, referral requests, job postings, domains and
+         aliases to the target company, keep its name as an alias of the target and delete the
+         source, in one transaction
+
+Flags are the same as the server's.`
+
+// runCompany implements the company subcommand and returns the process exit code.
+func runCompany(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, companyUsage)
+		return 2
+	}
+	if isHelpFlag(args[0]) {
+		fmt.Println(companyUsage)
+		return 0
+	}
+	if args[0] != "merge" {
+		fmt.Fprintln(os.Stderr, companyUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("company", flag.ContinueOnError)
+	cfg, code := loadCommandConfig(fs, args[1:], companyUsage)
+	if cfg == nil {
+		return code
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, companyUsage)
+		return 2
+	}
+	sourceID, sourceErr := strconv.ParseUint(fs.Arg(0), 10, 64)
+	targetID, targetErr := strconv.ParseUint(fs.Arg(1), 10, 64)
+	if sourceErr != nil || targetErr != nil {
+		fmt.Fprintf(os.Stderr, "Company IDs must be numbers\n\n%s\n", companyUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.CloseDatabase()
+
+	result, err := newCommandService(cfg, db).MergeCompanies(ctx, sourceID, targetID, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to merge companies: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Merged company %d into %d: moved %d referrers, %d referral requests, %d job postings, %d domains and %d aliases\n",
+		sourceID, targetID, result.Referrers, result.ReferralRequests, result.JobPostings, result.Domains, result.Aliases)
+	return 0
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "8080", cfg.Server.Port)
+	assert.Equal(t, "https://muslimreferrals.xyz/login", cfg.OAuth.RedirectURL)
+	assert.Equal(t, 24*time.Hour, cfg.Verification.TTL)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := `
+server:
+  port: "9000"
+  base_url: https://file.example.com
+verification:
+  ttl: 2h
+  max_active_per_user: 5
+`
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	t.Setenv("BASE_URL", "https://env.example.com")
+	t.Setenv("MAX_ACTIVE_VERIFICATIONS_PER_USER", "7")
+
+	cfg, err := Load([]string{"-config", path, "-port", "9100"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "9100", cfg.Server.Port, "flag overrides file")
+	assert.Equal(t, "https://env.example.com", cfg.Server.BaseURL, "env overrides file")
+	assert.Equal(t, 2*time.Hour, cfg.Verification.TTL, "file overrides default")
+	assert.Equal(t, 7, cfg.Verification.MaxActivePerUser)
+	assert.Equal(t, "https://env.example.com/login", cfg.OAuth.RedirectURL)
+}
+
+func TestLoadWithFlags_CommandFlags(t *testing.T) {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	users := fs.Int("users", 10, "")
+
+	cfg, err := LoadWithFlags(fs, []string{"-users", "3", "-db", "seed.db", "extra"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, *users)
+	assert.Equal(t, "seed.db", cfg.Database.Path)
+	assert.Equal(t, []string{"extra"}, fs.Args())
+}
+
+func TestLoad_LegacyGoogleRedirectURL(t *testing.T) {
+	t.Setenv("GOOGLE_REDIRECT_URL", "https://legacy.example.com")
+
+	cfg, err := Load(nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://legacy.example.com/login", cfg.OAuth.RedirectURL)
+}
+
+func TestLoad_UnknownFileField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  prot: \"80\"\n"), 0o600))
+
+	_, err := Load([]string{"-config", path})
+
+	assert.Error(t, err)
+}
+
+func TestLoad_InvalidEnv(t *testing.T) {
+	t.Setenv("EMAIL_VERIFICATION_TTL", "tomorrow")
+
+	_, err := Load(nil)
+
+	assert.ErrorContains(t, err, "EMAIL_VERIFICATION_TTL")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = "http"
+	cfg.Server.BaseURL = "muslimreferrals.xyz"
+	cfg.Server.TrustedProxies = []string{"not-an-ip"}
+	cfg.RateLimits.API.Window = 0
+	cfg.Database.Driver = "mysql"
+	cfg.JobPostings.MaxLifetime = time.Hour
+
+	err := cfg.Validate()
+
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "server.port")
+	assert.ErrorContains(t, err, "server.base_url")
+	assert.ErrorContains(t, err, "server.trusted_proxies")
+	assert.ErrorContains(t, err, "rate_limits.api")
+	assert.ErrorContains(t, err, "database.driver")
+	assert.ErrorContains(t, err, "job_postings")
+}
+
+func TestValidate_PostgresRequiresURL(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Driver = DatabaseDriverPostgres
+
+	assert.ErrorContains(t, cfg.Validate(), "database.url")
+
+	cfg.Database.URL = "postgres://app@localhost:5432/muslim_referrals"
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AccountDeletionStatus int
+
+const (
+	AccountDeletionPending   AccountDeletionStatus = iota // Waiting for the user to confirm by email
+	AccountDeletionScheduled                              // Confirmed, the account is erased at ScheduledFor
+)
+
+func (s AccountDeletionStatus) String() string {
+	switch s {
+	case AccountDeletionPending:
+		return "Pending"
+	case AccountDeletionScheduled:
+		return "Scheduled"
+	}
+	return fmt.Sprintf("AccountDeletionStatus(%d)", int(s))
+}
+
+// AccountDeletion is a user's request to delete their account. There is at most one per user;
+// cancelling it deletes the row, and so does carrying it out.
+type AccountDeletion struct {
+	UserID           uint64                `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	User             User                  `gorm:"foreignKey:UserID;references:Id" json:"-"`
+	ConfirmationCode string                `gorm:"not null;uniqueIndex" json:"-"`
+	Status           AccountDeletionStatus `gorm:"not null;default:0" json:"status"`
+	RequestedAt      time.Time             `gorm:"not null" json:"requested_at"`
+	ConfirmBy        time.Time             `gorm:"not null" json:"confirm_by"` // The confirmation link expires at this time
+	ScheduledFor     *time.Time            `gorm:"index" json:"scheduled_for"` // Set on confirmation, the end of the grace period
+}
+
+// ErrAlreadyAnonymized is returned by RestoreUser for users whose personal data was erased.
+var ErrAlreadyAnonymized = errors.New("the user's personal data was erased, they can't be restored")
+
+// GetAccountDeletion returns the user's deletion request, or gorm.ErrRecordNotFound.
+func (db *DbDriver) GetAccountDeletion(userID uint64) (*AccountDeletion, error) {
+	var deletion AccountDeletion
+	if err := db.db.First(&deletion, userID).Error; err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// GetAccountDeletionByCode returns the deletion request with the given confirmation code, or
+// gorm.ErrRecordNotFound.
+func (db *DbDriver) GetAccountDeletionByCode(code string) (*AccountDeletion, error) {
+	var deletion AccountDeletion
+	if err := db.db.Where("confirmation_code = ?", code).First(&deletion).Error; err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// SaveAccountDeletion creates the deletion request or replaces the user's existing one.
+func (db *DbDriver) SaveAccountDeletion(deletion *AccountDeletion) error {
+	return db.db.Save(deletion).Error
+}
+
+// CancelAccountDeletion deletes the user's deletion request, returning gorm.ErrRecordNotFound
+// if they have none.
+func (db *DbDriver) CancelAccountDeletion(userID uint64) error {
+	result := db.db.Delete(&AccountDeletion{}, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetDueAccountDeletions returns the confirmed deletions whose grace period ended by now.
+func (db *DbDriver) GetDueAccountDeletions(now time.Time) ([]AccountDeletion, error) {
+	var deletions []AccountDeletion
+	err := db.db.Where("status = ? AND scheduled_for <= ?", AccountDeletionScheduled, now).
+		Order("scheduled_for").Find(&deletions).Error
+	return deletions, err
+}
+
+// DeleteUnconfirmedAccountDeletions deletes the requests whose confirmation link expired before
+// now and returns how many there were.
+func (db *DbDriver) DeleteUnconfirmedAccountDeletions(now time.Time) (int64, error) {
+	result := db.db.Where("status = ? AND confirm_by <= ?", AccountDeletionPending, now).Delete(&AccountDeletion{})
+	return result.RowsAffected, result.Error
+}
+
+// AnonymizeUser erases the user's personal data and deletes their account. The rows other
+// people's history depends on are kept, soft-deleted and stripped of personal data: the user
+// row (renamed "Deleted User", with a placeholder email so the address can sign up again),
+// their profiles without resume link, corporate email or team, and their referral requests
+// without the free-text summary. The candidate profile's skills, education, positions and
+// preferences, the referrer profile's job families and locations, the referral requests they
+// opened as a referrer, email verifications and the deletion request itself are removed.
+func (db *DbDriver) AnonymizeUser(userID uint64) error {
+	return db.Transaction(func(tx *DbDriver) error {
+		var user User
+		if err := tx.db.Unscoped().First(&user, userID).Error; err != nil {
+			return err
+		}
+		now := tx.db.NowFunc()
+		if !user.DeletedAt.Valid {
+			if err := tx.DeleteUser(&user); err != nil {
+				return err
+			}
+		}
+
+		candidateIds := tx.db.Unscoped().Model(&Candidate{}).Select("candidate_id").Where("user_id = ?", userID)
+		err := tx.db.Unscoped().Model(&ReferralRequest{}).Where("candidate_id IN (?)", candidateIds).
+			UpdateColumn("summary", "").Error
+		if err != nil {
+			return err
+		}
+		if err := tx.db.Unscoped().Model(&Candidate{}).Where("user_id = ?", userID).UpdateColumn("resume_url", "").Error; err != nil {
+			return err
+		}
+		if err := tx.deleteCandidateProfiles(candidateIds); err != nil {
+			return err
+		}
+		err = tx.db.Unscoped().Model(&Referrer{}).Where("user_id = ?", userID).
+			UpdateColumns(map[string]interface{}{"corporate_email": "", "team": ""}).Error
+		if err != nil {
+			return err
+		}
+		referrerIds := tx.db.Unscoped().Model(&Referrer{}).Select("referrer_id").Where("user_id = ?", userID)
+		if err := tx.deleteReferrerProfiles(referrerIds); err != nil {
+			return err
+		}
+		if err := tx.db.Where("referrer_id IN (?)", referrerIds).Delete(&ReferralRequestView{}).Error; err != nil {
+			return err
+		}
+		if err := tx.db.Where("user_id = ?", userID).Delete(&EmailVerification{}).Error; err != nil {
+			return err
+		}
+		if err := tx.db.Delete(&AccountDeletion{}, userID).Error; err != nil {
+			return err
+		}
+
+		return tx.db.Unscoped().Model(&User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+			"first_name":    "Deleted",
+			"last_name":     "User",
+			"email":         fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+			"phone_number":  "",
+			"phone_ext":     "",
+			"linked_in":     nil,
+			"github":        nil,
+			"website":       nil,
+			"is_admin":      false,
+			"anonymized_at": now,
+		}).Error
+	})
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAnonymizeUser_ErasesPersonalDataAndKeepsHistory(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 1)
+			if err := db.RecordReferralRequestView(request.ReferralRequestId, referrerIds[0], time.Now()); err != nil {
+				t.Fatalf("failed to record view: %v", err)
+			}
+			if _, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerIds[0]); err != nil {
+				t.Fatalf("failed to claim referral request: %v", err)
+			}
+			referrer := db.GetReferrerById(referrerIds[0])
+			if _, err := db.CreateEmailVerification(&EmailVerification{ID: "code", Email: "referrer0@corp.example", UserID: referrer.UserId,
+				VerificationCode: "code", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+				t.Fatalf("failed to create email verification: %v", err)
+			}
+			if err := db.SaveAccountDeletion(&AccountDeletion{UserID: referrer.UserId, ConfirmationCode: "confirm",
+				Status: AccountDeletionScheduled, RequestedAt: time.Now(), ConfirmBy: time.Now()}); err != nil {
+				t.Fatalf("failed to save account deletion: %v", err)
+			}
+			candidate := db.GetUserByEmail("candidate@example.com")
+
+			for _, userID := range []uint64{candidate.Id, referrer.UserId} {
+				if err := db.AnonymizeUser(userID); err != nil {
+					t.Fatalf("failed to anonymize user %d: %v", userID, err)
+				}
+			}
+
+			data, err := db.GetPersonalData(referrer.UserId)
+			if err != nil {
+				t.Fatalf("failed to get personal data: %v", err)
+			}
+			if data.User.FirstName != "Deleted" || data.User.Email == "referrer0@example.com" || data.User.AnonymizedAt == nil || !data.User.DeletedAt.Valid {
+				t.Errorf("expected the user to be anonymized and deleted, got %+v", data.User)
+			}
+			if len(data.Referrers) != 1 || data.Referrers[0].CorporateEmail != "" {
+				t.Errorf("expected the referrer profile to be kept without its corporate email, got %+v", data.Referrers)
+			}
+			if len(data.EmailVerifications) != 0 {
+				t.Errorf("expected email verifications to be removed, got %+v", data.EmailVerifications)
+			}
+			if len(data.ReferralRequestViews) != 0 {
+				t.Errorf("expected the referral requests they opened to be forgotten, got %+v", data.ReferralRequestViews)
+			}
+			if len(data.ReferralsHandled) != 1 || data.ReferralsHandled[0].Status != ReferralSubmissionSent {
+				t.Errorf("expected the referral they handled to be kept, got %+v", data.ReferralsHandled)
+			}
+			if _, err := db.GetAccountDeletion(referrer.UserId); err == nil {
+				t.Errorf("expected the account deletion request to be removed")
+			}
+
+			data, err = db.GetPersonalData(candidate.Id)
+			if err != nil {
+				t.Fatalf("failed to get personal data: %v", err)
+			}
+			if len(data.Candidates) != 1 || data.Candidates[0].ResumeUrl != "" {
+				t.Errorf("expected the candidate profile to be kept without its resume, got %+v", data.Candidates)
+			}
+			if len(data.ReferralRequests) != 1 || !data.ReferralRequests[0].DeletedAt.Valid {
+				t.Errorf("expected the referral request to be kept, deleted, got %+v", data.ReferralRequests)
+			}
+
+			if _, err := db.CreateUser(&User{FirstName: "New", LastName: "User", Email: "candidate@example.com"}); err != nil {
+				t.Errorf("expected the email address to be free to sign up again, got %v", err)
+			}
+			if err := db.RestoreUser(candidate.Id); !errors.Is(err, ErrAlreadyAnonymized) {
+				t.Errorf("expected restoring an anonymized user to be refused, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			user, err := db.CreateUser(&User{FirstName: "Revoked", LastName: "User", Email: "revoked@example.com"})
+			if err != nil {
+				t.Fatalf("failed to create user: %v", err)
+			}
+			if revokedAt, err := db.GetSessionsRevokedAt(user.Id); err != nil || revokedAt != nil {
+				t.Fatalf("expected no revocation yet, got %v, %v", revokedAt, err)
+			}
+
+			// Deleted users' sessions can be revoked too
+			if err := db.DeleteUser(user); err != nil {
+				t.Fatalf("failed to delete user: %v", err)
+			}
+			at := time.Now().Truncate(time.Second)
+			if err := db.RevokeUserSessions(user.Id, at); err != nil {
+				t.Fatalf("failed to revoke sessions: %v", err)
+			}
+			revokedAt, err := db.GetSessionsRevokedAt(user.Id)
+			if err != nil || revokedAt == nil || !revokedAt.Equal(at) {
+				t.Errorf("expected sessions revoked at %v, got %v, %v", at, revokedAt, err)
+			}
+			if revokedAt, err := db.GetSessionsRevokedAt(user.Id + 100); err != nil || revokedAt != nil {
+				t.Errorf("expected nothing for a missing user, got %v, %v", revokedAt, err)
+			}
+		})
+	}
+}
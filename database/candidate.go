@@ -1,10 +1,12 @@
 package database
 
-import "fmt"
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
 
 func (db *DbDriver) CreateCandidate(record *Candidate) (*Candidate, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	if err := db.db.Create(record).Error; err != nil {
 		return nil, err
 	}
@@ -12,46 +14,40 @@ func (db *DbDriver) CreateCandidate(record *Candidate) (*Candidate, error) {
 }
 
 func (db *DbDriver) UpdateCandidate(userId uint64, record *Candidate) (*Candidate, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	if record.UserId != userId {
 		return nil, fmt.Errorf("unauthorized to update this candidate")
 	}
 
-	result := db.db.Model(record).Where("user_id = ?", userId).Save(record)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-
 	var updatedRecord Candidate
-	if err := db.db.Where("candidate_id = ? AND user_id = ?", record.CandidateId, userId).First(&updatedRecord).Error; err != nil {
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(record).Where("user_id = ?", userId).Save(record).Error; err != nil {
+			return err
+		}
+		return tx.Where("candidate_id = ? AND user_id = ?", record.CandidateId, userId).First(&updatedRecord).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &updatedRecord, nil
 }
 
+// DeleteCandidate soft-deletes the candidate profile together with its referral requests.
 func (db *DbDriver) DeleteCandidate(userId uint64, record *Candidate) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	if record.UserId != userId {
 		return fmt.Errorf("unauthorized to delete this candidate")
 	}
 
-	result := db.db.Where("user_id = ?", userId).Delete(record)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	return nil
+	return db.Transaction(func(tx *DbDriver) error {
+		now := tx.db.NowFunc()
+		if err := softDelete(tx.db.Where("candidate_id = ?", record.CandidateId), &ReferralRequest{}, now); err != nil {
+			return err
+		}
+		return softDelete(tx.db.Where("candidate_id = ? AND user_id = ?", record.CandidateId, userId), &Candidate{}, now)
+	})
 }
 
 func (db *DbDriver) GetCandidateById(userId, id uint64) *Candidate {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
 	var candidate Candidate
 	db.db.Preload("User").Where("candidate_id = ? AND user_id = ?", id, userId).First(&candidate)
 	if candidate.CandidateId == 0 {
@@ -61,18 +57,12 @@ func (db *DbDriver) GetCandidateById(userId, id uint64) *Candidate {
 }
 
 func (db *DbDriver) GetBulkCandidatesByIds(userId uint64, ids []uint64) *[]Candidate {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
 	var candidates []Candidate
 	db.db.Preload("User").Where("user_id = ? AND candidate_id IN ?", userId, ids).Find(&candidates)
 	return &candidates
 }
 
 func (db *DbDriver) GetCandidateByUserId(userId uint64) *Candidate {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
 	var candidate Candidate
 	db.db.Preload("User").Where("user_id = ?", userId).First(&candidate)
 	if candidate.CandidateId == 0 {
@@ -80,3 +70,10 @@ func (db *DbDriver) GetCandidateByUserId(userId uint64) *Candidate {
 	}
 	return &candidate
 }
+
+// LockCandidate locks the candidate until the transaction it's called in ends, so that checking
+// the candidate's referral requests and then writing one can't interleave with another such
+// transaction. Outside a transaction it has no lasting effect.
+func (db *DbDriver) LockCandidate(candidateId uint64) error {
+	return db.dialect.lockRow(db.db, "candidates", "candidate_id", candidateId)
+}
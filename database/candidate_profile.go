@@ -0,0 +1,278 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A candidate's profile is spread over child tables of candidates: skills from the shared
+// vocabulary, education, past positions, desired roles, work authorizations and preferences.
+// They stay when the candidate is soft-deleted, so a restore brings the profile back whole, and
+// are removed by AnonymizeUser.
+
+// Skill is a tag in the skills vocabulary shared by every candidate. Names are stored as
+// returned by NormalizeSkillName, so "Go" and " go " are the same skill.
+type Skill struct {
+	Id   uint64 `gorm:"primary_key;autoIncrement" json:"id"`
+	Name string `gorm:"not null;uniqueIndex" json:"name"`
+}
+
+type CandidateEducation struct {
+	Id           uint64 `gorm:"primary_key;autoIncrement" json:"id"`
+	CandidateId  uint64 `gorm:"not null;index" json:"candidateId"`
+	School       string `gorm:"not null" json:"school"`
+	Degree       string `gorm:"not null" json:"degree"`
+	FieldOfStudy string `gorm:"not null" json:"fieldOfStudy"`
+	StartYear    *int   `json:"startYear,omitempty"`
+	EndYear      *int   `json:"endYear,omitempty"` // The expected year while still studying
+}
+
+type CandidatePosition struct {
+	Id          uint64     `gorm:"primary_key;autoIncrement" json:"id"`
+	CandidateId uint64     `gorm:"not null;index" json:"candidateId"`
+	Title       string     `gorm:"not null" json:"title"`
+	CompanyName string     `gorm:"not null" json:"companyName"`
+	StartDate   time.Time  `gorm:"not null" json:"startDate"`
+	EndDate     *time.Time `json:"endDate,omitempty"` // Nil for the current position
+	Description string     `gorm:"not null" json:"description"`
+}
+
+type CandidateDesiredRole struct {
+	CandidateId uint64 `gorm:"primaryKey;autoIncrement:false" json:"candidateId"`
+	Title       string `gorm:"primaryKey;autoIncrement:false" json:"title"`
+}
+
+type CandidateWorkAuthorization struct {
+	CandidateId      uint64 `gorm:"primaryKey;autoIncrement:false" json:"candidateId"`
+	Country          string `gorm:"primaryKey;autoIncrement:false" json:"country"` // ISO 3166-1 alpha-2 code
+	NeedsSponsorship bool   `gorm:"not null;default:false" json:"needsSponsorship"`
+}
+
+type RemotePreference string
+
+const (
+	RemoteAny    RemotePreference = "any"
+	RemoteOnsite RemotePreference = "onsite"
+	RemoteHybrid RemotePreference = "hybrid"
+	RemoteOnly   RemotePreference = "remote"
+)
+
+type CandidatePreferences struct {
+	CandidateId      uint64           `gorm:"primaryKey;autoIncrement:false" json:"candidateId"`
+	RemotePreference RemotePreference `gorm:"not null;default:'any'" json:"remotePreference"`
+	OpenToRelocation bool             `gorm:"not null;default:false" json:"openToRelocation"`
+}
+
+// NormalizeSkillName trims the name, collapses its inner whitespace and lowercases it.
+func NormalizeSkillName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// SkillNames returns the names of the candidate's skills, which need to be loaded.
+func (c *Candidate) SkillNames() []string {
+	var names []string
+	for _, skill := range c.Skills {
+		names = append(names, skill.Name)
+	}
+	return names
+}
+
+// DesiredRoleTitles returns the titles of the candidate's desired roles, which need to be loaded.
+func (c *Candidate) DesiredRoleTitles() []string {
+	var titles []string
+	for _, role := range c.DesiredRoles {
+		titles = append(titles, role.Title)
+	}
+	return titles
+}
+
+// preloadCandidateProfile loads the profile of the candidate at path, e.g. "Candidate." for a
+// referral request's candidate or "" for a candidate itself.
+func preloadCandidateProfile(query *gorm.DB, path string) *gorm.DB {
+	return query.Preload(path+"Skills", func(db *gorm.DB) *gorm.DB { return db.Order("skills.name") }).
+		Preload(path+"Education", func(db *gorm.DB) *gorm.DB { return db.Order("end_year DESC, id") }).
+		Preload(path+"Positions", func(db *gorm.DB) *gorm.DB { return db.Order("start_date DESC, id") }).
+		Preload(path + "DesiredRoles").
+		Preload(path + "WorkAuthorizations").
+		Preload(path + "Preferences")
+}
+
+// GetCandidateProfile returns the user's candidate with their user and full profile loaded.
+func (db *DbDriver) GetCandidateProfile(userId uint64) *Candidate {
+	var candidate Candidate
+	preloadCandidateProfile(db.db.Preload("User"), "").Where("user_id = ?", userId).First(&candidate)
+	if candidate.CandidateId == 0 {
+		return nil
+	}
+	return &candidate
+}
+
+// SearchSkills returns up to limit skills from the vocabulary whose name starts with prefix.
+func (db *DbDriver) SearchSkills(prefix string, limit int) ([]Skill, error) {
+	var skills []Skill
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(NormalizeSkillName(prefix))
+	err := db.db.Where(`name LIKE ? ESCAPE '\'`, escaped+"%").Order("name").Limit(limit).Find(&skills).Error
+	return skills, err
+}
+
+// SetCandidateSkills replaces the candidate's skills, adding names not yet in the vocabulary.
+// It returns the skills as stored.
+func (db *DbDriver) SetCandidateSkills(candidateId uint64, names []string) ([]Skill, error) {
+	skills := make([]Skill, 0, len(names))
+	err := db.Transaction(func(tx *DbDriver) error {
+		seen := make(map[string]bool, len(names))
+		for _, name := range names {
+			name = NormalizeSkillName(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			// Another candidate may be adding the same skill, so don't fail on the unique index
+			if err := tx.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Skill{Name: name}).Error; err != nil {
+				return err
+			}
+			var skill Skill
+			if err := tx.db.Where("name = ?", name).First(&skill).Error; err != nil {
+				return err
+			}
+			skills = append(skills, skill)
+		}
+		return tx.db.Model(&Candidate{CandidateId: candidateId}).Association("Skills").Replace(skills)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return skills, nil
+}
+
+// updateCandidateRow overwrites the row of record's model with the given id, as long as it
+// belongs to the candidate, and returns gorm.ErrRecordNotFound otherwise.
+func (db *DbDriver) updateCandidateRow(record interface{}, candidateId, id uint64) error {
+	result := db.db.Model(record).Where("id = ? AND candidate_id = ?", id, candidateId).
+		Select("*").Omit("id", "candidate_id").Updates(record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// deleteCandidateRow deletes the row of model with the given id, as long as it belongs to the
+// candidate, and returns gorm.ErrRecordNotFound otherwise.
+func (db *DbDriver) deleteCandidateRow(model interface{}, candidateId, id uint64) error {
+	result := db.db.Where("id = ? AND candidate_id = ?", id, candidateId).Delete(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (db *DbDriver) CreateCandidateEducation(record *CandidateEducation) (*CandidateEducation, error) {
+	if err := db.db.Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// UpdateCandidateEducation overwrites one of the candidate's education entries, returning
+// gorm.ErrRecordNotFound if they have no entry with record.Id.
+func (db *DbDriver) UpdateCandidateEducation(candidateId uint64, record *CandidateEducation) (*CandidateEducation, error) {
+	record.CandidateId = candidateId
+	if err := db.updateCandidateRow(record, candidateId, record.Id); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (db *DbDriver) DeleteCandidateEducation(candidateId, id uint64) error {
+	return db.deleteCandidateRow(&CandidateEducation{}, candidateId, id)
+}
+
+func (db *DbDriver) CreateCandidatePosition(record *CandidatePosition) (*CandidatePosition, error) {
+	if err := db.db.Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// UpdateCandidatePosition overwrites one of the candidate's past positions, returning
+// gorm.ErrRecordNotFound if they have no position with record.Id.
+func (db *DbDriver) UpdateCandidatePosition(candidateId uint64, record *CandidatePosition) (*CandidatePosition, error) {
+	record.CandidateId = candidateId
+	if err := db.updateCandidateRow(record, candidateId, record.Id); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (db *DbDriver) DeleteCandidatePosition(candidateId, id uint64) error {
+	return db.deleteCandidateRow(&CandidatePosition{}, candidateId, id)
+}
+
+// SetCandidateDesiredRoles replaces the roles the candidate is looking for.
+func (db *DbDriver) SetCandidateDesiredRoles(candidateId uint64, titles []string) ([]CandidateDesiredRole, error) {
+	roles := make([]CandidateDesiredRole, 0, len(titles))
+	for _, title := range titles {
+		roles = append(roles, CandidateDesiredRole{CandidateId: candidateId, Title: title})
+	}
+	err := db.Transaction(func(tx *DbDriver) error {
+		if err := tx.db.Where("candidate_id = ?", candidateId).Delete(&CandidateDesiredRole{}).Error; err != nil {
+			return err
+		}
+		if len(roles) == 0 {
+			return nil
+		}
+		return tx.db.Create(&roles).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// SetCandidateWorkAuthorizations replaces the countries the candidate can work in.
+func (db *DbDriver) SetCandidateWorkAuthorizations(candidateId uint64, authorizations []CandidateWorkAuthorization) ([]CandidateWorkAuthorization, error) {
+	for i := range authorizations {
+		authorizations[i].CandidateId = candidateId
+	}
+	err := db.Transaction(func(tx *DbDriver) error {
+		if err := tx.db.Where("candidate_id = ?", candidateId).Delete(&CandidateWorkAuthorization{}).Error; err != nil {
+			return err
+		}
+		if len(authorizations) == 0 {
+			return nil
+		}
+		return tx.db.Create(&authorizations).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return authorizations, nil
+}
+
+// SaveCandidatePreferences creates or replaces the candidate's preferences.
+func (db *DbDriver) SaveCandidatePreferences(preferences *CandidatePreferences) (*CandidatePreferences, error) {
+	if err := db.db.Save(preferences).Error; err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// deleteCandidateProfiles removes the profile rows of the given candidates.
+func (db *DbDriver) deleteCandidateProfiles(candidateIds *gorm.DB) error {
+	for _, model := range []interface{}{&CandidateEducation{}, &CandidatePosition{}, &CandidateDesiredRole{},
+		&CandidateWorkAuthorization{}, &CandidatePreferences{}} {
+		if err := db.db.Where("candidate_id IN (?)", candidateIds).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return db.db.Exec("DELETE FROM candidate_skills WHERE candidate_id IN (?)", candidateIds).Error
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestCandidateProfile_RoundTripsAndIsLoadedWithReferralRequests(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, _ := seedReferralRequest(t, db, 0)
+			candidateId := request.CandidateID
+
+			skills, err := db.SetCandidateSkills(candidateId, []string{" Go ", "go", "Distributed  Systems"})
+			if err != nil {
+				t.Fatalf("failed to set skills: %v", err)
+			}
+			if len(skills) != 2 || skills[0].Name != "go" || skills[1].Name != "distributed systems" {
+				t.Errorf("expected normalized, deduplicated skills, got %+v", skills)
+			}
+			// Replacing keeps the vocabulary but drops the skill from the candidate
+			if _, err := db.SetCandidateSkills(candidateId, []string{"go"}); err != nil {
+				t.Fatalf("failed to replace skills: %v", err)
+			}
+			if found, err := db.SearchSkills("Dist", 10); err != nil || len(found) != 1 {
+				t.Errorf("expected the vocabulary to keep the dropped skill, got %+v, %v", found, err)
+			}
+
+			endYear := 2020
+			education, err := db.CreateCandidateEducation(&CandidateEducation{CandidateId: candidateId, School: "State University", EndYear: &endYear})
+			if err != nil {
+				t.Fatalf("failed to create education: %v", err)
+			}
+			education.Degree = "BSc"
+			if _, err := db.UpdateCandidateEducation(candidateId, education); err != nil {
+				t.Fatalf("failed to update education: %v", err)
+			}
+			if _, err := db.UpdateCandidateEducation(candidateId+1, education); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("expected another candidate's update to find nothing, got %v", err)
+			}
+			if _, err := db.CreateCandidatePosition(&CandidatePosition{CandidateId: candidateId, Title: "Engineer", CompanyName: "Acme",
+				StartDate: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+				t.Fatalf("failed to create position: %v", err)
+			}
+			if _, err := db.SetCandidateDesiredRoles(candidateId, []string{"Backend Engineer"}); err != nil {
+				t.Fatalf("failed to set desired roles: %v", err)
+			}
+			if _, err := db.SetCandidateWorkAuthorizations(candidateId, []CandidateWorkAuthorization{{Country: "US", NeedsSponsorship: true}}); err != nil {
+				t.Fatalf("failed to set work authorizations: %v", err)
+			}
+			if _, err := db.SaveCandidatePreferences(&CandidatePreferences{CandidateId: candidateId, RemotePreference: RemoteOnly}); err != nil {
+				t.Fatalf("failed to save preferences: %v", err)
+			}
+
+			candidate := db.GetReferralRequestById(request.ReferralRequestId).Candidate
+			if names := candidate.SkillNames(); len(names) != 1 || names[0] != "go" {
+				t.Errorf("expected the skills to be loaded, got %v", names)
+			}
+			if len(candidate.Education) != 1 || candidate.Education[0].Degree != "BSc" {
+				t.Errorf("expected the updated education to be loaded, got %+v", candidate.Education)
+			}
+			if len(candidate.Positions) != 1 || candidate.Positions[0].EndDate != nil {
+				t.Errorf("expected the current position to be loaded, got %+v", candidate.Positions)
+			}
+			if len(candidate.DesiredRoles) != 1 || len(candidate.WorkAuthorizations) != 1 || !candidate.WorkAuthorizations[0].NeedsSponsorship {
+				t.Errorf("expected desired roles and work authorizations to be loaded, got %+v, %+v", candidate.DesiredRoles, candidate.WorkAuthorizations)
+			}
+			if candidate.Preferences == nil || candidate.Preferences.RemotePreference != RemoteOnly {
+				t.Errorf("expected the preferences to be loaded, got %+v", candidate.Preferences)
+			}
+
+			if err := db.DeleteCandidateEducation(candidateId, education.Id); err != nil {
+				t.Errorf("failed to delete education: %v", err)
+			}
+			if err := db.DeleteCandidateEducation(candidateId, education.Id); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("expected deleting twice to find nothing, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAnonymizeUser_RemovesCandidateProfile(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, _ := seedReferralRequest(t, db, 0)
+			if _, err := db.SetCandidateSkills(request.CandidateID, []string{"go"}); err != nil {
+				t.Fatalf("failed to set skills: %v", err)
+			}
+			if _, err := db.CreateCandidatePosition(&CandidatePosition{CandidateId: request.CandidateID, Title: "Engineer",
+				CompanyName: "Acme", StartDate: time.Now()}); err != nil {
+				t.Fatalf("failed to create position: %v", err)
+			}
+			user := db.GetUserByEmail("candidate@example.com")
+
+			if err := db.AnonymizeUser(user.Id); err != nil {
+				t.Fatalf("failed to anonymize user: %v", err)
+			}
+
+			data, err := db.GetPersonalData(user.Id)
+			if err != nil {
+				t.Fatalf("failed to get personal data: %v", err)
+			}
+			if len(data.Candidates) != 1 || len(data.Candidates[0].Skills) != 0 || len(data.Candidates[0].Positions) != 0 {
+				t.Errorf("expected the candidate profile to be emptied, got %+v", data.Candidates)
+			}
+		})
+	}
+}
@@ -1,8 +1,64 @@
 package database
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCompanyAlias is returned for an alias with no letters or digits to match on.
+	ErrInvalidCompanyAlias = errors.New("company alias must contain letters or digits")
+	// ErrCompanyAliasTaken is returned for an alias that's already another company's name or
+	// any company's alias.
+	ErrCompanyAliasTaken = errors.New("company alias is already in use")
+)
+
+// CompanyAlias is another name a company goes by: a former name, its parent company, or the name
+// of a duplicate merged into it. Aliases are unique across companies by their key.
+type CompanyAlias struct {
+	Id        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	CompanyId uint64    `gorm:"not null;index" json:"company_id"`
+	Alias     string    `gorm:"not null" json:"alias"`
+	NameKey   string    `gorm:"not null;uniqueIndex" json:"-"` // CompanyNameKey(Alias)
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// CompanyMerge is the audit record of a MergeCompanies: which company was folded into which, by
+// whom, and how many rows moved.
+type CompanyMerge struct {
+	Id                 uint64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	SourceCompanyId    uint64  `gorm:"not null;index" json:"source_company_id"`
+	SourceCompanyName  string  `gorm:"not null" json:"source_company_name"`
+	TargetCompanyId    uint64  `gorm:"not null;index" json:"target_company_id"`
+	MergedByUserId     *uint64 `json:"merged_by_user_id"` // Nil for merges run with the company command
+	CompanyMergeResult `gorm:"embedded"`
+	CreatedAt          time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Legal suffixes dropped from company names before comparing them
+var companyNameSuffixes = map[string]bool{
+	"inc": true, "llc": true, "ltd": true, "corp": true, "corporation": true,
+	"co": true, "company": true, "plc": true, "gmbh": true, "limited": true,
+}
+
+// CompanyNameKey is the form company names and aliases are compared in: lowercase letters and
+// digits only, without legal suffixes such as Inc. or LLC, so "Google LLC" and "google" match.
+func CompanyNameKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && companyNameSuffixes[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, "")
+}
+
 func (db *DbDriver) CreateCompany(record *Company) (*Company, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	if err := db.db.Create(record).Error; err != nil {
 		return nil, err
 	}
@@ -10,29 +66,167 @@ func (db *DbDriver) CreateCompany(record *Company) (*Company, error) {
 }
 
 func (db *DbDriver) UpdateCompany(record *Company) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	db.db.Save(record)
 }
 
 func (db *DbDriver) DeleteCompany(record *Company) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	db.db.Delete(record)
 }
 
 func (db *DbDriver) GetCompanyById(id uint64) *Company {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var company Company
-	db.db.Preload("Domains").First(&company, id)
+	db.db.Preload("Domains").Preload("Aliases").First(&company, id)
 	return &company
 }
 
 func (db *DbDriver) GetAllCompanies() []Company {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var companies []Company
-	db.db.Preload("Domains").Find(&companies)
+	db.db.Preload("Domains").Preload("Aliases").Find(&companies)
 	return companies
 }
+
+// CreateCompanyAlias adds an alias to the company. It returns gorm.ErrRecordNotFound if the
+// company doesn't exist, ErrInvalidCompanyAlias if the alias has nothing to match on and
+// ErrCompanyAliasTaken if it's another company's name or already an alias.
+func (db *DbDriver) CreateCompanyAlias(companyId uint64, alias string) (*CompanyAlias, error) {
+	record := CompanyAlias{CompanyId: companyId, Alias: strings.TrimSpace(alias), NameKey: CompanyNameKey(alias)}
+	if record.NameKey == "" {
+		return nil, ErrInvalidCompanyAlias
+	}
+	err := db.Transaction(func(tx *DbDriver) error {
+		var company Company
+		if err := tx.db.First(&company, companyId).Error; err != nil {
+			return fmt.Errorf("company %d: %w", companyId, err)
+		}
+		var taken int64
+		if err := tx.db.Model(&CompanyAlias{}).Where("name_key = ?", record.NameKey).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrCompanyAliasTaken
+		}
+		var others []Company
+		if err := tx.db.Select("id", "name").Where("id <> ?", companyId).Find(&others).Error; err != nil {
+			return err
+		}
+		for _, other := range others {
+			if CompanyNameKey(other.Name) == record.NameKey {
+				return fmt.Errorf("%w: it's the name of company %d", ErrCompanyAliasTaken, other.Id)
+			}
+		}
+		return tx.db.Create(&record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// DeleteCompanyAlias removes an alias of the company, returning gorm.ErrRecordNotFound if the
+// company has no such alias.
+func (db *DbDriver) DeleteCompanyAlias(companyId, aliasId uint64) error {
+	result := db.db.Where("id = ? AND company_id = ?", aliasId, companyId).Delete(&CompanyAlias{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("company alias %d: %w", aliasId, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// CompanyMergeResult counts the rows MergeCompanies moved from the source company to the target.
+type CompanyMergeResult struct {
+	Referrers        int64 `json:"referrers"`
+	ReferralRequests int64 `json:"referral_requests"`
+	JobPostings      int64 `json:"job_postings"`
+	Domains          int64 `json:"domains"`
+	Aliases          int64 `json:"aliases"`
+}
+
+// MergeCompanies moves the referrers, referral requests, job postings, domains and aliases of the
+// source company to the target and deletes the source, all in one transaction. Rows already
+// deleted move too, so restoring them later finds the target. Domains the target already has are
+// dropped from the source, and the source's name becomes an alias of the target unless it
+// already matches the target's name or an alias. The merge is recorded, with mergedByUserId as
+// the admin who asked for it, and the record returned. It returns gorm.ErrRecordNotFound if
+// either company doesn't exist.
+func (db *DbDriver) MergeCompanies(sourceId, targetId uint64, mergedByUserId *uint64) (*CompanyMerge, error) {
+	var merge CompanyMerge
+	err := db.Transaction(func(tx *DbDriver) error {
+		var source, target Company
+		if err := tx.db.First(&source, sourceId).Error; err != nil {
+			return fmt.Errorf("source company %d: %w", sourceId, err)
+		}
+		if err := tx.db.First(&target, targetId).Error; err != nil {
+			return fmt.Errorf("target company %d: %w", targetId, err)
+		}
+
+		referrers := tx.db.Unscoped().Model(&Referrer{}).Where("company_id = ?", sourceId).Update("company_id", targetId)
+		if referrers.Error != nil {
+			return referrers.Error
+		}
+		requests := tx.db.Unscoped().Model(&ReferralRequest{}).Where("company_id = ?", sourceId).Update("company_id", targetId)
+		if requests.Error != nil {
+			return requests.Error
+		}
+		postings := tx.db.Unscoped().Model(&JobPosting{}).Where("company_id = ?", sourceId).Update("company_id", targetId)
+		if postings.Error != nil {
+			return postings.Error
+		}
+
+		targetDomains := tx.db.Model(&CompanyDomainAssociation{}).Select("domain").Where("company_id = ?", targetId)
+		if err := tx.db.Where("company_id = ? AND domain IN (?)", sourceId, targetDomains).Delete(&CompanyDomainAssociation{}).Error; err != nil {
+			return err
+		}
+		domains := tx.db.Model(&CompanyDomainAssociation{}).Where("company_id = ?", sourceId).Update("company_id", targetId)
+		if domains.Error != nil {
+			return domains.Error
+		}
+
+		aliases := tx.db.Model(&CompanyAlias{}).Where("company_id = ?", sourceId).Update("company_id", targetId)
+		if aliases.Error != nil {
+			return aliases.Error
+		}
+		if key := CompanyNameKey(source.Name); key != "" && key != CompanyNameKey(target.Name) {
+			var taken int64
+			if err := tx.db.Model(&CompanyAlias{}).Where("name_key = ?", key).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken == 0 {
+				if err := tx.db.Create(&CompanyAlias{CompanyId: targetId, Alias: source.Name, NameKey: key}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.db.Delete(&source).Error; err != nil {
+			return err
+		}
+		merge = CompanyMerge{
+			SourceCompanyId:   sourceId,
+			SourceCompanyName: source.Name,
+			TargetCompanyId:   targetId,
+			MergedByUserId:    mergedByUserId,
+			CompanyMergeResult: CompanyMergeResult{
+				Referrers:        referrers.RowsAffected,
+				ReferralRequests: requests.RowsAffected,
+				JobPostings:      postings.RowsAffected,
+				Domains:          domains.RowsAffected,
+				Aliases:          aliases.RowsAffected,
+			},
+		}
+		return tx.db.Create(&merge).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &merge, nil
+}
+
+// GetCompanyMerges lists the recorded company merges, latest first.
+func (db *DbDriver) GetCompanyMerges() ([]CompanyMerge, error) {
+	var merges []CompanyMerge
+	err := db.db.Order("created_at DESC").Order("id DESC").Find(&merges).Error
+	return merges, err
+}
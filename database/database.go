@@ -1,46 +1,97 @@
 package database
 
 import (
+	"fmt"
 	"log"
-	"sync"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
 
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
+// DbDriver wraps the GORM connection pool for the configured database (SQLite or PostgreSQL,
+// see dialect). It holds no locks of its own: SQLite runs in WAL mode so readers don't block the
+// writer, concurrent writers wait on the busy timeout, and operations spanning several
+// statements run in a transaction.
 type DbDriver struct {
-	mu sync.RWMutex
-	db *gorm.DB
+	db      *gorm.DB
+	dialect dialect
 }
 
-func NewDbDriver(dbPath string) *DbDriver {
+// queryLogger is GORM's default logger, except that it leaves the values out of the statements
+// it logs, since they include verification codes and personal data, and doesn't report lookups
+// that found nothing.
+var queryLogger = logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+	SlowThreshold:             200 * time.Millisecond,
+	LogLevel:                  logger.Warn,
+	IgnoreRecordNotFoundError: true,
+	ParameterizedQueries:      true,
+	Colorful:                  true,
+})
 
-	gormDb, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+func NewDbDriver(cfg config.DatabaseConfig) *DbDriver {
+	dialect, err := newDialect(cfg.Driver)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	gormDb, err := gorm.Open(dialect.open(cfg), &gorm.Config{Logger: queryLogger})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	log.Println("Database connection successful.")
-	return &DbDriver{db: gormDb}
+	if err := registerMetricsCallbacks(gormDb); err != nil {
+		log.Fatal("Failed to register database metrics callbacks:", err)
+	}
+
+	sqlDb, err := gormDb.DB()
+	if err != nil {
+		log.Fatal("Failed to get database handle:", err)
+	}
+	dialect.configurePool(sqlDb, cfg)
+
+	slog.Info("Database connection successful", "driver", dialect.name(), "target", dialect.target(cfg), "max_open_conns", cfg.MaxOpenConns)
+	return &DbDriver{db: gormDb, dialect: dialect}
+}
+
+// Dialect returns the name of the database dialect in use, "sqlite" or "postgres".
+func (dbd *DbDriver) Dialect() string {
+	return dbd.dialect.name()
 }
 
-func (dbd *DbDriver) CloseDatabase() {
+// Transaction runs fn with a DbDriver bound to a single database transaction. Everything fn
+// does through tx is committed if it returns nil and rolled back if it returns an error or panics.
+func (dbd *DbDriver) Transaction(fn func(tx *DbDriver) error) error {
+	return dbd.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&DbDriver{db: tx, dialect: dbd.dialect})
+	})
+}
+
+// CloseDatabase runs the dialect's housekeeping (for SQLite, checkpointing the write-ahead log
+// into the main database file) and closes the connection pool. It must only be called once
+// nothing else is using the DbDriver.
+func (dbd *DbDriver) CloseDatabase() error {
 	sqlDb, err := dbd.db.DB()
 	if err != nil {
-		log.Fatal("Failed to close database:", err)
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+	if err := dbd.dialect.beforeClose(dbd.db); err != nil {
+		slog.Warn("Database housekeeping before closing failed", "error", err)
+	}
+	if err := sqlDb.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
 	}
-	sqlDb.Close()
-	log.Println("Database connection closed.")
+	slog.Info("Database connection closed")
+	return nil
 }
 
 func (db *DbDriver) AddRecord(record interface{}) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	db.db.Create(record)
 }
 
 func (db *DbDriver) GetUser(userId uint64) *User {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var user User
 	db.db.First(&user, userId)
 	return &user
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+
+	"gorm.io/gorm"
+)
+
+// dialect isolates what differs between the supported databases: connecting, sizing the
+// connection pool and housekeeping SQL. Queries elsewhere in the package stick to SQL that
+// both SQLite and PostgreSQL accept; anything that can't belongs behind this interface.
+type dialect interface {
+	// name is the gormschema/atlas dialect name, which also names the migration directory
+	name() string
+	open(cfg config.DatabaseConfig) gorm.Dialector
+	// target describes the database being connected to for logs, without credentials
+	target(cfg config.DatabaseConfig) string
+	configurePool(sqlDb *sql.DB, cfg config.DatabaseConfig)
+	// beforeClose runs once nothing else is using the database, just before the pool is closed
+	beforeClose(db *gorm.DB) error
+	// lockMigrations is called at the start of each migration transaction so that instances
+	// starting at the same time apply migrations one at a time
+	lockMigrations(tx *gorm.DB) error
+	// lockRow locks the row of table whose column is id until the transaction ends, so that
+	// transactions reading and then writing what hangs off it run one at a time
+	lockRow(tx *gorm.DB, table, column string, id uint64) error
+}
+
+func newDialect(driver string) (dialect, error) {
+	switch driver {
+	case config.DatabaseDriverSQLite:
+		return sqliteDialect{}, nil
+	case config.DatabaseDriverPostgres:
+		return postgresDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
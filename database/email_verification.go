@@ -1,6 +1,8 @@
 package database
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -19,6 +21,22 @@ const (
 	EmailVerificationStatusSendFailed                                // Attempt to send email failed
 )
 
+func (s EmailVerificationStatus) String() string {
+	switch s {
+	case EmailVerificationStatusClaimed:
+		return "Claimed"
+	case EmailVerificationStatusSent:
+		return "Sent"
+	case EmailVerificationStatusVerified:
+		return "Verified"
+	case EmailVerificationStatusExpired:
+		return "Expired"
+	case EmailVerificationStatusSendFailed:
+		return "Send Failed"
+	}
+	return fmt.Sprintf("EmailVerificationStatus(%d)", int(s))
+}
+
 type EmailVerification struct {
 	ID               string                  `json:"id" gorm:"primaryKey"`
 	Email            string                  `json:"email" gorm:"unique;not null"`
@@ -30,8 +48,6 @@ type EmailVerification struct {
 }
 
 func (db *DbDriver) CreateEmailVerification(record *EmailVerification) (*EmailVerification, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	if err := db.db.Create(record).Error; err != nil {
 		return nil, err
 	}
@@ -39,8 +55,6 @@ func (db *DbDriver) CreateEmailVerification(record *EmailVerification) (*EmailVe
 }
 
 func (db *DbDriver) GetEmailVerificationByCode(code string) (*EmailVerification, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var verification EmailVerification
 	result := db.db.Where("verification_code = ?", code).First(&verification)
 	if result.Error != nil {
@@ -50,9 +64,6 @@ func (db *DbDriver) GetEmailVerificationByCode(code string) (*EmailVerification,
 }
 
 func (db *DbDriver) UpdateEmailVerification(record *EmailVerification) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	// Use Save to update all fields, including Status and potentially ExpiresAt if needed later
 	result := db.db.Save(record)
 	if result.Error != nil {
@@ -65,8 +76,6 @@ func (db *DbDriver) UpdateEmailVerification(record *EmailVerification) error {
 // for a specific email that is still in a pending state (Claimed or Sent).
 // Returns the verification record if found, nil otherwise. Error indicates a DB issue.
 func (db *DbDriver) GetActiveVerificationByEmail(email string) (*EmailVerification, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var verification EmailVerification
 	now := time.Now()
 	// Check for requests that are not yet verified/expired/failed and haven't passed expiry time
@@ -85,8 +94,6 @@ func (db *DbDriver) GetActiveVerificationByEmail(email string) (*EmailVerificati
 // CountActiveVerificationsForUser counts the number of unexpired verification requests
 // for a specific user that are still in a pending state (Claimed or Sent).
 func (db *DbDriver) CountActiveVerificationsForUser(userID uint64) (int64, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var count int64
 	now := time.Now()
 	// Count requests for the user that are not yet verified/expired/failed and haven't passed expiry time
@@ -98,3 +105,36 @@ func (db *DbDriver) CountActiveVerificationsForUser(userID uint64) (int64, error
 	}
 	return count, nil
 }
+
+// IsEmailVerified reports whether the user has verified the email address, ignoring case.
+func (db *DbDriver) IsEmailVerified(userID uint64, email string) (bool, error) {
+	var count int64
+	result := db.db.Model(&EmailVerification{}).Where("user_id = ? AND LOWER(email) = ? AND status = ?",
+		userID, strings.ToLower(email), EmailVerificationStatusVerified).Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
+}
+
+// GetEmailVerificationsByUserId returns every verification request the user has made, oldest first.
+func (db *DbDriver) GetEmailVerificationsByUserId(userID uint64) ([]EmailVerification, error) {
+	var verifications []EmailVerification
+	result := db.db.Where("user_id = ?", userID).Order("expires_at").Find(&verifications)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return verifications, nil
+}
+
+// ExpireEmailVerifications marks pending (Claimed or Sent) verification requests whose expiry
+// time is before now as Expired and returns how many were updated.
+func (db *DbDriver) ExpireEmailVerifications(now time.Time) (int64, error) {
+	result := db.db.Model(&EmailVerification{}).
+		Where("status IN (?, ?) AND expires_at <= ?", EmailVerificationStatusClaimed, EmailVerificationStatusSent, now).
+		Update("status", EmailVerificationStatusExpired)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
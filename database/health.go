@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// SchemaRevisionsTable is where atlas records the migrations applied to a database.
+const SchemaRevisionsTable = "atlas_schema_revisions"
+
+// Ping checks that the database can be reached.
+func (dbd *DbDriver) Ping(ctx context.Context) error {
+	sqlDb, err := dbd.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+	return sqlDb.PingContext(ctx)
+}
+
+// SchemaVersion returns the newest fully applied atlas migration version. It returns an
+// empty version if the database has no revisions table, i.e. its schema was applied
+// declaratively rather than through versioned migrations.
+func (dbd *DbDriver) SchemaVersion(ctx context.Context) (string, error) {
+	db := dbd.db.WithContext(ctx)
+	if !db.Migrator().HasTable(SchemaRevisionsTable) {
+		return "", nil
+	}
+
+	var version string
+	err := db.Table(SchemaRevisionsTable).
+		Select("version").
+		Where("applied = total AND (error IS NULL OR error = '')").
+		Order("version DESC").
+		Limit(1).
+		Scan(&version).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobPosting is an opening a verified referrer knows about and will refer candidates for.
+// Candidates who apply get a referral request already assigned to the referrer.
+type JobPosting struct {
+	JobPostingId uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReferrerId   uint64         `gorm:"not null;index" json:"referrerId"`
+	Referrer     Referrer       `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	CompanyId    uint64         `gorm:"not null;index" json:"companyId"` // The referrer's company when posted
+	Company      Company        `json:"-"`
+	Title        string         `gorm:"not null" json:"title"`
+	Link         string         `gorm:"not null" json:"link"`
+	Location     string         `gorm:"not null;default:''" json:"location"`
+	ReferralType ReferralType   `gorm:"not null" json:"referralType"`
+	Description  string         `gorm:"not null;default:''" json:"description"`
+	ExpiresAt    time.Time      `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updatedAt"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+}
+
+// IsOpen reports whether candidates can still apply for the posting at now.
+func (p *JobPosting) IsOpen(now time.Time) bool {
+	return now.Before(p.ExpiresAt)
+}
+
+// JobPostingFilter narrows the postings candidates browse. Zero fields don't filter.
+type JobPostingFilter struct {
+	CompanyId    uint64
+	ReferralType ReferralType
+	Location     string // Part of the location, case-insensitive
+	Query        string // Part of the title, case-insensitive
+	Limit        int
+}
+
+// preloadJobPosting loads the posting's company and referrer with their profile. The company is
+// loaded even if it has since been deleted.
+func preloadJobPosting(query *gorm.DB) *gorm.DB {
+	return preloadReferrerProfile(query, "Referrer.").
+		Preload("Company", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Referrer").
+		Preload("Referrer.User")
+}
+
+func (db *DbDriver) CreateJobPosting(record *JobPosting) (*JobPosting, error) {
+	if err := db.db.Omit("Referrer", "Company").Create(record).Error; err != nil {
+		return nil, err
+	}
+	return db.GetJobPostingById(record.JobPostingId), nil
+}
+
+// UpdateJobPosting saves the posting if it belongs to the referrer, returning
+// gorm.ErrRecordNotFound if it doesn't.
+func (db *DbDriver) UpdateJobPosting(referrerId uint64, record *JobPosting) (*JobPosting, error) {
+	result := db.db.Model(&JobPosting{}).
+		Where("job_posting_id = ? AND referrer_id = ?", record.JobPostingId, referrerId).
+		Select("title", "link", "location", "referral_type", "description", "expires_at").
+		Updates(record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return db.GetJobPostingById(record.JobPostingId), nil
+}
+
+// DeleteJobPosting soft-deletes the posting if it belongs to the referrer, returning
+// gorm.ErrRecordNotFound if it doesn't. Referral requests made through it are kept.
+func (db *DbDriver) DeleteJobPosting(referrerId, jobPostingId uint64) error {
+	result := db.db.Where("job_posting_id = ? AND referrer_id = ?", jobPostingId, referrerId).Delete(&JobPosting{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (db *DbDriver) GetJobPostingById(id uint64) *JobPosting {
+	var posting JobPosting
+	preloadJobPosting(db.db).First(&posting, id)
+	if posting.JobPostingId == 0 {
+		return nil
+	}
+	return &posting
+}
+
+// GetJobPostingsByReferrerId returns the referrer's postings, expired ones included, newest first.
+func (db *DbDriver) GetJobPostingsByReferrerId(referrerId uint64) []JobPosting {
+	var postings []JobPosting
+	preloadJobPosting(db.db).
+		Where("referrer_id = ?", referrerId).
+		Order("created_at DESC, job_posting_id DESC").
+		Find(&postings)
+	return postings
+}
+
+// SearchJobPostings returns the postings candidates can apply for at now that match the
+// filter, newest first. Postings whose referrer has deleted their profile are left out.
+func (db *DbDriver) SearchJobPostings(filter JobPostingFilter, now time.Time) ([]JobPosting, error) {
+	query := preloadJobPosting(db.db).
+		Joins("JOIN referrers ON referrers.referrer_id = job_postings.referrer_id AND referrers.deleted_at IS NULL").
+		Where("job_postings.expires_at > ?", now)
+	if filter.CompanyId != 0 {
+		query = query.Where("job_postings.company_id = ?", filter.CompanyId)
+	}
+	if filter.ReferralType != "" {
+		query = query.Where("job_postings.referral_type = ?", filter.ReferralType)
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	if location := strings.ToLower(strings.TrimSpace(filter.Location)); location != "" {
+		query = query.Where(`LOWER(job_postings.location) LIKE ? ESCAPE '\'`, "%"+escape.Replace(location)+"%")
+	}
+	if q := strings.ToLower(strings.TrimSpace(filter.Query)); q != "" {
+		query = query.Where(`LOWER(job_postings.title) LIKE ? ESCAPE '\'`, "%"+escape.Replace(q)+"%")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var postings []JobPosting
+	err := query.Order("job_postings.created_at DESC, job_postings.job_posting_id DESC").Find(&postings).Error
+	return postings, err
+}
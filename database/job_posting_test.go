@@ -0,0 +1,165 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestJobPostings_SearchFiltersAndExpiry(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 2)
+			now := time.Now()
+
+			for _, posting := range []*JobPosting{
+				{ReferrerId: referrerIds[0], Title: "Backend Engineer", Location: "Toronto, ON", ReferralType: FullTime, ExpiresAt: now.Add(time.Hour)},
+				{ReferrerId: referrerIds[0], Title: "Data 100% Engineer", Location: "Remote", ReferralType: Internship, ExpiresAt: now.Add(time.Hour)},
+				{ReferrerId: referrerIds[0], Title: "Expired Engineer", Location: "Toronto, ON", ReferralType: FullTime, ExpiresAt: now.Add(-time.Hour)},
+				{ReferrerId: referrerIds[1], Title: "Frontend Engineer", Location: "Toronto, ON", ReferralType: FullTime, ExpiresAt: now.Add(time.Hour)},
+			} {
+				posting.CompanyId = request.CompanyID
+				posting.Link = "https://jobs.example.com/1"
+				if _, err := db.CreateJobPosting(posting); err != nil {
+					t.Fatalf("failed to create job posting: %v", err)
+				}
+			}
+			// Postings of a referrer who deleted their profile are left out
+			if err := db.DeleteReferrer(db.GetReferrerById(referrerIds[1]).UserId, db.GetReferrerById(referrerIds[1])); err != nil {
+				t.Fatalf("failed to delete referrer: %v", err)
+			}
+
+			for _, tc := range []struct {
+				filter JobPostingFilter
+				want   []string
+			}{
+				{JobPostingFilter{}, []string{"Data 100% Engineer", "Backend Engineer"}},
+				{JobPostingFilter{Location: "toronto"}, []string{"Backend Engineer"}},
+				{JobPostingFilter{Query: "100%"}, []string{"Data 100% Engineer"}},
+				{JobPostingFilter{Query: "_"}, nil},
+				{JobPostingFilter{ReferralType: Internship}, []string{"Data 100% Engineer"}},
+				{JobPostingFilter{CompanyId: request.CompanyID + 1}, nil},
+				{JobPostingFilter{Limit: 1}, []string{"Data 100% Engineer"}},
+			} {
+				postings, err := db.SearchJobPostings(tc.filter, now)
+				if err != nil {
+					t.Fatalf("failed to search job postings: %v", err)
+				}
+				var titles []string
+				for _, posting := range postings {
+					titles = append(titles, posting.Title)
+				}
+				if len(titles) != len(tc.want) {
+					t.Errorf("%+v: expected %v, got %v", tc.filter, tc.want, titles)
+					continue
+				}
+				for i := range titles {
+					if titles[i] != tc.want[i] {
+						t.Errorf("%+v: expected %v, got %v", tc.filter, tc.want, titles)
+						break
+					}
+				}
+			}
+
+			if postings := db.GetJobPostingsByReferrerId(referrerIds[0]); len(postings) != 3 {
+				t.Errorf("expected the referrer's postings to include expired ones, got %d", len(postings))
+			}
+		})
+	}
+}
+
+func TestJobPostings_UpdateAndDeleteCheckOwnership(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 2)
+			posting, err := db.CreateJobPosting(&JobPosting{ReferrerId: referrerIds[0], CompanyId: request.CompanyID, Title: "Backend Engineer",
+				Link: "https://jobs.example.com/1", ReferralType: FullTime, ExpiresAt: time.Now().Add(time.Hour)})
+			if err != nil {
+				t.Fatalf("failed to create job posting: %v", err)
+			}
+			if posting.Referrer.User.Id == 0 || posting.Company.Id != request.CompanyID {
+				t.Errorf("expected the referrer and company to be loaded, got %+v", posting)
+			}
+
+			update := *posting
+			update.Title = "Staff Engineer"
+			update.CompanyId = request.CompanyID + 1
+			if _, err := db.UpdateJobPosting(referrerIds[1], &update); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("expected another referrer's update to find nothing, got %v", err)
+			}
+			updated, err := db.UpdateJobPosting(referrerIds[0], &update)
+			if err != nil {
+				t.Fatalf("failed to update job posting: %v", err)
+			}
+			if updated.Title != "Staff Engineer" || updated.CompanyId != request.CompanyID {
+				t.Errorf("expected only the title to change, got %+v", updated)
+			}
+
+			if err := db.DeleteJobPosting(referrerIds[1], posting.JobPostingId); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("expected another referrer's delete to find nothing, got %v", err)
+			}
+			if err := db.DeleteJobPosting(referrerIds[0], posting.JobPostingId); err != nil {
+				t.Fatalf("failed to delete job posting: %v", err)
+			}
+			if db.GetJobPostingById(posting.JobPostingId) != nil {
+				t.Errorf("expected the job posting to be deleted")
+			}
+		})
+	}
+}
+
+func TestClaimReferralRequest_AssignedReferrer(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 2)
+			if err := db.db.Model(request).Update("referrer_id", referrerIds[0]).Error; err != nil {
+				t.Fatalf("failed to assign referral request: %v", err)
+			}
+
+			if _, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerIds[1]); !errors.Is(err, ErrReferralRequestNotClaimable) {
+				t.Errorf("expected another referrer not to claim the assigned request, got %v", err)
+			}
+			claimed, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerIds[0])
+			if err != nil {
+				t.Fatalf("expected the assigned referrer to claim the request, got %v", err)
+			}
+			if claimed.Status != ReferralSubmissionSent || claimed.ClaimedAt == nil {
+				t.Errorf("expected the request to be claimed, got %+v", claimed)
+			}
+		})
+	}
+}
+
+func TestIsEmailVerified(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			user, err := db.CreateUser(&User{FirstName: "Referrer", LastName: "User", Email: "referrer@example.com"})
+			if err != nil {
+				t.Fatalf("failed to create user: %v", err)
+			}
+			for _, verification := range []*EmailVerification{
+				{ID: "1", Email: "Ref@Corp.example", UserID: user.Id, VerificationCode: "a", ExpiresAt: time.Now(), Status: EmailVerificationStatusVerified},
+				{ID: "2", Email: "pending@corp.example", UserID: user.Id, VerificationCode: "b", ExpiresAt: time.Now(), Status: EmailVerificationStatusSent},
+			} {
+				if _, err := db.CreateEmailVerification(verification); err != nil {
+					t.Fatalf("failed to create email verification: %v", err)
+				}
+			}
+
+			for email, want := range map[string]bool{"ref@corp.example": true, "pending@corp.example": false, "other@corp.example": false} {
+				if verified, err := db.IsEmailVerified(user.Id, email); err != nil || verified != want {
+					t.Errorf("%s: expected %v, got %v, %v", email, want, verified, err)
+				}
+			}
+			if verified, _ := db.IsEmailVerified(user.Id+1, "ref@corp.example"); verified {
+				t.Errorf("expected another user's verification not to count")
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/metrics"
+
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "metrics:query_start"
+
+// registerMetricsCallbacks times every statement gorm executes.
+func registerMetricsCallbacks(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(queryStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			value, ok := tx.InstanceGet(queryStartKey)
+			if !ok {
+				return
+			}
+			start, ok := value.(time.Time)
+			if !ok {
+				return
+			}
+			table := tx.Statement.Table
+			if table == "" {
+				table = "unknown"
+			}
+			metrics.DBQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+		}
+	}
+
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", before),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", before),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", before),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", before),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	)
+}
+
+// CountOpenReferralRequests returns the number of open referral requests per company and status.
+func (dbd *DbDriver) CountOpenReferralRequests() ([]metrics.OpenReferralRequestCount, error) {
+	var counts []metrics.OpenReferralRequestCount
+	err := dbd.db.Model(&ReferralRequest{}).
+		Select("company_id, status, count(*) AS count").
+		Where("status IN ?", OpenReferralStatuses).
+		Group("company_id, status").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,354 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/migrations"
+
+	"gorm.io/gorm"
+)
+
+// Revision types, as atlas records them in the type column.
+const (
+	revisionTypeBaseline uint = 1 << 0
+	revisionTypeExecute  uint = 1 << 1
+)
+
+// operatorVersion is recorded with each revision this package applies, where the atlas CLI
+// records its own version.
+const operatorVersion = "muslim-referrals embedded migrator"
+
+// legacyTable is created by the first migration. A database that has it but no revision table
+// had its schema applied declaratively with `atlas schema apply`.
+const legacyTable = "users"
+
+// legacySchemaVersion is the last migration whose schema `atlas schema apply` produced, before
+// the migrations were applied as files. Such a database is baselined at the newest migration up
+// to this version, and everything after it is applied.
+const legacySchemaVersion = "20261019130000"
+
+// ErrSchemaMismatch means the database schema isn't the one this build expects. The server
+// refuses to start rather than run against it.
+var ErrSchemaMismatch = errors.New("database schema does not match this build")
+
+// schemaRevision is a row of atlas_schema_revisions, with the columns atlas uses so the atlas
+// CLI can still read and apply migrations against databases migrated by this package.
+type schemaRevision struct {
+	Version         string    `gorm:"primaryKey;size:255"`
+	Description     string    `gorm:"not null;size:255"`
+	Type            uint      `gorm:"not null;default:2"`
+	Applied         int       `gorm:"not null;default:0"`
+	Total           int       `gorm:"not null;default:0"`
+	ExecutedAt      time.Time `gorm:"not null"`
+	ExecutionTime   int64     `gorm:"not null"` // Nanoseconds
+	Error           *string
+	ErrorStmt       *string
+	Hash            string `gorm:"not null"`
+	PartialHashes   *string
+	OperatorVersion string `gorm:"not null"`
+}
+
+func (schemaRevision) TableName() string {
+	return SchemaRevisionsTable
+}
+
+// MigrationStatus describes one embedded migration and whether the database has it.
+type MigrationStatus struct {
+	Version     string
+	Description string
+	Applied     bool
+	ExecutedAt  *time.Time // Nil for pending migrations and those covered by a baseline
+}
+
+// Migrator applies the embedded migrations for the database's dialect (see the migrations
+// package) and records them in atlas_schema_revisions.
+type Migrator struct {
+	db    *DbDriver
+	files []migrations.File
+}
+
+// Migrator loads the embedded migrations for the driver's dialect, verifying them against
+// their atlas.sum.
+func (dbd *DbDriver) Migrator() (*Migrator, error) {
+	files, err := migrations.Load(dbd.Dialect())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: dbd, files: files}, nil
+}
+
+// Status lists every embedded migration in order and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	revisions, err := m.revisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	baseline := baselineVersion(revisions)
+
+	statuses := make([]MigrationStatus, 0, len(m.files))
+	for _, file := range m.files {
+		status := MigrationStatus{Version: file.Version, Description: file.Description}
+		if revision, ok := revisions[file.Version]; ok && revision.Type&revisionTypeExecute != 0 {
+			status.Applied = true
+			executedAt := revision.ExecutedAt
+			status.ExecutedAt = &executedAt
+		} else if file.Version <= baseline {
+			status.Applied = true
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction together with its
+// revision row, and returns the versions applied. A database whose schema was applied with
+// `atlas schema apply`, and so has tables but no revisions, is first baselined at
+// legacySchemaVersion.
+func (m *Migrator) Up(ctx context.Context) ([]string, error) {
+	if err := m.ensureRevisionsTable(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.Check(ctx, true); err != nil {
+		return nil, err
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var applied []string
+	for i, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		if err := m.apply(ctx, m.files[i]); err != nil {
+			return applied, err
+		}
+		applied = append(applied, status.Version)
+	}
+	return applied, nil
+}
+
+// Down reverts the most recently applied migration using its reverse migration and returns its
+// version. It fails if the migration has no reverse migration or is covered by a baseline.
+func (m *Migrator) Down(ctx context.Context) (string, error) {
+	revisions, err := m.revisions(ctx)
+	if err != nil {
+		return "", err
+	}
+	var last *schemaRevision
+	for _, revision := range revisions {
+		if last == nil || revision.Version > last.Version {
+			last = revision
+		}
+	}
+	if last == nil {
+		return "", errors.New("no migrations have been applied")
+	}
+	if last.Type&revisionTypeExecute == 0 {
+		return "", fmt.Errorf("cannot revert %s: it is a baseline, not an applied migration", last.Version)
+	}
+	file, ok := m.file(last.Version)
+	if !ok {
+		return "", fmt.Errorf("%w: revision %s is not in this build's migrations", ErrSchemaMismatch, last.Version)
+	}
+	if file.Down == "" {
+		return "", fmt.Errorf("migration %s has no reverse migration in down/%s", file.Version, file.Name)
+	}
+
+	err = m.db.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := m.db.dialect.lockMigrations(tx); err != nil {
+			return err
+		}
+		for _, statement := range migrations.Statements(file.Down) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("reverting %s: %w", file.Name, err)
+			}
+		}
+		return tx.Delete(&schemaRevision{Version: file.Version}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "Reverted migration", "version", file.Version, "description", file.Description)
+	return file.Version, nil
+}
+
+// Check returns ErrSchemaMismatch, wrapped with the reason, unless the database is exactly at
+// this build's schema: every embedded migration applied, with the same contents, none failed
+// part way and none newer than this build knows about. If allowPending is set, migrations that
+// haven't been applied yet are not a mismatch.
+func (m *Migrator) Check(ctx context.Context, allowPending bool) error {
+	revisions, err := m.revisions(ctx)
+	if err != nil {
+		return err
+	}
+	latest := ""
+	if len(m.files) > 0 {
+		latest = m.files[len(m.files)-1].Version
+	}
+
+	for _, revision := range revisions {
+		if revision.Error != nil && *revision.Error != "" || revision.Applied < revision.Total {
+			return fmt.Errorf("%w: migration %s was only partially applied", ErrSchemaMismatch, revision.Version)
+		}
+		if revision.Version > latest {
+			return fmt.Errorf("%w: database is at %s, newer than this build's %s", ErrSchemaMismatch, revision.Version, latest)
+		}
+		if revision.Type&revisionTypeExecute == 0 {
+			continue
+		}
+		file, ok := m.file(revision.Version)
+		if !ok {
+			return fmt.Errorf("%w: applied migration %s is not in this build", ErrSchemaMismatch, revision.Version)
+		}
+		if revision.Hash != file.Hash {
+			return fmt.Errorf("%w: migration %s was changed after it was applied", ErrSchemaMismatch, revision.Version)
+		}
+	}
+
+	if allowPending {
+		return nil
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			return fmt.Errorf("%w: migration %s has not been applied", ErrSchemaMismatch, status.Version)
+		}
+	}
+	return nil
+}
+
+// EnsureSchema brings the database to this build's schema at startup. It applies pending
+// migrations if apply is set and then checks the result, returning ErrSchemaMismatch if the
+// server must not start.
+func (m *Migrator) EnsureSchema(ctx context.Context, apply bool) error {
+	if apply {
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			slog.InfoContext(ctx, "Applied database migrations", "versions", applied)
+		}
+	}
+	return m.Check(ctx, false)
+}
+
+func (m *Migrator) apply(ctx context.Context, file migrations.File) error {
+	return m.db.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := m.db.dialect.lockMigrations(tx); err != nil {
+			return err
+		}
+		// Another instance may have applied it while this one waited for the lock
+		var count int64
+		if err := tx.Model(&schemaRevision{}).Where("version = ?", file.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		start := time.Now()
+		statements := migrations.Statements(file.SQL)
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("applying %s: %w", file.Name, err)
+			}
+		}
+		slog.InfoContext(ctx, "Applied migration", "version", file.Version, "description", file.Description, "statements", len(statements))
+		return tx.Create(&schemaRevision{
+			Version:         file.Version,
+			Description:     file.Description,
+			Type:            revisionTypeExecute,
+			Applied:         len(statements),
+			Total:           len(statements),
+			ExecutedAt:      start,
+			ExecutionTime:   time.Since(start).Nanoseconds(),
+			Hash:            file.Hash,
+			OperatorVersion: operatorVersion,
+		}).Error
+	})
+}
+
+// ensureRevisionsTable creates the revisions table if it is missing. If the schema was already
+// applied declaratively, it records a baseline at the legacy schema so only the migrations
+// written since are applied.
+func (m *Migrator) ensureRevisionsTable(ctx context.Context) error {
+	db := m.db.db.WithContext(ctx)
+	if db.Migrator().HasTable(SchemaRevisionsTable) {
+		return nil
+	}
+	legacy := db.Migrator().HasTable(legacyTable)
+	if err := db.Migrator().CreateTable(&schemaRevision{}); err != nil {
+		return fmt.Errorf("failed to create %s: %w", SchemaRevisionsTable, err)
+	}
+	if !legacy || len(m.files) == 0 {
+		return nil
+	}
+
+	var baseline *migrations.File
+	for i := range m.files {
+		if m.files[i].Version <= legacySchemaVersion {
+			baseline = &m.files[i]
+		}
+	}
+	if baseline == nil {
+		return nil
+	}
+	slog.WarnContext(ctx, "Database has tables but no migration history, recording a baseline at the legacy schema",
+		"version", baseline.Version)
+	return db.Create(&schemaRevision{
+		Version:         baseline.Version,
+		Description:     baseline.Description,
+		Type:            revisionTypeBaseline,
+		ExecutedAt:      time.Now(),
+		Hash:            baseline.Hash,
+		OperatorVersion: operatorVersion,
+	}).Error
+}
+
+// revisions returns the recorded revisions by version. A database without the revisions table
+// has none.
+func (m *Migrator) revisions(ctx context.Context) (map[string]*schemaRevision, error) {
+	db := m.db.db.WithContext(ctx)
+	revisions := make(map[string]*schemaRevision)
+	if !db.Migrator().HasTable(SchemaRevisionsTable) {
+		return revisions, nil
+	}
+	var rows []schemaRevision
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", SchemaRevisionsTable, err)
+	}
+	for i := range rows {
+		revisions[rows[i].Version] = &rows[i]
+	}
+	return revisions, nil
+}
+
+func (m *Migrator) file(version string) (migrations.File, bool) {
+	for _, file := range m.files {
+		if file.Version == version {
+			return file, true
+		}
+	}
+	return migrations.File{}, false
+}
+
+// baselineVersion returns the newest baseline revision; migrations up to it count as applied.
+func baselineVersion(revisions map[string]*schemaRevision) string {
+	baseline := ""
+	for _, revision := range revisions {
+		if revision.Type&revisionTypeBaseline != 0 && revision.Version > baseline {
+			baseline = revision.Version
+		}
+	}
+	return baseline
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+	"github.com/Suhaibinator/muslim-referrals-backend/migrations"
+)
+
+func TestMigrator_UpDownAndStatus(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			db := openTestDbDriver(t, driver)
+			migrator, err := db.Migrator()
+			if err != nil {
+				t.Fatalf("failed to load migrations: %v", err)
+			}
+			latest := migrations.LatestVersion(driver)
+
+			if err := migrator.Check(ctx, false); !errors.Is(err, ErrSchemaMismatch) {
+				t.Errorf("expected pending migrations to be a mismatch, got %v", err)
+			}
+			applied, err := migrator.Up(ctx)
+			if err != nil || len(applied) == 0 || applied[len(applied)-1] != latest {
+				t.Fatalf("expected every migration up to %s to be applied, got %v, %v", latest, applied, err)
+			}
+			if err := migrator.Check(ctx, false); err != nil {
+				t.Errorf("expected migrated database to match, got %v", err)
+			}
+			if version, err := db.SchemaVersion(ctx); err != nil || version != latest {
+				t.Errorf("expected schema version %s, got %q, %v", latest, version, err)
+			}
+			if applied, err := migrator.Up(ctx); err != nil || len(applied) != 0 {
+				t.Errorf("expected a second Up to apply nothing, got %v, %v", applied, err)
+			}
+
+			reverted, err := migrator.Down(ctx)
+			if err != nil || reverted != latest {
+				t.Fatalf("expected %s to be reverted, got %q, %v", latest, reverted, err)
+			}
+			statuses, err := migrator.Status(ctx)
+			if err != nil {
+				t.Fatalf("failed to read status: %v", err)
+			}
+			if last := statuses[len(statuses)-1]; last.Version != latest || last.Applied {
+				t.Errorf("expected %s to be pending after Down, got %+v", latest, last)
+			}
+			if applied, err := migrator.Up(ctx); err != nil || len(applied) != 1 {
+				t.Errorf("expected the reverted migration to be re-applied, got %v, %v", applied, err)
+			}
+		})
+	}
+}
+
+func TestMigrator_UpgradesDeclarativeSchema(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			db := openTestDbDriver(t, driver)
+			// As `atlas schema apply` left production databases: the legacy schema but no
+			// revision history
+			files, err := migrations.Load(db.Dialect())
+			if err != nil {
+				t.Fatalf("failed to load migrations: %v", err)
+			}
+			for _, file := range files {
+				if file.Version > legacySchemaVersion {
+					break
+				}
+				for _, statement := range migrations.Statements(file.SQL) {
+					if err := db.db.Exec(statement).Error; err != nil {
+						t.Fatalf("failed to create the legacy schema: %v", err)
+					}
+				}
+			}
+			migrator, err := db.Migrator()
+			if err != nil {
+				t.Fatalf("failed to load migrations: %v", err)
+			}
+
+			if err := migrator.EnsureSchema(ctx, true); err != nil {
+				t.Fatalf("expected the legacy schema to be upgraded, got %v", err)
+			}
+			if !db.db.Migrator().HasColumn(&User{}, "is_admin") || !db.db.Migrator().HasColumn(&Referrer{}, "weekly_capacity") {
+				t.Error("expected the columns added since the legacy schema to exist")
+			}
+			for _, table := range []string{"account_deletions", "skills", "job_postings", "company_aliases", "company_merges"} {
+				if !db.db.Migrator().HasTable(table) {
+					t.Errorf("expected table %s to exist", table)
+				}
+			}
+
+			statuses, err := migrator.Status(ctx)
+			if err != nil {
+				t.Fatalf("failed to read status: %v", err)
+			}
+			for _, status := range statuses {
+				baselined := status.Version <= legacySchemaVersion
+				if !status.Applied || (status.ExecutedAt == nil) != baselined {
+					t.Errorf("expected %s to be applied, baselined %v, got %+v", status.Version, baselined, status)
+				}
+			}
+			if _, err := migrator.Up(ctx); err != nil {
+				t.Errorf("expected a second Up to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrator_RefusesToRevertBaseline(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDbDriver(t, config.DatabaseDriverSQLite)
+	if err := db.db.Exec("CREATE TABLE users (id integer PRIMARY KEY)").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	migrator, err := db.Migrator()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := migrator.ensureRevisionsTable(ctx); err != nil {
+		t.Fatalf("failed to record the baseline: %v", err)
+	}
+	if _, err := migrator.Down(ctx); err == nil {
+		t.Error("expected reverting a baseline to fail")
+	}
+}
+
+func TestMigrator_CheckRefusesMismatchedSchema(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		tamper func(db *DbDriver) error
+	}{
+		{
+			name: "edited migration",
+			tamper: func(db *DbDriver) error {
+				return db.db.Model(&schemaRevision{}).Where("version = ?", "20240818024712").Update("hash", "h1:edited").Error
+			},
+		},
+		{
+			name: "newer than this build",
+			tamper: func(db *DbDriver) error {
+				return db.db.Create(&schemaRevision{Version: "99991231235959", Type: revisionTypeExecute, ExecutedAt: time.Now()}).Error
+			},
+		},
+		{
+			name: "partially applied",
+			tamper: func(db *DbDriver) error {
+				return db.db.Model(&schemaRevision{}).Where("version = ?", "20240818024712").Update("applied", 1).Error
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDbDriver(t, config.DatabaseDriverSQLite)
+			if err := tt.tamper(db); err != nil {
+				t.Fatalf("failed to tamper with revisions: %v", err)
+			}
+			migrator, err := db.Migrator()
+			if err != nil {
+				t.Fatalf("failed to load migrations: %v", err)
+			}
+
+			if err := migrator.EnsureSchema(ctx, true); !errors.Is(err, ErrSchemaMismatch) {
+				t.Errorf("expected ErrSchemaMismatch, got %v", err)
+			}
+		})
+	}
+}
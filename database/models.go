@@ -4,19 +4,20 @@ import (
 	"time"
 
 	_ "ariga.io/atlas-provider-gorm/gormschema"
-	_ "gorm.io/gorm"
+	"gorm.io/gorm"
 )
 
 type Company struct {
 	Id            uint64                     `gorm:"primary_key;autoIncrement" json:"id"`
 	Name          string                     `gorm:"not null" json:"name"`
 	Domains       []CompanyDomainAssociation `gorm:"not null" json:"domains"`
+	Aliases       []CompanyAlias             `gorm:"constraint:OnDelete:CASCADE" json:"aliases"` // See company.go
 	IsSupported   bool                       `gorm:"not null" json:"is_supported"`
 	AddedByUserId uint64                     `gorm:"not null" json:"added_by_user_id"`
 	User          User                       `gorm:"foreignKey:AddedByUserId;references:Id"`
 	CreatedAt     time.Time                  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt     time.Time                  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
-	DeletedAt     *time.Time                 `json:"deleted_at"`
+	DeletedAt     gorm.DeletedAt             `gorm:"index" json:"deleted_at"`
 }
 
 type CompanyDomainAssociation struct {
@@ -25,43 +26,75 @@ type CompanyDomainAssociation struct {
 }
 
 type User struct {
-	Id          uint64     `gorm:"primary_key;autoIncrement" json:"id"`
-	FirstName   string     `gorm:"not null" json:"firstName" validate:"required"`
-	LastName    string     `gorm:"not null" json:"lastName" validate:"required"`
-	Email       string     `gorm:"not null;uniqueIndex" json:"email" validate:"required,email"`
-	PhoneNumber string     `json:"phoneNumber" validate:"omitempty,e164"`
-	PhoneExt    string     `json:"phoneExt" validate:"omitempty,numeric"`
-	LinkedIn    *string    `json:"linkedIn,omitempty" validate:"omitempty,url"`
-	Github      *string    `json:"github,omitempty" validate:"omitempty,url"`
-	Website     *string    `json:"website,omitempty" validate:"omitempty,url"`
-	CreatedAt   time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"createdAt"`
-	UpdatedAt   time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updatedAt"`
-	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
+	Id          uint64  `gorm:"primary_key;autoIncrement" json:"id"`
+	FirstName   string  `gorm:"not null" json:"firstName" validate:"required"`
+	LastName    string  `gorm:"not null" json:"lastName" validate:"required"`
+	Email       string  `gorm:"not null;uniqueIndex:idx_users_email,where:deleted_at IS NULL" json:"email" validate:"required,email"` // Deleted users don't hold on to their email
+	PhoneNumber string  `json:"phoneNumber" validate:"omitempty,e164"`
+	PhoneExt    string  `json:"phoneExt" validate:"omitempty,numeric"`
+	LinkedIn    *string `json:"linkedIn,omitempty" validate:"omitempty,url"`
+	Github      *string `json:"github,omitempty" validate:"omitempty,url"`
+	Website     *string `json:"website,omitempty" validate:"omitempty,url"`
+	IsAdmin     bool    `gorm:"not null;default:false" json:"isAdmin"` // Granted with the `user promote-admin` command
+	// Set when the user's personal data was erased by AnonymizeUser
+	AnonymizedAt *time.Time `json:"anonymizedAt,omitempty"`
+	// Sessions that signed in before this are refused, see RevokeUserSessions
+	SessionsRevokedAt *time.Time     `json:"-"`
+	CreatedAt         time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt         time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updatedAt"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
 }
 
 type Candidate struct {
-	CandidateId    uint64     `gorm:"primary_key;autoIncrement" json:"id"`
-	UserId         uint64     `gorm:"not null;uniqueIndex;index;constraint:OnDelete:CASCADE;foreignKey:UserId;references:Id" json:"userId"`
-	User           User       `gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user"`
-	WorkExperience int        `gorm:"not null;" json:"workExperience"`
-	ResumeUrl      string     `gorm:"not null;" json:"resumeUrl"`
-	CreatedAt      time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"createdAt"`
-	UpdatedAt      time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updatedAt"`
-	DeletedAt      *time.Time `json:"deletedAt,omitempty"`
+	CandidateId uint64 `gorm:"primary_key;autoIncrement" json:"id"`
+	// Unique among rows that aren't deleted, so a user can recreate a profile they deleted
+	UserId         uint64              `gorm:"not null;uniqueIndex:idx_candidates_user_id,where:deleted_at IS NULL;constraint:OnDelete:CASCADE;foreignKey:UserId;references:Id" json:"userId"`
+	User           User                `gorm:"foreignKey:UserId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user"`
+	WorkExperience int                 `gorm:"not null;" json:"workExperience"`
+	ResumeUrl      string              `gorm:"not null;" json:"resumeUrl"`
+	Visibility     CandidateVisibility `gorm:"not null;default:'full'" json:"visibility"` // What referrers see, unless a referral request overrides it
+	// The rest of the profile, see candidate_profile.go
+	Skills             []Skill                      `gorm:"many2many:candidate_skills;joinForeignKey:CandidateId;joinReferences:SkillId;constraint:OnDelete:CASCADE" json:"skills,omitempty"`
+	Education          []CandidateEducation         `gorm:"foreignKey:CandidateId;constraint:OnDelete:CASCADE" json:"education,omitempty"`
+	Positions          []CandidatePosition          `gorm:"foreignKey:CandidateId;constraint:OnDelete:CASCADE" json:"positions,omitempty"`
+	DesiredRoles       []CandidateDesiredRole       `gorm:"foreignKey:CandidateId;constraint:OnDelete:CASCADE" json:"desiredRoles,omitempty"`
+	WorkAuthorizations []CandidateWorkAuthorization `gorm:"foreignKey:CandidateId;constraint:OnDelete:CASCADE" json:"workAuthorizations,omitempty"`
+	Preferences        *CandidatePreferences        `gorm:"foreignKey:CandidateId;constraint:OnDelete:CASCADE" json:"preferences,omitempty"`
+	CreatedAt          time.Time                    `gorm:"not null;default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt          time.Time                    `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updatedAt"`
+	DeletedAt          gorm.DeletedAt               `gorm:"index" json:"deletedAt,omitempty"`
 }
 
 type Referrer struct {
-	ReferrerId     uint64     `gorm:"primary_key;autoIncrement" json:"id"`
-	UserId         uint64     `gorm:"not null;uniqueIndex;index;constraint:OnDelete:CASCADE;foreignKey:UserId;references:Id" json:"userId"`
-	User           User       `json:"user"`
-	CompanyId      uint64     `gorm:"not null;" json:"companyId"`
-	Company        Company    `json:"company"`
-	CorporateEmail string     `gorm:"not null;" json:"corporateEmail"`
-	CreatedAt      time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"createdAt"`
-	UpdatedAt      time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updatedAt"`
-	DeletedAt      *time.Time `json:"deletedAt,omitempty"`
+	ReferrerId uint64 `gorm:"primary_key;autoIncrement" json:"id"`
+	// Unique among rows that aren't deleted, so a user can recreate a profile they deleted
+	UserId         uint64  `gorm:"not null;uniqueIndex:idx_referrers_user_id,where:deleted_at IS NULL;constraint:OnDelete:CASCADE;foreignKey:UserId;references:Id" json:"userId"`
+	User           User    `json:"user"`
+	CompanyId      uint64  `gorm:"not null;" json:"companyId"`
+	Company        Company `json:"company"`
+	CorporateEmail string  `gorm:"not null;" json:"corporateEmail"`
+	// What the referrer can refer for and how much, see referrer_profile.go
+	Team             string              `gorm:"not null;default:''" json:"team"`
+	Seniority        Seniority           `gorm:"not null;default:''" json:"seniority"`
+	JobFamilies      []ReferrerJobFamily `gorm:"foreignKey:ReferrerId;constraint:OnDelete:CASCADE" json:"jobFamilies,omitempty"`
+	Locations        []ReferrerLocation  `gorm:"foreignKey:ReferrerId;constraint:OnDelete:CASCADE" json:"locations,omitempty"`
+	WeeklyCapacity   int                 `gorm:"not null;default:0" json:"weeklyCapacity"` // Claims per rolling week, 0 for no limit
+	UnavailableUntil *time.Time          `json:"unavailableUntil,omitempty"`               // Vacation mode
+	CreatedAt        time.Time           `gorm:"not null;default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt        time.Time           `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updatedAt"`
+	DeletedAt        gorm.DeletedAt      `gorm:"index" json:"deletedAt,omitempty"`
 }
 
+// CandidateVisibility is how much of a candidate's profile referrers at the company see. The
+// referrer who claims a referral request always sees the full profile and contact details.
+type CandidateVisibility string
+
+const (
+	VisibilityAnonymous CandidateVisibility = "anonymous" // Work experience only
+	VisibilityNameOnly  CandidateVisibility = "name_only" // Also the name
+	VisibilityFull      CandidateVisibility = "full"      // Also the resume and profile links
+)
+
 type ReferralType string
 
 const (
@@ -81,6 +114,14 @@ const (
 	Issue                      ReferralStatus = "Issue"
 )
 
+// OpenReferralStatuses are the statuses of referral requests still waiting on an outcome.
+var OpenReferralStatuses = []ReferralStatus{ReferralRequested, ReferralSubmissionSent}
+
+// IsOpen reports whether a referral request in this status is still waiting on an outcome.
+func (s ReferralStatus) IsOpen() bool {
+	return s == ReferralRequested || s == ReferralSubmissionSent
+}
+
 type ReferralRequest struct {
 	ReferralRequestId      uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
 	CandidateID            uint64 `gorm:"notNull" json:"candidate_id"`
@@ -92,20 +133,54 @@ type ReferralRequest struct {
 	ReferralType           ReferralType `gorm:"notNull" json:"referral_type"`
 	ReferrerId             *uint64      `gorm:"foreignKey:ReferrerId;references:ReferrerId" json:"referrer_id"`
 	Referrer               *Referrer
-	Status                 ReferralStatus `gorm:"notNull" json:"status"`
-	CreatedAt              time.Time      `gorm:"notNull;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt              time.Time      `gorm:"notNull;default:CURRENT_TIMESTAMP" json:"updated_at"`
-	DeletedAt              *time.Time     `json:"deleted_at,omitempty"`
+	Status                 ReferralStatus        `gorm:"notNull" json:"status"`
+	Visibility             *CandidateVisibility  `json:"visibility,omitempty"` // Overrides the candidate's visibility when set
+	ClaimedAt              *time.Time            `json:"claimed_at,omitempty"`
+	JobPostingId           *uint64               `gorm:"index" json:"job_posting_id,omitempty"` // Set when the candidate applied through a referrer's posting, see job_posting.go
+	Views                  []ReferralRequestView `gorm:"constraint:OnDelete:CASCADE" json:"-"`  // See referral_request_view.go
+	CreatedAt              time.Time             `gorm:"notNull;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt              time.Time             `gorm:"notNull;default:CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt              gorm.DeletedAt        `gorm:"index" json:"deleted_at,omitempty"`
 	Candidate              Candidate
 	Company                Company
 }
 
+// ATS is the applicant tracking system hosting a job posting, when it's one we recognize.
+type ATS string
+
+const (
+	ATSGreenhouse ATS = "greenhouse"
+	ATSLever      ATS = "lever"
+	ATSWorkday    ATS = "workday"
+	ATSAshby      ATS = "ashby"
+)
+
 type ReferralRequestJobLinksAssociation struct {
 	ReferralRequestID uint64 `gorm:"primaryKey;autoIncrement:false" json:"referral_request_id"`
-	JobLink           string `gorm:"primaryKey;autoIncrement:false" json:"job_link"`
+	JobLink           string `gorm:"primaryKey;autoIncrement:false" json:"job_link"` // Canonical, see service/job_link.go
+	ATS               ATS    `gorm:"not null;default:''" json:"ats,omitempty"`
+	ATSJobId          string `gorm:"not null;default:''" json:"ats_job_id,omitempty"` // The posting's ID on the ATS
 }
 
+// ReferralRequestLocationAssociation is a location as the candidate wrote it, resolved against
+// the gazetteer in service/location.go. The structured fields are empty when it couldn't be.
 type ReferralRequestLocationAssociation struct {
 	ReferralRequestID uint64 `gorm:"primaryKey;autoIncrement:false" json:"referral_request_id"`
 	Location          string `gorm:"primaryKey;autoIncrement:false" json:"location"`
+	City              string `gorm:"not null;default:''" json:"city,omitempty"`
+	Region            string `gorm:"not null;default:''" json:"region,omitempty"`        // Subdivision code, e.g. NY
+	Country           string `gorm:"not null;default:'';index" json:"country,omitempty"` // ISO 3166-1 alpha-2 code
+	Remote            bool   `gorm:"not null;default:false" json:"remote,omitempty"`
+}
+
+// EffectiveVisibility is the visibility referrers get for this request: its own if set, else
+// the candidate's. It needs Candidate loaded, and is VisibilityAnonymous if it isn't.
+func (r *ReferralRequest) EffectiveVisibility() CandidateVisibility {
+	if r.Visibility != nil && *r.Visibility != "" {
+		return *r.Visibility
+	}
+	if r.Candidate.Visibility != "" {
+		return r.Candidate.Visibility
+	}
+	return VisibilityAnonymous
 }
@@ -0,0 +1,68 @@
+package database
+
+import "gorm.io/gorm"
+
+// PersonalData is every row stored about one user, including soft-deleted ones.
+type PersonalData struct {
+	User                 User
+	Candidates           []Candidate           // With their profile
+	Referrers            []Referrer            // With their company
+	ReferralRequests     []ReferralRequest     // Made as a candidate, with company, job links and locations
+	ReferralsHandled     []ReferralRequest     // Claimed as a referrer, with company
+	JobPostings          []JobPosting          // Posted as a referrer, with company
+	ReferralRequestViews []ReferralRequestView // Referral requests opened as a referrer
+	EmailVerifications   []EmailVerification
+	CompaniesAdded       []Company // With domains
+}
+
+// GetPersonalData loads everything stored about the user, deleted rows included. It returns
+// gorm.ErrRecordNotFound if the user doesn't exist.
+func (db *DbDriver) GetPersonalData(userId uint64) (*PersonalData, error) {
+	var data PersonalData
+	err := db.Transaction(func(tx *DbDriver) error {
+		// Each query starts afresh from tx.db, so that their conditions don't accumulate
+		unscoped := func() *gorm.DB { return tx.db.Unscoped() }
+		company := func(db *gorm.DB) *gorm.DB { return db.Unscoped() }
+
+		if err := unscoped().First(&data.User, userId).Error; err != nil {
+			return err
+		}
+		if err := preloadCandidateProfile(unscoped(), "").Where("user_id = ?", userId).Order("candidate_id").Find(&data.Candidates).Error; err != nil {
+			return err
+		}
+		if err := preloadReferrerProfile(unscoped().Preload("Company", company), "").Where("user_id = ?", userId).Order("referrer_id").Find(&data.Referrers).Error; err != nil {
+			return err
+		}
+
+		candidateIds := unscoped().Model(&Candidate{}).Select("candidate_id").Where("user_id = ?", userId)
+		err := unscoped().Preload("Company", company).Preload("JobLinks").Preload("Locations").
+			Where("candidate_id IN (?)", candidateIds).Order("referral_request_id").Find(&data.ReferralRequests).Error
+		if err != nil {
+			return err
+		}
+		referrerIds := unscoped().Model(&Referrer{}).Select("referrer_id").Where("user_id = ?", userId)
+		err = unscoped().Preload("Company", company).
+			Where("referrer_id IN (?)", referrerIds).Order("referral_request_id").Find(&data.ReferralsHandled).Error
+		if err != nil {
+			return err
+		}
+		err = unscoped().Preload("Company", company).
+			Where("referrer_id IN (?)", referrerIds).Order("job_posting_id").Find(&data.JobPostings).Error
+		if err != nil {
+			return err
+		}
+		err = unscoped().Where("referrer_id IN (?)", referrerIds).Order("first_viewed_at, referral_request_id").Find(&data.ReferralRequestViews).Error
+		if err != nil {
+			return err
+		}
+
+		if err := unscoped().Where("user_id = ?", userId).Order("expires_at").Find(&data.EmailVerifications).Error; err != nil {
+			return err
+		}
+		return unscoped().Preload("Domains").Where("added_by_user_id = ?", userId).Order("id").Find(&data.CompaniesAdded).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
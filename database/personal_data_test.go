@@ -0,0 +1,58 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestGetPersonalData_IncludesDeletedRecords(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 1)
+			if err := db.RecordReferralRequestView(request.ReferralRequestId, referrerIds[0], time.Now()); err != nil {
+				t.Fatalf("failed to record view: %v", err)
+			}
+			if _, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerIds[0]); err != nil {
+				t.Fatalf("failed to claim referral request: %v", err)
+			}
+			user := db.GetUserByEmail("candidate@example.com")
+			if err := db.DeleteCandidate(user.Id, db.GetCandidateByUserId(user.Id)); err != nil {
+				t.Fatalf("failed to delete candidate: %v", err)
+			}
+
+			data, err := db.GetPersonalData(user.Id)
+			if err != nil {
+				t.Fatalf("failed to get personal data: %v", err)
+			}
+			if len(data.Candidates) != 1 || !data.Candidates[0].DeletedAt.Valid {
+				t.Errorf("expected the deleted candidate profile, got %+v", data.Candidates)
+			}
+			if len(data.ReferralRequests) != 1 || data.ReferralRequests[0].Company.Name != "Example" {
+				t.Errorf("expected the deleted referral request with its company, got %+v", data.ReferralRequests)
+			}
+			if len(data.CompaniesAdded) != 1 || len(data.Referrers) != 0 || len(data.ReferralsHandled) != 0 || len(data.ReferralRequestViews) != 0 {
+				t.Errorf("expected one company added and nothing as a referrer, got %+v", data)
+			}
+
+			referrer := db.GetReferrerById(referrerIds[0])
+			data, err = db.GetPersonalData(referrer.UserId)
+			if err != nil {
+				t.Fatalf("failed to get personal data: %v", err)
+			}
+			if len(data.Referrers) != 1 || len(data.ReferralsHandled) != 1 {
+				t.Errorf("expected the referrer profile and the referral they handled, got %+v", data)
+			}
+			if len(data.ReferralRequestViews) != 1 || data.ReferralRequestViews[0].ReferralRequestId != request.ReferralRequestId {
+				t.Errorf("expected the referral they opened, got %+v", data.ReferralRequestViews)
+			}
+
+			if _, err := db.GetPersonalData(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("expected an unknown user to be not found, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"database/sql"
+	"net/url"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// migrationLockKey identifies the advisory lock held while applying migrations.
+const migrationLockKey = 7226737953
+
+type postgresDialect struct{}
+
+func (postgresDialect) name() string {
+	return config.DatabaseDriverPostgres
+}
+
+func (postgresDialect) open(cfg config.DatabaseConfig) gorm.Dialector {
+	return postgres.Open(cfg.URL)
+}
+
+// target returns the host and database name from a URL-style connection string, or a
+// placeholder for the key=value form, which may hold a password anywhere in it.
+func (postgresDialect) target(cfg config.DatabaseConfig) string {
+	parsed, err := url.Parse(cfg.URL)
+	if err != nil || parsed.Host == "" {
+		return "postgres"
+	}
+	return parsed.Host + parsed.Path
+}
+
+func (postgresDialect) configurePool(sqlDb *sql.DB, cfg config.DatabaseConfig) {
+	sqlDb.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDb.SetMaxIdleConns(cfg.MaxIdleConns)
+}
+
+func (postgresDialect) beforeClose(db *gorm.DB) error {
+	return nil
+}
+
+// lockMigrations takes a transaction-scoped advisory lock, released on commit or rollback.
+func (postgresDialect) lockMigrations(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error
+}
+
+// lockRow selects the row FOR UPDATE, which blocks other transactions locking it until commit or
+// rollback.
+func (postgresDialect) lockRow(tx *gorm.DB, table, column string, id uint64) error {
+	return tx.Exec("SELECT 1 FROM "+table+" WHERE "+column+" = ? FOR UPDATE", id).Error
+}
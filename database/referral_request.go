@@ -1,27 +1,59 @@
 package database
 
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrReferralRequestNotClaimable is returned when a referral request has already been claimed
+// by a referrer or is no longer waiting for one.
+var ErrReferralRequestNotClaimable = errors.New("referral request is no longer open to be claimed")
+
+// preloadReferralRequest loads a referral request's associations, including the candidate's
+// profile. The company and referrer are loaded even if they have since been deleted, so the
+// request's history stays complete.
+func preloadReferralRequest(query *gorm.DB) *gorm.DB {
+	unscoped := func(db *gorm.DB) *gorm.DB { return db.Unscoped() }
+	return preloadCandidateProfile(query, "Candidate.").
+		Preload("Candidate").
+		Preload("Candidate.User").
+		Preload("Company", unscoped).
+		Preload("Referrer", unscoped).
+		Preload("Referrer.User", unscoped).
+		Preload("JobLinks").
+		Preload("Locations")
+}
+
 func (db *DbDriver) CreateReferralRequest(record *ReferralRequest) (*ReferralRequest, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	if err := db.db.Create(record).Error; err != nil {
 		return nil, err
 	}
 	return record, nil
 }
 
+// UpdateReferralRequest saves the referral request, replacing its job links and locations with
+// the ones on record.
 func (db *DbDriver) UpdateReferralRequest(record *ReferralRequest) (*ReferralRequest, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	var updatedRecord ReferralRequest
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("referral_request_id = ?", record.ReferralRequestId).Delete(&ReferralRequestJobLinksAssociation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("referral_request_id = ?", record.ReferralRequestId).Delete(&ReferralRequestLocationAssociation{}).Error; err != nil {
+			return err
+		}
 
-	// Save the updated record
-	result := db.db.Save(record)
-	if result.Error != nil {
-		return nil, result.Error // Handle the error, could be due to a database issue
-	}
+		// Save the updated record
+		if err := tx.Save(record).Error; err != nil {
+			return err // Handle the error, could be due to a database issue
+		}
 
-	// Fetch the updated record
-	var updatedRecord ReferralRequest
-	if err := db.db.Where("referral_request_id = ?", record.ReferralRequestId).First(&updatedRecord).Error; err != nil {
+		// Fetch the updated record
+		return tx.Where("referral_request_id = ?", record.ReferralRequestId).First(&updatedRecord).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -29,22 +61,12 @@ func (db *DbDriver) UpdateReferralRequest(record *ReferralRequest) (*ReferralReq
 }
 
 func (db *DbDriver) DeleteReferralRequest(record *ReferralRequest) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	return db.db.Delete(record).Error
 }
 
 func (db *DbDriver) GetReferralRequestById(id uint64) *ReferralRequest {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var referralRequest ReferralRequest
-	db.db.Preload("Candidate").
-		Preload("Candidate.User").
-		Preload("Company").
-		Preload("Referrer").
-		Preload("Referrer.User").
-		Preload("JobLinks").
-		Preload("Locations").
+	preloadReferralRequest(db.db).
 		First(&referralRequest, id)
 	if referralRequest.ReferralRequestId == 0 { // Checking if the referral request was found
 		return nil
@@ -53,64 +75,32 @@ func (db *DbDriver) GetReferralRequestById(id uint64) *ReferralRequest {
 }
 
 func (db *DbDriver) GetReferralRequestsByReferrerId(referrerId uint64) []ReferralRequest {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var referralRequests []ReferralRequest
-	db.db.Preload("Candidate").
-		Preload("Candidate.User").
-		Preload("Company").
-		Preload("Referrer").
-		Preload("Referrer.User").
-		Preload("JobLinks").
-		Preload("Locations").
+	preloadReferralRequest(db.db).
 		Where("referrer_id = ?", referrerId).
 		Find(&referralRequests)
 	return referralRequests
 }
 
 func (db *DbDriver) GetReferralRequestsByCandidateId(candidateId uint64) []ReferralRequest {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var referralRequests []ReferralRequest
-	db.db.Preload("Candidate").
-		Preload("Candidate.User").
-		Preload("Company").
-		Preload("Referrer").
-		Preload("Referrer.User").
-		Preload("JobLinks").
-		Preload("Locations").
+	preloadReferralRequest(db.db).
 		Where("candidate_id = ?", candidateId).
 		Find(&referralRequests)
 	return referralRequests
 }
 
 func (db *DbDriver) GetReferralRequestsByCompanyId(companyId uint64) []ReferralRequest {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var referralRequests []ReferralRequest
-	db.db.Preload("Candidate").
-		Preload("Candidate.User").
-		Preload("Company").
-		Preload("Referrer").
-		Preload("Referrer.User").
-		Preload("JobLinks").
-		Preload("Locations").
+	preloadReferralRequest(db.db).
 		Where("company_id = ?", companyId).
 		Find(&referralRequests)
 	return referralRequests
 }
 
 func (db *DbDriver) GetReferralRequestByIdAndCandidateId(referralRequestId, candidateId uint64) *ReferralRequest {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var referralRequest ReferralRequest
-	db.db.Preload("Candidate").
-		Preload("Candidate.User").
-		Preload("Company").
-		Preload("Referrer").
-		Preload("Referrer.User").
-		Preload("JobLinks").
-		Preload("Locations").
+	preloadReferralRequest(db.db).
 		Where("id = ? AND candidate_id = ?", referralRequestId, candidateId).
 		First(&referralRequest)
 	if referralRequest.ReferralRequestId == 0 { // Checking if the referral request was found
@@ -118,3 +108,83 @@ func (db *DbDriver) GetReferralRequestByIdAndCandidateId(referralRequestId, cand
 	}
 	return &referralRequest
 }
+
+// releaseReferralRequests hands the open referral requests of the referrers selected by
+// referrerIds back to every referrer at the company: those it claimed go from "Referred for Job"
+// back to "Referral Requested", and those assigned to it through a job posting lose the
+// assignment. Requests with an outcome keep the referrer. Restoring the referrer doesn't take
+// the requests back.
+func (db *DbDriver) releaseReferralRequests(referrerIds *gorm.DB) error {
+	return db.db.Model(&ReferralRequest{}).
+		Where("referrer_id IN (?) AND status IN ?", referrerIds, OpenReferralStatuses).
+		Updates(map[string]interface{}{
+			"referrer_id": nil,
+			"status":      ReferralRequested,
+			"claimed_at":  nil,
+		}).Error
+}
+
+// ClaimReferralRequest assigns an unclaimed, requested referral request to a referrer and marks
+// the candidate as referred. A request already assigned to the referrer, made through one of
+// their job postings, can be claimed by them too. The check and the update are a single
+// conditional UPDATE, so two referrers claiming the same request at once can't both succeed;
+// the caller is told which case applies through gorm.ErrRecordNotFound or
+// ErrReferralRequestNotClaimable.
+func (db *DbDriver) ClaimReferralRequest(referralRequestId, referrerId uint64) (*ReferralRequest, error) {
+	var claimed ReferralRequest
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&ReferralRequest{}).
+			Where("referral_request_id = ? AND status = ? AND (referrer_id IS NULL OR referrer_id = ?)", referralRequestId, ReferralRequested, referrerId).
+			Updates(map[string]interface{}{
+				"referrer_id": referrerId,
+				"status":      ReferralSubmissionSent,
+				"claimed_at":  time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		err := preloadReferralRequest(tx).
+			First(&claimed, referralRequestId).Error
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return ErrReferralRequestNotClaimable
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claimed, nil
+}
+
+// GetUnplacedReferralRequestLocations returns the referral request locations that aren't placed
+// in a country, such as the ones stored before locations were normalized.
+func (db *DbDriver) GetUnplacedReferralRequestLocations() ([]ReferralRequestLocationAssociation, error) {
+	var locations []ReferralRequestLocationAssociation
+	err := db.db.Where("country = ?", "").Find(&locations).Error
+	return locations, err
+}
+
+// UpdateReferralRequestLocations saves the structured fields of the given referral request
+// locations, all in one transaction.
+func (db *DbDriver) UpdateReferralRequestLocations(locations []ReferralRequestLocationAssociation) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		for _, location := range locations {
+			err := tx.Model(&ReferralRequestLocationAssociation{}).
+				Where("referral_request_id = ? AND location = ?", location.ReferralRequestID, location.Location).
+				Updates(map[string]interface{}{
+					"city":    location.City,
+					"region":  location.Region,
+					"country": location.Country,
+					"remote":  location.Remote,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// ReferralRequestView records that a referrer opened a referral request, first and most recently.
+type ReferralRequestView struct {
+	ReferralRequestId uint64    `gorm:"primaryKey;autoIncrement:false" json:"referralRequestId"`
+	ReferrerId        uint64    `gorm:"primaryKey;autoIncrement:false;index" json:"referrerId"`
+	Referrer          *Referrer `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	FirstViewedAt     time.Time `gorm:"not null" json:"firstViewedAt"`
+	LastViewedAt      time.Time `gorm:"not null" json:"lastViewedAt"`
+}
+
+// RecordReferralRequestView records that the referrer opened the referral request at now.
+func (db *DbDriver) RecordReferralRequestView(referralRequestId, referrerId uint64, now time.Time) error {
+	return db.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "referral_request_id"}, {Name: "referrer_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_viewed_at"}),
+	}).Create(&ReferralRequestView{
+		ReferralRequestId: referralRequestId,
+		ReferrerId:        referrerId,
+		FirstViewedAt:     now,
+		LastViewedAt:      now,
+	}).Error
+}
+
+// CountReferralRequestViewers returns, for each of the referral requests that has been opened,
+// how many referrers other than excludeReferrerId opened it.
+func (db *DbDriver) CountReferralRequestViewers(referralRequestIds []uint64, excludeReferrerId uint64) (map[uint64]int64, error) {
+	counts := make(map[uint64]int64)
+	if len(referralRequestIds) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		ReferralRequestId uint64
+		Viewers           int64
+	}
+	err := db.db.Model(&ReferralRequestView{}).
+		Select("referral_request_id, COUNT(*) AS viewers").
+		Where("referral_request_id IN ? AND referrer_id <> ?", referralRequestIds, excludeReferrerId).
+		Group("referral_request_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.ReferralRequestId] = row.Viewers
+	}
+	return counts, nil
+}
+
+// CountReferrerClaimsByType returns how many referral requests of each type the referrer has
+// claimed, including requests deleted since.
+func (db *DbDriver) CountReferrerClaimsByType(referrerId uint64) (map[ReferralType]int64, error) {
+	var rows []struct {
+		ReferralType ReferralType
+		Claims       int64
+	}
+	err := db.db.Unscoped().Model(&ReferralRequest{}).
+		Select("referral_type, COUNT(*) AS claims").
+		Where("referrer_id = ?", referrerId).
+		Group("referral_type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[ReferralType]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ReferralType] = row.Claims
+	}
+	return counts, nil
+}
+
+// GetOpenReferralRequestsByCompanyId returns the company's referral requests that are waiting
+// for a referrer, oldest first.
+func (db *DbDriver) GetOpenReferralRequestsByCompanyId(companyId uint64) []ReferralRequest {
+	var referralRequests []ReferralRequest
+	preloadReferralRequest(db.db).
+		Where("company_id = ? AND status = ? AND referrer_id IS NULL", companyId, ReferralRequested).
+		Order("created_at, referral_request_id").
+		Find(&referralRequests)
+	return referralRequests
+}
+
+// ReferralRequestTiming is when a referral request was created, first opened by a referrer and
+// claimed, for response time statistics.
+type ReferralRequestTiming struct {
+	ReferralRequestId uint64
+	CompanyId         uint64
+	CompanyName       string
+	CreatedAt         time.Time
+	FirstViewedAt     *time.Time
+	ClaimedAt         *time.Time
+}
+
+// GetReferralRequestTimings returns the timings of the referral requests created since the given
+// time, by company and then age.
+func (db *DbDriver) GetReferralRequestTimings(since time.Time) ([]ReferralRequestTiming, error) {
+	var timings []ReferralRequestTiming
+	err := db.db.Model(&ReferralRequest{}).
+		Select("referral_requests.referral_request_id, referral_requests.company_id, companies.name AS company_name, "+
+			"referral_requests.created_at, referral_requests.claimed_at").
+		Joins("JOIN companies ON companies.id = referral_requests.company_id").
+		Where("referral_requests.created_at >= ?", since).
+		Order("referral_requests.company_id, referral_requests.created_at").
+		Scan(&timings).Error
+	if err != nil || len(timings) == 0 {
+		return timings, err
+	}
+
+	// The first view is the earliest of the referrers' first views
+	var views []ReferralRequestView
+	err = db.db.Select("referral_request_id, first_viewed_at").
+		Where("referral_request_id IN (?)", db.db.Model(&ReferralRequest{}).Select("referral_request_id").Where("created_at >= ?", since)).
+		Find(&views).Error
+	if err != nil {
+		return nil, err
+	}
+	firstViews := make(map[uint64]time.Time, len(views))
+	for _, view := range views {
+		if first, ok := firstViews[view.ReferralRequestId]; !ok || view.FirstViewedAt.Before(first) {
+			firstViews[view.ReferralRequestId] = view.FirstViewedAt
+		}
+	}
+	for i := range timings {
+		if first, ok := firstViews[timings[i].ReferralRequestId]; ok {
+			timings[i].FirstViewedAt = &first
+		}
+	}
+	return timings, nil
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReferralRequestViews_RecordAndCount(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 3)
+			id := request.ReferralRequestId
+
+			first := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+			for _, referrerId := range referrerIds[:2] {
+				if err := db.RecordReferralRequestView(id, referrerId, first); err != nil {
+					t.Fatalf("failed to record view: %v", err)
+				}
+			}
+			// Opening it again only moves the last view
+			if err := db.RecordReferralRequestView(id, referrerIds[0], first.Add(time.Minute)); err != nil {
+				t.Fatalf("failed to record view again: %v", err)
+			}
+			var view ReferralRequestView
+			if err := db.db.Where("referral_request_id = ? AND referrer_id = ?", id, referrerIds[0]).First(&view).Error; err != nil {
+				t.Fatalf("failed to load view: %v", err)
+			}
+			if !view.FirstViewedAt.Equal(first) || !view.LastViewedAt.Equal(first.Add(time.Minute)) {
+				t.Errorf("expected first view %v and last view %v, got %+v", first, first.Add(time.Minute), view)
+			}
+
+			counts, err := db.CountReferralRequestViewers([]uint64{id, id + 1}, referrerIds[0])
+			if err != nil {
+				t.Fatalf("failed to count viewers: %v", err)
+			}
+			if counts[id] != 1 || counts[id+1] != 0 {
+				t.Errorf("expected one other viewer, got %v", counts)
+			}
+			if counts, err := db.CountReferralRequestViewers([]uint64{id}, referrerIds[2]); err != nil || counts[id] != 2 {
+				t.Errorf("expected two viewers, got %v, %v", counts, err)
+			}
+		})
+	}
+}
+
+func TestOpenReferralRequestsAndClaimsByType(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 1)
+
+			if open := db.GetOpenReferralRequestsByCompanyId(request.CompanyID); len(open) != 1 {
+				t.Fatalf("expected the request to be open, got %d", len(open))
+			}
+			if _, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerIds[0]); err != nil {
+				t.Fatalf("failed to claim referral request: %v", err)
+			}
+			if open := db.GetOpenReferralRequestsByCompanyId(request.CompanyID); len(open) != 0 {
+				t.Errorf("expected the claimed request not to be open, got %d", len(open))
+			}
+			counts, err := db.CountReferrerClaimsByType(referrerIds[0])
+			if err != nil || counts[FullTime] != 1 || len(counts) != 1 {
+				t.Errorf("expected one full-time claim, got %v, %v", counts, err)
+			}
+		})
+	}
+}
+
+func TestGetReferralRequestTimings(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 2)
+			created := db.GetReferralRequestById(request.ReferralRequestId).CreatedAt
+
+			if timings, err := db.GetReferralRequestTimings(created.Add(-time.Hour)); err != nil || len(timings) != 1 ||
+				timings[0].FirstViewedAt != nil || timings[0].ClaimedAt != nil {
+				t.Fatalf("expected an unviewed, unclaimed request, got %+v, %v", timings, err)
+			}
+
+			firstView := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+			if err := db.RecordReferralRequestView(request.ReferralRequestId, referrerIds[1], firstView.Add(time.Hour)); err != nil {
+				t.Fatalf("failed to record view: %v", err)
+			}
+			if err := db.RecordReferralRequestView(request.ReferralRequestId, referrerIds[0], firstView); err != nil {
+				t.Fatalf("failed to record view: %v", err)
+			}
+			if _, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerIds[0]); err != nil {
+				t.Fatalf("failed to claim referral request: %v", err)
+			}
+
+			timings, err := db.GetReferralRequestTimings(created.Add(-time.Hour))
+			if err != nil || len(timings) != 1 {
+				t.Fatalf("expected one timing, got %+v, %v", timings, err)
+			}
+			timing := timings[0]
+			if timing.CompanyName != "Example" || timing.FirstViewedAt == nil || !timing.FirstViewedAt.Equal(firstView) || timing.ClaimedAt == nil {
+				t.Errorf("expected the earliest view and the claim, got %+v", timing)
+			}
+			if timings, err := db.GetReferralRequestTimings(time.Now().Add(time.Hour)); err != nil || len(timings) != 0 {
+				t.Errorf("expected no requests created in the future, got %+v, %v", timings, err)
+			}
+		})
+	}
+}
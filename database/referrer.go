@@ -1,10 +1,12 @@
 package database
 
-import "fmt"
+import (
+	"fmt"
+
+	"gorm.io/gorm/clause"
+)
 
 func (db *DbDriver) CreateReferrer(record *Referrer) (*Referrer, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	if err := db.db.Create(record).Error; err != nil {
 		return nil, err
 	}
@@ -12,59 +14,58 @@ func (db *DbDriver) CreateReferrer(record *Referrer) (*Referrer, error) {
 }
 
 func (db *DbDriver) UpdateReferrer(userId uint64, record *Referrer) (*Referrer, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	// Ensure the referrer belongs to the user
 	if record.UserId != userId {
 		return nil, fmt.Errorf("unauthorized to update this referrer")
 	}
 
-	// Save updates to the referrer if it belongs to the user
-	result := db.db.Model(record).Where("user_id = ?", userId).Save(record)
-	if result.Error != nil {
-		return nil, result.Error // Handle the error, could be due to a database issue
-	}
-
-	// Fetch the updated record
 	var updatedRecord Referrer
-	if err := db.db.Where("referrer_id = ? AND user_id = ?", record.ReferrerId, userId).First(&updatedRecord).Error; err != nil {
+	err := db.Transaction(func(tx *DbDriver) error {
+		// Save updates to the referrer if it belongs to the user. Vacation mode has its own
+		// endpoint, so it's left as it is.
+		if err := tx.db.Model(record).Where("user_id = ?", userId).Omit(clause.Associations, "unavailable_until").Save(record).Error; err != nil {
+			return err // Handle the error, could be due to a database issue
+		}
+		if err := tx.replaceReferrerProfile(record); err != nil {
+			return err
+		}
+
+		// Fetch the updated record
+		return preloadReferrerProfile(tx.db, "").Where("referrer_id = ? AND user_id = ?", record.ReferrerId, userId).First(&updatedRecord).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &updatedRecord, nil
 }
 
+// DeleteReferrer soft-deletes the referrer profile. Referral requests the referrer saw through
+// keep pointing at it, so their history survives the referrer leaving; the open ones it claimed
+// or was assigned are released for other referrers (see releaseReferralRequests).
 func (db *DbDriver) DeleteReferrer(userId uint64, record *Referrer) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	// Ensure the referrer belongs to the user
 	if record.UserId != userId {
 		return fmt.Errorf("unauthorized to delete this referrer")
 	}
 
-	// Delete the referrer if it belongs to the user
-	result := db.db.Where("user_id = ?", userId).Delete(record)
-	if result.Error != nil {
-		return result.Error // Handle the error, could be due to a database issue
-	}
-
-	return nil
+	return db.Transaction(func(tx *DbDriver) error {
+		if err := tx.releaseReferralRequests(tx.db.Model(&Referrer{}).Select("referrer_id").Where("referrer_id = ? AND user_id = ?", record.ReferrerId, userId)); err != nil {
+			return err
+		}
+		// Delete the referrer if it belongs to the user
+		return tx.db.Where("user_id = ?", userId).Delete(record).Error
+	})
 }
 
 func (db *DbDriver) GetReferrerById(id uint64) *Referrer {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var referrer Referrer
-	db.db.Preload("User").First(&referrer, id)
+	preloadReferrerProfile(db.db.Preload("User"), "").First(&referrer, id)
 	return &referrer
 }
 
 func (db *DbDriver) GetReferrerByUserId(userId uint64) *Referrer {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var referrer Referrer
-	db.db.Preload("User").Where("user_id = ?", userId).First(&referrer)
+	preloadReferrerProfile(db.db.Preload("User"), "").Where("user_id = ?", userId).First(&referrer)
 	return &referrer
 }
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// A referrer declares their team, seniority, the job families and locations they can credibly
+// refer for and how many referral requests they can take on a week. While unavailable (vacation
+// mode) they're left out of matching.
+
+type Seniority string
+
+const (
+	SeniorityUnspecified Seniority = ""
+	SeniorityJunior      Seniority = "junior"
+	SeniorityMid         Seniority = "mid"
+	SenioritySenior      Seniority = "senior"
+	SeniorityStaff       Seniority = "staff"
+	SeniorityPrincipal   Seniority = "principal"
+	SeniorityManager     Seniority = "manager"
+	SeniorityDirector    Seniority = "director"
+	SeniorityExecutive   Seniority = "executive"
+)
+
+// JobFamily is a broad kind of role, so that referrers and requests can be matched without
+// comparing job titles word for word.
+type JobFamily string
+
+const (
+	JobFamilyEngineering JobFamily = "engineering"
+	JobFamilyData        JobFamily = "data"
+	JobFamilyProduct     JobFamily = "product"
+	JobFamilyDesign      JobFamily = "design"
+	JobFamilyMarketing   JobFamily = "marketing"
+	JobFamilySales       JobFamily = "sales"
+	JobFamilyOperations  JobFamily = "operations"
+	JobFamilyFinance     JobFamily = "finance"
+	JobFamilyPeople      JobFamily = "people"
+	JobFamilyLegal       JobFamily = "legal"
+	JobFamilySupport     JobFamily = "support"
+	JobFamilyOther       JobFamily = "other"
+)
+
+type ReferrerJobFamily struct {
+	ReferrerId uint64    `gorm:"primaryKey;autoIncrement:false" json:"referrerId"`
+	JobFamily  JobFamily `gorm:"primaryKey;autoIncrement:false" json:"jobFamily"`
+}
+
+type ReferrerLocation struct {
+	ReferrerId uint64 `gorm:"primaryKey;autoIncrement:false" json:"referrerId"`
+	Location   string `gorm:"primaryKey;autoIncrement:false" json:"location"`
+}
+
+// ClaimWindow is the rolling period a referrer's WeeklyCapacity applies to.
+const ClaimWindow = 7 * 24 * time.Hour
+
+// IsAvailable reports whether the referrer is out of vacation mode at now.
+func (r *Referrer) IsAvailable(now time.Time) bool {
+	return r.UnavailableUntil == nil || !now.Before(*r.UnavailableUntil)
+}
+
+// preloadReferrerProfile loads the job families and locations of the referrer at path, e.g.
+// "Referrer." for a referral request's referrer or "" for a referrer itself.
+func preloadReferrerProfile(query *gorm.DB, path string) *gorm.DB {
+	return query.Preload(path+"JobFamilies", func(db *gorm.DB) *gorm.DB { return db.Order("job_family") }).
+		Preload(path+"Locations", func(db *gorm.DB) *gorm.DB { return db.Order("location") })
+}
+
+// replaceReferrerProfile replaces the referrer's job families and locations with the ones on
+// record.
+func (db *DbDriver) replaceReferrerProfile(record *Referrer) error {
+	if err := db.db.Where("referrer_id = ?", record.ReferrerId).Delete(&ReferrerJobFamily{}).Error; err != nil {
+		return err
+	}
+	if err := db.db.Where("referrer_id = ?", record.ReferrerId).Delete(&ReferrerLocation{}).Error; err != nil {
+		return err
+	}
+	for i := range record.JobFamilies {
+		record.JobFamilies[i].ReferrerId = record.ReferrerId
+	}
+	for i := range record.Locations {
+		record.Locations[i].ReferrerId = record.ReferrerId
+	}
+	if len(record.JobFamilies) > 0 {
+		if err := db.db.Create(&record.JobFamilies).Error; err != nil {
+			return err
+		}
+	}
+	if len(record.Locations) > 0 {
+		return db.db.Create(&record.Locations).Error
+	}
+	return nil
+}
+
+// deleteReferrerProfiles removes the job families and locations of the referrers selected by the
+// referrerIds subquery.
+func (db *DbDriver) deleteReferrerProfiles(referrerIds *gorm.DB) error {
+	if err := db.db.Where("referrer_id IN (?)", referrerIds).Delete(&ReferrerJobFamily{}).Error; err != nil {
+		return err
+	}
+	return db.db.Where("referrer_id IN (?)", referrerIds).Delete(&ReferrerLocation{}).Error
+}
+
+// SetReferrerUnavailableUntil puts the referrer in vacation mode until the given time, or takes
+// them out of it if until is nil.
+func (db *DbDriver) SetReferrerUnavailableUntil(referrerId uint64, until *time.Time) error {
+	result := db.db.Model(&Referrer{}).Where("referrer_id = ?", referrerId).UpdateColumn("unavailable_until", until)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// LockReferrer locks the referrer until the transaction it's called in ends, so that counting
+// the referrer's claims and then claiming a request can't interleave with another claim.
+func (db *DbDriver) LockReferrer(referrerId uint64) error {
+	return db.dialect.lockRow(db.db, "referrers", "referrer_id", referrerId)
+}
+
+// CountReferrerClaimsSince returns how many referral requests the referrer claimed since the
+// given time, including requests deleted since.
+func (db *DbDriver) CountReferrerClaimsSince(referrerId uint64, since time.Time) (int64, error) {
+	var count int64
+	err := db.db.Unscoped().Model(&ReferralRequest{}).
+		Where("referrer_id = ? AND claimed_at >= ?", referrerId, since).
+		Count(&count).Error
+	return count, err
+}
+
+// GetReferrersByCompanyId returns the company's referrers with their user and profile.
+func (db *DbDriver) GetReferrersByCompanyId(companyId uint64) ([]Referrer, error) {
+	var referrers []Referrer
+	err := preloadReferrerProfile(db.db.Preload("User"), "").
+		Where("company_id = ?", companyId).
+		Order("referrer_id").
+		Find(&referrers).Error
+	return referrers, err
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReferrerProfile_UpdateReplacesJobFamiliesAndLocations(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			_, referrerIds := seedReferralRequest(t, db, 1)
+			referrer := db.GetReferrerById(referrerIds[0])
+
+			referrer.Team = "Payments"
+			referrer.Seniority = SenioritySenior
+			referrer.WeeklyCapacity = 3
+			referrer.JobFamilies = []ReferrerJobFamily{{JobFamily: JobFamilyEngineering}, {JobFamily: JobFamilyData}}
+			referrer.Locations = []ReferrerLocation{{Location: "Toronto"}}
+			if _, err := db.UpdateReferrer(referrer.UserId, referrer); err != nil {
+				t.Fatalf("failed to update referrer: %v", err)
+			}
+			referrer.JobFamilies = []ReferrerJobFamily{{JobFamily: JobFamilyProduct}}
+			updated, err := db.UpdateReferrer(referrer.UserId, referrer)
+			if err != nil {
+				t.Fatalf("failed to update referrer: %v", err)
+			}
+
+			if updated.Team != "Payments" || updated.Seniority != SenioritySenior || updated.WeeklyCapacity != 3 {
+				t.Errorf("expected the profile fields to be saved, got %+v", updated)
+			}
+			if len(updated.JobFamilies) != 1 || updated.JobFamilies[0].JobFamily != JobFamilyProduct {
+				t.Errorf("expected the job families to be replaced, got %+v", updated.JobFamilies)
+			}
+			if len(updated.Locations) != 1 || updated.Locations[0].Location != "Toronto" {
+				t.Errorf("expected the locations to be kept, got %+v", updated.Locations)
+			}
+		})
+	}
+}
+
+func TestReferrerProfile_AvailabilityAndClaimCount(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 1)
+			referrerId := referrerIds[0]
+
+			until := time.Now().Add(24 * time.Hour)
+			if err := db.SetReferrerUnavailableUntil(referrerId, &until); err != nil {
+				t.Fatalf("failed to set availability: %v", err)
+			}
+			// Updating the rest of the profile leaves vacation mode alone
+			referrer := db.GetReferrerById(referrerId)
+			referrer.UnavailableUntil = nil
+			if _, err := db.UpdateReferrer(referrer.UserId, referrer); err != nil {
+				t.Fatalf("failed to update referrer: %v", err)
+			}
+			if referrer := db.GetReferrerById(referrerId); referrer.IsAvailable(time.Now()) || !referrer.IsAvailable(until) {
+				t.Errorf("expected the referrer to be unavailable until %v, got %v", until, referrer.UnavailableUntil)
+			}
+
+			before := time.Now().Add(-time.Minute)
+			if _, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerId); err != nil {
+				t.Fatalf("failed to claim referral request: %v", err)
+			}
+			if count, err := db.CountReferrerClaimsSince(referrerId, before); err != nil || count != 1 {
+				t.Errorf("expected one claim since %v, got %d, %v", before, count, err)
+			}
+			if count, err := db.CountReferrerClaimsSince(referrerId, time.Now().Add(time.Minute)); err != nil || count != 0 {
+				t.Errorf("expected no claims in the future, got %d, %v", count, err)
+			}
+		})
+	}
+}
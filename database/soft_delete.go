@@ -0,0 +1,156 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Users, companies, candidates, referrers and referral requests are soft-deleted: Delete sets
+// deleted_at and every query made through the models skips such rows. Deleting a user cascades
+// to their candidate and referrer profiles and deleting a candidate to their referral requests.
+// A referrer's deletion doesn't cascade, so referral requests they handled keep their history;
+// the open ones they had are released for other referrers.
+// Rows deleted by a cascade share the parent's deletion time, which is how a restore tells them
+// apart from rows that had been deleted on their own earlier.
+
+var (
+	// ErrRestoreParentDeleted is returned when restoring a row whose parent (the user of a
+	// profile, or the candidate of a referral request) is itself deleted.
+	ErrRestoreParentDeleted = errors.New("the record it belongs to is deleted, restore that first")
+	// ErrRestoreConflict is returned when restoring a profile whose user has since created another.
+	ErrRestoreConflict = errors.New("the user already has another profile of this kind")
+	// ErrRestoreEmailTaken is returned when restoring a user whose email another live user has.
+	ErrRestoreEmailTaken = errors.New("another user has this email")
+	// ErrRestoreMerged is returned when restoring a company that was merged into another, which
+	// now has everything that was the deleted company's.
+	ErrRestoreMerged = errors.New("the company was merged into another one")
+)
+
+// softDelete marks the rows of model matched by query as deleted at now. Rows already deleted
+// keep their original deletion time.
+func softDelete(query *gorm.DB, model interface{}, now time.Time) error {
+	return query.Model(model).UpdateColumn("deleted_at", now).Error
+}
+
+// restoreDeleted clears deleted_at on the rows of model matched by query.
+func restoreDeleted(query *gorm.DB, model interface{}) error {
+	return query.Unscoped().Model(model).UpdateColumn("deleted_at", nil).Error
+}
+
+// firstDeleted loads the deleted row of model with the given primary key, returning
+// gorm.ErrRecordNotFound if there is no such row or it isn't deleted.
+func (db *DbDriver) firstDeleted(model interface{}, id uint64) error {
+	return db.db.Unscoped().Where("deleted_at IS NOT NULL").First(model, id).Error
+}
+
+// requireLive returns ErrRestoreParentDeleted unless the row of model with the given primary
+// key exists and isn't deleted.
+func (db *DbDriver) requireLive(model interface{}, id uint64) error {
+	err := db.db.First(model, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrRestoreParentDeleted
+	}
+	return err
+}
+
+// RestoreUser restores a deleted user along with the profiles and referral requests that were
+// deleted with them.
+func (db *DbDriver) RestoreUser(id uint64) error {
+	return db.Transaction(func(tx *DbDriver) error {
+		var user User
+		if err := tx.firstDeleted(&user, id); err != nil {
+			return err
+		}
+		if user.AnonymizedAt != nil {
+			return ErrAlreadyAnonymized
+		}
+		if tx.GetUserByEmail(user.Email) != nil {
+			return ErrRestoreEmailTaken
+		}
+		deletedAt := user.DeletedAt.Time
+
+		candidateIds := tx.db.Unscoped().Model(&Candidate{}).Select("candidate_id").Where("user_id = ?", id)
+		if err := restoreDeleted(tx.db.Where("candidate_id IN (?) AND deleted_at >= ?", candidateIds, deletedAt), &ReferralRequest{}); err != nil {
+			return err
+		}
+		if err := restoreDeleted(tx.db.Where("user_id = ? AND deleted_at >= ?", id, deletedAt), &Candidate{}); err != nil {
+			return err
+		}
+		if err := restoreDeleted(tx.db.Where("user_id = ? AND deleted_at >= ?", id, deletedAt), &Referrer{}); err != nil {
+			return err
+		}
+		return restoreDeleted(tx.db.Where("id = ?", id), &User{})
+	})
+}
+
+// RestoreCandidate restores a deleted candidate profile along with the referral requests that
+// were deleted with it.
+func (db *DbDriver) RestoreCandidate(id uint64) error {
+	return db.Transaction(func(tx *DbDriver) error {
+		var candidate Candidate
+		if err := tx.firstDeleted(&candidate, id); err != nil {
+			return err
+		}
+		if err := tx.requireLive(&User{}, candidate.UserId); err != nil {
+			return err
+		}
+		if tx.GetCandidateByUserId(candidate.UserId) != nil {
+			return ErrRestoreConflict
+		}
+
+		if err := restoreDeleted(tx.db.Where("candidate_id = ? AND deleted_at >= ?", id, candidate.DeletedAt.Time), &ReferralRequest{}); err != nil {
+			return err
+		}
+		return restoreDeleted(tx.db.Where("candidate_id = ?", id), &Candidate{})
+	})
+}
+
+// RestoreReferrer restores a deleted referrer profile.
+func (db *DbDriver) RestoreReferrer(id uint64) error {
+	return db.Transaction(func(tx *DbDriver) error {
+		var referrer Referrer
+		if err := tx.firstDeleted(&referrer, id); err != nil {
+			return err
+		}
+		if err := tx.requireLive(&User{}, referrer.UserId); err != nil {
+			return err
+		}
+		if existing := tx.GetReferrerByUserId(referrer.UserId); existing != nil && existing.ReferrerId != 0 {
+			return ErrRestoreConflict
+		}
+		return restoreDeleted(tx.db.Where("referrer_id = ?", id), &Referrer{})
+	})
+}
+
+// RestoreReferralRequest restores a deleted referral request.
+func (db *DbDriver) RestoreReferralRequest(id uint64) error {
+	return db.Transaction(func(tx *DbDriver) error {
+		var request ReferralRequest
+		if err := tx.firstDeleted(&request, id); err != nil {
+			return err
+		}
+		if err := tx.requireLive(&Candidate{}, request.CandidateID); err != nil {
+			return err
+		}
+		return restoreDeleted(tx.db.Where("referral_request_id = ?", id), &ReferralRequest{})
+	})
+}
+
+// RestoreCompany restores a deleted company.
+func (db *DbDriver) RestoreCompany(id uint64) error {
+	return db.Transaction(func(tx *DbDriver) error {
+		if err := tx.firstDeleted(&Company{}, id); err != nil {
+			return err
+		}
+		var merges int64
+		if err := tx.db.Model(&CompanyMerge{}).Where("source_company_id = ?", id).Count(&merges).Error; err != nil {
+			return err
+		}
+		if merges > 0 {
+			return ErrRestoreMerged
+		}
+		return restoreDeleted(tx.db.Where("id = ?", id), &Company{})
+	})
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestDeleteReferrer_KeepsReferralHistory(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 1)
+			if _, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerIds[0]); err != nil {
+				t.Fatalf("failed to claim referral request: %v", err)
+			}
+			if err := db.db.Model(&ReferralRequest{}).Where("referral_request_id = ?", request.ReferralRequestId).
+				Update("status", ReferralSubmissionAccepted).Error; err != nil {
+				t.Fatalf("failed to accept referral request: %v", err)
+			}
+			open, err := db.CreateReferralRequest(&ReferralRequest{CandidateID: request.CandidateID, CompanyID: request.CompanyID,
+				Status: ReferralRequested, ReferralType: Internship})
+			if err != nil {
+				t.Fatalf("failed to create referral request: %v", err)
+			}
+			if _, err := db.ClaimReferralRequest(open.ReferralRequestId, referrerIds[0]); err != nil {
+				t.Fatalf("failed to claim referral request: %v", err)
+			}
+			referrer := db.GetReferrerById(referrerIds[0])
+
+			if err := db.DeleteReferrer(referrer.UserId, referrer); err != nil {
+				t.Fatalf("failed to delete referrer: %v", err)
+			}
+
+			if found := db.GetReferrerByUserId(referrer.UserId); found.ReferrerId != 0 {
+				t.Errorf("expected the deleted referrer to be hidden, got %+v", found)
+			}
+			accepted := db.GetReferralRequestById(request.ReferralRequestId)
+			if accepted == nil || accepted.Referrer == nil || accepted.Referrer.ReferrerId != referrer.ReferrerId {
+				t.Fatalf("expected the accepted referral request to still show its referrer, got %+v", accepted)
+			}
+			if !accepted.Referrer.DeletedAt.Valid {
+				t.Errorf("expected the referrer to be marked deleted")
+			}
+
+			// The open one goes back to the other referrers
+			released := db.GetReferralRequestById(open.ReferralRequestId)
+			if released == nil || released.ReferrerId != nil || released.Status != ReferralRequested || released.ClaimedAt != nil {
+				t.Fatalf("expected the claimed referral request to be released, got %+v", released)
+			}
+		})
+	}
+}
+
+func TestDeleteUser_ReleasesReferrersRequests(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, referrerIds := seedReferralRequest(t, db, 1)
+			if _, err := db.ClaimReferralRequest(request.ReferralRequestId, referrerIds[0]); err != nil {
+				t.Fatalf("failed to claim referral request: %v", err)
+			}
+			referrer := db.GetReferrerById(referrerIds[0])
+
+			if err := db.DeleteUser(&referrer.User); err != nil {
+				t.Fatalf("failed to delete user: %v", err)
+			}
+
+			released := db.GetReferralRequestById(request.ReferralRequestId)
+			if released == nil || released.ReferrerId != nil || released.Status != ReferralRequested {
+				t.Fatalf("expected the claimed referral request to be released, got %+v", released)
+			}
+		})
+	}
+}
+
+func TestDeleteUser_CascadesAndRestores(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			request, _ := seedReferralRequest(t, db, 0)
+			user := db.GetUserByEmail("candidate@example.com")
+			candidate := db.GetCandidateByUserId(user.Id)
+
+			// Deleted on its own before the user, so restoring the user must leave it deleted
+			earlier, err := db.CreateReferralRequest(&ReferralRequest{CandidateID: candidate.CandidateId, CompanyID: request.CompanyID,
+				Status: ReferralRequested, ReferralType: Internship})
+			if err != nil {
+				t.Fatalf("failed to create referral request: %v", err)
+			}
+			if err := db.DeleteReferralRequest(earlier); err != nil {
+				t.Fatalf("failed to delete referral request: %v", err)
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			if err := db.DeleteUser(user); err != nil {
+				t.Fatalf("failed to delete user: %v", err)
+			}
+			if db.GetUserByEmail(user.Email) != nil || db.GetCandidateByUserId(user.Id) != nil || db.GetReferralRequestById(request.ReferralRequestId) != nil {
+				t.Fatalf("expected the user, their candidate profile and referral request to be hidden")
+			}
+
+			if err := db.RestoreUser(user.Id); err != nil {
+				t.Fatalf("failed to restore user: %v", err)
+			}
+			if db.GetUserByEmail(user.Email) == nil || db.GetCandidateByUserId(user.Id) == nil || db.GetReferralRequestById(request.ReferralRequestId) == nil {
+				t.Errorf("expected the user, their candidate profile and referral request to be restored")
+			}
+			if db.GetReferralRequestById(earlier.ReferralRequestId) != nil {
+				t.Errorf("expected the referral request deleted before the user to stay deleted")
+			}
+			if err := db.RestoreUser(user.Id); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("expected restoring a live user to find nothing, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeleteCandidate_AllowsNewProfileAndRefusesConflictingRestore(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			seedReferralRequest(t, db, 0)
+			user := db.GetUserByEmail("candidate@example.com")
+			deleted := db.GetCandidateByUserId(user.Id)
+
+			if err := db.DeleteCandidate(user.Id, deleted); err != nil {
+				t.Fatalf("failed to delete candidate: %v", err)
+			}
+			if _, err := db.CreateCandidate(&Candidate{UserId: user.Id, ResumeUrl: "https://example.com/new.pdf"}); err != nil {
+				t.Fatalf("expected a new candidate profile to be allowed after deleting the old one, got %v", err)
+			}
+
+			if err := db.RestoreCandidate(deleted.CandidateId); !errors.Is(err, ErrRestoreConflict) {
+				t.Errorf("expected restoring over a live profile to conflict, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeleteUser_FreesEmail(t *testing.T) {
+	for _, driver := range testDrivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newTestDbDriver(t, driver)
+			user, err := db.CreateUser(&User{FirstName: "First", LastName: "User", Email: "reused@example.com"})
+			if err != nil {
+				t.Fatalf("failed to create user: %v", err)
+			}
+			if err := db.DeleteUser(user); err != nil {
+				t.Fatalf("failed to delete user: %v", err)
+			}
+			if deleted := db.GetDeletedUserByEmail("reused@example.com"); deleted == nil || deleted.Id != user.Id {
+				t.Fatalf("expected to find the deleted user, got %+v", deleted)
+			}
+
+			second, err := db.CreateUser(&User{FirstName: "Second", LastName: "User", Email: "reused@example.com"})
+			if err != nil {
+				t.Fatalf("expected a deleted user's email to be free, got %v", err)
+			}
+			if _, err := db.CreateUser(&User{FirstName: "Third", LastName: "User", Email: "reused@example.com"}); err == nil {
+				t.Errorf("expected live users to still have unique emails")
+			}
+			if err := db.RestoreUser(user.Id); !errors.Is(err, ErrRestoreEmailTaken) {
+				t.Errorf("expected ErrRestoreEmailTaken, got %v", err)
+			}
+			if found := db.GetUserByEmail("reused@example.com"); found == nil || found.Id != second.Id {
+				t.Errorf("expected the email to belong to the new user, got %+v", found)
+			}
+		})
+	}
+}
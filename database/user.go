@@ -1,11 +1,14 @@
 package database
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 func (db *DbDriver) CreateUser(record *User) (*User, error) {
 	record.Id = 0
-	db.mu.Lock()
-	defer db.mu.Unlock()
 	if err := db.db.Create(record).Error; err != nil {
 		return nil, err
 	}
@@ -13,9 +16,6 @@ func (db *DbDriver) CreateUser(record *User) (*User, error) {
 }
 
 func (db *DbDriver) UpdateUser(record *User) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	result := db.db.Save(record)
 	if result.Error != nil {
 		return result.Error
@@ -24,23 +24,35 @@ func (db *DbDriver) UpdateUser(record *User) error {
 	return nil
 }
 
-func (db *DbDriver) DeleteUser(record *User) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-	db.db.Delete(record)
+// DeleteUser soft-deletes the user together with their candidate and referrer profiles and the
+// candidate's referral requests, releasing the open requests the referrer had.
+func (db *DbDriver) DeleteUser(record *User) error {
+	return db.Transaction(func(tx *DbDriver) error {
+		now := tx.db.NowFunc()
+		candidateIds := tx.db.Model(&Candidate{}).Select("candidate_id").Where("user_id = ?", record.Id)
+		if err := softDelete(tx.db.Where("candidate_id IN (?)", candidateIds), &ReferralRequest{}, now); err != nil {
+			return err
+		}
+		if err := softDelete(tx.db.Where("user_id = ?", record.Id), &Candidate{}, now); err != nil {
+			return err
+		}
+		if err := tx.releaseReferralRequests(tx.db.Model(&Referrer{}).Select("referrer_id").Where("user_id = ?", record.Id)); err != nil {
+			return err
+		}
+		if err := softDelete(tx.db.Where("user_id = ?", record.Id), &Referrer{}, now); err != nil {
+			return err
+		}
+		return softDelete(tx.db.Where("id = ?", record.Id), &User{}, now)
+	})
 }
 
 func (db *DbDriver) GetUserById(id uint64) *User {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var user User
 	db.db.First(&user, id)
 	return &user
 }
 
 func (db *DbDriver) GetUserByEmail(email string) *User {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	var user User
 	result := db.db.Where("email = ?", email).First(&user)
 	if result.Error != nil && result.Error == gorm.ErrRecordNotFound {
@@ -48,3 +60,43 @@ func (db *DbDriver) GetUserByEmail(email string) *User {
 	}
 	return &user
 }
+
+// GetDeletedUserByEmail returns the deleted user with this email, unless their personal data was
+// erased, or nil if there is none. The email stays theirs until they are restored, though the
+// unique index only covers live users.
+func (db *DbDriver) GetDeletedUserByEmail(email string) *User {
+	var user User
+	err := db.db.Unscoped().Where("email = ? AND deleted_at IS NOT NULL AND anonymized_at IS NULL", email).
+		Order("deleted_at DESC").First(&user).Error
+	if err != nil {
+		return nil
+	}
+	return &user
+}
+
+// RevokeUserSessions records that every session the user signed in before at is revoked. It
+// applies to deleted users too.
+func (db *DbDriver) RevokeUserSessions(userID uint64, at time.Time) error {
+	return db.db.Unscoped().Model(&User{}).Where("id = ?", userID).UpdateColumn("sessions_revoked_at", at).Error
+}
+
+// GetSessionsRevokedAt returns when the user's sessions were last revoked, or nil if they never
+// were or there is no such user.
+func (db *DbDriver) GetSessionsRevokedAt(userID uint64) (*time.Time, error) {
+	var user User
+	err := db.db.Unscoped().Select("id", "sessions_revoked_at").First(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user.SessionsRevokedAt, nil
+}
+
+// CountUsers returns the number of users in the database.
+func (db *DbDriver) CountUsers() (int64, error) {
+	var count int64
+	err := db.db.Model(&User{}).Count(&count).Error
+	return count, err
+}
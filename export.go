@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+)
+
+const exportUsage = `usage: muslim-referrals export [-format json|zip] [-o file] [flags] <user-id|email>
+
+Writes everything stored about the user (profile, candidate and referrer profiles, referral
+requests, email verifications and companies added, deleted ones included) to stdout, or to
+the file given by -o. This is the same export users download from GET /api/user/export.
+Other flags are the same as the server's.`
+
+// runExport implements the export subcommand and returns the process exit code.
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write the export to instead of stdout")
+	formatName := fs.String("format", string(service.ExportFormatJSON), "export format, json or zip")
+	cfg, code := loadCommandConfig(fs, args, exportUsage)
+	if cfg == nil {
+		return code
+	}
+	format, err := service.ParseExportFormat(*formatName)
+	if err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, exportUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.CloseDatabase()
+
+	userID, err := strconv.ParseUint(fs.Arg(0), 10, 64)
+	if err != nil {
+		user := db.GetUserByEmail(fs.Arg(0))
+		if user == nil {
+			fmt.Fprintf(os.Stderr, "No user with email %s\n", fs.Arg(0))
+			return 1
+		}
+		userID = user.Id
+	}
+
+	archive, err := newCommandService(cfg, db).BuildUserDataArchive(ctx, userID, format)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			fmt.Fprintf(os.Stderr, "No user with ID %d\n", userID)
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to export user data: %v\n", err)
+		}
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		// The export holds personal data, so only the owner may read the file
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", *output, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	if _, err := w.Write(archive.Data); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write export: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey struct{}
+
+// requestFields holds the values attached to every log record made while handling a request.
+// The user ID is only known once a handler has authenticated the request, after the context
+// has been created, so it is set through the pointer rather than by deriving a new context.
+type requestFields struct {
+	mu        sync.Mutex
+	requestID string
+	userID    uint64
+}
+
+// WithRequestID returns a context whose log records carry the given request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestFields{requestID: requestID})
+}
+
+// RequestID returns the request ID stored in ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	fields := fieldsFromContext(ctx)
+	if fields == nil {
+		return ""
+	}
+	fields.mu.Lock()
+	defer fields.mu.Unlock()
+	return fields.requestID
+}
+
+// SetUserID attaches the authenticated user's ID to the log records of the request that ctx
+// belongs to. It does nothing if ctx wasn't created by WithRequestID.
+func SetUserID(ctx context.Context, userID uint64) {
+	fields := fieldsFromContext(ctx)
+	if fields == nil {
+		return
+	}
+	fields.mu.Lock()
+	defer fields.mu.Unlock()
+	fields.userID = userID
+}
+
+func fieldsFromContext(ctx context.Context) *requestFields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(contextKey{}).(*requestFields)
+	return fields
+}
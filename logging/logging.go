@@ -0,0 +1,70 @@
+// Package logging configures structured JSON logging with log/slog. Records made with a
+// request's context carry its request ID and, once authenticated, its user ID; sensitive
+// attributes such as tokens, emails and resume URLs are redacted by key.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ParseLevel parses one of "debug", "info", "warn" or "error".
+func ParseLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	return parsed, nil
+}
+
+// NewLogger returns a JSON logger writing to w at the given level.
+func NewLogger(w io.Writer, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// Setup installs a JSON logger at the given level as the default logger. Output from the
+// standard log package is routed through it as well.
+func Setup(w io.Writer, level string) error {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(NewLogger(w, parsed))
+	return nil
+}
+
+// contextHandler adds the request ID and user ID stored in the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if fields := fieldsFromContext(ctx); fields != nil {
+		fields.mu.Lock()
+		requestID, userID := fields.requestID, fields.userID
+		fields.mu.Unlock()
+
+		if requestID != "" {
+			record.AddAttrs(slog.String("request_id", requestID))
+		}
+		if userID != 0 {
+			record.AddAttrs(slog.Uint64("user_id", userID))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func logOne(t *testing.T, ctx context.Context, msg string, args ...any) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, slog.LevelDebug)
+	logger.InfoContext(ctx, msg, args...)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	return record
+}
+
+func TestLogger_AddsRequestAndUserID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-123")
+	SetUserID(ctx, 42)
+
+	record := logOne(t, ctx, "hello")
+
+	assert.Equal(t, "req-123", record["request_id"])
+	assert.Equal(t, float64(42), record["user_id"])
+}
+
+func TestLogger_WithoutRequestContext(t *testing.T) {
+	record := logOne(t, context.Background(), "hello")
+
+	assert.NotContains(t, record, "request_id")
+	assert.NotContains(t, record, "user_id")
+}
+
+func TestLogger_RedactsSensitiveAttributes(t *testing.T) {
+	record := logOne(t, context.Background(), "hello",
+		"token_digest", "eyJhY2Nlc3NfdG9rZW4iOiJzZWNyZXQifQ==",
+		"email", "jane.doe@example.com",
+		"resume_url", "https://drive.example.com/resume.pdf",
+		"verification_code", "0b6e2f1c",
+		"company_id", 7,
+	)
+
+	assert.Equal(t, redacted, record["token_digest"])
+	assert.Equal(t, "j***@example.com", record["email"])
+	assert.Equal(t, redacted, record["resume_url"])
+	assert.Equal(t, redacted, record["verification_code"])
+	assert.Equal(t, float64(7), record["company_id"])
+}
+
+func TestMaskEmail(t *testing.T) {
+	assert.Equal(t, "a***@b.com", MaskEmail("abc@b.com"))
+	assert.Equal(t, redacted, MaskEmail("not-an-email"))
+	assert.Equal(t, redacted, MaskEmail("@b.com"))
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("debug")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values must never be written to the logs in full.
+// Emails are masked rather than removed so that support can still correlate log lines.
+var sensitiveKeys = map[string]func(string) string{
+	"token":             redactAll,
+	"token_digest":      redactAll,
+	"auth":              redactAll,
+	"code":              redactAll,
+	"verification_code": redactAll,
+	"resume_url":        redactAll,
+	"email":             MaskEmail,
+	"corporate_email":   MaskEmail,
+}
+
+func redactAll(string) string {
+	return redacted
+}
+
+// MaskEmail keeps the first character of the local part and the domain, e.g. "j***@example.com".
+func MaskEmail(email string) string {
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		return redacted
+	}
+	return local[:1] + "***@" + domain
+}
+
+// redactAttr is a slog ReplaceAttr function that redacts sensitive attributes by key.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	redact, ok := sensitiveKeys[strings.ToLower(a.Key)]
+	if !ok || a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	return slog.String(a.Key, redact(a.Value.String()))
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OpenReferralRequestCount is the number of open referral requests in one status at one company.
+type OpenReferralRequestCount struct {
+	CompanyID uint64
+	Status    string
+	Count     int64
+}
+
+var openReferralRequestsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "referral_requests", "open"),
+	"Open referral requests, by company and status.",
+	[]string{"company_id", "status"}, nil,
+)
+
+// OpenReferralRequestsCollector reports open referral request counts. They are read from
+// the database on every scrape rather than tracked in process, so they stay correct
+// across restarts and writes made outside the server.
+type OpenReferralRequestsCollector struct {
+	count func() ([]OpenReferralRequestCount, error)
+}
+
+func NewOpenReferralRequestsCollector(count func() ([]OpenReferralRequestCount, error)) *OpenReferralRequestsCollector {
+	return &OpenReferralRequestsCollector{count: count}
+}
+
+func (c *OpenReferralRequestsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- openReferralRequestsDesc
+}
+
+func (c *OpenReferralRequestsCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.count()
+	if err != nil {
+		slog.Error("Failed to count open referral requests for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(openReferralRequestsDesc, err)
+		return
+	}
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(openReferralRequestsDesc, prometheus.GaugeValue, float64(count.Count),
+			strconv.FormatUint(count.CompanyID, 10), count.Status)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+const migrateUsage = `usage: muslim-referrals migrate <status|up|down> [flags]
+
+  status  list the embedded migrations and whether each has been applied
+  up      apply all pending migrations, then place stored locations the gazetteer now knows
+  down    revert the most recently applied migration
+
+Flags are the same as the server's, e.g. -config or -db.`
+
+// runMigrate implements the migrate subcommand and returns the process exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if isHelpFlag(args[0]) {
+		fmt.Println(migrateUsage)
+		return 0
+	}
+	action, args := args[0], args[1:]
+
+	cfg, code := loadCommandConfig(flag.NewFlagSet("migrate", flag.ContinueOnError), args, migrateUsage)
+	if cfg == nil {
+		return code
+	}
+
+	db := database.NewDbDriver(cfg.Database)
+	defer db.CloseDatabase()
+	migrator, err := db.Migrator()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tDESCRIPTION\tSTATUS\tEXECUTED AT")
+		for _, status := range statuses {
+			state, executedAt := "pending", ""
+			if status.Applied {
+				state = "applied"
+			}
+			if status.ExecutedAt != nil {
+				executedAt = status.ExecutedAt.Format("2006-01-02 15:04:05 MST")
+			} else if status.Applied {
+				executedAt = "(baseline)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status.Version, status.Description, state, executedAt)
+		}
+		w.Flush()
+		if err := migrator.Check(ctx, true); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, version := range applied {
+			fmt.Printf("Applied %s\n", version)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		backfilled, err := newCommandService(cfg, db).BackfillLocations(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Location backfill failed: %v\n", err)
+			return 1
+		}
+		if backfilled > 0 {
+			fmt.Printf("Backfilled %d referral request locations\n", backfilled)
+		}
+	case "down":
+		version, err := migrator.Down(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to revert migration: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Reverted %s\n", version)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,168 @@
+// Package migrations embeds the atlas migration directories, one per database dialect, so the
+// binary can apply them at startup and knows which schema version it was built against.
+//
+// Each directory holds atlas-format migration files and their atlas.sum. Reverse migrations,
+// used by `migrate down`, live in a down/ subdirectory under the same file name; atlas
+// ignores subdirectories, so they don't take part in atlas.sum.
+package migrations
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed sqlite postgres
+var FS embed.FS
+
+// SumFile is the name of the checksum file atlas keeps in each migration directory.
+const SumFile = "atlas.sum"
+
+// ErrChecksumMismatch means the migration files don't match atlas.sum, i.e. a file was added or
+// edited without running `atlas migrate hash`.
+var ErrChecksumMismatch = errors.New("migration files do not match atlas.sum")
+
+// File is one migration in a dialect's directory.
+type File struct {
+	Name        string // File name, e.g. 20240818024712.sql
+	Version     string // Atlas version, the name up to the first underscore or the extension
+	Description string // The rest of the name after the version, if any
+	SQL         string
+	Down        string // Reverse migration from down/<Name>, empty if there is none
+	Hash        string // The file's entry in atlas.sum, which atlas also records for applied revisions
+}
+
+// Dir returns the migration directory for a dialect ("sqlite" or "postgres").
+func Dir(dialect string) (fs.FS, error) {
+	dir, err := fs.Sub(FS, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fs.Stat(dir, SumFile); err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+	return dir, nil
+}
+
+// Load reads a dialect's migrations in version order and verifies them against atlas.sum.
+func Load(dialect string) ([]File, error) {
+	dir, err := Dir(dialect)
+	if err != nil {
+		return nil, err
+	}
+	names, err := fs.Glob(dir, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	files := make([]File, 0, len(names))
+	for _, name := range names {
+		contents, err := fs.ReadFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		file := File{Name: name, SQL: string(contents)}
+		file.Version, file.Description = parseName(name)
+		if down, err := fs.ReadFile(dir, path.Join("down", name)); err == nil {
+			file.Down = string(down)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	sum, err := fs.ReadFile(dir, SumFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySum(files, string(sum)); err != nil {
+		return nil, fmt.Errorf("%s migrations: %w", dialect, err)
+	}
+	return files, nil
+}
+
+// LatestVersion returns the version of the dialect's newest migration file, which is the atlas
+// revision a database must be at for this build.
+func LatestVersion(dialect string) string {
+	dir, err := Dir(dialect)
+	if err != nil {
+		return ""
+	}
+	names, err := fs.Glob(dir, "*.sql")
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	version, _ := parseName(names[len(names)-1])
+	return version
+}
+
+// parseName splits a migration file name into its version and description, as atlas names them:
+// 20240818024712_add_users.sql has version 20240818024712 and description add_users.
+func parseName(name string) (version, description string) {
+	base := strings.TrimSuffix(name, ".sql")
+	version, description, _ = strings.Cut(base, "_")
+	return version, description
+}
+
+// verifySum recomputes atlas.sum for files and compares it with sum, filling in each file's Hash.
+// Atlas hashes the files cumulatively: each file's entry covers its name and contents and
+// everything before it, and the first line covers all the entries.
+func verifySum(files []File, sum string) error {
+	lines := strings.Split(strings.TrimSpace(sum), "\n")
+	if len(lines) != len(files)+1 {
+		return fmt.Errorf("%w: atlas.sum lists %d files, found %d", ErrChecksumMismatch, len(lines)-1, len(files))
+	}
+
+	running := sha256.New()
+	total := sha256.New()
+	for i := range files {
+		running.Write([]byte(files[i].Name))
+		running.Write([]byte(files[i].SQL))
+		files[i].Hash = "h1:" + base64.StdEncoding.EncodeToString(running.Sum(nil))
+		if expected := files[i].Name + " " + files[i].Hash; lines[i+1] != expected {
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, files[i].Name)
+		}
+		total.Write([]byte(files[i].Name))
+		total.Write([]byte(strings.TrimPrefix(files[i].Hash, "h1:")))
+	}
+	if lines[0] != "h1:"+base64.StdEncoding.EncodeToString(total.Sum(nil)) {
+		return fmt.Errorf("%w: directory checksum", ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// Statements splits a migration file into the statements to execute. It relies on the layout
+// atlas writes: every statement ends with a semicolon at the end of a line, and comments take
+// whole lines.
+func Statements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(sql))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
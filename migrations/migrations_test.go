@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLoad_EmbeddedDirectoriesMatchAtlasSum(t *testing.T) {
+	for _, dialect := range []string{"sqlite", "postgres"} {
+		files, err := Load(dialect)
+		if err != nil {
+			t.Fatalf("%s: %v", dialect, err)
+		}
+		if len(files) == 0 || files[len(files)-1].Version != LatestVersion(dialect) {
+			t.Errorf("%s: expected files ending at %s, got %d files", dialect, LatestVersion(dialect), len(files))
+		}
+		for _, file := range files {
+			if file.Hash == "" || len(Statements(file.SQL)) == 0 {
+				t.Errorf("%s: %s has no hash or statements", dialect, file.Name)
+			}
+		}
+	}
+}
+
+func TestVerifySum_DetectsEditedFile(t *testing.T) {
+	files, err := Load("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := FS.ReadFile("sqlite/" + SumFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[0].SQL += "\nDROP TABLE `users`;"
+
+	if err := verifySum(files, string(sum)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	sql := "-- Create \"a\" table\nCREATE TABLE `a` (\n  `id` integer NULL\n);\n-- Create index\nCREATE INDEX `i` ON `a` (`id`);\n\nPRAGMA foreign_keys = on;\n"
+
+	got := Statements(sql)
+
+	want := []string{
+		"CREATE TABLE `a` (\n  `id` integer NULL\n);",
+		"CREATE INDEX `i` ON `a` (`id`);",
+		"PRAGMA foreign_keys = on;",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Statements() = %q, want %q", got, want)
+	}
+}
+
+func TestParseName(t *testing.T) {
+	if version, description := parseName("20261019130000_email_verifications.sql"); version != "20261019130000" || description != "email_verifications" {
+		t.Errorf("got %q %q", version, description)
+	}
+	if version, description := parseName("20240818024712.sql"); version != "20240818024712" || description != "" {
+		t.Errorf("got %q %q", version, description)
+	}
+}
@@ -0,0 +1,112 @@
+-- Create "users" table
+CREATE TABLE "users" (
+  "id" bigserial NOT NULL,
+  "first_name" text NOT NULL,
+  "last_name" text NOT NULL,
+  "email" text NOT NULL,
+  "phone_number" text NULL,
+  "phone_ext" text NULL,
+  "linked_in" text NULL,
+  "github" text NULL,
+  "website" text NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "deleted_at" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_users_email" to table: "users"
+CREATE UNIQUE INDEX "idx_users_email" ON "users" ("email");
+-- Create "candidates" table
+CREATE TABLE "candidates" (
+  "candidate_id" bigserial NOT NULL,
+  "user_id" bigint NOT NULL,
+  "work_experience" bigint NOT NULL,
+  "resume_url" text NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "deleted_at" timestamptz NULL,
+  PRIMARY KEY ("candidate_id"),
+  CONSTRAINT "fk_candidates_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE CASCADE ON DELETE CASCADE
+);
+-- Create index "idx_candidates_user_id" to table: "candidates"
+CREATE UNIQUE INDEX "idx_candidates_user_id" ON "candidates" ("user_id");
+-- Create "companies" table
+CREATE TABLE "companies" (
+  "id" bigserial NOT NULL,
+  "name" text NOT NULL,
+  "is_supported" boolean NOT NULL,
+  "added_by_user_id" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "deleted_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_companies_user" FOREIGN KEY ("added_by_user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create "company_domain_associations" table
+CREATE TABLE "company_domain_associations" (
+  "company_id" bigint NOT NULL,
+  "domain" text NOT NULL,
+  PRIMARY KEY ("company_id", "domain"),
+  CONSTRAINT "fk_companies_domains" FOREIGN KEY ("company_id") REFERENCES "companies" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create "referrers" table
+CREATE TABLE "referrers" (
+  "referrer_id" bigserial NOT NULL,
+  "user_id" bigint NOT NULL,
+  "company_id" bigint NOT NULL,
+  "corporate_email" text NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "deleted_at" timestamptz NULL,
+  PRIMARY KEY ("referrer_id"),
+  CONSTRAINT "fk_referrers_company" FOREIGN KEY ("company_id") REFERENCES "companies" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_referrers_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_referrers_user_id" to table: "referrers"
+CREATE UNIQUE INDEX "idx_referrers_user_id" ON "referrers" ("user_id");
+-- Create "referral_requests" table
+CREATE TABLE "referral_requests" (
+  "referral_request_id" bigserial NOT NULL,
+  "candidate_id" bigint NOT NULL,
+  "company_id" bigint NOT NULL,
+  "primary_job_title_seeking" text NOT NULL,
+  "summary" text NOT NULL,
+  "referral_type" text NOT NULL,
+  "referrer_id" bigint NULL,
+  "status" text NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "deleted_at" timestamptz NULL,
+  PRIMARY KEY ("referral_request_id"),
+  CONSTRAINT "fk_referral_requests_candidate" FOREIGN KEY ("candidate_id") REFERENCES "candidates" ("candidate_id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_referral_requests_company" FOREIGN KEY ("company_id") REFERENCES "companies" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_referral_requests_referrer" FOREIGN KEY ("referrer_id") REFERENCES "referrers" ("referrer_id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create "referral_request_job_links_associations" table
+CREATE TABLE "referral_request_job_links_associations" (
+  "referral_request_id" bigint NOT NULL,
+  "job_link" text NOT NULL,
+  PRIMARY KEY ("referral_request_id", "job_link"),
+  CONSTRAINT "fk_referral_requests_job_links" FOREIGN KEY ("referral_request_id") REFERENCES "referral_requests" ("referral_request_id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create "referral_request_location_associations" table
+CREATE TABLE "referral_request_location_associations" (
+  "referral_request_id" bigint NOT NULL,
+  "location" text NOT NULL,
+  PRIMARY KEY ("referral_request_id", "location"),
+  CONSTRAINT "fk_referral_requests_locations" FOREIGN KEY ("referral_request_id") REFERENCES "referral_requests" ("referral_request_id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create "email_verifications" table
+CREATE TABLE "email_verifications" (
+  "id" text NOT NULL,
+  "email" text NOT NULL,
+  "user_id" bigint NOT NULL,
+  "verification_code" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "status" bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "uni_email_verifications_email" UNIQUE ("email"),
+  CONSTRAINT "fk_email_verifications_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_email_verifications_user_id" to table: "email_verifications"
+CREATE INDEX "idx_email_verifications_user_id" ON "email_verifications" ("user_id");
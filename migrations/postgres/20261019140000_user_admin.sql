@@ -0,0 +1,2 @@
+-- Modify "users" table
+ALTER TABLE "users" ADD COLUMN "is_admin" boolean NOT NULL DEFAULT false;
@@ -0,0 +1,17 @@
+-- Modify "users" table
+ALTER TABLE "users" ADD COLUMN "anonymized_at" timestamptz NULL;
+-- Create "account_deletions" table
+CREATE TABLE "account_deletions" (
+  "user_id" bigint NOT NULL,
+  "confirmation_code" text NOT NULL,
+  "status" bigint NOT NULL DEFAULT 0,
+  "requested_at" timestamptz NOT NULL,
+  "confirm_by" timestamptz NOT NULL,
+  "scheduled_for" timestamptz NULL,
+  PRIMARY KEY ("user_id"),
+  CONSTRAINT "fk_account_deletions_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "idx_account_deletions_confirmation_code" to table: "account_deletions"
+CREATE UNIQUE INDEX "idx_account_deletions_confirmation_code" ON "account_deletions" ("confirmation_code");
+-- Create index "idx_account_deletions_scheduled_for" to table: "account_deletions"
+CREATE INDEX "idx_account_deletions_scheduled_for" ON "account_deletions" ("scheduled_for");
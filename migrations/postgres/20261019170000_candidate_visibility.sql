@@ -0,0 +1,4 @@
+-- Modify "candidates" table
+ALTER TABLE "candidates" ADD COLUMN "visibility" text NOT NULL DEFAULT 'full';
+-- Modify "referral_requests" table
+ALTER TABLE "referral_requests" ADD COLUMN "visibility" text NULL;
@@ -0,0 +1,20 @@
+-- Modify "referrers" table
+ALTER TABLE "referrers" ADD COLUMN "team" text NOT NULL DEFAULT '', ADD COLUMN "seniority" text NOT NULL DEFAULT '', ADD COLUMN "weekly_capacity" bigint NOT NULL DEFAULT 0, ADD COLUMN "unavailable_until" timestamptz NULL;
+-- Modify "referral_requests" table
+ALTER TABLE "referral_requests" ADD COLUMN "claimed_at" timestamptz NULL;
+-- Requests claimed before claims were timestamped were last updated when they were claimed, or later
+UPDATE "referral_requests" SET "claimed_at" = "updated_at" WHERE "referrer_id" IS NOT NULL;
+-- Create "referrer_job_families" table
+CREATE TABLE "referrer_job_families" (
+  "referrer_id" bigint NOT NULL,
+  "job_family" text NOT NULL,
+  PRIMARY KEY ("referrer_id", "job_family"),
+  CONSTRAINT "fk_referrers_job_families" FOREIGN KEY ("referrer_id") REFERENCES "referrers" ("referrer_id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create "referrer_locations" table
+CREATE TABLE "referrer_locations" (
+  "referrer_id" bigint NOT NULL,
+  "location" text NOT NULL,
+  PRIMARY KEY ("referrer_id", "location"),
+  CONSTRAINT "fk_referrers_locations" FOREIGN KEY ("referrer_id") REFERENCES "referrers" ("referrer_id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
@@ -0,0 +1,30 @@
+-- Create "job_postings" table
+CREATE TABLE "job_postings" (
+  "job_posting_id" bigserial NOT NULL,
+  "referrer_id" bigint NOT NULL,
+  "company_id" bigint NOT NULL,
+  "title" text NOT NULL,
+  "link" text NOT NULL,
+  "location" text NOT NULL DEFAULT '',
+  "referral_type" text NOT NULL,
+  "description" text NOT NULL DEFAULT '',
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "deleted_at" timestamptz NULL,
+  PRIMARY KEY ("job_posting_id"),
+  CONSTRAINT "fk_job_postings_company" FOREIGN KEY ("company_id") REFERENCES "companies" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION,
+  CONSTRAINT "fk_job_postings_referrer" FOREIGN KEY ("referrer_id") REFERENCES "referrers" ("referrer_id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_job_postings_company_id" to table: "job_postings"
+CREATE INDEX "idx_job_postings_company_id" ON "job_postings" ("company_id");
+-- Create index "idx_job_postings_deleted_at" to table: "job_postings"
+CREATE INDEX "idx_job_postings_deleted_at" ON "job_postings" ("deleted_at");
+-- Create index "idx_job_postings_expires_at" to table: "job_postings"
+CREATE INDEX "idx_job_postings_expires_at" ON "job_postings" ("expires_at");
+-- Create index "idx_job_postings_referrer_id" to table: "job_postings"
+CREATE INDEX "idx_job_postings_referrer_id" ON "job_postings" ("referrer_id");
+-- Modify "referral_requests" table
+ALTER TABLE "referral_requests" ADD COLUMN "job_posting_id" bigint NULL;
+-- Create index "idx_referral_requests_job_posting_id" to table: "referral_requests"
+CREATE INDEX "idx_referral_requests_job_posting_id" ON "referral_requests" ("job_posting_id");
@@ -0,0 +1,2 @@
+-- Modify "referral_request_job_links_associations" table
+ALTER TABLE "referral_request_job_links_associations" ADD COLUMN "ats" text NOT NULL DEFAULT '', ADD COLUMN "ats_job_id" text NOT NULL DEFAULT '';
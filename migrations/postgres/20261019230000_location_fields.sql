@@ -0,0 +1,4 @@
+-- Modify "referral_request_location_associations" table
+ALTER TABLE "referral_request_location_associations" ADD COLUMN "city" text NOT NULL DEFAULT '', ADD COLUMN "region" text NOT NULL DEFAULT '', ADD COLUMN "country" text NOT NULL DEFAULT '', ADD COLUMN "remote" boolean NOT NULL DEFAULT false;
+-- Create index "idx_referral_request_location_associations_country" to table: "referral_request_location_associations"
+CREATE INDEX "idx_referral_request_location_associations_country" ON "referral_request_location_associations" ("country");
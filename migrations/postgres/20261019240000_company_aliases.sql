@@ -0,0 +1,33 @@
+-- Create "company_aliases" table
+CREATE TABLE "company_aliases" (
+  "id" bigserial NOT NULL,
+  "company_id" bigint NOT NULL,
+  "alias" text NOT NULL,
+  "name_key" text NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_companies_aliases" FOREIGN KEY ("company_id") REFERENCES "companies" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_company_aliases_company_id" to table: "company_aliases"
+CREATE INDEX "idx_company_aliases_company_id" ON "company_aliases" ("company_id");
+-- Create index "idx_company_aliases_name_key" to table: "company_aliases"
+CREATE UNIQUE INDEX "idx_company_aliases_name_key" ON "company_aliases" ("name_key");
+-- Create "company_merges" table
+CREATE TABLE "company_merges" (
+  "id" bigserial NOT NULL,
+  "source_company_id" bigint NOT NULL,
+  "source_company_name" text NOT NULL,
+  "target_company_id" bigint NOT NULL,
+  "merged_by_user_id" bigint NULL,
+  "referrers" bigint NULL,
+  "referral_requests" bigint NULL,
+  "job_postings" bigint NULL,
+  "domains" bigint NULL,
+  "aliases" bigint NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_company_merges_source_company_id" to table: "company_merges"
+CREATE INDEX "idx_company_merges_source_company_id" ON "company_merges" ("source_company_id");
+-- Create index "idx_company_merges_target_company_id" to table: "company_merges"
+CREATE INDEX "idx_company_merges_target_company_id" ON "company_merges" ("target_company_id");
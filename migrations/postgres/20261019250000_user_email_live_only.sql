@@ -0,0 +1,4 @@
+-- Drop index "idx_users_email" from table: "users"
+DROP INDEX "idx_users_email";
+-- Create index "idx_users_email" to table: "users"
+CREATE UNIQUE INDEX "idx_users_email" ON "users" ("email") WHERE deleted_at IS NULL;
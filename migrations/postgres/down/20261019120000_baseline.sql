@@ -0,0 +1,10 @@
+-- Drop every table created by the baseline, dependents first
+DROP TABLE "email_verifications";
+DROP TABLE "referral_request_location_associations";
+DROP TABLE "referral_request_job_links_associations";
+DROP TABLE "referral_requests";
+DROP TABLE "referrers";
+DROP TABLE "company_domain_associations";
+DROP TABLE "companies";
+DROP TABLE "candidates";
+DROP TABLE "users";
@@ -0,0 +1,4 @@
+-- Drop "account_deletions" table
+DROP TABLE "account_deletions";
+-- Modify "users" table
+ALTER TABLE "users" DROP COLUMN "anonymized_at";
@@ -0,0 +1,4 @@
+-- Modify "referral_requests" table
+ALTER TABLE "referral_requests" DROP COLUMN "visibility";
+-- Modify "candidates" table
+ALTER TABLE "candidates" DROP COLUMN "visibility";
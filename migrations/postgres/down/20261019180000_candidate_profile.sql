@@ -0,0 +1,14 @@
+-- Drop "candidate_work_authorizations" table
+DROP TABLE "candidate_work_authorizations";
+-- Drop "candidate_preferences" table
+DROP TABLE "candidate_preferences";
+-- Drop "candidate_positions" table
+DROP TABLE "candidate_positions";
+-- Drop "candidate_educations" table
+DROP TABLE "candidate_educations";
+-- Drop "candidate_desired_roles" table
+DROP TABLE "candidate_desired_roles";
+-- Drop "candidate_skills" table
+DROP TABLE "candidate_skills";
+-- Drop "skills" table
+DROP TABLE "skills";
@@ -0,0 +1,8 @@
+-- Drop "referrer_locations" table
+DROP TABLE "referrer_locations";
+-- Drop "referrer_job_families" table
+DROP TABLE "referrer_job_families";
+-- Modify "referral_requests" table
+ALTER TABLE "referral_requests" DROP COLUMN "claimed_at";
+-- Modify "referrers" table
+ALTER TABLE "referrers" DROP COLUMN "unavailable_until", DROP COLUMN "weekly_capacity", DROP COLUMN "seniority", DROP COLUMN "team";
@@ -0,0 +1,4 @@
+-- Modify "referral_requests" table
+ALTER TABLE "referral_requests" DROP COLUMN "job_posting_id";
+-- Drop "job_postings" table
+DROP TABLE "job_postings";
@@ -0,0 +1,2 @@
+-- Modify "referral_request_job_links_associations" table
+ALTER TABLE "referral_request_job_links_associations" DROP COLUMN "ats_job_id", DROP COLUMN "ats";
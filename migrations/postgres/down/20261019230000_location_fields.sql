@@ -0,0 +1,4 @@
+-- Drop index "idx_referral_request_location_associations_country" from table: "referral_request_location_associations"
+DROP INDEX "idx_referral_request_location_associations_country";
+-- Modify "referral_request_location_associations" table
+ALTER TABLE "referral_request_location_associations" DROP COLUMN "remote", DROP COLUMN "country", DROP COLUMN "region", DROP COLUMN "city";
@@ -0,0 +1,4 @@
+-- Drop "company_merges" table
+DROP TABLE "company_merges";
+-- Drop "company_aliases" table
+DROP TABLE "company_aliases";
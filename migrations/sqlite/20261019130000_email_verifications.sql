@@ -0,0 +1,15 @@
+-- Create "email_verifications" table
+CREATE TABLE `email_verifications` (
+  `id` text NULL,
+  `email` text NOT NULL,
+  `user_id` integer NOT NULL,
+  `verification_code` text NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `status` integer NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_email_verifications_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+-- Create index "email_verifications_email" to table: "email_verifications"
+CREATE UNIQUE INDEX `email_verifications_email` ON `email_verifications` (`email`);
+-- Create index "idx_email_verifications_user_id" to table: "email_verifications"
+CREATE INDEX `idx_email_verifications_user_id` ON `email_verifications` (`user_id`);
@@ -0,0 +1,2 @@
+-- Add column "is_admin" to table: "users"
+ALTER TABLE `users` ADD COLUMN `is_admin` numeric NOT NULL DEFAULT false;
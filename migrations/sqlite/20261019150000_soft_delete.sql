@@ -0,0 +1,18 @@
+-- Create index "idx_users_deleted_at" to table: "users"
+CREATE INDEX `idx_users_deleted_at` ON `users` (`deleted_at`);
+-- Create index "idx_companies_deleted_at" to table: "companies"
+CREATE INDEX `idx_companies_deleted_at` ON `companies` (`deleted_at`);
+-- Drop index "idx_candidates_user_id" from table: "candidates"
+DROP INDEX `idx_candidates_user_id`;
+-- Create index "idx_candidates_user_id" to table: "candidates"
+CREATE UNIQUE INDEX `idx_candidates_user_id` ON `candidates` (`user_id`) WHERE deleted_at IS NULL;
+-- Create index "idx_candidates_deleted_at" to table: "candidates"
+CREATE INDEX `idx_candidates_deleted_at` ON `candidates` (`deleted_at`);
+-- Drop index "idx_referrers_user_id" from table: "referrers"
+DROP INDEX `idx_referrers_user_id`;
+-- Create index "idx_referrers_user_id" to table: "referrers"
+CREATE UNIQUE INDEX `idx_referrers_user_id` ON `referrers` (`user_id`) WHERE deleted_at IS NULL;
+-- Create index "idx_referrers_deleted_at" to table: "referrers"
+CREATE INDEX `idx_referrers_deleted_at` ON `referrers` (`deleted_at`);
+-- Create index "idx_referral_requests_deleted_at" to table: "referral_requests"
+CREATE INDEX `idx_referral_requests_deleted_at` ON `referral_requests` (`deleted_at`);
@@ -0,0 +1,4 @@
+-- Add column "visibility" to table: "candidates"
+ALTER TABLE `candidates` ADD COLUMN `visibility` text NOT NULL DEFAULT 'full';
+-- Add column "visibility" to table: "referral_requests"
+ALTER TABLE `referral_requests` ADD COLUMN `visibility` text NULL;
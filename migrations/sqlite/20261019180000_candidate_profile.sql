@@ -0,0 +1,64 @@
+-- Create "skills" table
+CREATE TABLE `skills` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `name` text NOT NULL
+);
+-- Create index "idx_skills_name" to table: "skills"
+CREATE UNIQUE INDEX `idx_skills_name` ON `skills` (`name`);
+-- Create "candidate_skills" table
+CREATE TABLE `candidate_skills` (
+  `candidate_id` integer NULL,
+  `skill_id` integer NULL,
+  PRIMARY KEY (`candidate_id`, `skill_id`),
+  CONSTRAINT `fk_candidate_skills_candidate` FOREIGN KEY (`candidate_id`) REFERENCES `candidates` (`candidate_id`) ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT `fk_candidate_skills_skill` FOREIGN KEY (`skill_id`) REFERENCES `skills` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create "candidate_desired_roles" table
+CREATE TABLE `candidate_desired_roles` (
+  `candidate_id` integer NULL,
+  `title` text NULL,
+  PRIMARY KEY (`candidate_id`, `title`),
+  CONSTRAINT `fk_candidates_desired_roles` FOREIGN KEY (`candidate_id`) REFERENCES `candidates` (`candidate_id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create "candidate_educations" table
+CREATE TABLE `candidate_educations` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `candidate_id` integer NOT NULL,
+  `school` text NOT NULL,
+  `degree` text NOT NULL,
+  `field_of_study` text NOT NULL,
+  `start_year` integer NULL,
+  `end_year` integer NULL,
+  CONSTRAINT `fk_candidates_education` FOREIGN KEY (`candidate_id`) REFERENCES `candidates` (`candidate_id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_candidate_educations_candidate_id" to table: "candidate_educations"
+CREATE INDEX `idx_candidate_educations_candidate_id` ON `candidate_educations` (`candidate_id`);
+-- Create "candidate_positions" table
+CREATE TABLE `candidate_positions` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `candidate_id` integer NOT NULL,
+  `title` text NOT NULL,
+  `company_name` text NOT NULL,
+  `start_date` datetime NOT NULL,
+  `end_date` datetime NULL,
+  `description` text NOT NULL,
+  CONSTRAINT `fk_candidates_positions` FOREIGN KEY (`candidate_id`) REFERENCES `candidates` (`candidate_id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_candidate_positions_candidate_id" to table: "candidate_positions"
+CREATE INDEX `idx_candidate_positions_candidate_id` ON `candidate_positions` (`candidate_id`);
+-- Create "candidate_preferences" table
+CREATE TABLE `candidate_preferences` (
+  `candidate_id` integer NULL,
+  `remote_preference` text NOT NULL DEFAULT 'any',
+  `open_to_relocation` numeric NOT NULL DEFAULT false,
+  PRIMARY KEY (`candidate_id`),
+  CONSTRAINT `fk_candidates_preferences` FOREIGN KEY (`candidate_id`) REFERENCES `candidates` (`candidate_id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create "candidate_work_authorizations" table
+CREATE TABLE `candidate_work_authorizations` (
+  `candidate_id` integer NULL,
+  `country` text NULL,
+  `needs_sponsorship` numeric NOT NULL DEFAULT false,
+  PRIMARY KEY (`candidate_id`, `country`),
+  CONSTRAINT `fk_candidates_work_authorizations` FOREIGN KEY (`candidate_id`) REFERENCES `candidates` (`candidate_id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
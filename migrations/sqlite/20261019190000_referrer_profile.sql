@@ -0,0 +1,26 @@
+-- Add column "team" to table: "referrers"
+ALTER TABLE `referrers` ADD COLUMN `team` text NOT NULL DEFAULT '';
+-- Add column "seniority" to table: "referrers"
+ALTER TABLE `referrers` ADD COLUMN `seniority` text NOT NULL DEFAULT '';
+-- Add column "weekly_capacity" to table: "referrers"
+ALTER TABLE `referrers` ADD COLUMN `weekly_capacity` integer NOT NULL DEFAULT 0;
+-- Add column "unavailable_until" to table: "referrers"
+ALTER TABLE `referrers` ADD COLUMN `unavailable_until` datetime NULL;
+-- Add column "claimed_at" to table: "referral_requests"
+ALTER TABLE `referral_requests` ADD COLUMN `claimed_at` datetime NULL;
+-- Requests claimed before claims were timestamped were last updated when they were claimed, or later
+UPDATE `referral_requests` SET `claimed_at` = `updated_at` WHERE `referrer_id` IS NOT NULL;
+-- Create "referrer_job_families" table
+CREATE TABLE `referrer_job_families` (
+  `referrer_id` integer NULL,
+  `job_family` text NULL,
+  PRIMARY KEY (`referrer_id`, `job_family`),
+  CONSTRAINT `fk_referrers_job_families` FOREIGN KEY (`referrer_id`) REFERENCES `referrers` (`referrer_id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create "referrer_locations" table
+CREATE TABLE `referrer_locations` (
+  `referrer_id` integer NULL,
+  `location` text NULL,
+  PRIMARY KEY (`referrer_id`, `location`),
+  CONSTRAINT `fk_referrers_locations` FOREIGN KEY (`referrer_id`) REFERENCES `referrers` (`referrer_id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
@@ -0,0 +1,12 @@
+-- Create "referral_request_views" table
+CREATE TABLE `referral_request_views` (
+  `referral_request_id` integer NULL,
+  `referrer_id` integer NULL,
+  `first_viewed_at` datetime NOT NULL,
+  `last_viewed_at` datetime NOT NULL,
+  PRIMARY KEY (`referral_request_id`, `referrer_id`),
+  CONSTRAINT `fk_referral_request_views_referrer` FOREIGN KEY (`referrer_id`) REFERENCES `referrers` (`referrer_id`) ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT `fk_referral_requests_views` FOREIGN KEY (`referral_request_id`) REFERENCES `referral_requests` (`referral_request_id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_referral_request_views_referrer_id" to table: "referral_request_views"
+CREATE INDEX `idx_referral_request_views_referrer_id` ON `referral_request_views` (`referrer_id`);
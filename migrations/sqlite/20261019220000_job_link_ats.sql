@@ -0,0 +1,4 @@
+-- Add column "ats" to table: "referral_request_job_links_associations"
+ALTER TABLE `referral_request_job_links_associations` ADD COLUMN `ats` text NOT NULL DEFAULT '';
+-- Add column "ats_job_id" to table: "referral_request_job_links_associations"
+ALTER TABLE `referral_request_job_links_associations` ADD COLUMN `ats_job_id` text NOT NULL DEFAULT '';
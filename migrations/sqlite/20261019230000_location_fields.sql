@@ -0,0 +1,10 @@
+-- Add column "city" to table: "referral_request_location_associations"
+ALTER TABLE `referral_request_location_associations` ADD COLUMN `city` text NOT NULL DEFAULT '';
+-- Add column "region" to table: "referral_request_location_associations"
+ALTER TABLE `referral_request_location_associations` ADD COLUMN `region` text NOT NULL DEFAULT '';
+-- Add column "country" to table: "referral_request_location_associations"
+ALTER TABLE `referral_request_location_associations` ADD COLUMN `country` text NOT NULL DEFAULT '';
+-- Add column "remote" to table: "referral_request_location_associations"
+ALTER TABLE `referral_request_location_associations` ADD COLUMN `remote` numeric NOT NULL DEFAULT false;
+-- Create index "idx_referral_request_location_associations_country" to table: "referral_request_location_associations"
+CREATE INDEX `idx_referral_request_location_associations_country` ON `referral_request_location_associations` (`country`);
@@ -0,0 +1,31 @@
+-- Create "company_aliases" table
+CREATE TABLE `company_aliases` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `company_id` integer NOT NULL,
+  `alias` text NOT NULL,
+  `name_key` text NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  CONSTRAINT `fk_companies_aliases` FOREIGN KEY (`company_id`) REFERENCES `companies` (`id`) ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_company_aliases_company_id" to table: "company_aliases"
+CREATE INDEX `idx_company_aliases_company_id` ON `company_aliases` (`company_id`);
+-- Create index "idx_company_aliases_name_key" to table: "company_aliases"
+CREATE UNIQUE INDEX `idx_company_aliases_name_key` ON `company_aliases` (`name_key`);
+-- Create "company_merges" table
+CREATE TABLE `company_merges` (
+  `id` integer NULL PRIMARY KEY AUTOINCREMENT,
+  `source_company_id` integer NOT NULL,
+  `source_company_name` text NOT NULL,
+  `target_company_id` integer NOT NULL,
+  `merged_by_user_id` integer NULL,
+  `referrers` integer NULL,
+  `referral_requests` integer NULL,
+  `job_postings` integer NULL,
+  `domains` integer NULL,
+  `aliases` integer NULL,
+  `created_at` datetime NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+-- Create index "idx_company_merges_source_company_id" to table: "company_merges"
+CREATE INDEX `idx_company_merges_source_company_id` ON `company_merges` (`source_company_id`);
+-- Create index "idx_company_merges_target_company_id" to table: "company_merges"
+CREATE INDEX `idx_company_merges_target_company_id` ON `company_merges` (`target_company_id`);
@@ -0,0 +1,2 @@
+-- Drop "email_verifications" table
+DROP TABLE `email_verifications`;
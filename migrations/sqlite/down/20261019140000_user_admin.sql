@@ -0,0 +1,2 @@
+-- Drop column "is_admin" from table: "users"
+ALTER TABLE `users` DROP COLUMN `is_admin`;
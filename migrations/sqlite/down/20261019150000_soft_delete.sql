@@ -0,0 +1,10 @@
+-- Fails if a user has both a deleted and a live candidate or referrer profile
+DROP INDEX `idx_referral_requests_deleted_at`;
+DROP INDEX `idx_referrers_deleted_at`;
+DROP INDEX `idx_referrers_user_id`;
+CREATE UNIQUE INDEX `idx_referrers_user_id` ON `referrers` (`user_id`);
+DROP INDEX `idx_candidates_deleted_at`;
+DROP INDEX `idx_candidates_user_id`;
+CREATE UNIQUE INDEX `idx_candidates_user_id` ON `candidates` (`user_id`);
+DROP INDEX `idx_companies_deleted_at`;
+DROP INDEX `idx_users_deleted_at`;
@@ -0,0 +1,4 @@
+-- Drop "account_deletions" table
+DROP TABLE `account_deletions`;
+-- Drop column "anonymized_at" from table: "users"
+ALTER TABLE `users` DROP COLUMN `anonymized_at`;
@@ -0,0 +1,4 @@
+-- Drop column "visibility" from table: "referral_requests"
+ALTER TABLE `referral_requests` DROP COLUMN `visibility`;
+-- Drop column "visibility" from table: "candidates"
+ALTER TABLE `candidates` DROP COLUMN `visibility`;
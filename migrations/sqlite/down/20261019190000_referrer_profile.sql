@@ -0,0 +1,14 @@
+-- Drop "referrer_locations" table
+DROP TABLE `referrer_locations`;
+-- Drop "referrer_job_families" table
+DROP TABLE `referrer_job_families`;
+-- Drop column "claimed_at" from table: "referral_requests"
+ALTER TABLE `referral_requests` DROP COLUMN `claimed_at`;
+-- Drop column "unavailable_until" from table: "referrers"
+ALTER TABLE `referrers` DROP COLUMN `unavailable_until`;
+-- Drop column "weekly_capacity" from table: "referrers"
+ALTER TABLE `referrers` DROP COLUMN `weekly_capacity`;
+-- Drop column "seniority" from table: "referrers"
+ALTER TABLE `referrers` DROP COLUMN `seniority`;
+-- Drop column "team" from table: "referrers"
+ALTER TABLE `referrers` DROP COLUMN `team`;
@@ -0,0 +1,2 @@
+-- Drop "referral_request_views" table
+DROP TABLE `referral_request_views`;
@@ -0,0 +1,6 @@
+-- Drop index "idx_referral_requests_job_posting_id" from table: "referral_requests"
+DROP INDEX `idx_referral_requests_job_posting_id`;
+-- Drop column "job_posting_id" from table: "referral_requests"
+ALTER TABLE `referral_requests` DROP COLUMN `job_posting_id`;
+-- Drop "job_postings" table
+DROP TABLE `job_postings`;
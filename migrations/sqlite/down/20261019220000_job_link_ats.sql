@@ -0,0 +1,4 @@
+-- Drop column "ats_job_id" from table: "referral_request_job_links_associations"
+ALTER TABLE `referral_request_job_links_associations` DROP COLUMN `ats_job_id`;
+-- Drop column "ats" from table: "referral_request_job_links_associations"
+ALTER TABLE `referral_request_job_links_associations` DROP COLUMN `ats`;
@@ -0,0 +1,10 @@
+-- Drop index "idx_referral_request_location_associations_country" from table: "referral_request_location_associations"
+DROP INDEX `idx_referral_request_location_associations_country`;
+-- Drop column "remote" from table: "referral_request_location_associations"
+ALTER TABLE `referral_request_location_associations` DROP COLUMN `remote`;
+-- Drop column "country" from table: "referral_request_location_associations"
+ALTER TABLE `referral_request_location_associations` DROP COLUMN `country`;
+-- Drop column "region" from table: "referral_request_location_associations"
+ALTER TABLE `referral_request_location_associations` DROP COLUMN `region`;
+-- Drop column "city" from table: "referral_request_location_associations"
+ALTER TABLE `referral_request_location_associations` DROP COLUMN `city`;
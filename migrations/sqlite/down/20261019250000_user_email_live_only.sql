@@ -0,0 +1,3 @@
+-- Fails if a deleted user and a live one share an email
+DROP INDEX `idx_users_email`;
+CREATE UNIQUE INDEX `idx_users_email` ON `users` (`email`);
@@ -0,0 +1,2 @@
+-- Drop column "sessions_revoked_at" from table: "users"
+ALTER TABLE `users` DROP COLUMN `sessions_revoked_at`;
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const reapUsage = `usage: muslim-referrals reap [flags]
+
+Runs the expiry jobs the server runs every jobs.reap_interval, once: email verifications
+still pending past their expiry are marked as expired, unconfirmed account deletion requests
+are dropped and accounts whose deletion grace period has ended are erased. Erasing an account
+records the time its sessions were revoked in the database, so every server refuses the
+account's tokens from then on, even ones it had cached. Flags are the same as the server's.`
+
+// runReap implements the reap subcommand and returns the process exit code.
+func runReap(args []string) int {
+	cfg, code := loadCommandConfig(flag.NewFlagSet("reap", flag.ContinueOnError), args, reapUsage)
+	if cfg == nil {
+		return code
+	}
+
+	ctx := context.Background()
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.CloseDatabase()
+
+	result, err := newCommandService(cfg, db).Reap(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Reap failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Expired %d email verifications, dropped %d unconfirmed account deletions and deleted %d accounts\n",
+		result.ExpiredVerifications, result.UnconfirmedAccountDeletions, result.DeletedAccounts)
+	return 0
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+const seedUsage = `usage: muslim-referrals seed [-users n] [-companies n] [-seed n] [-force] [flags]
+
+Fills the database with realistic fake users, companies, candidates, referrers and referral
+requests, some of them claimed. Requests go through the same rules as the API. Everything is
+created in one transaction, and the same -seed always produces the same data.
+It refuses to touch a database that already has users unless -force is given.
+Other flags are the same as the server's.`
+
+var (
+	seedFirstNames = []string{"Aisha", "Omar", "Fatima", "Yusuf", "Maryam", "Ibrahim", "Khadija", "Bilal",
+		"Zainab", "Hamza", "Amina", "Idris", "Layla", "Tariq", "Noor", "Salman", "Hafsa", "Zaid", "Sumayya", "Musa"}
+	seedLastNames = []string{"Rahman", "Khan", "Siddiqui", "Abdullah", "Hussain", "Malik", "Chaudhry", "Haddad",
+		"Farouk", "Mansour", "Qureshi", "Nasser", "Osman", "Yilmaz", "Bakr", "Hassan"}
+	seedCompanies = []struct{ name, domain string }{
+		{"Crescent Labs", "crescentlabs.example"},
+		{"Oasis Cloud", "oasiscloud.example"},
+		{"Minaret Systems", "minaret.example"},
+		{"Sahara Analytics", "sahara-analytics.example"},
+		{"Lantern Health", "lanternhealth.example"},
+		{"Caravan Logistics", "caravan.example"},
+		{"Zamzam Payments", "zamzampay.example"},
+		{"Andalus Robotics", "andalus.example"},
+		{"Noor Energy", "noorenergy.example"},
+		{"Medina Media", "medinamedia.example"},
+	}
+	seedJobTitles = []string{"Software Engineer", "Senior Software Engineer", "Data Scientist", "Product Manager",
+		"Site Reliability Engineer", "UX Designer", "Data Engineer", "Engineering Manager", "Security Engineer"}
+	seedLocations = []string{"New York, NY", "Toronto, ON", "London, UK", "Seattle, WA", "Austin, TX",
+		"Chicago, IL", "Dearborn, MI", "Remote"}
+	seedReferralTypes = []database.ReferralType{database.FullTime, database.FullTime, database.Internship,
+		database.PartTime, database.Contract}
+)
+
+// runSeed implements the seed subcommand and returns the process exit code.
+func runSeed(args []string) int {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	users := fs.Int("users", 30, "number of users to create; about a third become referrers")
+	companies := fs.Int("companies", 6, fmt.Sprintf("number of companies to create, at most %d", len(seedCompanies)))
+	seed := fs.Uint64("seed", 1, "random seed")
+	force := fs.Bool("force", false, "seed even if the database already has users")
+	cfg, code := loadCommandConfig(fs, args, seedUsage)
+	if cfg == nil {
+		return code
+	}
+	if *users < 2 || *companies < 1 || *companies > len(seedCompanies) {
+		fmt.Fprintf(os.Stderr, "-users must be at least 2 and -companies between 1 and %d\n", len(seedCompanies))
+		return 2
+	}
+
+	ctx := context.Background()
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.CloseDatabase()
+
+	existing, err := db.CountUsers()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to count users: %v\n", err)
+		return 1
+	}
+	if existing > 0 && !*force {
+		fmt.Fprintf(os.Stderr, "The database already has %d users; pass -force to seed it anyway\n", existing)
+		return 1
+	}
+
+	seeder := &seeder{cfg: cfg, rand: rand.New(rand.NewPCG(*seed, *seed)), offset: int(existing)}
+	err = db.Transaction(func(tx *database.DbDriver) error {
+		return seeder.seed(ctx, tx, *users, *companies)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Seeding failed, nothing was written: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Created %d users, %d companies, %d candidates, %d referrers and %d referral requests (%d claimed)\n",
+		seeder.users, seeder.companies, seeder.candidates, seeder.referrers, seeder.requests, seeder.claimed)
+	return 0
+}
+
+// seeder creates the fake data and counts what it created.
+type seeder struct {
+	cfg    *config.Config
+	rand   *rand.Rand
+	offset int // Keeps generated emails unique when seeding a database that already has users
+
+	users, companies, candidates, referrers, requests, claimed int
+}
+
+func (sd *seeder) seed(ctx context.Context, db *database.DbDriver, userCount, companyCount int) error {
+	svc := newCommandService(sd.cfg, db)
+
+	users := make([]*database.User, 0, userCount)
+	for i := 0; i < userCount; i++ {
+		user, err := db.CreateUser(sd.fakeUser(sd.offset + i))
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		users = append(users, user)
+		sd.users++
+	}
+
+	companies := make([]*database.Company, 0, companyCount)
+	for _, seedCompany := range seedCompanies[:companyCount] {
+		company, err := db.CreateCompany(&database.Company{
+			Name:          seedCompany.name,
+			Domains:       []database.CompanyDomainAssociation{{Domain: seedCompany.domain}},
+			IsSupported:   true,
+			AddedByUserId: users[sd.rand.IntN(len(users))].Id,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create company: %w", err)
+		}
+		companies = append(companies, company)
+		sd.companies++
+	}
+
+	// The first user of every three refers at a company, the rest are looking for a job
+	referrerUsers := map[uint64][]uint64{} // Company ID to the user IDs of its referrers
+	var candidates []*database.Candidate
+	for i, user := range users {
+		if i%3 == 0 {
+			company := companies[sd.rand.IntN(len(companies))]
+			_, err := db.CreateReferrer(&database.Referrer{
+				UserId:         user.Id,
+				CompanyId:      company.Id,
+				CorporateEmail: emailLocalPart(user) + "@" + company.Domains[0].Domain,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create referrer: %w", err)
+			}
+			referrerUsers[company.Id] = append(referrerUsers[company.Id], user.Id)
+			sd.referrers++
+			continue
+		}
+		candidate, err := db.CreateCandidate(&database.Candidate{
+			UserId:         user.Id,
+			WorkExperience: sd.rand.IntN(15),
+			ResumeUrl:      fmt.Sprintf("https://example.com/resumes/%s.pdf", emailLocalPart(user)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create candidate: %w", err)
+		}
+		candidates = append(candidates, candidate)
+		sd.candidates++
+	}
+
+	for _, candidate := range candidates {
+		for _, companyIndex := range sd.rand.Perm(len(companies))[:1+sd.rand.IntN(min(3, len(companies)))] {
+			company := companies[companyIndex]
+			request, err := svc.CreateReferralRequest(ctx, candidate.CandidateId, sd.fakeReferralRequest(company))
+			if err != nil {
+				return fmt.Errorf("failed to create referral request: %w", err)
+			}
+			sd.requests++
+
+			// About half the requests at companies with referrers have been picked up
+			referrers := referrerUsers[company.Id]
+			if len(referrers) == 0 || sd.rand.IntN(2) == 0 {
+				continue
+			}
+			claimed, err := svc.ClaimReferralRequest(ctx, referrers[sd.rand.IntN(len(referrers))], request.ReferralRequestId)
+			if err != nil {
+				return fmt.Errorf("failed to claim referral request: %w", err)
+			}
+			sd.claimed++
+			if outcome := sd.rand.IntN(4); outcome < 2 {
+				claimed.Status = []database.ReferralStatus{database.ReferralSubmissionAccepted, database.ReferralSubmissionRejected}[outcome]
+				if _, err := db.UpdateReferralRequest(claimed); err != nil {
+					return fmt.Errorf("failed to update referral request: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (sd *seeder) fakeUser(n int) *database.User {
+	firstName := seedFirstNames[sd.rand.IntN(len(seedFirstNames))]
+	lastName := seedLastNames[sd.rand.IntN(len(seedLastNames))]
+	linkedIn := fmt.Sprintf("https://www.linkedin.com/in/%s-%s-%d", strings.ToLower(firstName), strings.ToLower(lastName), n)
+	return &database.User{
+		FirstName:   firstName,
+		LastName:    lastName,
+		Email:       fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(firstName), strings.ToLower(lastName), n),
+		PhoneNumber: fmt.Sprintf("+1555%07d", sd.rand.IntN(10_000_000)),
+		LinkedIn:    &linkedIn,
+	}
+}
+
+func (sd *seeder) fakeReferralRequest(company *database.Company) *database.ReferralRequest {
+	title := seedJobTitles[sd.rand.IntN(len(seedJobTitles))]
+	location := seedLocations[sd.rand.IntN(len(seedLocations))]
+	return &database.ReferralRequest{
+		CompanyID:              company.Id,
+		PrimaryJobTitleSeeking: title,
+		Summary: fmt.Sprintf("%s with %d years of experience looking for a %s role at %s.",
+			seedJobTitles[sd.rand.IntN(len(seedJobTitles))], 1+sd.rand.IntN(12), strings.ToLower(title), company.Name),
+		ReferralType: seedReferralTypes[sd.rand.IntN(len(seedReferralTypes))],
+		JobLinks: []database.ReferralRequestJobLinksAssociation{{
+			JobLink: fmt.Sprintf("https://careers.%s/jobs/%d", company.Domains[0].Domain, 1000+sd.rand.IntN(9000)),
+		}},
+		Locations: []database.ReferralRequestLocationAssociation{{Location: location}},
+	}
+}
+
+// emailLocalPart returns the part of the user's email before the @.
+func emailLocalPart(user *database.User) string {
+	localPart, _, _ := strings.Cut(user.Email, "@")
+	return localPart
+}
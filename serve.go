@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os/signal"
+	"syscall"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/api"
+	"github.com/Suhaibinator/muslim-referrals-backend/metrics"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/resend/resend-go/v2" // Added Resend import
+)
+
+const serveUsage = `usage: muslim-referrals serve [flags]
+
+Runs the HTTP server until it receives SIGINT or SIGTERM. Pending migrations are applied
+first unless auto_migrate is off, in which case the server refuses to start, and stored
+locations the gazetteer has learned to place are backfilled.`
+
+// runServe implements the serve subcommand and returns the process exit code.
+func runServe(args []string) int {
+	cfg, code := loadCommandConfig(flag.NewFlagSet("serve", flag.ContinueOnError), args, serveUsage)
+	if cfg == nil {
+		return code
+	}
+
+	db, err := openDatabase(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Failed to open database", "error", err)
+		return 1
+	}
+	prometheus.MustRegister(metrics.NewOpenReferralRequestsCollector(db.CountOpenReferralRequests))
+
+	// Initialize Resend client
+	var emailSender service.EmailSender
+	if cfg.Email.ResendAPIKey == "" {
+		slog.Warn("RESEND_API_KEY is not configured, email sending will be disabled")
+		// Allow service to start without API key for environments where email isn't needed/configured;
+		// a nil sender makes the service skip sending and /readyz report it
+	} else {
+		emailSender = resend.NewClient(cfg.Email.ResendAPIKey).Emails
+	}
+
+	// Pass the db driver (wrapped to satisfy DatabaseOperations) and the resend client (which satisfies EmailSender)
+	service := service.NewService(cfg, service.NewDatabaseOperations(db), emailSender) // Pass resendClient.Emails which implements EmailsSvc
+	// Until it succeeds, locations it would have placed are normalized from their text when used
+	if _, err := service.BackfillLocations(context.Background()); err != nil {
+		slog.Warn("Failed to backfill referral request locations", "error", err)
+	}
+	service.Start()
+
+	httpServer := api.NewHttpServer(cfg, service, db)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- httpServer.StartServer()
+	}()
+
+	// Cancelled on the first SIGINT/SIGTERM; a second signal kills the process immediately
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("Received shutdown signal, shutting down")
+	case err := <-serverErr:
+		slog.Error("HTTP server failed", "error", err)
+		exitCode = 1
+	}
+	stop()
+
+	// Stop in dependency order: no new requests, then background workers, then the database
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error during HTTP server shutdown", "error", err)
+		exitCode = 1
+	}
+	cancel()
+	service.Stop()
+	if err := db.CloseDatabase(); err != nil {
+		slog.Error("Error closing database", "error", err)
+		exitCode = 1
+	}
+	return exitCode
+}
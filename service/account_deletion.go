@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/logging"
+	"github.com/Suhaibinator/muslim-referrals-backend/metrics"
+
+	"github.com/google/uuid"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/resend/resend-go/v2"
+	"gorm.io/gorm"
+)
+
+// Deleting an account takes three steps: DELETE /api/user emails the user a confirmation link,
+// opening it schedules the deletion for the end of the grace period and the reaper then erases
+// the account with database.AnonymizeUser and revokes the user's sessions. Until then the user
+// can cancel.
+
+var (
+	ErrAccountDeletionNotFound  = errors.New("account deletion request not found")
+	ErrAccountDeletionExpired   = errors.New("account deletion confirmation link expired")
+	ErrAccountDeletionScheduled = errors.New("account deletion is already scheduled")
+	ErrSessionRevoked           = errors.New("session revoked")
+)
+
+// accountDeletionEmailType labels deletion confirmation emails in the email metrics.
+const accountDeletionEmailType = "account_deletion"
+
+// RequestAccountDeletion starts deleting the user's account by emailing them a confirmation
+// link. Requesting again replaces the previous link, unless the deletion is already confirmed.
+func (s *Service) RequestAccountDeletion(ctx context.Context, userID uint64) (*database.AccountDeletion, error) {
+	user := s.dbDriver.GetUserById(userID)
+	if user == nil || user.Id == 0 {
+		return nil, ErrUserNotFound
+	}
+	existing, err := s.dbDriver.GetAccountDeletion(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.ErrorContext(ctx, "Error loading account deletion", "error", err)
+		return nil, fmt.Errorf("failed to load account deletion: %w", err)
+	}
+	if existing != nil && existing.Status == database.AccountDeletionScheduled {
+		return existing, ErrAccountDeletionScheduled
+	}
+	// Without email the request could never be confirmed
+	if s.emailSender == nil {
+		slog.WarnContext(ctx, "Email sender not configured, can't confirm account deletion")
+		metrics.EmailsSentTotal.WithLabelValues(accountDeletionEmailType, "disabled").Inc()
+		return nil, ErrEmailSendingDisabled
+	}
+
+	now := time.Now()
+	deletion := &database.AccountDeletion{
+		UserID:           userID,
+		ConfirmationCode: uuid.NewString(),
+		Status:           database.AccountDeletionPending,
+		RequestedAt:      now,
+		ConfirmBy:        now.Add(s.config.AccountDeletion.ConfirmationTTL),
+	}
+	if err := s.dbDriver.SaveAccountDeletion(deletion); err != nil {
+		slog.ErrorContext(ctx, "Error saving account deletion", "error", err)
+		return nil, fmt.Errorf("failed to save account deletion: %w", err)
+	}
+	if err := s.sendAccountDeletionEmail(ctx, user, deletion); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Requested account deletion", "confirm_by", deletion.ConfirmBy)
+	return deletion, nil
+}
+
+func (s *Service) sendAccountDeletionEmail(ctx context.Context, user *database.User, deletion *database.AccountDeletion) error {
+	confirmLink, err := url.JoinPath(s.config.Server.BaseURL, "/api/user/deletion/confirm", deletion.ConfirmationCode)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error building account deletion link", "error", err)
+		return ErrEmailSendFailed
+	}
+	htmlBody := fmt.Sprintf(`
+		<h1>Delete your Muslim Referrals account?</h1>
+		<p>We received a request to delete your account. To confirm, click the link below:</p>
+		<p><a href="%s">Delete my account</a></p>
+		<p>This link will expire in %s. Once confirmed, your account is deleted after %s; you can cancel by signing in before then.</p>
+		<p>If you did not ask to delete your account, please ignore this email.</p>
+	`, confirmLink, s.config.AccountDeletion.ConfirmationTTL.String(), s.config.AccountDeletion.GracePeriod.String())
+
+	sent, err := s.emailSender.Send(&resend.SendEmailRequest{
+		From:    s.config.Email.Sender,
+		To:      []string{user.Email},
+		Subject: "Confirm your account deletion",
+		Html:    htmlBody,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending account deletion email via Resend", "error", err)
+		metrics.EmailsSentTotal.WithLabelValues(accountDeletionEmailType, "failure").Inc()
+		return ErrEmailSendFailed
+	}
+	metrics.EmailsSentTotal.WithLabelValues(accountDeletionEmailType, "success").Inc()
+	slog.InfoContext(ctx, "Sent account deletion email via Resend", "resend_id", sent.Id)
+	return nil
+}
+
+// ConfirmAccountDeletion confirms the deletion request with the given code, scheduling the
+// account to be erased at the end of the grace period. Confirming twice is harmless.
+func (s *Service) ConfirmAccountDeletion(ctx context.Context, code string) (*database.AccountDeletion, error) {
+	deletion, err := s.dbDriver.GetAccountDeletionByCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountDeletionNotFound
+		}
+		slog.ErrorContext(ctx, "Error loading account deletion", "error", err)
+		return nil, fmt.Errorf("failed to load account deletion: %w", err)
+	}
+	// The confirmation link is opened without the auth cookie, so attribute the request here
+	logging.SetUserID(ctx, deletion.UserID)
+
+	if deletion.Status == database.AccountDeletionScheduled {
+		return deletion, nil
+	}
+	now := time.Now()
+	if now.After(deletion.ConfirmBy) {
+		return nil, ErrAccountDeletionExpired
+	}
+
+	scheduledFor := now.Add(s.config.AccountDeletion.GracePeriod)
+	deletion.Status = database.AccountDeletionScheduled
+	deletion.ScheduledFor = &scheduledFor
+	if err := s.dbDriver.SaveAccountDeletion(deletion); err != nil {
+		slog.ErrorContext(ctx, "Error scheduling account deletion", "error", err)
+		return nil, fmt.Errorf("failed to schedule account deletion: %w", err)
+	}
+	slog.InfoContext(ctx, "Scheduled account deletion", "scheduled_for", scheduledFor)
+	return deletion, nil
+}
+
+// GetAccountDeletion returns the user's pending or scheduled deletion.
+func (s *Service) GetAccountDeletion(ctx context.Context, userID uint64) (*database.AccountDeletion, error) {
+	deletion, err := s.dbDriver.GetAccountDeletion(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountDeletionNotFound
+		}
+		slog.ErrorContext(ctx, "Error loading account deletion", "error", err)
+		return nil, fmt.Errorf("failed to load account deletion: %w", err)
+	}
+	return deletion, nil
+}
+
+// CancelAccountDeletion cancels the user's pending or scheduled deletion.
+func (s *Service) CancelAccountDeletion(ctx context.Context, userID uint64) error {
+	if err := s.dbDriver.CancelAccountDeletion(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccountDeletionNotFound
+		}
+		slog.ErrorContext(ctx, "Error cancelling account deletion", "error", err)
+		return fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+	slog.InfoContext(ctx, "Cancelled account deletion")
+	return nil
+}
+
+// deleteDueAccounts erases the accounts whose grace period has ended and returns how many it
+// erased. A failure for one account is logged and the others still go ahead.
+func (s *Service) deleteDueAccounts(ctx context.Context, now time.Time) (int64, error) {
+	due, err := s.dbDriver.GetDueAccountDeletions(now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load due account deletions: %w", err)
+	}
+
+	var deleted int64
+	var errs []error
+	for _, deletion := range due {
+		if err := s.dbDriver.AnonymizeUser(deletion.UserID); err != nil {
+			slog.ErrorContext(ctx, "Error deleting account", "target_user_id", deletion.UserID, "error", err)
+			errs = append(errs, fmt.Errorf("user %d: %w", deletion.UserID, err))
+			continue
+		}
+		s.exports.Delete(deletion.UserID)
+		deleted++
+		revoked, err := s.RevokeSessions(deletion.UserID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error revoking sessions of deleted account", "target_user_id", deletion.UserID, "error", err)
+			errs = append(errs, fmt.Errorf("user %d: %w", deletion.UserID, err))
+			continue
+		}
+		slog.InfoContext(ctx, "Deleted account", "target_user_id", deletion.UserID, "sessions_revoked", revoked)
+	}
+	if len(errs) > 0 {
+		return deleted, fmt.Errorf("failed to delete accounts: %w", errors.Join(errs...))
+	}
+	return deleted, nil
+}
+
+// RevokeSessions signs the user out everywhere. The revocation is recorded on the user, so
+// every process refuses tokens looked up before it when they are next used, including ones
+// cached by a server other than the one revoking them, e.g. when the reap command erases an
+// account. This process's cached tokens are dropped right away and refused from then on, rather
+// than looked up again with Google; other processes only learn which tokens to refuse as they
+// are used, so a token they hadn't cached is looked up with Google like any new one. It returns
+// the number of sessions this process had cached.
+func (s *Service) RevokeSessions(userID uint64) (int, error) {
+	if err := s.dbDriver.RevokeUserSessions(userID, time.Now()); err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	var tokens []string
+	s.userToIdCache.Range(func(item *ttlcache.Item[string, cachedSession]) bool {
+		if item.Value().userID == userID {
+			tokens = append(tokens, item.Key())
+		}
+		return true
+	})
+	for _, token := range tokens {
+		s.userToIdCache.Delete(token)
+		s.revokedTokens.Set(token, struct{}{}, ttlcache.DefaultTTL)
+	}
+	return len(tokens), nil
+}
@@ -0,0 +1,186 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/resend/resend-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// --- Mock methods for account deletion ---
+
+func (m *MockDatabaseDriver) GetUserById(id uint64) *database.User {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*database.User)
+}
+
+func (m *MockDatabaseDriver) GetAccountDeletion(userID uint64) (*database.AccountDeletion, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.AccountDeletion), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) GetAccountDeletionByCode(code string) (*database.AccountDeletion, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.AccountDeletion), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) SaveAccountDeletion(deletion *database.AccountDeletion) error {
+	args := m.Called(deletion)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseDriver) CancelAccountDeletion(userID uint64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseDriver) GetDueAccountDeletions(now time.Time) ([]database.AccountDeletion, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]database.AccountDeletion), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) DeleteUnconfirmedAccountDeletions(now time.Time) (int64, error) {
+	args := m.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) AnonymizeUser(userID uint64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseDriver) RevokeUserSessions(userID uint64, at time.Time) error {
+	args := m.Called(userID, at)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseDriver) GetSessionsRevokedAt(userID uint64) (*time.Time, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+// --- Test Cases ---
+
+func TestRequestAccountDeletion_SendsConfirmation(t *testing.T) {
+	mockResendEmails := new(MockResendEmailsAPI)
+	svc, mockDB, _ := setupServiceWithMocks(mockResendEmails)
+	mockDB.On("GetUserById", uint64(4)).Return(&database.User{Id: 4, Email: "amina@example.com"})
+	mockDB.On("GetAccountDeletion", uint64(4)).Return(nil, gorm.ErrRecordNotFound)
+	mockDB.On("SaveAccountDeletion", mock.MatchedBy(func(d *database.AccountDeletion) bool {
+		return d.UserID == 4 && d.Status == database.AccountDeletionPending && d.ConfirmationCode != ""
+	})).Return(nil)
+	var sent *resend.SendEmailRequest
+	mockResendEmails.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(0).(*resend.SendEmailRequest)
+	}).Return(&resend.SendEmailResponse{Id: "email-id"}, nil)
+
+	deletion, err := svc.RequestAccountDeletion(context.Background(), 4)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"amina@example.com"}, sent.To)
+	assert.True(t, strings.Contains(sent.Html, "/api/user/deletion/confirm/"+deletion.ConfirmationCode),
+		"the email should link to the confirmation endpoint")
+}
+
+func TestRequestAccountDeletion_AlreadyScheduled(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(new(MockResendEmailsAPI))
+	mockDB.On("GetUserById", uint64(4)).Return(&database.User{Id: 4})
+	mockDB.On("GetAccountDeletion", uint64(4)).Return(&database.AccountDeletion{UserID: 4, Status: database.AccountDeletionScheduled}, nil)
+
+	_, err := svc.RequestAccountDeletion(context.Background(), 4)
+
+	assert.ErrorIs(t, err, service.ErrAccountDeletionScheduled)
+	mockDB.AssertNotCalled(t, "SaveAccountDeletion", mock.Anything)
+}
+
+func TestConfirmAccountDeletion_SchedulesAfterGracePeriod(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	pending := &database.AccountDeletion{UserID: 4, ConfirmationCode: "code", ConfirmBy: time.Now().Add(time.Hour)}
+	mockDB.On("GetAccountDeletionByCode", "code").Return(pending, nil)
+	mockDB.On("SaveAccountDeletion", pending).Return(nil)
+
+	deletion, err := svc.ConfirmAccountDeletion(context.Background(), "code")
+
+	require.NoError(t, err)
+	assert.Equal(t, database.AccountDeletionScheduled, deletion.Status)
+	grace := newTestConfig().AccountDeletion.GracePeriod
+	assert.WithinDuration(t, time.Now().Add(grace), *deletion.ScheduledFor, time.Minute)
+}
+
+func TestConfirmAccountDeletion_Expired(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetAccountDeletionByCode", "code").Return(&database.AccountDeletion{UserID: 4, ConfirmBy: time.Now().Add(-time.Minute)}, nil)
+
+	_, err := svc.ConfirmAccountDeletion(context.Background(), "code")
+
+	assert.ErrorIs(t, err, service.ErrAccountDeletionExpired)
+	mockDB.AssertNotCalled(t, "SaveAccountDeletion", mock.Anything)
+}
+
+func TestReap_DeletesDueAccountsAndRevokesSessions(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	svc.SetUserIDForToken("deleted-user-token", 4)
+	svc.SetUserIDForToken("other-user-token", 5)
+	mockDB.On("ExpireEmailVerifications", mock.AnythingOfType("time.Time")).Return(int64(0), nil)
+	mockDB.On("DeleteUnconfirmedAccountDeletions", mock.AnythingOfType("time.Time")).Return(int64(1), nil)
+	mockDB.On("GetDueAccountDeletions", mock.AnythingOfType("time.Time")).Return([]database.AccountDeletion{{UserID: 4}}, nil)
+	mockDB.On("AnonymizeUser", uint64(4)).Return(nil)
+	mockDB.On("RevokeUserSessions", uint64(4), mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+	result, err := svc.Reap(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.UnconfirmedAccountDeletions)
+	assert.Equal(t, int64(1), result.DeletedAccounts)
+	_, cached := svc.CachedUserIDForToken("deleted-user-token")
+	assert.False(t, cached, "the deleted user's session should be dropped")
+	_, _, err = svc.GetUserIdFromTokenDigest(context.Background(), "deleted-user-token")
+	assert.ErrorIs(t, err, service.ErrSessionRevoked, "the token must not be looked up again")
+	_, cached = svc.CachedUserIDForToken("other-user-token")
+	assert.True(t, cached, "other users' sessions should be kept")
+}
+
+func TestGetUserIdFromTokenDigest_RefusesSessionsRevokedElsewhere(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	svc.SetUserIDForToken("deleted-user-token", 4)
+	svc.SetUserIDForToken("other-user-token", 5)
+	// Recorded by another process, such as the reap command, after the tokens were cached
+	revokedAt := time.Now().Add(time.Second)
+	mockDB.On("GetSessionsRevokedAt", uint64(4)).Return(&revokedAt, nil).Once()
+	mockDB.On("GetSessionsRevokedAt", uint64(5)).Return(nil, nil)
+
+	_, _, err := svc.GetUserIdFromTokenDigest(context.Background(), "deleted-user-token")
+	assert.ErrorIs(t, err, service.ErrSessionRevoked)
+	_, cached := svc.CachedUserIDForToken("deleted-user-token")
+	assert.False(t, cached, "the revoked session should be dropped")
+	_, _, err = svc.GetUserIdFromTokenDigest(context.Background(), "deleted-user-token")
+	assert.ErrorIs(t, err, service.ErrSessionRevoked, "the token must not be looked up again")
+
+	userID, _, err := svc.GetUserIdFromTokenDigest(context.Background(), "other-user-token")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), userID)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+
+	"gorm.io/gorm"
+)
+
+// SetAdmin grants (or, with isAdmin false, revokes) admin rights for the user with the given email.
+func (s *Service) SetAdmin(ctx context.Context, email string, isAdmin bool) (*database.User, error) {
+	user := s.dbDriver.GetUserByEmail(email)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.IsAdmin == isAdmin {
+		return user, nil
+	}
+
+	user.IsAdmin = isAdmin
+	if err := s.dbDriver.UpdateUser(user); err != nil {
+		slog.ErrorContext(ctx, "Error updating admin flag", "target_user_id", user.Id, "error", err)
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	slog.InfoContext(ctx, "Updated admin flag", "target_user_id", user.Id, "is_admin", isAdmin)
+	return user, nil
+}
+
+// MergeCompanies folds the source company into the target: its referrers, referral requests, job
+// postings, domains and aliases move to the target, its name becomes an alias of the target and
+// the source is deleted. The merge is recorded with mergedByUserID, nil when run from the command
+// line.
+func (s *Service) MergeCompanies(ctx context.Context, sourceID, targetID uint64, mergedByUserID *uint64) (*database.CompanyMerge, error) {
+	if sourceID == targetID {
+		return nil, ErrCompanyMergeIntoSelf
+	}
+
+	merge, err := s.dbDriver.MergeCompanies(sourceID, targetID, mergedByUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrCompanyNotFound, err)
+		}
+		slog.ErrorContext(ctx, "Error merging companies", "source_company_id", sourceID, "target_company_id", targetID, "error", err)
+		return nil, fmt.Errorf("failed to merge companies: %w", err)
+	}
+	slog.InfoContext(ctx, "Merged companies", "source_company_id", sourceID, "target_company_id", targetID,
+		"referrers", merge.Referrers, "referral_requests", merge.ReferralRequests, "job_postings", merge.JobPostings,
+		"domains", merge.Domains, "aliases", merge.Aliases)
+	return merge, nil
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// --- Mock methods for admin operations and jobs ---
+
+func (m *MockDatabaseDriver) UpdateUser(user *database.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockDatabaseDriver) MergeCompanies(sourceID, targetID uint64, mergedByUserID *uint64) (*database.CompanyMerge, error) {
+	args := m.Called(sourceID, targetID, mergedByUserID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.CompanyMerge), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) ExpireEmailVerifications(now time.Time) (int64, error) {
+	args := m.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// --- Test Cases ---
+
+func TestSetAdmin_Promotes(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	user := &database.User{Id: 4, Email: "admin@example.com"}
+	mockDB.On("GetUserByEmail", user.Email).Return(user)
+	mockDB.On("UpdateUser", mock.MatchedBy(func(u *database.User) bool { return u.Id == 4 && u.IsAdmin })).Return(nil)
+
+	updated, err := svc.SetAdmin(context.Background(), user.Email, true)
+
+	assert.NoError(t, err)
+	assert.True(t, updated.IsAdmin)
+	mockDB.AssertExpectations(t)
+}
+
+func TestSetAdmin_UnknownEmail(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetUserByEmail", "nobody@example.com").Return(nil)
+
+	_, err := svc.SetAdmin(context.Background(), "nobody@example.com", true)
+
+	assert.ErrorIs(t, err, service.ErrUserNotFound)
+	mockDB.AssertNotCalled(t, "UpdateUser", mock.Anything)
+}
+
+func TestMergeCompanies_IntoSelf(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+
+	_, err := svc.MergeCompanies(context.Background(), 3, 3, nil)
+
+	assert.ErrorIs(t, err, service.ErrCompanyMergeIntoSelf)
+	mockDB.AssertNotCalled(t, "MergeCompanies", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMergeCompanies_MissingCompany(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("MergeCompanies", uint64(3), uint64(9), (*uint64)(nil)).Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := svc.MergeCompanies(context.Background(), 3, 9, nil)
+
+	assert.ErrorIs(t, err, service.ErrCompanyNotFound)
+}
+
+func TestReap_ExpiresVerifications(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("ExpireEmailVerifications", mock.AnythingOfType("time.Time")).Return(int64(2), nil)
+	mockDB.On("DeleteUnconfirmedAccountDeletions", mock.AnythingOfType("time.Time")).Return(int64(0), nil)
+	mockDB.On("GetDueAccountDeletions", mock.AnythingOfType("time.Time")).Return([]database.AccountDeletion{}, nil)
+
+	result, err := svc.Reap(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.ExpiredVerifications)
+}
+
+func TestReap_DbError(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	dbErr := errors.New("database is locked")
+	mockDB.On("ExpireEmailVerifications", mock.AnythingOfType("time.Time")).Return(int64(0), dbErr)
+
+	_, err := svc.Reap(context.Background())
+
+	assert.ErrorIs(t, err, dbErr)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+// CompanyMatchReason says why an existing company was taken for the one being created.
+type CompanyMatchReason string
+
+const (
+	CompanyMatchDomain      CompanyMatchReason = "domain"       // It has one of the domains
+	CompanyMatchName        CompanyMatchReason = "name"         // Same name, give or take case, punctuation and legal suffixes
+	CompanyMatchAlias       CompanyMatchReason = "alias"        // The name is one of its aliases
+	CompanyMatchSimilarName CompanyMatchReason = "similar_name" // Its name or an alias is close to the name
+)
+
+// Similar names have to be at least this close, as 1 - edit distance / length of the longer
+// name key; 0.8 allows one typo in a five letter name
+const minCompanyNameSimilarity = 0.8
+
+// Names this short only match exactly, since a single letter changes them into another name
+const minFuzzyCompanyNameLength = 5
+
+// maxCompanyMatches caps the suggestions for a new company.
+const maxCompanyMatches = 5
+
+// CompanyMatch is an existing company that a new one may duplicate.
+type CompanyMatch struct {
+	Company database.Company
+	Reason  CompanyMatchReason
+	Matched string  // The domain, name or alias that matched
+	Score   float64 // 1 for exact matches, less for similar names
+}
+
+// Exact reports whether the match leaves no doubt that it's the same company.
+func (m CompanyMatch) Exact() bool {
+	return m.Reason != CompanyMatchSimilarName
+}
+
+// FindSimilarCompanies lists the existing companies a company with this name and these domains
+// may duplicate, best match first and each company once. Names are compared by
+// database.CompanyNameKey, against companies' names and aliases; a name that merely starts with
+// another, like "Amazon Web Services" and "Amazon", counts as similar.
+func (s *Service) FindSimilarCompanies(name string, domains []string) []CompanyMatch {
+	key := database.CompanyNameKey(name)
+	wantDomains := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		if domain = normalizeCompanyDomain(domain); domain != "" {
+			wantDomains[domain] = true
+		}
+	}
+
+	var matches []CompanyMatch
+	for _, company := range s.dbDriver.GetAllCompanies() {
+		best := CompanyMatch{}
+		consider := func(match CompanyMatch) {
+			if match.Score > best.Score || (match.Score == best.Score && match.Exact() && !best.Exact()) {
+				best = match
+			}
+		}
+		for _, domain := range company.Domains {
+			if wantDomains[normalizeCompanyDomain(domain.Domain)] {
+				consider(CompanyMatch{Company: company, Reason: CompanyMatchDomain, Matched: domain.Domain, Score: 1})
+			}
+		}
+		names := []string{company.Name}
+		for _, alias := range company.Aliases {
+			names = append(names, alias.Alias)
+		}
+		for i, other := range names {
+			otherKey := database.CompanyNameKey(other)
+			if key == "" || otherKey == "" {
+				continue
+			}
+			if key == otherKey {
+				reason := CompanyMatchName
+				if i > 0 {
+					reason = CompanyMatchAlias
+				}
+				consider(CompanyMatch{Company: company, Reason: reason, Matched: other, Score: 1})
+			} else if score := companyNameSimilarity(key, otherKey); score > 0 {
+				consider(CompanyMatch{Company: company, Reason: CompanyMatchSimilarName, Matched: other, Score: score})
+			}
+		}
+		if best.Score > 0 {
+			matches = append(matches, best)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Company.Id < matches[j].Company.Id
+	})
+	if len(matches) > maxCompanyMatches {
+		matches = matches[:maxCompanyMatches]
+	}
+	return matches
+}
+
+// CreateCompany creates a company unless it already exists, by domain, name or alias, in which
+// case it returns ErrCompanyExists and the existing companies. Companies with similar names are
+// returned with ErrSimilarCompanies instead of creating the company, unless ignoreSimilar is set
+// because the user has seen them and says theirs is a different one.
+func (s *Service) CreateCompany(ctx context.Context, company *database.Company, ignoreSimilar bool) (*database.Company, []CompanyMatch, error) {
+	domains := make([]string, 0, len(company.Domains))
+	for _, domain := range company.Domains {
+		domains = append(domains, domain.Domain)
+	}
+	matches := s.FindSimilarCompanies(company.Name, domains)
+	for _, match := range matches {
+		if match.Exact() {
+			slog.InfoContext(ctx, "Refused to create a duplicate company", "name", company.Name, "existing_company_id", match.Company.Id, "reason", match.Reason)
+			return nil, matches, ErrCompanyExists
+		}
+	}
+	if len(matches) > 0 && !ignoreSimilar {
+		return nil, matches, ErrSimilarCompanies
+	}
+
+	created, err := s.dbDriver.CreateCompany(company)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating company", "name", company.Name, "error", err)
+		return nil, nil, fmt.Errorf("failed to create company: %w", err)
+	}
+	return created, nil, nil
+}
+
+// normalizeCompanyDomain lowercases a domain and drops a leading www.
+func normalizeCompanyDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+}
+
+// companyNameSimilarity scores how alike two different name keys are, from 0 (not similar
+// enough to suggest) to just under 1.
+func companyNameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	shorter, longer := len(ra), len(rb)
+	if shorter > longer {
+		shorter, longer = longer, shorter
+	}
+	if shorter < minFuzzyCompanyNameLength-1 {
+		return 0
+	}
+	score := 1 - float64(levenshtein(ra, rb))/float64(longer)
+	if shorter < minFuzzyCompanyNameLength || score < minCompanyNameSimilarity {
+		score = 0
+	}
+	// A longer name starting with the other is probably the same company's full name
+	if strings.HasPrefix(a, b) || strings.HasPrefix(b, a) {
+		score = max(score, 0.5+0.5*float64(shorter)/float64(longer))
+	}
+	return min(score, 0.99)
+}
+
+// levenshtein is the edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,98 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// --- Mock methods for companies ---
+
+func (m *MockDatabaseDriver) GetAllCompanies() []database.Company {
+	args := m.Called()
+	return args.Get(0).([]database.Company)
+}
+
+func (m *MockDatabaseDriver) CreateCompany(record *database.Company) (*database.Company, error) {
+	args := m.Called(record)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.Company), args.Error(1)
+}
+
+// existingCompanies is Acme and Globex, which also goes by Globex Group.
+func existingCompanies() []database.Company {
+	return []database.Company{
+		*acmeCompany(),
+		{Id: 6, Name: "Globex Corporation", Aliases: []database.CompanyAlias{{Id: 1, CompanyId: 6, Alias: "Globex Group"}}},
+	}
+}
+
+// --- Test Cases ---
+
+func TestFindSimilarCompanies(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		domains []string
+		wantId  uint64 // 0 for no match
+		reason  service.CompanyMatchReason
+	}{
+		{"ACME LLC", nil, 5, service.CompanyMatchName},
+		{"Initech", []string{"WWW.Example.com"}, 5, service.CompanyMatchDomain},
+		{"globex group", nil, 6, service.CompanyMatchAlias},
+		{"Globez", nil, 6, service.CompanyMatchSimilarName},
+		{"Acme Robotics", nil, 5, service.CompanyMatchSimilarName},
+		{"Acne", nil, 0, ""}, // Too short to match on anything but the exact name
+		{"Initech", []string{"initech.com"}, 0, ""},
+	} {
+		svc, mockDB, _ := setupServiceWithMocks(nil)
+		mockDB.On("GetAllCompanies").Return(existingCompanies())
+
+		matches := svc.FindSimilarCompanies(tc.name, tc.domains)
+
+		if tc.wantId == 0 {
+			assert.Empty(t, matches, tc.name)
+			continue
+		}
+		if assert.Len(t, matches, 1, tc.name) {
+			assert.Equal(t, tc.wantId, matches[0].Company.Id, tc.name)
+			assert.Equal(t, tc.reason, matches[0].Reason, tc.name)
+		}
+	}
+}
+
+func TestCreateCompany_RefusesDuplicates(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetAllCompanies").Return(existingCompanies())
+
+	_, matches, err := svc.CreateCompany(context.Background(), &database.Company{Name: "Globex, Corp."}, true)
+
+	assert.ErrorIs(t, err, service.ErrCompanyExists)
+	assert.Len(t, matches, 1)
+	mockDB.AssertNotCalled(t, "CreateCompany", mock.Anything)
+}
+
+func TestCreateCompany_SimilarNames(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetAllCompanies").Return(existingCompanies())
+	company := &database.Company{Name: "Globex Labs"}
+
+	_, matches, err := svc.CreateCompany(context.Background(), company, false)
+	assert.ErrorIs(t, err, service.ErrSimilarCompanies)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, uint64(6), matches[0].Company.Id)
+	}
+	mockDB.AssertNotCalled(t, "CreateCompany", mock.Anything)
+
+	// Once the user says it's a different company, it's created
+	mockDB.On("CreateCompany", company).Return(company, nil)
+	created, _, err := svc.CreateCompany(context.Background(), company, true)
+	assert.NoError(t, err)
+	assert.Same(t, company, created)
+}
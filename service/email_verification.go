@@ -1,12 +1,16 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
 	"time"
 
 	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/logging"
+	"github.com/Suhaibinator/muslim-referrals-backend/metrics"
 
 	"github.com/google/uuid"
 	"github.com/resend/resend-go/v2" // Added Resend import
@@ -24,46 +28,42 @@ var (
 	ErrEmailSendFailed          = errors.New("failed to send verification email")
 )
 
-const (
-	emailVerificationTTL          = 24 * time.Hour // Verification link valid for 24 hours
-	maxActiveVerificationsPerUser = 3              // Max pending requests per user
-	// IMPORTANT: Replace with your actual verified sender email and base URL
-	verificationSenderEmail = "verify@yourdomain.com" // TODO: Replace with verified sender
-	verificationBaseURL     = "http://localhost:8080" // TODO: Replace with actual frontend/API base URL
-)
+// verificationEmailType labels verification emails in the email metrics.
+const verificationEmailType = "verification"
 
 // --- Helper Functions for RequestEmailVerification ---
 
 // checkVerificationPreconditions performs rate limiting and existence checks.
-func (s *Service) checkVerificationPreconditions(userID uint64, emailToVerify string) error {
+func (s *Service) checkVerificationPreconditions(ctx context.Context, userID uint64, emailToVerify string) error {
 	// 1. Check if an active verification already exists for this email
 	existingVerification, err := s.dbDriver.GetActiveVerificationByEmail(emailToVerify)
 	if err != nil {
-		log.Printf("Error checking for existing verification for email %s: %v", emailToVerify, err)
+		slog.ErrorContext(ctx, "Error checking for existing verification", "email", emailToVerify, "error", err)
 		return fmt.Errorf("database error checking existing verification: %w", err)
 	}
 	if existingVerification != nil {
-		log.Printf("User %d attempted to verify email %s, but an active request already exists (ID: %s)", userID, emailToVerify, existingVerification.ID)
+		slog.InfoContext(ctx, "Active verification request already exists for email", "email", emailToVerify, "verification_id", existingVerification.ID)
 		return ErrActiveVerificationExists
 	}
 
 	// 2. Check user's active verification count (Rate Limit)
 	activeCount, err := s.dbDriver.CountActiveVerificationsForUser(userID)
 	if err != nil {
-		log.Printf("Error counting active verifications for user %d: %v", userID, err)
+		slog.ErrorContext(ctx, "Error counting active verifications", "error", err)
 		return fmt.Errorf("database error checking verification count: %w", err)
 	}
-	if activeCount >= maxActiveVerificationsPerUser {
-		log.Printf("User %d attempted to verify email %s, but reached max active limit (%d)", userID, emailToVerify, maxActiveVerificationsPerUser)
+	maxActive := s.config.Verification.MaxActivePerUser
+	if activeCount >= int64(maxActive) {
+		slog.InfoContext(ctx, "User reached the active verification limit", "email", emailToVerify, "limit", maxActive)
 		return ErrMaxVerificationsReached
 	}
 	return nil
 }
 
 // createVerificationRecord creates the initial DB entry for the verification request.
-func (s *Service) createVerificationRecord(userID uint64, emailToVerify string) (*database.EmailVerification, error) {
+func (s *Service) createVerificationRecord(ctx context.Context, userID uint64, emailToVerify string) (*database.EmailVerification, error) {
 	verificationCode := uuid.NewString()
-	expiresAt := time.Now().Add(emailVerificationTTL)
+	expiresAt := time.Now().Add(s.config.Verification.TTL)
 	verification := &database.EmailVerification{
 		ID:               verificationCode, // Use UUID as primary key for easier lookup
 		Email:            emailToVerify,
@@ -75,28 +75,33 @@ func (s *Service) createVerificationRecord(userID uint64, emailToVerify string)
 
 	createdVerification, err := s.dbDriver.CreateEmailVerification(verification)
 	if err != nil {
-		log.Printf("Error creating email verification record for user %d, email %s: %v", userID, emailToVerify, err)
+		slog.ErrorContext(ctx, "Error creating email verification record", "email", emailToVerify, "error", err)
 		return nil, fmt.Errorf("failed to create verification record: %w", err)
 	}
-	log.Printf("Created email verification record ID %s for user %d, email %s", createdVerification.ID, userID, emailToVerify)
+	slog.InfoContext(ctx, "Created email verification record", "verification_id", createdVerification.ID, "email", emailToVerify)
 	return createdVerification, nil
 }
 
 // sendVerificationEmail handles the construction and sending of the email via Resend.
-func (s *Service) sendVerificationEmail(verification *database.EmailVerification) error {
+func (s *Service) sendVerificationEmail(ctx context.Context, verification *database.EmailVerification) error {
 	// Check if the email sender interface is nil (meaning sending is disabled)
 	if s.emailSender == nil {
-		log.Printf("WARN: Email sender not configured. Skipping email send for verification %s.", verification.ID)
+		slog.WarnContext(ctx, "Email sender not configured, skipping email send", "verification_id", verification.ID)
+		metrics.EmailsSentTotal.WithLabelValues(verificationEmailType, "disabled").Inc()
 		return ErrEmailSendingDisabled
 	}
 
 	// Optional: Keep the API key check as a secondary defense, though injection is preferred.
 	// if os.Getenv("RESEND_API_KEY") == "" {
-	// 	log.Printf("WARN: Resend API key not set (env var). Skipping email send for verification %s.", verification.ID)
+	// 	slog.WarnContext(ctx, "Resend API key not set (env var). Skipping email send for verification %s.", verification.ID)
 	// 	return ErrEmailSendingDisabled
 	// }
 
-	verificationLink := fmt.Sprintf("%s/api/email-verification/verify/%s", verificationBaseURL, verification.VerificationCode)
+	verificationLink, err := url.JoinPath(s.config.Server.BaseURL, "/api/email-verification/verify", verification.VerificationCode)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error building verification link", "verification_id", verification.ID, "error", err)
+		return ErrEmailSendFailed
+	}
 	subject := "Verify Your Email Address"
 	htmlBody := fmt.Sprintf(`
 		<h1>Welcome to Muslim Referrals!</h1>
@@ -104,10 +109,10 @@ func (s *Service) sendVerificationEmail(verification *database.EmailVerification
 		<p><a href="%s">Verify Email</a></p>
 		<p>This link will expire in %s.</p>
 		<p>If you did not request this verification, please ignore this email.</p>
-	`, verificationLink, emailVerificationTTL.String())
+	`, verificationLink, s.config.Verification.TTL.String())
 
 	params := &resend.SendEmailRequest{
-		From:    verificationSenderEmail, // Use configured sender
+		From:    s.config.Email.Sender, // Use configured sender
 		To:      []string{verification.Email},
 		Subject: subject,
 		Html:    htmlBody,
@@ -116,18 +121,20 @@ func (s *Service) sendVerificationEmail(verification *database.EmailVerification
 	// Use the injected emailSender interface
 	sent, err := s.emailSender.Send(params)
 	if err != nil {
-		log.Printf("ERROR sending verification email via Resend for verification ID %s (User: %d, Email: %s): %v",
-			verification.ID, verification.UserID, verification.Email, err)
+		slog.ErrorContext(ctx, "Error sending verification email via Resend",
+			"verification_id", verification.ID, "email", verification.Email, "error", err)
+		metrics.EmailsSentTotal.WithLabelValues(verificationEmailType, "failure").Inc()
 		return ErrEmailSendFailed // Return generic send error
 	}
+	metrics.EmailsSentTotal.WithLabelValues(verificationEmailType, "success").Inc()
 
-	log.Printf("Successfully sent verification email via Resend for verification ID %s (User: %d, Email: %s). Resend ID: %s",
-		verification.ID, verification.UserID, verification.Email, sent.Id)
+	slog.InfoContext(ctx, "Sent verification email via Resend",
+		"verification_id", verification.ID, "email", verification.Email, "resend_id", sent.Id)
 	return nil // Send successful
 }
 
 // updateVerificationStatusAfterSend updates the DB status based on the email send outcome.
-func (s *Service) updateVerificationStatusAfterSend(verification *database.EmailVerification, sendErr error) {
+func (s *Service) updateVerificationStatusAfterSend(ctx context.Context, verification *database.EmailVerification, sendErr error) {
 	if verification == nil {
 		return // Should not happen, but defensive check
 	}
@@ -149,71 +156,73 @@ func (s *Service) updateVerificationStatusAfterSend(verification *database.Email
 	if updateErr != nil {
 		// Log error based on the intended status update
 		if sendErr != nil {
-			log.Printf("ERROR updating verification status to SendFailed for ID %s after send error: %v", verification.ID, updateErr)
+			slog.ErrorContext(ctx, "Error updating verification status to SendFailed after send error", "verification_id", verification.ID, "error", updateErr)
 		} else {
-			log.Printf("ERROR updating verification status to Sent for ID %s after successful send: %v", verification.ID, updateErr)
+			slog.ErrorContext(ctx, "Error updating verification status to Sent after successful send", "verification_id", verification.ID, "error", updateErr)
 		}
 		// Note: The primary operation (sending or failing to send) already determined the outcome.
 		// This DB update failure is secondary but should be logged prominently.
 	} else {
-		log.Printf("Successfully updated verification status for ID %s to %d", verification.ID, verification.Status)
+		slog.InfoContext(ctx, "Updated verification status", "verification_id", verification.ID, "status", verification.Status)
 	}
 }
 
 // --- Main Service Methods ---
 
 // RequestEmailVerification creates a new email verification request and sends the email.
-func (s *Service) RequestEmailVerification(userID uint64, emailToVerify string) error {
+func (s *Service) RequestEmailVerification(ctx context.Context, userID uint64, emailToVerify string) error {
 	// 1. Perform Pre-checks (Rate limits, existing requests)
-	if err := s.checkVerificationPreconditions(userID, emailToVerify); err != nil {
+	if err := s.checkVerificationPreconditions(ctx, userID, emailToVerify); err != nil {
 		return err // Return specific errors like ErrActiveVerificationExists, ErrMaxVerificationsReached
 	}
 
 	// 2. Create the initial verification record in the database
-	verification, err := s.createVerificationRecord(userID, emailToVerify)
+	verification, err := s.createVerificationRecord(ctx, userID, emailToVerify)
 	if err != nil {
 		return err // Return error from DB creation
 	}
 
 	// 3. Attempt to send the verification email
-	sendErr := s.sendVerificationEmail(verification)
+	sendErr := s.sendVerificationEmail(ctx, verification)
 
 	// 4. Update the verification status based on send result (handles DB update internally)
-	s.updateVerificationStatusAfterSend(verification, sendErr)
+	s.updateVerificationStatusAfterSend(ctx, verification, sendErr)
 
 	// Return the original send error, if any, to the caller (API handler)
 	// This ensures the API layer knows if the primary action (sending) failed.
 	return sendErr
 }
 
-// VerifyEmail verifies an email address using the provided verification code.
-// (No major refactoring needed here for now, structure is reasonably clear)
-func (s *Service) VerifyEmail(verificationCode string) error {
+// VerifyEmail verifies an email address using the provided verification code and records it as
+// the referrer's corporate email. Both updates happen in one transaction.
+func (s *Service) VerifyEmail(ctx context.Context, verificationCode string) error {
 	verification, err := s.dbDriver.GetEmailVerificationByCode(verificationCode)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("Verification code not found: %s", verificationCode)
+			slog.InfoContext(ctx, "Verification code not found", "verification_code", verificationCode)
 			return ErrVerificationNotFound
 		}
-		log.Printf("Error retrieving verification code %s: %v", verificationCode, err)
+		slog.ErrorContext(ctx, "Error retrieving verification code", "verification_code", verificationCode, "error", err)
 		return fmt.Errorf("database error retrieving verification: %w", err)
 	}
+	// The verification link is opened without the auth cookie, so attribute the request here
+	logging.SetUserID(ctx, verification.UserID)
 
 	// Check status - Should be 'Sent' to be verifiable
 	if verification.Status != database.EmailVerificationStatusSent {
-		log.Printf("Verification code %s has invalid status for verification: %d (expected %d)",
-			verificationCode, verification.Status, database.EmailVerificationStatusSent)
+		slog.InfoContext(ctx, "Verification code has invalid status for verification",
+			"verification_id", verification.ID, "status", verification.Status, "expected_status", database.EmailVerificationStatusSent)
 		// Covers Claimed, Verified, Expired, SendFailed statuses
 		return ErrVerificationInvalid
 	}
 
 	// Check expiry
 	if time.Now().After(verification.ExpiresAt) {
-		log.Printf("Verification code %s expired at %s", verificationCode, verification.ExpiresAt)
+		slog.InfoContext(ctx, "Verification code expired", "verification_id", verification.ID, "expired_at", verification.ExpiresAt)
 		verification.Status = database.EmailVerificationStatusExpired
 		updateErr := s.dbDriver.UpdateEmailVerification(verification)
 		if updateErr != nil {
-			log.Printf("Error updating expired verification status for code %s: %v", verificationCode, updateErr)
+			slog.ErrorContext(ctx, "Error updating expired verification status", "verification_id", verification.ID, "error", updateErr)
 			// Log error but still return expired error to user
 		}
 		return ErrVerificationExpired
@@ -221,34 +230,38 @@ func (s *Service) VerifyEmail(verificationCode string) error {
 
 	// --- Verification Successful ---
 
-	// 1. Update Verification Status
-	verification.Status = database.EmailVerificationStatusVerified
-	err = s.dbDriver.UpdateEmailVerification(verification)
-	if err != nil {
-		log.Printf("Error updating verification status to verified for code %s: %v", verificationCode, err)
-		return fmt.Errorf("database error updating verification status: %w", err)
-	}
+	// Consuming the code and updating the referrer's corporate email commit or roll back together,
+	// so a failure part way leaves the code usable for another attempt
+	var referrer *database.Referrer
+	err = s.dbDriver.RunInTransaction(func(tx DatabaseOperations) error {
+		// 1. Update Verification Status
+		verification.Status = database.EmailVerificationStatusVerified
+		if err := tx.UpdateEmailVerification(verification); err != nil {
+			slog.ErrorContext(ctx, "Error updating verification status to verified", "verification_id", verification.ID, "error", err)
+			return fmt.Errorf("database error updating verification status: %w", err)
+		}
 
-	// 2. Update Referrer's Corporate Email
-	// GetReferrerByUserId doesn't return an error, it returns nil if not found
-	referrer := s.dbDriver.GetReferrerByUserId(verification.UserID)
-	if referrer == nil {
-		log.Printf("Referrer not found for user ID %d during email verification %s", verification.UserID, verificationCode)
-		// This shouldn't happen if the request flow is correct, but handle defensively.
-		return ErrReferrerNotFound
-	}
+		// 2. Update Referrer's Corporate Email
+		// GetReferrerByUserId doesn't return an error, it returns nil if not found
+		referrer = tx.GetReferrerByUserId(verification.UserID)
+		if referrer == nil {
+			slog.WarnContext(ctx, "Referrer not found during email verification", "verification_id", verification.ID)
+			// This shouldn't happen if the request flow is correct, but handle defensively.
+			return ErrReferrerNotFound
+		}
 
-	referrer.CorporateEmail = verification.Email
-	// UpdateReferrer requires UserID and returns (*Referrer, error)
-	_, err = s.dbDriver.UpdateReferrer(verification.UserID, referrer)
+		referrer.CorporateEmail = verification.Email
+		// UpdateReferrer requires UserID and returns (*Referrer, error)
+		if _, err := tx.UpdateReferrer(verification.UserID, referrer); err != nil {
+			slog.ErrorContext(ctx, "Error updating referrer corporate email after verification", "verification_id", verification.ID, "error", err)
+			return fmt.Errorf("database error updating referrer email: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		log.Printf("Error updating referrer corporate email for user ID %d after verification %s: %v", verification.UserID, verificationCode, err)
-		// Critical: Log this, but the verification itself succeeded.
-		// Consider how to handle this - maybe retry? For now, return success but log prominently.
-		// Or potentially revert the verification status update? Less ideal.
-		return fmt.Errorf("database error updating referrer email: %w", err) // Or return nil and just log?
+		return err
 	}
 
-	log.Printf("Successfully verified email %s for user %d (referrer %d) using code %s", verification.Email, verification.UserID, referrer.ReferrerId, verificationCode)
+	slog.InfoContext(ctx, "Verified email", "email", verification.Email, "referrer_id", referrer.ReferrerId, "verification_id", verification.ID)
 	return nil
 }
@@ -1,12 +1,14 @@
 package service_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
 	"github.com/Suhaibinator/muslim-referrals-backend/database"
 	"github.com/Suhaibinator/muslim-referrals-backend/service"
 
@@ -14,7 +16,6 @@ import (
 	"github.com/resend/resend-go/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"golang.org/x/oauth2"
 	"gorm.io/gorm"
 )
 
@@ -25,11 +26,27 @@ import (
 // For now, we'll just embed mock.Mock.
 type MockDatabaseDriver struct {
 	mock.Mock
+
+	committed, rolledBack int      // Outcomes of RunInTransaction calls
+	lockedCandidates      []uint64 // LockCandidate calls
+	lockedReferrers       []uint64 // LockReferrer calls
 }
 
 // Ensure MockDatabaseDriver implements the necessary methods (adjust interface name if needed)
 var _ service.DatabaseOperations = (*MockDatabaseDriver)(nil) // Check interface implementation
 
+// RunInTransaction runs fn against the mock itself and records whether the unit of work
+// would have been committed or rolled back.
+func (m *MockDatabaseDriver) RunInTransaction(fn func(tx service.DatabaseOperations) error) error {
+	err := fn(m)
+	if err != nil {
+		m.rolledBack++
+	} else {
+		m.committed++
+	}
+	return err
+}
+
 func (m *MockDatabaseDriver) GetActiveVerificationByEmail(email string) (*database.EmailVerification, error) {
 	args := m.Called(email)
 	if args.Get(0) == nil {
@@ -108,6 +125,14 @@ func (m *MockDatabaseDriver) GetUserByEmail(email string) *database.User {
 	return args.Get(0).(*database.User)
 }
 
+func (m *MockDatabaseDriver) GetDeletedUserByEmail(email string) *database.User {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*database.User)
+}
+
 func (m *MockDatabaseDriver) CreateUser(user *database.User) (*database.User, error) {
 	args := m.Called(user)
 	if args.Get(0) == nil {
@@ -157,42 +182,34 @@ func (m *MockResendEmailsAPI) Send(params *resend.SendEmailRequest) (*resend.Sen
 // Allows optionally passing a specific EmailSender (e.g., nil for testing disabled state).
 // If emailSenderOverride is nil, the service instance will receive nil for its emailSender field.
 func setupServiceWithMocks(emailSenderOverride service.EmailSender) (*service.Service, *MockDatabaseDriver, *MockResendEmailsAPI) {
-	mockDB := new(MockDatabaseDriver)
-	// Always create the mockResendEmails instance so the caller can potentially set expectations on it,
-	// even if the service itself receives nil.
-	mockResendEmails := new(MockResendEmailsAPI)
+	return setupServiceWithConfig(newTestConfig(), emailSenderOverride)
+}
 
-	// Create a dummy OAuth config (replace with actual config if needed for other tests)
-	dummyOauthConfig := &oauth2.Config{
+// newTestConfig returns the default configuration with dummy OAuth credentials.
+func newTestConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Server.BaseURL = "http://localhost:8080"
+	cfg.OAuth = config.OAuthConfig{
 		ClientID:     "dummy-client-id",
 		ClientSecret: "dummy-client-secret",
 		RedirectURL:  "http://localhost/callback",
-		Scopes:       []string{"openid", "email", "profile"},
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://accounts.google.com/o/oauth2/auth",
-			TokenURL: "https://oauth2.googleapis.com/token",
-		},
 	}
+	return cfg
+}
 
-	// Instantiate the real service using the constructor.
-	// NewService takes *oauth2.Config and *database.DbDriver.
-	// It initializes its own internal resend client.
-	// We need to pass mockDB, assuming it satisfies the interface expected by *database.DbDriver methods used by the service.
-	// If *database.DbDriver is a struct, mockDB needs to implement an interface that DbDriver implicitly satisfies,
-	// or we need a more complex setup.
-	// Instantiate the service using the refactored constructor, injecting mocks.
+// setupServiceWithConfig is like setupServiceWithMocks but lets the caller adjust settings
+// such as limits before the service is created.
+func setupServiceWithConfig(cfg *config.Config, emailSenderOverride service.EmailSender) (*service.Service, *MockDatabaseDriver, *MockResendEmailsAPI) {
+	mockDB := new(MockDatabaseDriver)
+	// Always create the mockResendEmails instance so the caller can potentially set expectations on it,
+	// even if the service itself receives nil.
+	mockResendEmails := new(MockResendEmailsAPI)
+
+	// Instantiate the service using the constructor, injecting mocks.
 	// MockDatabaseDriver implicitly satisfies the DatabaseOperations interface (as long as methods match).
 	// MockResendEmailsAPI implicitly satisfies the EmailSender interface.
 	// Pass the override directly. If it's nil, the service gets nil.
-	s := service.NewService(dummyOauthConfig, mockDB, emailSenderOverride)
-
-	// Now the service `s` has the mocks injected correctly (or nil sender).
-	// Return the service, the db mock, and the *original* email mock instance
-	// so the caller can set expectations on it if needed.
-	// Ensure RESEND_API_KEY is set for tests that might still check the env var directly
-	// (though the refactored service shouldn't rely on it internally anymore for the client instance).
-	// in tests where sending *should* be attempted.
-	os.Setenv("RESEND_API_KEY", "test-key-for-testing")
+	s := service.NewService(cfg, mockDB, emailSenderOverride)
 
 	return s, mockDB, mockResendEmails
 }
@@ -228,7 +245,7 @@ func TestRequestEmailVerification_Success(t *testing.T) {
 	})).Return(nil).Once()
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	assert.NoError(t, err)
@@ -248,7 +265,7 @@ func TestRequestEmailVerification_ErrActiveVerificationExists(t *testing.T) {
 	// No other mocks should be called
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	assert.Error(t, err)
@@ -272,7 +289,7 @@ func TestRequestEmailVerification_ErrMaxVerificationsReached(t *testing.T) {
 	// No other mocks should be called
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	assert.Error(t, err)
@@ -294,7 +311,7 @@ func TestRequestEmailVerification_CheckPreconditionsDbError_GetActive(t *testing
 	mockDB.On("GetActiveVerificationByEmail", email).Return(nil, dbError).Once()
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	assert.Error(t, err)
@@ -319,7 +336,7 @@ func TestRequestEmailVerification_CheckPreconditionsDbError_CountActive(t *testi
 	mockDB.On("CountActiveVerificationsForUser", userID).Return(int64(0), dbError).Once()
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	assert.Error(t, err)
@@ -345,7 +362,7 @@ func TestRequestEmailVerification_CreateRecordFails(t *testing.T) {
 	// No email send or update should happen
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	assert.Error(t, err)
@@ -390,7 +407,7 @@ func TestRequestEmailVerification_EmailSendingDisabled(t *testing.T) {
 	})).Return(nil).Once()
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	assert.Error(t, err)
@@ -426,7 +443,7 @@ func TestRequestEmailVerification_EmailSendFails(t *testing.T) {
 	})).Return(nil).Once()
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	assert.Error(t, err)
@@ -460,7 +477,7 @@ func TestRequestEmailVerification_UpdateStatusAfterSendFails(t *testing.T) {
 	})).Return(updateError).Once()
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	// The primary operation (sending email) succeeded, so RequestEmailVerification should return nil.
@@ -497,7 +514,7 @@ func TestRequestEmailVerification_UpdateStatusAfterSendFail_WhenSendFailed(t *te
 	})).Return(updateError).Once()
 
 	// Call the function
-	err := s.RequestEmailVerification(userID, email)
+	err := s.RequestEmailVerification(context.Background(), userID, email)
 
 	// Assertions
 	// The primary operation (sending email) failed, so RequestEmailVerification should return ErrEmailSendFailed.
@@ -545,10 +562,11 @@ func TestVerifyEmail_Success(t *testing.T) {
 	})).Return(referrer, nil).Once()
 
 	// Call the function
-	err := s.VerifyEmail(verificationCode)
+	err := s.VerifyEmail(context.Background(), verificationCode)
 
 	// Assertions
 	assert.NoError(t, err)
+	assert.Equal(t, 1, mockDB.committed, "verification and referrer updates should commit together")
 	mockDB.AssertExpectations(t)
 }
 
@@ -561,7 +579,7 @@ func TestVerifyEmail_NotFound(t *testing.T) {
 	mockDB.On("GetEmailVerificationByCode", verificationCode).Return(nil, gorm.ErrRecordNotFound).Once()
 
 	// Call the function
-	err := s.VerifyEmail(verificationCode)
+	err := s.VerifyEmail(context.Background(), verificationCode)
 
 	// Assertions
 	assert.Error(t, err)
@@ -582,7 +600,7 @@ func TestVerifyEmail_DbErrorOnGet(t *testing.T) {
 	mockDB.On("GetEmailVerificationByCode", verificationCode).Return(nil, dbError).Once()
 
 	// Call the function
-	err := s.VerifyEmail(verificationCode)
+	err := s.VerifyEmail(context.Background(), verificationCode)
 
 	// Assertions
 	assert.Error(t, err)
@@ -621,7 +639,7 @@ func TestVerifyEmail_InvalidStatus(t *testing.T) {
 			// No update should happen
 
 			// Call the function
-			err := s.VerifyEmail(verificationCode)
+			err := s.VerifyEmail(context.Background(), verificationCode)
 
 			// Assertions
 			assert.Error(t, err)
@@ -655,7 +673,7 @@ func TestVerifyEmail_Expired(t *testing.T) {
 	})).Return(nil).Once() // Assume update succeeds
 
 	// Call the function
-	err := s.VerifyEmail(verificationCode)
+	err := s.VerifyEmail(context.Background(), verificationCode)
 
 	// Assertions
 	assert.Error(t, err)
@@ -687,7 +705,7 @@ func TestVerifyEmail_Expired_UpdateFails(t *testing.T) {
 	})).Return(updateError).Once()
 
 	// Call the function
-	err := s.VerifyEmail(verificationCode)
+	err := s.VerifyEmail(context.Background(), verificationCode)
 
 	// Assertions
 	assert.Error(t, err)
@@ -723,12 +741,13 @@ func TestVerifyEmail_UpdateVerificationStatusFails(t *testing.T) {
 	// No referrer lookups/updates should happen if the first update fails
 
 	// Call the function
-	err := s.VerifyEmail(verificationCode)
+	err := s.VerifyEmail(context.Background(), verificationCode)
 
 	// Assertions
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "database error updating verification status")
 	assert.ErrorIs(t, err, updateError)
+	assert.Equal(t, 1, mockDB.rolledBack)
 	mockDB.AssertExpectations(t)
 	mockDB.AssertNotCalled(t, "GetReferrerByUserId", mock.Anything)
 	mockDB.AssertNotCalled(t, "UpdateReferrer", mock.Anything, mock.Anything)
@@ -760,11 +779,12 @@ func TestVerifyEmail_ReferrerNotFound(t *testing.T) {
 	// No UpdateReferrer call expected
 
 	// Call the function
-	err := s.VerifyEmail(verificationCode)
+	err := s.VerifyEmail(context.Background(), verificationCode)
 
 	// Assertions
 	assert.Error(t, err)
 	assert.Equal(t, service.ErrReferrerNotFound, err)
+	assert.Equal(t, 1, mockDB.rolledBack, "the verification status update should be rolled back")
 	mockDB.AssertExpectations(t)
 	mockDB.AssertNotCalled(t, "UpdateReferrer", mock.Anything, mock.Anything)
 }
@@ -804,11 +824,13 @@ func TestVerifyEmail_UpdateReferrerFails(t *testing.T) {
 	})).Return(nil, updateError).Once()
 
 	// Call the function
-	err := s.VerifyEmail(verificationCode)
+	err := s.VerifyEmail(context.Background(), verificationCode)
 
 	// Assertions
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "database error updating referrer email")
 	assert.ErrorIs(t, err, updateError)
+	assert.Equal(t, 1, mockDB.rolledBack, "the verification status update should be rolled back")
+	assert.Zero(t, mockDB.committed)
 	mockDB.AssertExpectations(t)
 }
@@ -3,5 +3,12 @@ package service
 import "errors"
 
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrUserDeleted          = errors.New("the account was deleted, an admin can restore it")
+	ErrCompanyNotFound      = errors.New("company not found")
+	ErrCompanyMergeIntoSelf = errors.New("cannot merge a company into itself")
+	ErrCompanyExists        = errors.New("the company already exists")
+	ErrSimilarCompanies     = errors.New("companies with similar names already exist")
+	ErrExportNotFound       = errors.New("export not found")
+	ErrInvalidExportFormat  = errors.New("export format must be json or zip")
 )
@@ -0,0 +1,451 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserDataExport is everything stored about one user, in the shape it is exported in. Records
+// the user deleted are still stored, so they are included with their deletedAt time.
+type UserDataExport struct {
+	ExportedAt         time.Time                   `json:"exportedAt"`
+	User               database.User               `json:"user"`
+	CandidateProfiles  []ExportedCandidate         `json:"candidateProfiles"`
+	ReferrerProfiles   []ExportedReferrer          `json:"referrerProfiles"`
+	ReferralRequests   []ExportedReferralRequest   `json:"referralRequests"` // Made as a candidate
+	ReferralsHandled   []ExportedReferralRequest   `json:"referralsHandled"` // Claimed as a referrer
+	JobPostings        []ExportedJobPosting        `json:"jobPostings"`      // Posted as a referrer
+	ReferralsViewed    []ExportedReferralView      `json:"referralsViewed"`  // Opened as a referrer
+	EmailVerifications []ExportedEmailVerification `json:"emailVerifications"`
+	CompaniesAdded     []ExportedCompany           `json:"companiesAdded"`
+}
+
+type ExportedCandidate struct {
+	Id             uint64 `json:"id"`
+	WorkExperience int    `json:"workExperience"`
+	ResumeUrl      string `json:"resumeUrl"` // Resumes are links the user provided, we don't store the files
+	Visibility     string `json:"visibility"`
+
+	Skills             []string                              `json:"skills,omitempty"`
+	Education          []database.CandidateEducation         `json:"education,omitempty"`
+	Positions          []database.CandidatePosition          `json:"positions,omitempty"`
+	DesiredRoles       []string                              `json:"desiredRoles,omitempty"`
+	WorkAuthorizations []database.CandidateWorkAuthorization `json:"workAuthorizations,omitempty"`
+	Preferences        *database.CandidatePreferences        `json:"preferences,omitempty"`
+
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type ExportedReferrer struct {
+	Id             uint64 `json:"id"`
+	CompanyId      uint64 `json:"companyId"`
+	CompanyName    string `json:"companyName"`
+	CorporateEmail string `json:"corporateEmail"`
+
+	Team             string     `json:"team,omitempty"`
+	Seniority        string     `json:"seniority,omitempty"`
+	JobFamilies      []string   `json:"jobFamilies,omitempty"`
+	Locations        []string   `json:"locations,omitempty"`
+	WeeklyCapacity   int        `json:"weeklyCapacity"`
+	UnavailableUntil *time.Time `json:"unavailableUntil,omitempty"`
+
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// ExportedReferralRequest leaves out the other party: a referrer's export doesn't carry the
+// candidate's details and a candidate's only the ID of the referrer who claimed the request.
+type ExportedReferralRequest struct {
+	Id           uint64                  `json:"id"`
+	CompanyId    uint64                  `json:"companyId"`
+	CompanyName  string                  `json:"companyName"`
+	JobTitle     string                  `json:"jobTitle"`
+	Summary      string                  `json:"summary,omitempty"`
+	JobLinks     []string                `json:"jobLinks,omitempty"`
+	Locations    []string                `json:"locations,omitempty"`
+	ReferralType database.ReferralType   `json:"referralType"`
+	Status       database.ReferralStatus `json:"status"`
+	ReferrerId   *uint64                 `json:"referrerId,omitempty"`
+	CreatedAt    time.Time               `json:"createdAt"`
+	UpdatedAt    time.Time               `json:"updatedAt"`
+	DeletedAt    *time.Time              `json:"deletedAt,omitempty"`
+}
+
+type ExportedJobPosting struct {
+	Id           uint64                `json:"id"`
+	CompanyId    uint64                `json:"companyId"`
+	CompanyName  string                `json:"companyName"`
+	Title        string                `json:"title"`
+	Link         string                `json:"link"`
+	Location     string                `json:"location,omitempty"`
+	ReferralType database.ReferralType `json:"referralType"`
+	Description  string                `json:"description,omitempty"`
+	ExpiresAt    time.Time             `json:"expiresAt"`
+	CreatedAt    time.Time             `json:"createdAt"`
+	UpdatedAt    time.Time             `json:"updatedAt"`
+	DeletedAt    *time.Time            `json:"deletedAt,omitempty"`
+}
+
+type ExportedReferralView struct {
+	ReferralRequestId uint64    `json:"referralRequestId"`
+	ReferrerId        uint64    `json:"referrerId"`
+	FirstViewedAt     time.Time `json:"firstViewedAt"`
+	LastViewedAt      time.Time `json:"lastViewedAt"`
+}
+
+// ExportedEmailVerification leaves out the verification code, which is a credential.
+type ExportedEmailVerification struct {
+	Email     string    `json:"email"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ExportedCompany struct {
+	Id        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Domains   []string  `json:"domains"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ExportUserData collects everything stored about the user.
+func (s *Service) ExportUserData(ctx context.Context, userID uint64) (*UserDataExport, error) {
+	data, err := s.dbDriver.GetPersonalData(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		slog.ErrorContext(ctx, "Error loading personal data", "target_user_id", userID, "error", err)
+		return nil, fmt.Errorf("failed to load personal data: %w", err)
+	}
+
+	export := &UserDataExport{
+		ExportedAt:         time.Now().UTC(),
+		User:               data.User,
+		CandidateProfiles:  make([]ExportedCandidate, 0, len(data.Candidates)),
+		ReferrerProfiles:   make([]ExportedReferrer, 0, len(data.Referrers)),
+		ReferralRequests:   make([]ExportedReferralRequest, 0, len(data.ReferralRequests)),
+		ReferralsHandled:   make([]ExportedReferralRequest, 0, len(data.ReferralsHandled)),
+		JobPostings:        make([]ExportedJobPosting, 0, len(data.JobPostings)),
+		ReferralsViewed:    make([]ExportedReferralView, 0, len(data.ReferralRequestViews)),
+		EmailVerifications: make([]ExportedEmailVerification, 0, len(data.EmailVerifications)),
+		CompaniesAdded:     make([]ExportedCompany, 0, len(data.CompaniesAdded)),
+	}
+	for _, candidate := range data.Candidates {
+		export.CandidateProfiles = append(export.CandidateProfiles, ExportedCandidate{
+			Id:             candidate.CandidateId,
+			WorkExperience: candidate.WorkExperience,
+			ResumeUrl:      candidate.ResumeUrl,
+			Visibility:     string(candidate.Visibility),
+
+			Skills:             candidate.SkillNames(),
+			Education:          candidate.Education,
+			Positions:          candidate.Positions,
+			DesiredRoles:       candidate.DesiredRoleTitles(),
+			WorkAuthorizations: candidate.WorkAuthorizations,
+			Preferences:        candidate.Preferences,
+
+			CreatedAt: candidate.CreatedAt,
+			UpdatedAt: candidate.UpdatedAt,
+			DeletedAt: deletedAt(candidate.DeletedAt),
+		})
+	}
+	for _, referrer := range data.Referrers {
+		exported := ExportedReferrer{
+			Id:               referrer.ReferrerId,
+			CompanyId:        referrer.CompanyId,
+			CompanyName:      referrer.Company.Name,
+			CorporateEmail:   referrer.CorporateEmail,
+			Team:             referrer.Team,
+			Seniority:        string(referrer.Seniority),
+			WeeklyCapacity:   referrer.WeeklyCapacity,
+			UnavailableUntil: referrer.UnavailableUntil,
+			CreatedAt:        referrer.CreatedAt,
+			UpdatedAt:        referrer.UpdatedAt,
+			DeletedAt:        deletedAt(referrer.DeletedAt),
+		}
+		for _, family := range referrer.JobFamilies {
+			exported.JobFamilies = append(exported.JobFamilies, string(family.JobFamily))
+		}
+		for _, location := range referrer.Locations {
+			exported.Locations = append(exported.Locations, location.Location)
+		}
+		export.ReferrerProfiles = append(export.ReferrerProfiles, exported)
+	}
+	for _, request := range data.ReferralRequests {
+		exported := exportReferralRequest(request)
+		exported.Summary = request.Summary
+		exported.ReferrerId = request.ReferrerId
+		for _, link := range request.JobLinks {
+			exported.JobLinks = append(exported.JobLinks, link.JobLink)
+		}
+		for _, location := range request.Locations {
+			exported.Locations = append(exported.Locations, location.Location)
+		}
+		export.ReferralRequests = append(export.ReferralRequests, exported)
+	}
+	for _, request := range data.ReferralsHandled {
+		export.ReferralsHandled = append(export.ReferralsHandled, exportReferralRequest(request))
+	}
+	for _, posting := range data.JobPostings {
+		export.JobPostings = append(export.JobPostings, ExportedJobPosting{
+			Id:           posting.JobPostingId,
+			CompanyId:    posting.CompanyId,
+			CompanyName:  posting.Company.Name,
+			Title:        posting.Title,
+			Link:         posting.Link,
+			Location:     posting.Location,
+			ReferralType: posting.ReferralType,
+			Description:  posting.Description,
+			ExpiresAt:    posting.ExpiresAt,
+			CreatedAt:    posting.CreatedAt,
+			UpdatedAt:    posting.UpdatedAt,
+			DeletedAt:    deletedAt(posting.DeletedAt),
+		})
+	}
+	for _, view := range data.ReferralRequestViews {
+		export.ReferralsViewed = append(export.ReferralsViewed, ExportedReferralView{
+			ReferralRequestId: view.ReferralRequestId,
+			ReferrerId:        view.ReferrerId,
+			FirstViewedAt:     view.FirstViewedAt,
+			LastViewedAt:      view.LastViewedAt,
+		})
+	}
+	for _, verification := range data.EmailVerifications {
+		export.EmailVerifications = append(export.EmailVerifications, ExportedEmailVerification{
+			Email:     verification.Email,
+			Status:    verification.Status.String(),
+			ExpiresAt: verification.ExpiresAt,
+		})
+	}
+	for _, company := range data.CompaniesAdded {
+		domains := make([]string, 0, len(company.Domains))
+		for _, domain := range company.Domains {
+			domains = append(domains, domain.Domain)
+		}
+		export.CompaniesAdded = append(export.CompaniesAdded, ExportedCompany{
+			Id:        company.Id,
+			Name:      company.Name,
+			Domains:   domains,
+			CreatedAt: company.CreatedAt,
+		})
+	}
+
+	slog.InfoContext(ctx, "Exported user data", "target_user_id", userID)
+	return export, nil
+}
+
+// exportReferralRequest converts the fields of a referral request both of its parties can see.
+func exportReferralRequest(request database.ReferralRequest) ExportedReferralRequest {
+	return ExportedReferralRequest{
+		Id:           request.ReferralRequestId,
+		CompanyId:    request.CompanyID,
+		CompanyName:  request.Company.Name,
+		JobTitle:     request.PrimaryJobTitleSeeking,
+		ReferralType: request.ReferralType,
+		Status:       request.Status,
+		CreatedAt:    request.CreatedAt,
+		UpdatedAt:    request.UpdatedAt,
+		DeletedAt:    deletedAt(request.DeletedAt),
+	}
+}
+
+func deletedAt(d gorm.DeletedAt) *time.Time {
+	if !d.Valid {
+		return nil
+	}
+	return &d.Time
+}
+
+// ExportFormat is the file format of a personal data export.
+type ExportFormat string
+
+const (
+	ExportFormatJSON ExportFormat = "json"
+	ExportFormatZIP  ExportFormat = "zip" // The JSON export plus a README describing it
+)
+
+// ParseExportFormat parses a format name, defaulting to JSON when it is empty.
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch format := ExportFormat(name); format {
+	case "":
+		return ExportFormatJSON, nil
+	case ExportFormatJSON, ExportFormatZIP:
+		return format, nil
+	}
+	return "", ErrInvalidExportFormat
+}
+
+// ExportArchive is a finished personal data export, ready to be downloaded.
+type ExportArchive struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+const exportReadme = `This archive holds everything Muslim Referrals stores about you, in personal_data.json:
+
+- user: your account
+- candidateProfiles and referrerProfiles: your profiles, including deleted ones
+- referralRequests: the referral requests you made, with their current status
+- referralsHandled: the referral requests you claimed as a referrer
+- jobPostings: the job openings you posted as a referrer
+- referralsViewed: the referral requests you opened as a referrer, first and last time
+- emailVerifications: the corporate email addresses you asked to verify
+- companiesAdded: the companies you added
+
+Records you deleted are kept for a while so they can be restored and carry a deletedAt time.
+Resumes are stored as the links you provided, not as files. We don't store messages.
+`
+
+// BuildUserDataArchive exports the user's data as a file in the given format.
+func (s *Service) BuildUserDataArchive(ctx context.Context, userID uint64, format ExportFormat) (*ExportArchive, error) {
+	export, err := s.ExportUserData(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode export: %w", err)
+	}
+
+	name := fmt.Sprintf("muslim-referrals-export-%d-%s", userID, export.ExportedAt.Format("20060102"))
+	if format != ExportFormatZIP {
+		return &ExportArchive{Filename: name + ".json", ContentType: "application/json", Data: data}, nil
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{"personal_data.json", data},
+		{"README.txt", []byte(exportReadme)},
+	} {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", file.name, err)
+		}
+		if _, err := w.Write(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return &ExportArchive{Filename: name + ".zip", ContentType: "application/zip", Data: buf.Bytes()}, nil
+}
+
+// exportJob is an export being built in the background. archive and err are set before done
+// is closed.
+type exportJob struct {
+	id        string
+	format    ExportFormat
+	startedAt time.Time
+	done      chan struct{}
+	archive   *ExportArchive
+	err       error
+}
+
+// UserDataExportStatus describes a user's export. Archive is nil while it is still being built.
+type UserDataExportStatus struct {
+	ID        string
+	Format    ExportFormat
+	StartedAt time.Time
+	Archive   *ExportArchive
+}
+
+// status reports on the job, returning its error if it failed.
+func (j *exportJob) status() (*UserDataExportStatus, error) {
+	status := &UserDataExportStatus{ID: j.id, Format: j.format, StartedAt: j.startedAt}
+	select {
+	case <-j.done:
+		if j.err != nil {
+			return nil, j.err
+		}
+		status.Archive = j.archive
+	default:
+	}
+	return status, nil
+}
+
+// RequestUserDataExport starts building the user's export in the background and waits up to
+// Export.SyncWait for it, so small accounts get their archive straight away. Larger ones get
+// a status without an archive and fetch it later with GetUserDataExport. Each user has one
+// export at a time: while one is being built, requesting another returns that one.
+func (s *Service) RequestUserDataExport(ctx context.Context, userID uint64, format ExportFormat) (*UserDataExportStatus, error) {
+	s.exportsMu.Lock()
+	job := s.pendingExport(userID)
+	if job == nil {
+		job = &exportJob{id: uuid.NewString(), format: format, startedAt: time.Now().UTC(), done: make(chan struct{})}
+		s.exports.Set(userID, job, 0) // 0 uses the cache's TTL, Export.Retention
+		s.workers.Add(1)
+		// The export outlives the request, but keeps its values (request ID) for logging
+		go s.buildExport(context.WithoutCancel(ctx), userID, job)
+		slog.InfoContext(ctx, "Started user data export", "export_id", job.id, "format", format)
+	}
+	s.exportsMu.Unlock()
+
+	timer := time.NewTimer(s.config.Export.SyncWait)
+	defer timer.Stop()
+	select {
+	case <-job.done:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return job.status()
+}
+
+// pendingExport returns the user's export if it is still being built.
+func (s *Service) pendingExport(userID uint64) *exportJob {
+	item := s.exports.Get(userID)
+	if item == nil {
+		return nil
+	}
+	select {
+	case <-item.Value().done:
+		return nil
+	default:
+		return item.Value()
+	}
+}
+
+func (s *Service) buildExport(ctx context.Context, userID uint64, job *exportJob) {
+	defer s.workers.Done()
+	defer close(job.done)
+
+	start := time.Now()
+	job.archive, job.err = s.BuildUserDataArchive(ctx, userID, job.format)
+	if job.err != nil {
+		slog.ErrorContext(ctx, "Error building user data export", "export_id", job.id, "error", job.err)
+		// Forget it so that the user can try again
+		s.exports.Delete(userID)
+		return
+	}
+	slog.InfoContext(ctx, "Built user data export", "export_id", job.id, "bytes", len(job.archive.Data), "duration", time.Since(start))
+}
+
+// GetUserDataExport returns the status of the user's export with the given ID, including the
+// archive once it is ready. It returns ErrExportNotFound if the user has no such export,
+// including once it has expired.
+func (s *Service) GetUserDataExport(ctx context.Context, userID uint64, exportID string) (*UserDataExportStatus, error) {
+	item := s.exports.Get(userID)
+	if item == nil || item.Value().id != exportID {
+		return nil, ErrExportNotFound
+	}
+	return item.Value().status()
+}
@@ -0,0 +1,157 @@
+package service_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// --- Mock methods for personal data exports ---
+
+func (m *MockDatabaseDriver) GetPersonalData(userID uint64) (*database.PersonalData, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.PersonalData), args.Error(1)
+}
+
+// testPersonalData is a user who made one referral request, deleted their first candidate
+// profile and opened and handled another candidate's request as a referrer.
+func testPersonalData() *database.PersonalData {
+	referrerID := uint64(7)
+	deletedAt := gorm.DeletedAt{Time: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	return &database.PersonalData{
+		User: database.User{Id: 4, FirstName: "Amina", Email: "amina@example.com"},
+		Candidates: []database.Candidate{
+			{CandidateId: 5, UserId: 4, ResumeUrl: "https://example.com/old.pdf", DeletedAt: deletedAt},
+			{CandidateId: 6, UserId: 4, ResumeUrl: "https://example.com/cv.pdf"},
+		},
+		Referrers: []database.Referrer{{ReferrerId: 8, UserId: 4, CompanyId: 2, Company: database.Company{Name: "Acme"}}},
+		ReferralRequests: []database.ReferralRequest{{
+			ReferralRequestId: 1, CandidateID: 6, CompanyID: 3, Company: database.Company{Name: "Globex"},
+			PrimaryJobTitleSeeking: "Engineer", Summary: "About me", ReferrerId: &referrerID,
+			JobLinks:  []database.ReferralRequestJobLinksAssociation{{JobLink: "https://globex.example/jobs/1"}},
+			Locations: []database.ReferralRequestLocationAssociation{{Location: "London"}},
+		}},
+		ReferralsHandled: []database.ReferralRequest{{
+			ReferralRequestId: 2, CandidateID: 9, CompanyID: 2, Company: database.Company{Name: "Acme"},
+			PrimaryJobTitleSeeking: "Designer", Summary: "Someone else's summary",
+		}},
+		ReferralRequestViews: []database.ReferralRequestView{{
+			ReferralRequestId: 2, ReferrerId: 8,
+			FirstViewedAt: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), LastViewedAt: time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC),
+		}},
+		EmailVerifications: []database.EmailVerification{
+			{ID: "id", Email: "amina@acme.example", VerificationCode: "secret", Status: database.EmailVerificationStatusVerified},
+		},
+	}
+}
+
+// --- Test Cases ---
+
+func TestExportUserData_CollectsEverything(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetPersonalData", uint64(4)).Return(testPersonalData(), nil)
+
+	export, err := svc.ExportUserData(context.Background(), 4)
+
+	require.NoError(t, err)
+	assert.Equal(t, "amina@example.com", export.User.Email)
+	require.Len(t, export.CandidateProfiles, 2)
+	assert.NotNil(t, export.CandidateProfiles[0].DeletedAt, "deleted profiles are still stored, so they are exported")
+	assert.Nil(t, export.CandidateProfiles[1].DeletedAt)
+	assert.Equal(t, "Acme", export.ReferrerProfiles[0].CompanyName)
+
+	require.Len(t, export.ReferralRequests, 1)
+	assert.Equal(t, []string{"https://globex.example/jobs/1"}, export.ReferralRequests[0].JobLinks)
+	assert.Equal(t, []string{"London"}, export.ReferralRequests[0].Locations)
+	assert.Equal(t, uint64(7), *export.ReferralRequests[0].ReferrerId)
+
+	require.Len(t, export.ReferralsHandled, 1)
+	assert.Empty(t, export.ReferralsHandled[0].Summary, "a referrer's export must not carry the candidate's summary")
+
+	require.Len(t, export.ReferralsViewed, 1)
+	assert.Equal(t, uint64(2), export.ReferralsViewed[0].ReferralRequestId)
+	assert.Equal(t, time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC), export.ReferralsViewed[0].LastViewedAt)
+
+	require.Len(t, export.EmailVerifications, 1)
+	assert.Equal(t, "Verified", export.EmailVerifications[0].Status)
+	encoded, err := json.Marshal(export)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "secret", "verification codes must not be exported")
+	assert.NotNil(t, export.CompaniesAdded, "empty lists are exported as [] rather than null")
+}
+
+func TestExportUserData_UnknownUser(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetPersonalData", uint64(4)).Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := svc.ExportUserData(context.Background(), 4)
+
+	assert.ErrorIs(t, err, service.ErrUserNotFound)
+}
+
+func TestBuildUserDataArchive_Zip(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetPersonalData", uint64(4)).Return(testPersonalData(), nil)
+
+	archive, err := svc.BuildUserDataArchive(context.Background(), 4, service.ExportFormatZIP)
+
+	require.NoError(t, err)
+	assert.Equal(t, "application/zip", archive.ContentType)
+	reader, err := zip.NewReader(bytes.NewReader(archive.Data), int64(len(archive.Data)))
+	require.NoError(t, err)
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	assert.ElementsMatch(t, []string{"personal_data.json", "README.txt"}, names)
+}
+
+func TestRequestUserDataExport_ReadyWithinSyncWait(t *testing.T) {
+	svc, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetPersonalData", uint64(4)).Return(testPersonalData(), nil)
+
+	status, err := svc.RequestUserDataExport(context.Background(), 4, service.ExportFormatJSON)
+
+	require.NoError(t, err)
+	require.NotNil(t, status.Archive)
+	assert.Equal(t, "application/json", status.Archive.ContentType)
+}
+
+func TestRequestUserDataExport_SlowExportFinishesInBackground(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Export.SyncWait = 10 * time.Millisecond
+	svc, mockDB, _ := setupServiceWithConfig(cfg, nil)
+	release := make(chan time.Time)
+	mockDB.On("GetPersonalData", uint64(4)).WaitUntil(release).Return(testPersonalData(), nil).Once()
+
+	status, err := svc.RequestUserDataExport(context.Background(), 4, service.ExportFormatZIP)
+	require.NoError(t, err)
+	assert.Nil(t, status.Archive, "the export should still be pending")
+
+	again, err := svc.RequestUserDataExport(context.Background(), 4, service.ExportFormatZIP)
+	require.NoError(t, err)
+	assert.Equal(t, status.ID, again.ID, "a pending export should be reused rather than started again")
+
+	_, err = svc.GetUserDataExport(context.Background(), 5, status.ID)
+	assert.ErrorIs(t, err, service.ErrExportNotFound, "another user's export must not be found")
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		ready, err := svc.GetUserDataExport(context.Background(), 4, status.ID)
+		return err == nil && ready.Archive != nil
+	}, time.Second, 5*time.Millisecond)
+	mockDB.AssertExpectations(t)
+}
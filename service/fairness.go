@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+// OrderReferralRequestsFairly orders a referrer's listing so attention is spread across open
+// requests rather than going to whichever happen to be listed first. Open requests come first,
+// those opened by the fewest referrers before the rest and, among those, the ones that have
+// waited longest. Claimed and closed requests follow, most recently updated first. Open requests
+// older than referral_requests.stale_after have aged out and are left out, unless includeStale is
+// set, in which case they come last.
+func (s *Service) OrderReferralRequestsFairly(ctx context.Context, requests []database.ReferralRequest, includeStale bool) ([]database.ReferralRequest, error) {
+	ids := make([]uint64, 0, len(requests))
+	for _, request := range requests {
+		ids = append(ids, request.ReferralRequestId)
+	}
+	// Nobody has referrer ID 0, so this counts every viewer
+	viewers, err := s.dbDriver.CountReferralRequestViewers(ids, 0)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting referral request viewers", "error", err)
+		return nil, fmt.Errorf("failed to count referral request viewers: %w", err)
+	}
+
+	const (
+		open = iota
+		closed
+		stale
+	)
+	staleAfter := s.config.ReferralRequests.StaleAfter
+	now := time.Now()
+	group := func(request *database.ReferralRequest) int {
+		if request.Status != database.ReferralRequested || request.ReferrerId != nil {
+			return closed
+		}
+		if staleAfter > 0 && now.Sub(request.CreatedAt) > staleAfter {
+			return stale
+		}
+		return open
+	}
+
+	ordered := make([]database.ReferralRequest, 0, len(requests))
+	for _, request := range requests {
+		if includeStale || group(&request) != stale {
+			ordered = append(ordered, request)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := &ordered[i], &ordered[j]
+		if ga, gb := group(a), group(b); ga != gb {
+			return ga < gb
+		}
+		switch group(a) {
+		case open:
+			if va, vb := viewers[a.ReferralRequestId], viewers[b.ReferralRequestId]; va != vb {
+				return va < vb
+			}
+			return a.CreatedAt.Before(b.CreatedAt)
+		case closed:
+			return a.UpdatedAt.After(b.UpdatedAt)
+		default:
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	})
+	return ordered, nil
+}
+
+// CompanyResponseStats is how quickly referrers at a company respond to referral requests.
+// The times are zero when no request has been viewed or claimed.
+type CompanyResponseStats struct {
+	CompanyId              uint64
+	CompanyName            string
+	Requests               int
+	Viewed                 int
+	Claimed                int
+	MedianTimeToFirstView  time.Duration
+	AverageTimeToFirstView time.Duration
+	MedianTimeToClaim      time.Duration
+	AverageTimeToClaim     time.Duration
+}
+
+// GetCompanyResponseStats returns, for each company with referral requests created since the
+// given time, how long those requests took to be first opened by a referrer and to be claimed.
+// Requests not yet opened or claimed only count towards Requests.
+func (s *Service) GetCompanyResponseStats(ctx context.Context, since time.Time) ([]CompanyResponseStats, error) {
+	timings, err := s.dbDriver.GetReferralRequestTimings(since)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading referral request timings", "error", err)
+		return nil, fmt.Errorf("failed to load referral request timings: %w", err)
+	}
+
+	// Timings come grouped by company
+	stats := make([]CompanyResponseStats, 0)
+	var toFirstView, toClaim []time.Duration
+	finish := func() {
+		current := &stats[len(stats)-1]
+		current.Viewed, current.Claimed = len(toFirstView), len(toClaim)
+		current.MedianTimeToFirstView, current.AverageTimeToFirstView = medianAndAverage(toFirstView)
+		current.MedianTimeToClaim, current.AverageTimeToClaim = medianAndAverage(toClaim)
+		toFirstView, toClaim = nil, nil
+	}
+	for _, timing := range timings {
+		if len(stats) == 0 || stats[len(stats)-1].CompanyId != timing.CompanyId {
+			if len(stats) > 0 {
+				finish()
+			}
+			stats = append(stats, CompanyResponseStats{CompanyId: timing.CompanyId, CompanyName: timing.CompanyName})
+		}
+		stats[len(stats)-1].Requests++
+		if timing.FirstViewedAt != nil {
+			toFirstView = append(toFirstView, timing.FirstViewedAt.Sub(timing.CreatedAt))
+		}
+		if timing.ClaimedAt != nil {
+			toClaim = append(toClaim, timing.ClaimedAt.Sub(timing.CreatedAt))
+		}
+	}
+	if len(stats) > 0 {
+		finish()
+	}
+	return stats, nil
+}
+
+// medianAndAverage returns the median and mean of the durations, or zeros if there are none.
+func medianAndAverage(durations []time.Duration) (median, average time.Duration) {
+	if len(durations) == 0 {
+		return 0, 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if n := len(sorted); n%2 == 1 {
+		median = sorted[n/2]
+	} else {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return median, total / time.Duration(len(sorted))
+}
@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockDatabaseDriver) GetReferralRequestTimings(since time.Time) ([]database.ReferralRequestTiming, error) {
+	args := m.Called(since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]database.ReferralRequestTiming), args.Error(1)
+}
+
+// --- Test Cases for OrderReferralRequestsFairly ---
+
+func TestOrderReferralRequestsFairly(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	now := time.Now()
+	referrerID := uint64(9)
+	claimed := openRequest(1, "Engineer", database.FullTime, now.Add(-10*24*time.Hour))
+	claimed.Status, claimed.ReferrerId = database.ReferralSubmissionSent, &referrerID
+	requests := []database.ReferralRequest{
+		claimed,
+		openRequest(2, "Engineer", database.FullTime, now.Add(-90*24*time.Hour)), // Stale by default
+		openRequest(3, "Engineer", database.FullTime, now.Add(-5*24*time.Hour)),  // Opened by two referrers
+		openRequest(4, "Engineer", database.FullTime, now.Add(-time.Hour)),
+		openRequest(5, "Engineer", database.FullTime, now.Add(-2*time.Hour)),
+	}
+	mockDB.On("CountReferralRequestViewers", []uint64{1, 2, 3, 4, 5}, uint64(0)).Return(map[uint64]int64{1: 1, 3: 2}, nil)
+
+	ids := func(requests []database.ReferralRequest) []uint64 {
+		result := make([]uint64, 0, len(requests))
+		for _, request := range requests {
+			result = append(result, request.ReferralRequestId)
+		}
+		return result
+	}
+
+	ordered, err := s.OrderReferralRequestsFairly(context.Background(), requests, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{5, 4, 3, 1}, ids(ordered))
+
+	ordered, err = s.OrderReferralRequestsFairly(context.Background(), requests, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{5, 4, 3, 1, 2}, ids(ordered))
+}
+
+func TestOrderReferralRequestsFairly_StaleAfterDisabled(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ReferralRequests.StaleAfter = 0
+	s, mockDB, _ := setupServiceWithConfig(cfg, nil)
+	requests := []database.ReferralRequest{
+		openRequest(1, "Engineer", database.FullTime, time.Now()),
+		openRequest(2, "Engineer", database.FullTime, time.Now().Add(-365*24*time.Hour)),
+	}
+	mockDB.On("CountReferralRequestViewers", mock.Anything, uint64(0)).Return(map[uint64]int64{}, nil)
+
+	ordered, err := s.OrderReferralRequestsFairly(context.Background(), requests, false)
+
+	assert.NoError(t, err)
+	if assert.Len(t, ordered, 2) {
+		assert.Equal(t, uint64(2), ordered[0].ReferralRequestId)
+	}
+}
+
+// --- Test Cases for GetCompanyResponseStats ---
+
+func TestGetCompanyResponseStats(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	since := time.Now().Add(-30 * 24 * time.Hour)
+	created := time.Now().Add(-10 * 24 * time.Hour)
+	at := func(d time.Duration) *time.Time {
+		t := created.Add(d)
+		return &t
+	}
+	mockDB.On("GetReferralRequestTimings", since).Return([]database.ReferralRequestTiming{
+		{ReferralRequestId: 1, CompanyId: 1, CompanyName: "Acme", CreatedAt: created, FirstViewedAt: at(time.Hour), ClaimedAt: at(4 * time.Hour)},
+		{ReferralRequestId: 2, CompanyId: 1, CompanyName: "Acme", CreatedAt: created, FirstViewedAt: at(3 * time.Hour)},
+		{ReferralRequestId: 3, CompanyId: 1, CompanyName: "Acme", CreatedAt: created, FirstViewedAt: at(8 * time.Hour)},
+		{ReferralRequestId: 4, CompanyId: 2, CompanyName: "Globex", CreatedAt: created},
+	}, nil).Once()
+
+	stats, err := s.GetCompanyResponseStats(context.Background(), since)
+
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 2) {
+		acme := stats[0]
+		assert.Equal(t, 3, acme.Requests)
+		assert.Equal(t, 3, acme.Viewed)
+		assert.Equal(t, 1, acme.Claimed)
+		assert.Equal(t, 3*time.Hour, acme.MedianTimeToFirstView)
+		assert.Equal(t, 4*time.Hour, acme.AverageTimeToFirstView)
+		assert.Equal(t, 4*time.Hour, acme.MedianTimeToClaim)
+
+		globex := stats[1]
+		assert.Equal(t, 1, globex.Requests)
+		assert.Equal(t, 0, globex.Viewed)
+		assert.Zero(t, globex.MedianTimeToFirstView)
+	}
+}
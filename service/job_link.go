@@ -0,0 +1,333 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+var (
+	ErrInvalidJobLink      = errors.New("job link must be an http or https URL")
+	ErrJobLinkOtherCompany = errors.New("job link is not for a job at this company")
+)
+
+// JobLink is a job link reduced to its canonical URL, with what it says about the posting on the
+// applicant tracking system hosting it, when that's one we recognize.
+type JobLink struct {
+	URL   string       // Canonical: tracking parameters, fragments and ATS page suffixes removed
+	ATS   database.ATS // Empty for links on other sites without an ATS job ID parameter
+	Board string       // The company's board on the ATS: Greenhouse board token, Lever or Ashby company, Workday tenant
+	JobID string       // The posting's ID on the ATS, if the link is for a single posting
+}
+
+var (
+	greenhouseHosts = map[string]bool{
+		"boards.greenhouse.io":        true,
+		"job-boards.greenhouse.io":    true,
+		"boards.eu.greenhouse.io":     true,
+		"job-boards.eu.greenhouse.io": true,
+	}
+	leverHosts = map[string]bool{
+		"jobs.lever.co":    true,
+		"jobs.eu.lever.co": true,
+	}
+	ashbyHost = "jobs.ashbyhq.com"
+
+	workdayHostPattern = regexp.MustCompile(`^([a-z0-9-]+)\.wd\d+\.myworkdayjobs\.com$`)
+	// Workday puts the posting's ID after the last underscore of the last path segment, e.g.
+	// Senior-Engineer_JR-1234 or Senior-Engineer_R123456-1
+	workdayJobIDPattern    = regexp.MustCompile(`_([A-Za-z0-9-]+)$`)
+	workdayLocalePattern   = regexp.MustCompile(`^[a-zA-Z]{2}-[a-zA-Z]{2}$`)
+	greenhouseJobIDPattern = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// ParseJobLink canonicalizes a job link and extracts the ATS job ID from Greenhouse, Lever,
+// Workday and Ashby links. Links on other sites keep their path and the query parameters that
+// aren't tracking parameters; a gh_jid or ashby_jid parameter on a company's careers page still
+// identifies the posting.
+func ParseJobLink(rawLink string) (*JobLink, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawLink))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobLink, err)
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	if (scheme != "http" && scheme != "https") || parsed.Hostname() == "" {
+		return nil, ErrInvalidJobLink
+	}
+	host := strings.ToLower(parsed.Host)
+	hostname := strings.ToLower(parsed.Hostname())
+	segments := pathSegments(parsed.Path)
+	query := parsed.Query()
+
+	switch {
+	case greenhouseHosts[hostname]:
+		link := &JobLink{ATS: database.ATSGreenhouse}
+		// The embedded application form names the board and job in its query
+		if len(segments) == 2 && segments[0] == "embed" && segments[1] == "job_app" {
+			link.Board, link.JobID = query.Get("for"), query.Get("token")
+		} else if len(segments) >= 3 && segments[1] == "jobs" {
+			link.Board, link.JobID = segments[0], segments[2]
+		} else if len(segments) > 0 {
+			link.Board = segments[0]
+		}
+		if link.Board != "" && greenhouseJobIDPattern.MatchString(link.JobID) {
+			link.URL = "https://" + host + "/" + url.PathEscape(link.Board) + "/jobs/" + link.JobID
+			return link, nil
+		}
+		link.JobID = ""
+		link.URL = genericJobLinkURL(scheme, host, parsed)
+		return link, nil
+
+	case leverHosts[hostname], hostname == ashbyHost:
+		link := &JobLink{ATS: database.ATSLever}
+		if hostname == ashbyHost {
+			link.ATS = database.ATSAshby
+		}
+		if len(segments) > 0 {
+			link.Board = segments[0]
+		}
+		// The posting page may be followed by /apply (Lever) or /application (Ashby)
+		if len(segments) >= 2 {
+			link.JobID = strings.ToLower(segments[1])
+			link.URL = "https://" + host + "/" + url.PathEscape(link.Board) + "/" + url.PathEscape(link.JobID)
+			return link, nil
+		}
+		link.URL = genericJobLinkURL(scheme, host, parsed)
+		return link, nil
+	}
+
+	if match := workdayHostPattern.FindStringSubmatch(hostname); match != nil {
+		link := &JobLink{ATS: database.ATSWorkday, Board: match[1]}
+		if len(segments) > 0 && workdayLocalePattern.MatchString(segments[0]) {
+			segments = segments[1:]
+		}
+		// {site}/job/{location}/{title}_{id} or {site}/details/{title}_{id}, maybe followed by /apply
+		for i := 1; i < len(segments); i++ {
+			if segments[i] != "job" && segments[i] != "details" {
+				continue
+			}
+			end := len(segments)
+			for j := i + 1; j < len(segments); j++ {
+				if segments[j] == "apply" {
+					end = j
+					break
+				}
+			}
+			if end <= i+1 {
+				break
+			}
+			if id := workdayJobIDPattern.FindStringSubmatch(segments[end-1]); id != nil {
+				link.JobID = id[1]
+				escaped := make([]string, 0, end)
+				for _, segment := range segments[:end] {
+					escaped = append(escaped, url.PathEscape(segment))
+				}
+				link.URL = "https://" + host + "/" + strings.Join(escaped, "/")
+				return link, nil
+			}
+			break
+		}
+		link.URL = genericJobLinkURL(scheme, host, parsed)
+		return link, nil
+	}
+
+	link := &JobLink{URL: genericJobLinkURL(scheme, host, parsed)}
+	if id := query.Get("gh_jid"); greenhouseJobIDPattern.MatchString(id) {
+		link.ATS, link.JobID = database.ATSGreenhouse, id
+	} else if id := query.Get("ashby_jid"); id != "" {
+		link.ATS, link.JobID = database.ATSAshby, strings.ToLower(id)
+	}
+	return link, nil
+}
+
+// pathSegments splits a URL path into its non-empty segments.
+func pathSegments(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// trackingQueryParams are stripped from job links, since they only identify where the candidate
+// found the posting.
+var trackingQueryParams = map[string]bool{
+	"gh_src":       true,
+	"lever-source": true,
+	"lever-origin": true,
+	"source":       true,
+	"src":          true,
+	"ref":          true,
+	"referrer":     true,
+	"trk":          true,
+	"trackingid":   true,
+	"refid":        true,
+	"fbclid":       true,
+	"gclid":        true,
+	"mc_cid":       true,
+	"mc_eid":       true,
+	"_hsenc":       true,
+	"_hsmi":        true,
+}
+
+// genericJobLinkURL rebuilds a link without its fragment, trailing slash and tracking parameters.
+func genericJobLinkURL(scheme, host string, parsed *url.URL) string {
+	query := parsed.Query()
+	for key := range query {
+		lowerKey := strings.ToLower(key)
+		if trackingQueryParams[lowerKey] || strings.HasPrefix(lowerKey, "utm_") {
+			query.Del(key)
+		}
+	}
+	canonical := scheme + "://" + host + strings.TrimRight(parsed.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		canonical += "?" + encoded
+	}
+	return canonical
+}
+
+// key identifies the posting the link points at for duplicate detection. Workday job IDs are
+// only unique within a tenant; the other ATSs' are unique across boards, so a gh_jid link on a
+// careers page matches the Greenhouse board link. Links without a job ID are compared by their
+// canonical URL, over http or https and with or without www.
+func (l *JobLink) key() string {
+	switch {
+	case l.JobID == "":
+		_, address, _ := strings.Cut(l.URL, "://")
+		return strings.TrimPrefix(address, "www.")
+	case l.ATS == database.ATSWorkday:
+		return string(l.ATS) + ":" + strings.ToLower(l.Board) + ":" + strings.ToLower(l.JobID)
+	default:
+		return string(l.ATS) + ":" + strings.ToLower(l.JobID)
+	}
+}
+
+// jobLinkKey is the duplicate detection key of a stored or submitted link, see JobLink.key.
+// Links that don't parse are compared case-insensitively as they are.
+func jobLinkKey(rawLink string) string {
+	link, err := ParseJobLink(rawLink)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(rawLink))
+	}
+	return link.key()
+}
+
+// MatchesCompany reports whether the link can be for a job at the company. A link to an ATS
+// board must be to a board named after the company, one of its aliases or one of its domains,
+// either exactly or followed by a separator or one of boardNameSuffixes; other links must be on
+// one of the company's domains or their subdomains, unless the company has no domains.
+func (l *JobLink) MatchesCompany(company *database.Company) bool {
+	if l.Board != "" {
+		names := []string{squashName(database.CompanyNameKey(company.Name))}
+		for _, alias := range company.Aliases {
+			names = append(names, squashName(database.CompanyNameKey(alias.Alias)))
+		}
+		for _, domain := range company.Domains {
+			label, _, _ := strings.Cut(strings.TrimPrefix(strings.ToLower(domain.Domain), "www."), ".")
+			names = append(names, squashName(label))
+		}
+		for _, name := range names {
+			if len(name) >= 2 && boardNamedAfter(l.Board, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(company.Domains) == 0 {
+		return true
+	}
+	parsed, err := url.Parse(l.URL)
+	if err != nil {
+		return false
+	}
+	hostname := strings.ToLower(parsed.Hostname())
+	for _, domain := range company.Domains {
+		domainName := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain.Domain)), "www.")
+		if domainName != "" && (hostname == domainName || strings.HasSuffix(hostname, "."+domainName)) {
+			return true
+		}
+	}
+	return false
+}
+
+// boardNameSuffixes are the words boards add to a company's name, e.g. acmecareers or acmehq.
+var boardNameSuffixes = []string{"careers", "career", "jobs", "hiring", "recruiting", "talent", "team", "hq", "inc", "corp", "co", "group", "global", "labs", "io", "ai"}
+
+// boardNameSeparators split the company's name from the rest of a board name, as in acme-emea.
+const boardNameSeparators = "-_."
+
+// boardNamedAfter reports whether an ATS board is named after the squashed company name: the
+// board is the name, the name and one of boardNameSuffixes, or the name and a separator. A board
+// that merely starts with the name, like metabase for Meta, is another company's.
+func boardNamedAfter(board, name string) bool {
+	squashed := squashName(board)
+	if squashed == name {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(squashed, name); ok && slices.Contains(boardNameSuffixes, suffix) {
+		return true
+	}
+	// The name may itself be split across words, as in acme-robotics-emea for Acme Robotics
+	board = strings.ToLower(board)
+	for i, r := range board {
+		if strings.ContainsRune(boardNameSeparators, r) && squashName(board[:i]) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// squashName lowercases s and drops everything but letters and digits.
+func squashName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// canonicalJobLink parses the link and, when the company is known, checks that it's for a job
+// there.
+func canonicalJobLink(rawLink string, company *database.Company) (*JobLink, error) {
+	link, err := ParseJobLink(rawLink)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidJobLink, rawLink)
+	}
+	if company != nil && company.Id != 0 && !link.MatchesCompany(company) {
+		return nil, fmt.Errorf("%w (%s): %s", ErrJobLinkOtherCompany, company.Name, link.URL)
+	}
+	return link, nil
+}
+
+// canonicalizeJobLinks replaces each job link with its canonical URL and records its ATS and job
+// ID, dropping links that turn out to be for the same posting.
+func canonicalizeJobLinks(links []database.ReferralRequestJobLinksAssociation, company *database.Company) ([]database.ReferralRequestJobLinksAssociation, error) {
+	canonical := make([]database.ReferralRequestJobLinksAssociation, 0, len(links))
+	seen := make(map[string]bool, len(links))
+	for _, jobLink := range links {
+		link, err := canonicalJobLink(jobLink.JobLink, company)
+		if err != nil {
+			return nil, err
+		}
+		if key := link.key(); !seen[key] {
+			seen[key] = true
+			canonical = append(canonical, database.ReferralRequestJobLinksAssociation{
+				ReferralRequestID: jobLink.ReferralRequestID,
+				JobLink:           link.URL,
+				ATS:               link.ATS,
+				ATSJobId:          link.JobID,
+			})
+		}
+	}
+	return canonical, nil
+}
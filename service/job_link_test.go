@@ -0,0 +1,184 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mock methods for companies ---
+
+func (m *MockDatabaseDriver) GetCompanyById(id uint64) *database.Company {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*database.Company)
+}
+
+// --- Helpers ---
+
+// acmeCompany is company 5, whose jobs are on example.com and on ATS boards named acme.
+func acmeCompany() *database.Company {
+	return &database.Company{Id: 5, Name: "Acme, Inc.", Domains: []database.CompanyDomainAssociation{{CompanyId: 5, Domain: "example.com"}}}
+}
+
+// --- Test Cases for ParseJobLink ---
+
+func TestParseJobLink(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want service.JobLink
+	}{
+		{"https://Boards.Greenhouse.io/acme/jobs/4012345?gh_src=abc&utm_source=linkedin#app",
+			service.JobLink{URL: "https://boards.greenhouse.io/acme/jobs/4012345", ATS: database.ATSGreenhouse, Board: "acme", JobID: "4012345"}},
+		{"https://boards.greenhouse.io/embed/job_app?for=acme&token=4012345",
+			service.JobLink{URL: "https://boards.greenhouse.io/acme/jobs/4012345", ATS: database.ATSGreenhouse, Board: "acme", JobID: "4012345"}},
+		{"https://careers.example.com/openings/?gh_jid=4012345&source=LinkedIn",
+			service.JobLink{URL: "https://careers.example.com/openings?gh_jid=4012345", ATS: database.ATSGreenhouse, JobID: "4012345"}},
+		{"https://jobs.lever.co/acme/7F1D3C2A-1B2C-4D5E-8F90-ABCDEF012345/apply?lever-source=LinkedIn",
+			service.JobLink{URL: "https://jobs.lever.co/acme/7f1d3c2a-1b2c-4d5e-8f90-abcdef012345", ATS: database.ATSLever, Board: "acme", JobID: "7f1d3c2a-1b2c-4d5e-8f90-abcdef012345"}},
+		{"https://jobs.ashbyhq.com/acme/0c1e7f36-9a4d-4a8e-bf7e-2d5b6c7d8e9f/application",
+			service.JobLink{URL: "https://jobs.ashbyhq.com/acme/0c1e7f36-9a4d-4a8e-bf7e-2d5b6c7d8e9f", ATS: database.ATSAshby, Board: "acme", JobID: "0c1e7f36-9a4d-4a8e-bf7e-2d5b6c7d8e9f"}},
+		{"https://acme.wd5.myworkdayjobs.com/en-US/External/job/Toronto-ON/Senior-Engineer_JR-1234/apply?source=LinkedIn",
+			service.JobLink{URL: "https://acme.wd5.myworkdayjobs.com/External/job/Toronto-ON/Senior-Engineer_JR-1234", ATS: database.ATSWorkday, Board: "acme", JobID: "JR-1234"}},
+		{"https://acme.wd1.myworkdayjobs.com/External",
+			service.JobLink{URL: "https://acme.wd1.myworkdayjobs.com/External", ATS: database.ATSWorkday, Board: "acme"}},
+		{"HTTPS://www.Example.com/careers/123/?ref=abc&team=payments",
+			service.JobLink{URL: "https://www.example.com/careers/123?team=payments"}},
+	} {
+		link, err := service.ParseJobLink(tc.raw)
+		require.NoError(t, err, tc.raw)
+		assert.Equal(t, tc.want, *link, tc.raw)
+	}
+
+	for _, raw := range []string{"", "not a link", "ftp://example.com/job", "javascript:alert(1)", "https:///jobs/1"} {
+		_, err := service.ParseJobLink(raw)
+		assert.ErrorIs(t, err, service.ErrInvalidJobLink, raw)
+	}
+}
+
+func TestJobLink_MatchesCompany(t *testing.T) {
+	company := acmeCompany()
+	for raw, want := range map[string]bool{
+		"https://boards.greenhouse.io/acme/jobs/1":                         true,
+		"https://jobs.lever.co/acmecareers/abc":                            true, // Boards may add a suffix to the name
+		"https://example.wd5.myworkdayjobs.com/External/job/X/Engineer_R1": true, // Named after the domain
+		"https://jobs.lever.co/globex/abc":                                 false,
+		"https://careers.example.com/jobs/1":                               true,
+		"https://example.com/jobs/1":                                       true,
+		"https://notexample.com/jobs/1":                                    false,
+		"https://careers.globex.com/jobs/1?gh_jid=1":                       false,
+		"https://jobs.ashbyhq.com/acme-emea/abc":                           true, // Or a separator and anything
+		"https://boards.greenhouse.io/acmehq/jobs/1":                       true,
+		"https://boards.greenhouse.io/acmebase/jobs/1":                     false, // Merely starts with the name
+		"https://jobs.lever.co/acmeinsurance/abc":                          false,
+		"https://jobs.lever.co/examplemart/abc":                            false, // Nor with the domain's name
+	} {
+		link, err := service.ParseJobLink(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, link.MatchesCompany(company), raw)
+	}
+
+	// Without domains, only ATS boards can be checked
+	link, err := service.ParseJobLink("https://careers.globex.com/jobs/1")
+	require.NoError(t, err)
+	assert.True(t, link.MatchesCompany(&database.Company{Id: 6, Name: "Acme"}))
+
+	// Boards named after an alias, such as a company merged into this one, match too
+	link, err = service.ParseJobLink("https://jobs.lever.co/globex/abc")
+	require.NoError(t, err)
+	company.Aliases = []database.CompanyAlias{{Alias: "Globex, Inc."}}
+	assert.True(t, link.MatchesCompany(company))
+
+	// Short names don't match every board that starts with them
+	for raw, want := range map[string]bool{
+		"https://boards.greenhouse.io/meta/jobs/1":        true,
+		"https://boards.greenhouse.io/metacareers/jobs/1": true,
+		"https://boards.greenhouse.io/metabase/jobs/1":    false,
+		"https://jobs.lever.co/metaview/abc":              false,
+	} {
+		link, err := service.ParseJobLink(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, link.MatchesCompany(&database.Company{Id: 7, Name: "Meta Platforms, Inc.", Aliases: []database.CompanyAlias{{Alias: "Meta"}}}), raw)
+	}
+}
+
+// --- Test Cases for canonicalization on create ---
+
+func TestCreateReferralRequest_CanonicalizesJobLinks(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{},
+		"https://boards.greenhouse.io/acme/jobs/123?gh_src=linkedin",
+		"https://careers.example.com/jobs?gh_jid=123",
+		"https://acme.wd5.myworkdayjobs.com/en-US/External/job/Remote/Engineer_JR-9/apply")
+
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany()).Once()
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{}).Once()
+	var saved *database.ReferralRequest
+	mockDB.On("CreateReferralRequest", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(*database.ReferralRequest)
+	}).Return(&request, nil).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, []database.ReferralRequestJobLinksAssociation{
+		{JobLink: "https://boards.greenhouse.io/acme/jobs/123", ATS: database.ATSGreenhouse, ATSJobId: "123"},
+		{JobLink: "https://acme.wd5.myworkdayjobs.com/External/job/Remote/Engineer_JR-9", ATS: database.ATSWorkday, ATSJobId: "JR-9"},
+	}, saved.JobLinks, "the careers page link is the same Greenhouse posting")
+}
+
+func TestCreateReferralRequest_JobLinkAtOtherCompany(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	request := newReferralRequest(0, 5, "", time.Time{}, "https://jobs.lever.co/globex/abc")
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany()).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), 1, &request)
+
+	assert.ErrorIs(t, err, service.ErrJobLinkOtherCompany)
+	mockDB.AssertNotCalled(t, "GetReferralRequestsByCandidateId", mock.Anything)
+	mockDB.AssertNotCalled(t, "CreateReferralRequest", mock.Anything)
+}
+
+func TestCreateReferralRequest_DuplicateByATSJobID(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{}, "https://careers.example.com/jobs?gh_jid=123")
+
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany()).Once()
+	// Stored before links were canonicalized
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralRequested, time.Now(), "https://boards.greenhouse.io/embed/job_app?for=acme&token=123"),
+	}).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.ErrorIs(t, err, service.ErrDuplicateReferralRequest)
+	mockDB.AssertNotCalled(t, "CreateReferralRequest", mock.Anything)
+}
+
+func TestCreateReferralRequest_DuplicateCareersPageLink(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{}, "http://www.example.com/careers/42/?utm_source=linkedin&team=payments#apply")
+
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany()).Once()
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralRequested, time.Now(), "https://example.com/careers/42?team=payments"),
+	}).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.ErrorIs(t, err, service.ErrDuplicateReferralRequest)
+	mockDB.AssertNotCalled(t, "CreateReferralRequest", mock.Anything)
+}
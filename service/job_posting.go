@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReferrerNotVerified      = errors.New("verify your corporate email before posting jobs")
+	ErrJobPostingNotFound       = errors.New("job posting not found")
+	ErrJobPostingExpired        = errors.New("job posting has expired")
+	ErrInvalidJobPostingExpiry  = errors.New("job posting expiry must be in the future and within the maximum lifetime")
+	ErrApplyForOwnJobPosting    = errors.New("cannot apply for your own job posting")
+	ErrJobPostingReferrerAbsent = errors.New("the referrer who posted this job is no longer on the site")
+)
+
+// requireVerifiedReferrer returns the user's referrer profile if its corporate email has been
+// verified.
+func (s *Service) requireVerifiedReferrer(ctx context.Context, userID uint64) (*database.Referrer, error) {
+	referrer := s.dbDriver.GetReferrerByUserId(userID)
+	if referrer == nil || referrer.ReferrerId == 0 {
+		return nil, ErrReferrerNotFound
+	}
+	if referrer.CorporateEmail == "" {
+		return nil, ErrReferrerNotVerified
+	}
+	verified, err := s.dbDriver.IsEmailVerified(userID, referrer.CorporateEmail)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error checking corporate email verification", "referrer_id", referrer.ReferrerId, "error", err)
+		return nil, fmt.Errorf("failed to check corporate email verification: %w", err)
+	}
+	if !verified {
+		slog.InfoContext(ctx, "Unverified referrer attempted to post a job", "referrer_id", referrer.ReferrerId)
+		return nil, ErrReferrerNotVerified
+	}
+	return referrer, nil
+}
+
+// setJobPostingExpiry gives a posting without an expiry the default lifetime, and checks that
+// any other expiry is in the future and within the maximum lifetime.
+func (s *Service) setJobPostingExpiry(posting *database.JobPosting, now time.Time) error {
+	if posting.ExpiresAt.IsZero() {
+		posting.ExpiresAt = now.Add(s.config.JobPostings.DefaultLifetime)
+		return nil
+	}
+	if !posting.ExpiresAt.After(now) || posting.ExpiresAt.After(now.Add(s.config.JobPostings.MaxLifetime)) {
+		return ErrInvalidJobPostingExpiry
+	}
+	return nil
+}
+
+// canonicalizeJobPostingLink replaces the posting's link with its canonical form, see
+// job_link.go, and checks that it's for a job at the posting's company.
+func (s *Service) canonicalizeJobPostingLink(ctx context.Context, posting *database.JobPosting, companyID uint64) error {
+	link, err := canonicalJobLink(posting.Link, s.dbDriver.GetCompanyById(companyID))
+	if err != nil {
+		slog.InfoContext(ctx, "Rejected job posting link", "company_id", companyID, "error", err)
+		return err
+	}
+	posting.Link = link.URL
+	return nil
+}
+
+// CreateJobPosting posts a job opening at the referrer's company. Only referrers who have
+// verified their corporate email can post.
+func (s *Service) CreateJobPosting(ctx context.Context, userID uint64, posting *database.JobPosting) (*database.JobPosting, error) {
+	referrer, err := s.requireVerifiedReferrer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setJobPostingExpiry(posting, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.canonicalizeJobPostingLink(ctx, posting, referrer.CompanyId); err != nil {
+		return nil, err
+	}
+	posting.JobPostingId = 0
+	posting.ReferrerId = referrer.ReferrerId
+	posting.CompanyId = referrer.CompanyId
+
+	created, err := s.dbDriver.CreateJobPosting(posting)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating job posting", "referrer_id", referrer.ReferrerId, "error", err)
+		return nil, fmt.Errorf("failed to create job posting: %w", err)
+	}
+	slog.InfoContext(ctx, "Referrer posted a job", "job_posting_id", created.JobPostingId, "referrer_id", referrer.ReferrerId)
+	return created, nil
+}
+
+// UpdateJobPosting updates one of the referrer's job postings. The posting stays at the company
+// it was posted at.
+func (s *Service) UpdateJobPosting(ctx context.Context, userID uint64, posting *database.JobPosting) (*database.JobPosting, error) {
+	referrer, err := s.requireVerifiedReferrer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setJobPostingExpiry(posting, time.Now()); err != nil {
+		return nil, err
+	}
+	existing := s.dbDriver.GetJobPostingById(posting.JobPostingId)
+	if existing == nil || existing.ReferrerId != referrer.ReferrerId {
+		return nil, ErrJobPostingNotFound
+	}
+	if err := s.canonicalizeJobPostingLink(ctx, posting, existing.CompanyId); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.dbDriver.UpdateJobPosting(referrer.ReferrerId, posting)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobPostingNotFound
+		}
+		slog.ErrorContext(ctx, "Error updating job posting", "job_posting_id", posting.JobPostingId, "referrer_id", referrer.ReferrerId, "error", err)
+		return nil, fmt.Errorf("failed to update job posting: %w", err)
+	}
+	return updated, nil
+}
+
+// ApplyForJobPosting creates a referral request for the posted job, already assigned to the
+// referrer who posted it. The application carries the candidate's summary and visibility; the
+// rest comes from the posting. It's subject to the same limits as any other referral request.
+func (s *Service) ApplyForJobPosting(ctx context.Context, userID, candidateID, jobPostingID uint64, application *database.ReferralRequest) (*database.ReferralRequest, error) {
+	posting := s.dbDriver.GetJobPostingById(jobPostingID)
+	if posting == nil {
+		return nil, ErrJobPostingNotFound
+	}
+	if !posting.IsOpen(time.Now()) {
+		return nil, ErrJobPostingExpired
+	}
+	// The referrer isn't loaded once they've deleted their profile
+	if posting.Referrer.ReferrerId == 0 {
+		return nil, ErrJobPostingReferrerAbsent
+	}
+	if posting.Referrer.UserId == userID {
+		return nil, ErrApplyForOwnJobPosting
+	}
+
+	referrerID := posting.ReferrerId
+	request := &database.ReferralRequest{
+		CompanyID:              posting.CompanyId,
+		PrimaryJobTitleSeeking: posting.Title,
+		JobLinks:               []database.ReferralRequestJobLinksAssociation{{JobLink: posting.Link}},
+		Summary:                application.Summary,
+		ReferralType:           posting.ReferralType,
+		ReferrerId:             &referrerID,
+		Visibility:             application.Visibility,
+		JobPostingId:           &posting.JobPostingId,
+	}
+	if posting.Location != "" {
+		request.Locations = []database.ReferralRequestLocationAssociation{{Location: posting.Location}}
+	}
+
+	created, err := s.CreateReferralRequest(ctx, candidateID, request)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Candidate applied for job posting", "job_posting_id", jobPostingID, "referral_request_id", created.ReferralRequestId)
+
+	// Reload it with its company and referrer for the response
+	if loaded := s.dbDriver.GetReferralRequestById(created.ReferralRequestId); loaded != nil {
+		return loaded, nil
+	}
+	return created, nil
+}
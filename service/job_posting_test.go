@@ -0,0 +1,255 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// --- Mock methods for job postings ---
+
+func (m *MockDatabaseDriver) IsEmailVerified(userID uint64, email string) (bool, error) {
+	args := m.Called(userID, email)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) CreateJobPosting(posting *database.JobPosting) (*database.JobPosting, error) {
+	args := m.Called(posting)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.JobPosting), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) UpdateJobPosting(referrerID uint64, posting *database.JobPosting) (*database.JobPosting, error) {
+	args := m.Called(referrerID, posting)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.JobPosting), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) GetJobPostingById(id uint64) *database.JobPosting {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*database.JobPosting)
+}
+
+// --- Helpers ---
+
+func postingReferrer(userID uint64) *database.Referrer {
+	return &database.Referrer{ReferrerId: 9, UserId: userID, CompanyId: 5, CorporateEmail: "ref@example.com"}
+}
+
+func openPosting(id uint64, referrerUserID uint64) *database.JobPosting {
+	return &database.JobPosting{
+		JobPostingId: id,
+		ReferrerId:   9,
+		Referrer:     database.Referrer{ReferrerId: 9, UserId: referrerUserID, CompanyId: 5},
+		CompanyId:    5,
+		Title:        "Backend Engineer",
+		Link:         "https://jobs.example.com/123",
+		Location:     "Toronto",
+		ReferralType: database.FullTime,
+		ExpiresAt:    time.Now().Add(24 * time.Hour),
+	}
+}
+
+// --- Test Cases for CreateJobPosting ---
+
+func TestCreateJobPosting_DefaultsExpiryAndCompany(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetReferrerByUserId", uint64(1)).Return(postingReferrer(1))
+	mockDB.On("IsEmailVerified", uint64(1), "ref@example.com").Return(true, nil)
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	var saved *database.JobPosting
+	mockDB.On("CreateJobPosting", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(*database.JobPosting)
+	}).Return(&database.JobPosting{JobPostingId: 3}, nil)
+
+	before := time.Now()
+	created, err := s.CreateJobPosting(context.Background(), 1, &database.JobPosting{JobPostingId: 42, CompanyId: 77, Title: "Backend Engineer",
+		Link: "https://boards.greenhouse.io/acme/jobs/123?gh_src=linkedin"})
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), created.JobPostingId)
+	require.NotNil(t, saved)
+	assert.Zero(t, saved.JobPostingId, "the client can't choose the ID")
+	assert.Equal(t, uint64(9), saved.ReferrerId)
+	assert.Equal(t, uint64(5), saved.CompanyId, "postings are at the referrer's company")
+	assert.WithinDuration(t, before.Add(30*24*time.Hour), saved.ExpiresAt, time.Minute)
+	assert.Equal(t, "https://boards.greenhouse.io/acme/jobs/123", saved.Link)
+}
+
+func TestCreateJobPosting_LinkAtOtherCompany(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetReferrerByUserId", uint64(1)).Return(postingReferrer(1))
+	mockDB.On("IsEmailVerified", uint64(1), "ref@example.com").Return(true, nil)
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+
+	_, err := s.CreateJobPosting(context.Background(), 1, &database.JobPosting{Title: "Backend Engineer", Link: "https://jobs.globex.com/1"})
+
+	assert.ErrorIs(t, err, service.ErrJobLinkOtherCompany)
+	mockDB.AssertNotCalled(t, "CreateJobPosting", mock.Anything)
+}
+
+func TestCreateJobPosting_RequiresVerifiedEmail(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetReferrerByUserId", uint64(1)).Return(postingReferrer(1))
+	mockDB.On("IsEmailVerified", uint64(1), "ref@example.com").Return(false, nil)
+
+	_, err := s.CreateJobPosting(context.Background(), 1, &database.JobPosting{Title: "Backend Engineer"})
+
+	assert.ErrorIs(t, err, service.ErrReferrerNotVerified)
+	mockDB.AssertNotCalled(t, "CreateJobPosting", mock.Anything)
+}
+
+func TestCreateJobPosting_NoCorporateEmail(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	referrer := postingReferrer(1)
+	referrer.CorporateEmail = ""
+	mockDB.On("GetReferrerByUserId", uint64(1)).Return(referrer)
+
+	_, err := s.CreateJobPosting(context.Background(), 1, &database.JobPosting{Title: "Backend Engineer"})
+
+	assert.ErrorIs(t, err, service.ErrReferrerNotVerified)
+	mockDB.AssertNotCalled(t, "IsEmailVerified", mock.Anything, mock.Anything)
+}
+
+func TestCreateJobPosting_InvalidExpiry(t *testing.T) {
+	for name, expiresAt := range map[string]time.Time{
+		"past":         time.Now().Add(-time.Hour),
+		"too far away": time.Now().Add(91 * 24 * time.Hour),
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, mockDB, _ := setupServiceWithMocks(nil)
+			mockDB.On("GetReferrerByUserId", uint64(1)).Return(postingReferrer(1))
+			mockDB.On("IsEmailVerified", uint64(1), "ref@example.com").Return(true, nil)
+
+			_, err := s.CreateJobPosting(context.Background(), 1, &database.JobPosting{Title: "Backend Engineer", ExpiresAt: expiresAt})
+
+			assert.ErrorIs(t, err, service.ErrInvalidJobPostingExpiry)
+			mockDB.AssertNotCalled(t, "CreateJobPosting", mock.Anything)
+		})
+	}
+}
+
+// --- Test Cases for UpdateJobPosting ---
+
+func TestUpdateJobPosting_NotOwned(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetReferrerByUserId", uint64(1)).Return(postingReferrer(1))
+	mockDB.On("IsEmailVerified", uint64(1), "ref@example.com").Return(true, nil)
+	othersPosting := openPosting(4, 2)
+	othersPosting.ReferrerId = 10
+	mockDB.On("GetJobPostingById", uint64(4)).Return(othersPosting)
+
+	_, err := s.UpdateJobPosting(context.Background(), 1, &database.JobPosting{JobPostingId: 4, Title: "Backend Engineer"})
+
+	assert.ErrorIs(t, err, service.ErrJobPostingNotFound)
+	mockDB.AssertNotCalled(t, "UpdateJobPosting", mock.Anything, mock.Anything)
+}
+
+func TestUpdateJobPosting_DeletedMeanwhile(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetReferrerByUserId", uint64(1)).Return(postingReferrer(1))
+	mockDB.On("IsEmailVerified", uint64(1), "ref@example.com").Return(true, nil)
+	mockDB.On("GetJobPostingById", uint64(4)).Return(openPosting(4, 1))
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	mockDB.On("UpdateJobPosting", uint64(9), mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := s.UpdateJobPosting(context.Background(), 1, &database.JobPosting{JobPostingId: 4, Title: "Backend Engineer", Link: "https://jobs.example.com/123"})
+
+	assert.ErrorIs(t, err, service.ErrJobPostingNotFound)
+}
+
+// --- Test Cases for ApplyForJobPosting ---
+
+func TestApplyForJobPosting_CreatesAssignedRequest(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetJobPostingById", uint64(4)).Return(openPosting(4, 2))
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	mockDB.On("GetReferralRequestsByCandidateId", uint64(7)).Return([]database.ReferralRequest{})
+	var saved *database.ReferralRequest
+	mockDB.On("CreateReferralRequest", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(*database.ReferralRequest)
+	}).Return(&database.ReferralRequest{ReferralRequestId: 11}, nil)
+	mockDB.On("GetReferralRequestById", uint64(11)).Return(nil)
+
+	visibility := database.VisibilityNameOnly
+	created, err := s.ApplyForJobPosting(context.Background(), 1, 7, 4, &database.ReferralRequest{Summary: "Five years of Go", Visibility: &visibility})
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(11), created.ReferralRequestId)
+	require.NotNil(t, saved)
+	assert.Equal(t, uint64(7), saved.CandidateID)
+	assert.Equal(t, uint64(5), saved.CompanyID)
+	assert.Equal(t, "Backend Engineer", saved.PrimaryJobTitleSeeking)
+	assert.Equal(t, database.FullTime, saved.ReferralType)
+	assert.Equal(t, database.ReferralRequested, saved.Status)
+	assert.Equal(t, "Five years of Go", saved.Summary)
+	assert.Equal(t, &visibility, saved.Visibility)
+	require.NotNil(t, saved.ReferrerId)
+	assert.Equal(t, uint64(9), *saved.ReferrerId, "the request is assigned to the referrer who posted the job")
+	require.NotNil(t, saved.JobPostingId)
+	assert.Equal(t, uint64(4), *saved.JobPostingId)
+	require.Len(t, saved.JobLinks, 1)
+	assert.Equal(t, "https://jobs.example.com/123", saved.JobLinks[0].JobLink)
+	require.Len(t, saved.Locations, 1)
+	assert.Equal(t, "Toronto", saved.Locations[0].Location)
+}
+
+func TestApplyForJobPosting_Duplicate(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	mockDB.On("GetJobPostingById", uint64(4)).Return(openPosting(4, 2))
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	mockDB.On("GetReferralRequestsByCandidateId", uint64(7)).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralRequested, time.Now(), "https://jobs.example.com/123?utm_source=x"),
+	})
+
+	_, err := s.ApplyForJobPosting(context.Background(), 1, 7, 4, &database.ReferralRequest{})
+
+	assert.ErrorIs(t, err, service.ErrDuplicateReferralRequest)
+	mockDB.AssertNotCalled(t, "CreateReferralRequest", mock.Anything)
+}
+
+func TestApplyForJobPosting_Refused(t *testing.T) {
+	expired := openPosting(4, 2)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	referrerGone := openPosting(4, 2)
+	referrerGone.Referrer = database.Referrer{}
+
+	for name, tc := range map[string]struct {
+		posting *database.JobPosting
+		want    error
+	}{
+		"not found":     {nil, service.ErrJobPostingNotFound},
+		"expired":       {expired, service.ErrJobPostingExpired},
+		"referrer gone": {referrerGone, service.ErrJobPostingReferrerAbsent},
+		"own posting":   {openPosting(4, 1), service.ErrApplyForOwnJobPosting},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, mockDB, _ := setupServiceWithMocks(nil)
+			if tc.posting == nil {
+				mockDB.On("GetJobPostingById", uint64(4)).Return(nil)
+			} else {
+				mockDB.On("GetJobPostingById", uint64(4)).Return(tc.posting)
+			}
+
+			_, err := s.ApplyForJobPosting(context.Background(), 1, 7, 4, &database.ReferralRequest{})
+
+			assert.ErrorIs(t, err, tc.want)
+			mockDB.AssertNotCalled(t, "CreateReferralRequest", mock.Anything)
+		})
+	}
+}
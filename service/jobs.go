@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ReapResult counts the records changed by one run of the expiry jobs.
+type ReapResult struct {
+	ExpiredVerifications        int64 `json:"expired_verifications"`
+	UnconfirmedAccountDeletions int64 `json:"unconfirmed_account_deletions"`
+	DeletedAccounts             int64 `json:"deleted_accounts"`
+}
+
+// Reap runs the expiry jobs once. Email verification requests still pending past their expiry
+// time are marked as expired, so their status no longer depends on being checked at verify time.
+// Account deletion requests not confirmed in time are dropped, and accounts whose deletion grace
+// period has ended are erased.
+func (s *Service) Reap(ctx context.Context) (ReapResult, error) {
+	var result ReapResult
+	now := time.Now()
+	expired, err := s.dbDriver.ExpireEmailVerifications(now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error expiring email verifications", "error", err)
+		return result, fmt.Errorf("failed to expire email verifications: %w", err)
+	}
+	result.ExpiredVerifications = expired
+
+	unconfirmed, err := s.dbDriver.DeleteUnconfirmedAccountDeletions(now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error dropping unconfirmed account deletions", "error", err)
+		return result, fmt.Errorf("failed to drop unconfirmed account deletions: %w", err)
+	}
+	result.UnconfirmedAccountDeletions = unconfirmed
+
+	// Failures for single accounts are logged by deleteDueAccounts, the others are still counted
+	result.DeletedAccounts, err = s.deleteDueAccounts(ctx, now)
+	if err != nil {
+		return result, err
+	}
+
+	slog.InfoContext(ctx, "Reaped expired records", "expired_verifications", result.ExpiredVerifications,
+		"unconfirmed_account_deletions", result.UnconfirmedAccountDeletions, "deleted_accounts", result.DeletedAccounts)
+	return result, nil
+}
+
+// runReaper calls Reap every interval until Stop is called.
+func (s *Service) runReaper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			// Failures are logged by Reap and retried on the next tick
+			s.Reap(context.Background())
+		}
+	}
+}
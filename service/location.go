@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+var (
+	ErrUnknownCountry = errors.New("unknown country")
+	ErrUnknownRegion  = errors.New("unknown region")
+	ErrInvalidRemote  = errors.New("remote must be true or false")
+)
+
+// Location is a free-text location resolved against the gazetteer. Any of its fields can be
+// empty: "Remote" has no place at all, "Texas" no city.
+type Location struct {
+	City    string // The gazetteer's name for the city, or the text given for one it doesn't know
+	Region  string // Code of the state, province or other subdivision, e.g. NY or ON
+	Country string // ISO 3166-1 alpha-2 code
+	Remote  bool
+}
+
+// IsZero reports whether nothing could be made of the text.
+func (l Location) IsZero() bool {
+	return l == Location{}
+}
+
+// gazetteer.json lists the countries, their subdivisions and the cities our users are most
+// likely to name, with the abbreviations and other spellings they use for them. Aliases are
+// written in their matching form, see locationKey.
+//
+//go:embed gazetteer.json
+var gazetteerJSON []byte
+
+type gazetteerCountry struct {
+	Code    string   `json:"code"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+type gazetteerRegion struct {
+	Country string   `json:"country"`
+	Code    string   `json:"code"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+type gazetteerCity struct {
+	Name    string   `json:"name"`
+	Region  string   `json:"region"`
+	Country string   `json:"country"`
+	Aliases []string `json:"aliases"`
+}
+
+type gazetteer struct {
+	Countries []gazetteerCountry `json:"countries"`
+	Regions   []gazetteerRegion  `json:"regions"`
+	Cities    []gazetteerCity    `json:"cities"`
+
+	// Indexes from matching keys into the slices above, in gazetteer order
+	countryCodes map[string]int
+	countryNames map[string][]int
+	regionKeys   map[string][]int
+	cityKeys     map[string][]int
+}
+
+// loadGazetteer parses the embedded gazetteer once. It's part of the binary, so a gazetteer that
+// doesn't parse is a programming error.
+var loadGazetteer = sync.OnceValue(func() *gazetteer {
+	g := &gazetteer{}
+	if err := json.Unmarshal(gazetteerJSON, g); err != nil {
+		panic(fmt.Sprintf("service: invalid gazetteer.json: %v", err))
+	}
+	g.countryCodes = make(map[string]int, len(g.Countries))
+	g.countryNames = make(map[string][]int)
+	g.regionKeys = make(map[string][]int)
+	g.cityKeys = make(map[string][]int)
+	addKeys := func(index map[string][]int, i int, names ...string) {
+		for _, name := range names {
+			if key := locationKey(name); key != "" && !containsInt(index[key], i) {
+				index[key] = append(index[key], i)
+			}
+		}
+	}
+	for i, country := range g.Countries {
+		g.countryCodes[strings.ToLower(country.Code)] = i
+		addKeys(g.countryNames, i, append([]string{country.Name}, country.Aliases...)...)
+	}
+	for i, region := range g.Regions {
+		addKeys(g.regionKeys, i, append([]string{region.Code, region.Name}, region.Aliases...)...)
+	}
+	for i, city := range g.Cities {
+		addKeys(g.cityKeys, i, append([]string{city.Name}, city.Aliases...)...)
+	}
+	return g
+})
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// Separators between the parts of a location; a hyphen only with spaces around it, since
+	// it's also part of names
+	locationSeparatorPattern = regexp.MustCompile(`[,/|;()\[\]•·]|\s+[-–—]\s+`)
+	remoteWordPattern        = regexp.MustCompile(`\b(fully remote|remote|anywhere|worldwide|work from home|wfh|distributed)\b`)
+	// Words around a place name that don't change which place it is
+	locationFillerPattern = regexp.MustCompile(`\b(hybrid|on-?site|in-?office|in office|office|greater|metro|metropolitan|area|region|based|only|city of)\b`)
+	locationSpacePattern  = regexp.MustCompile(`\s+`)
+	locationAccents       = strings.NewReplacer("á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+		"í", "i", "î", "i", "ï", "i", "ñ", "n", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ú", "u", "û", "u", "ü", "u")
+)
+
+// locationKey is the form place names are matched in: lowercase, without accents, dots or
+// extra spaces, so "Montréal" matches "montreal" and "D.C." matches "dc".
+func locationKey(s string) string {
+	s = locationAccents.Replace(strings.ToLower(s))
+	s = strings.NewReplacer(".", "", "’", "'").Replace(s)
+	s = locationSpacePattern.ReplaceAllString(s, " ")
+	return strings.Trim(s, " -–—:")
+}
+
+const maxLocationParts = 5
+
+// locationPart is one comma- (or otherwise) separated part of a location.
+type locationPart struct {
+	text string // As given, for cities the gazetteer doesn't know
+	key  string // See locationKey
+	bare string // The key without filler words such as "greater" or "area"
+}
+
+// NormalizeLocation resolves free-text input such as "NYC", "New York, NY" or "remote US" into
+// a Location. Words like remote or anywhere set Remote wherever they appear; the rest is read
+// as city, region and country, most specific first, which is how people write locations. Where
+// that's ambiguous ("London", "Perth, WA") the reading that recognizes the most parts wins, and
+// of equally good ones the gazetteer's order decides. A leading part the gazetteer doesn't know
+// is kept as the city when the rest places it, so "Springfield, IL" still gets its state.
+func NormalizeLocation(text string) Location {
+	var location Location
+	var parts []locationPart
+	for _, raw := range locationSeparatorPattern.Split(text, -1) {
+		key := locationKey(raw)
+		if remoteWordPattern.MatchString(key) {
+			location.Remote = true
+			key = locationKey(remoteWordPattern.ReplaceAllString(key, " "))
+			raw = ""
+		}
+		bare := locationKey(locationFillerPattern.ReplaceAllString(key, " "))
+		if bare == "" {
+			continue
+		}
+		parts = append(parts, locationPart{text: strings.TrimSpace(raw), key: key, bare: bare})
+	}
+	if len(parts) == 0 {
+		return location
+	}
+	// Every reading is tried, so the parts are capped; nobody needs more to say where a job is
+	if len(parts) > maxLocationParts {
+		parts = parts[:maxLocationParts]
+	}
+
+	best, _ := loadGazetteer().resolve(parts, len(parts)-1, Location{Remote: location.Remote}, 0)
+	return best
+}
+
+// resolve reads parts[:i+1] right to left, with loc holding what the parts after them said, and
+// returns the best reading and its score. Recognized cities count for more than regions and
+// countries, so "New York" is the city rather than the state; parts that can't be read at all
+// count against.
+func (g *gazetteer) resolve(parts []locationPart, i int, loc Location, score int) (Location, int) {
+	if i < 0 {
+		return loc, score
+	}
+	part := parts[i]
+	best, bestScore := Location{}, 0
+	found := false
+	try := func(next Location, gain int) {
+		if candidate, candidateScore := g.resolve(parts, i-1, next, score+gain); !found || candidateScore > bestScore {
+			best, bestScore, found = candidate, candidateScore, true
+		}
+	}
+
+	if loc.City == "" && loc.Region == "" {
+		for _, r := range g.lookup(g.regionKeys, part) {
+			region := g.Regions[r]
+			if loc.Country == "" || region.Country == loc.Country {
+				try(Location{Region: region.Code, Country: region.Country, Remote: loc.Remote}, 2)
+			}
+		}
+	}
+	if loc.City == "" && loc.Region == "" && loc.Country == "" {
+		countries := g.lookup(g.countryNames, part)
+		// Two-letter codes clash with states and provinces (CA, DE, IN), so they're only taken
+		// for the country at the end of a longer location, as in "Toronto, ON, CA"
+		if c, ok := g.countryCodes[part.bare]; ok && len(part.bare) == 2 && len(parts) > 1 && i == len(parts)-1 && !containsInt(countries, c) {
+			countries = append(countries, c)
+		}
+		for _, c := range countries {
+			try(Location{Country: g.Countries[c].Code, Remote: loc.Remote}, 2)
+		}
+	}
+	if loc.City == "" {
+		for _, c := range g.lookup(g.cityKeys, part) {
+			city := g.Cities[c]
+			if (loc.Country == "" || city.Country == loc.Country) && (loc.Region == "" || city.Region == loc.Region) {
+				try(Location{City: city.Name, Region: city.Region, Country: city.Country, Remote: loc.Remote}, 3)
+			}
+		}
+	}
+	// A leading part nobody knows is taken as a small city in the place the rest named
+	if i == 0 && loc.City == "" && !loc.Remote && loc.Country != "" && part.text != "" {
+		named := loc
+		named.City = strings.TrimSpace(locationFillerPattern.ReplaceAllString(part.text, " "))
+		if named.City == "" {
+			named.City = part.text
+		}
+		try(named, 1)
+	}
+	try(loc, -1)
+	return best, bestScore
+}
+
+// lookup finds the entries of index named by the part, as written or without filler words.
+func (g *gazetteer) lookup(index map[string][]int, part locationPart) []int {
+	if matches := index[part.key]; len(matches) > 0 {
+		return matches
+	}
+	return index[part.bare]
+}
+
+// normalizeReferralRequestLocations fills in the structured fields of the request's locations
+// and drops ones entered twice.
+func normalizeReferralRequestLocations(request *database.ReferralRequest) {
+	if len(request.Locations) == 0 {
+		return
+	}
+	normalized := make([]database.ReferralRequestLocationAssociation, 0, len(request.Locations))
+	seen := make(map[string]bool, len(request.Locations))
+	for _, location := range request.Locations {
+		text := strings.TrimSpace(location.Location)
+		if text == "" || seen[strings.ToLower(text)] {
+			continue
+		}
+		seen[strings.ToLower(text)] = true
+		resolved := NormalizeLocation(text)
+		normalized = append(normalized, database.ReferralRequestLocationAssociation{
+			ReferralRequestID: location.ReferralRequestID,
+			Location:          text,
+			City:              resolved.City,
+			Region:            resolved.Region,
+			Country:           resolved.Country,
+			Remote:            resolved.Remote,
+		})
+	}
+	request.Locations = normalized
+}
+
+// BackfillLocations fills in the structured fields of the stored referral request locations that
+// aren't placed in a country, such as the ones entered before locations were normalized, with
+// what NormalizeLocation makes of their text now. It returns how many it changed; running it
+// again changes nothing until the gazetteer learns more places.
+func (s *Service) BackfillLocations(ctx context.Context) (int, error) {
+	locations, err := s.dbDriver.GetUnplacedReferralRequestLocations()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing unplaced referral request locations", "error", err)
+		return 0, fmt.Errorf("failed to list referral request locations: %w", err)
+	}
+	changed := make([]database.ReferralRequestLocationAssociation, 0, len(locations))
+	for _, location := range locations {
+		resolved := NormalizeLocation(location.Location)
+		if resolved == (Location{City: location.City, Region: location.Region, Country: location.Country, Remote: location.Remote}) {
+			continue
+		}
+		location.City, location.Region, location.Country, location.Remote = resolved.City, resolved.Region, resolved.Country, resolved.Remote
+		changed = append(changed, location)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	if err := s.dbDriver.UpdateReferralRequestLocations(changed); err != nil {
+		slog.ErrorContext(ctx, "Error backfilling referral request locations", "error", err)
+		return 0, fmt.Errorf("failed to backfill referral request locations: %w", err)
+	}
+	slog.InfoContext(ctx, "Backfilled referral request locations", "count", len(changed))
+	return len(changed), nil
+}
+
+// LocationFilter narrows a listing of referral requests down by where the jobs are. Empty
+// fields don't filter.
+type LocationFilter struct {
+	Country string // ISO 3166-1 alpha-2 code
+	Region  string // Subdivision code within Country
+	Remote  *bool
+}
+
+// IsZero reports whether the filter lets everything through.
+func (f LocationFilter) IsZero() bool {
+	return f.Country == "" && f.Region == "" && f.Remote == nil
+}
+
+// ParseLocationFilter builds a filter from query parameters. Countries can be given by ISO code
+// or by name ("US", "usa", "United States"), regions by code or name. A region without a country
+// is looked up in every country, the first in the gazetteer winning, and sets the country too;
+// give the country as well to pick another ("WA" with "AU" is Western Australia).
+func ParseLocationFilter(country, region, remote string) (LocationFilter, error) {
+	g := loadGazetteer()
+	var filter LocationFilter
+	if country = strings.TrimSpace(country); country != "" {
+		key := locationKey(country)
+		if c, ok := g.countryCodes[key]; ok {
+			filter.Country = g.Countries[c].Code
+		} else if matches := g.countryNames[key]; len(matches) > 0 {
+			filter.Country = g.Countries[matches[0]].Code
+		} else {
+			return LocationFilter{}, fmt.Errorf("%w: %q", ErrUnknownCountry, country)
+		}
+	}
+	if region = strings.TrimSpace(region); region != "" {
+		for _, r := range g.regionKeys[locationKey(region)] {
+			if filter.Country == "" || g.Regions[r].Country == filter.Country {
+				filter.Region, filter.Country = g.Regions[r].Code, g.Regions[r].Country
+				break
+			}
+		}
+		if filter.Region == "" {
+			return LocationFilter{}, fmt.Errorf("%w: %q", ErrUnknownRegion, region)
+		}
+	}
+	if remote = strings.TrimSpace(remote); remote != "" {
+		parsed, err := strconv.ParseBool(remote)
+		if err != nil {
+			return LocationFilter{}, ErrInvalidRemote
+		}
+		filter.Remote = &parsed
+	}
+	return filter, nil
+}
+
+// Matches reports whether the location passes the filter. A remote location that doesn't say
+// which country it's in is taken to be open to all of them; one that does still has to be in the
+// country asked for.
+func (f LocationFilter) Matches(location Location) bool {
+	if f.Remote != nil && location.Remote != *f.Remote {
+		return false
+	}
+	if f.Country != "" && location.Country != f.Country && !(location.Remote && location.Country == "") {
+		return false
+	}
+	if f.Region != "" && location.Region != f.Region {
+		return false
+	}
+	return true
+}
+
+// FilterReferralRequestsByLocation keeps the requests with at least one location passing the
+// filter. Locations stored without a country, such as the ones BackfillLocations hasn't reached
+// yet, are normalized here from their text.
+func FilterReferralRequestsByLocation(requests []database.ReferralRequest, filter LocationFilter) []database.ReferralRequest {
+	if filter.IsZero() {
+		return requests
+	}
+	filtered := make([]database.ReferralRequest, 0, len(requests))
+	for _, request := range requests {
+		for _, stored := range request.Locations {
+			if filter.Matches(storedLocation(stored)) {
+				filtered = append(filtered, request)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// storedLocation is the structured form of a stored location. One that isn't placed in a country
+// is normalized again from its text, in case the stored fields predate the gazetteer's knowing it.
+func storedLocation(stored database.ReferralRequestLocationAssociation) Location {
+	if stored.Country == "" {
+		return NormalizeLocation(stored.Location)
+	}
+	return Location{City: stored.City, Region: stored.Region, Country: stored.Country, Remote: stored.Remote}
+}
@@ -0,0 +1,157 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mock Implementations for Locations ---
+
+func (m *MockDatabaseDriver) GetUnplacedReferralRequestLocations() ([]database.ReferralRequestLocationAssociation, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]database.ReferralRequestLocationAssociation), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) UpdateReferralRequestLocations(locations []database.ReferralRequestLocationAssociation) error {
+	args := m.Called(locations)
+	return args.Error(0)
+}
+
+func TestNormalizeLocation(t *testing.T) {
+	for text, want := range map[string]service.Location{
+		"NYC":                             {City: "New York", Region: "NY", Country: "US"},
+		"New York, NY":                    {City: "New York", Region: "NY", Country: "US"},
+		"new york city, new york, usa":    {City: "New York", Region: "NY", Country: "US"},
+		"NY":                              {Region: "NY", Country: "US"},
+		"remote US":                       {Country: "US", Remote: true},
+		"Remote - United States":          {Country: "US", Remote: true},
+		"US (Remote)":                     {Country: "US", Remote: true},
+		"Remote":                          {Remote: true},
+		"Anywhere":                        {Remote: true},
+		"Hybrid - San Francisco Bay Area": {City: "San Francisco", Region: "CA", Country: "US"},
+		"San Francisco, CA":               {City: "San Francisco", Region: "CA", Country: "US"},
+		"Toronto, ON, CA":                 {City: "Toronto", Region: "ON", Country: "CA"},
+		"Greater Toronto Area":            {City: "Toronto", Region: "ON", Country: "CA"},
+		"London":                          {City: "London", Region: "ENG", Country: "GB"},
+		"London, ON":                      {City: "London", Region: "ON", Country: "CA"},
+		"London, UK":                      {City: "London", Region: "ENG", Country: "GB"},
+		"Perth, WA":                       {City: "Perth", Region: "WA", Country: "AU"},
+		"Seattle, WA":                     {City: "Seattle", Region: "WA", Country: "US"},
+		"Berlin, DE":                      {City: "Berlin", Country: "DE"},
+		"Wilmington, DE":                  {City: "Wilmington", Region: "DE", Country: "US"},
+		"Springfield, IL":                 {City: "Springfield", Region: "IL", Country: "US"},
+		"Montréal, Québec":                {City: "Montreal", Region: "QC", Country: "CA"},
+		"Washington, D.C.":                {City: "Washington", Region: "DC", Country: "US"},
+		"Washington state":                {Region: "WA", Country: "US"},
+		"Georgia":                         {Region: "GA", Country: "US"},
+		"Dubai, UAE":                      {City: "Dubai", Country: "AE"},
+		"Riyadh, KSA":                     {City: "Riyadh", Country: "SA"},
+		"Adelaide, SA":                    {City: "Adelaide", Region: "SA", Country: "AU"},
+		"Bengaluru, India":                {City: "Bangalore", Region: "KA", Country: "IN"},
+		"Singapore":                       {City: "Singapore", Country: "SG"},
+		"Canada":                          {Country: "CA"},
+		"Remote, Canada":                  {Country: "CA", Remote: true},
+		"Atlantis":                        {},
+		"":                                {},
+	} {
+		assert.Equal(t, want, service.NormalizeLocation(text), text)
+	}
+}
+
+func TestCreateReferralRequest_NormalizesLocations(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{})
+	request.Locations = []database.ReferralRequestLocationAssociation{{Location: " NYC "}, {Location: "nyc"}, {Location: "Remote - US"}, {Location: "Atlantis"}}
+
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{}).Once()
+	var saved *database.ReferralRequest
+	mockDB.On("CreateReferralRequest", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(*database.ReferralRequest)
+	}).Return(&request, nil).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, []database.ReferralRequestLocationAssociation{
+		{Location: "NYC", City: "New York", Region: "NY", Country: "US"},
+		{Location: "Remote - US", Country: "US", Remote: true},
+		{Location: "Atlantis"},
+	}, saved.Locations)
+}
+
+func TestParseLocationFilter(t *testing.T) {
+	filter, err := service.ParseLocationFilter("usa", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, service.LocationFilter{Country: "US"}, filter)
+
+	// A region sets its country, and the country picks between regions with the same code
+	filter, err = service.ParseLocationFilter("", "Ontario", "")
+	require.NoError(t, err)
+	assert.Equal(t, service.LocationFilter{Country: "CA", Region: "ON"}, filter)
+	filter, err = service.ParseLocationFilter("AU", "wa", "true")
+	require.NoError(t, err)
+	assert.Equal(t, "WA", filter.Region)
+	assert.Equal(t, "AU", filter.Country)
+	require.NotNil(t, filter.Remote)
+	assert.True(t, *filter.Remote)
+
+	_, err = service.ParseLocationFilter("Atlantis", "", "")
+	assert.ErrorIs(t, err, service.ErrUnknownCountry)
+	_, err = service.ParseLocationFilter("US", "ON", "")
+	assert.ErrorIs(t, err, service.ErrUnknownRegion)
+	_, err = service.ParseLocationFilter("", "", "sometimes")
+	assert.ErrorIs(t, err, service.ErrInvalidRemote)
+}
+
+func TestLocationFilter_Matches(t *testing.T) {
+	remote := true
+	usRemote := service.LocationFilter{Country: "US", Remote: &remote}
+	assert.True(t, usRemote.Matches(service.Location{Country: "US", Remote: true}))
+	assert.True(t, usRemote.Matches(service.Location{Remote: true}), "remote anywhere includes the US")
+	assert.False(t, usRemote.Matches(service.Location{Country: "CA", Remote: true}))
+	assert.False(t, usRemote.Matches(service.Location{City: "New York", Region: "NY", Country: "US"}))
+}
+
+func TestBackfillLocations(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+
+	mockDB.On("GetUnplacedReferralRequestLocations").Return([]database.ReferralRequestLocationAssociation{
+		{ReferralRequestID: 1, Location: "NYC"},
+		{ReferralRequestID: 1, Location: "Remote (Austin, TX area)", Remote: true},
+		{ReferralRequestID: 2, Location: "Remote", Remote: true},
+		{ReferralRequestID: 2, Location: "Atlantis"},
+	}, nil).Once()
+	mockDB.On("UpdateReferralRequestLocations", []database.ReferralRequestLocationAssociation{
+		{ReferralRequestID: 1, Location: "NYC", City: "New York", Region: "NY", Country: "US"},
+		{ReferralRequestID: 1, Location: "Remote (Austin, TX area)", City: "Austin", Region: "TX", Country: "US", Remote: true},
+	}).Return(nil).Once()
+
+	backfilled, err := s.BackfillLocations(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, backfilled)
+	mockDB.AssertExpectations(t)
+}
+
+func TestFilterReferralRequestsByLocation_RenormalizesUnplacedLocations(t *testing.T) {
+	request := newReferralRequest(1, 5, database.ReferralRequested, time.Now())
+	// Stored as remote somewhere before the gazetteer placed it
+	request.Locations = []database.ReferralRequestLocationAssociation{{ReferralRequestID: 1, Location: "Remote (Austin, TX area)", Remote: true}}
+
+	filter, err := service.ParseLocationFilter("US", "TX", "")
+	require.NoError(t, err)
+	assert.Len(t, service.FilterReferralRequestsByLocation([]database.ReferralRequest{request}, filter), 1)
+}
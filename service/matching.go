@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+)
+
+// Weights of the signals RecommendReferralRequests ranks open referral requests by. A request
+// that matches on everything, has waited two weeks and nobody else has opened scores 12.
+const (
+	jobFamilyMatchWeight    = 3.0
+	teamMatchWeight         = 2.0
+	locationMatchWeight     = 2.0
+	referralTypeMatchWeight = 1.0
+	maxAgeWeight            = 2.0 // Reached once a request has waited ageWeightHorizon
+	ageWeightHorizon        = 14 * 24 * time.Hour
+	unseenWeight            = 2.0 // Divided by one plus the number of other referrers who opened the request
+)
+
+// jobFamilyKeywords are the words in a job title that place it in a job family. A title can be
+// in several, e.g. "Data Engineer".
+var jobFamilyKeywords = []struct {
+	family   database.JobFamily
+	keywords []string
+}{
+	{database.JobFamilyEngineering, []string{"engineer", "engineering", "developer", "software", "programmer", "sre", "devops", "backend", "frontend", "fullstack", "mobile", "ios", "android"}},
+	{database.JobFamilyData, []string{"data", "analyst", "analytics", "scientist", "ml", "ai", "bi"}},
+	{database.JobFamilyProduct, []string{"product", "pm"}},
+	{database.JobFamilyDesign, []string{"design", "designer", "ux", "ui", "researcher"}},
+	{database.JobFamilyMarketing, []string{"marketing", "marketer", "growth", "seo", "content", "brand", "communications"}},
+	{database.JobFamilySales, []string{"sales", "account", "bdr", "sdr", "partnerships"}},
+	{database.JobFamilyOperations, []string{"operations", "ops", "logistics", "supply", "program", "project"}},
+	{database.JobFamilyFinance, []string{"finance", "financial", "accountant", "accounting", "controller", "treasury", "tax"}},
+	{database.JobFamilyPeople, []string{"recruiter", "recruiting", "hr", "people", "talent"}},
+	{database.JobFamilyLegal, []string{"legal", "counsel", "lawyer", "attorney", "paralegal", "compliance"}},
+	{database.JobFamilySupport, []string{"support", "customer", "helpdesk"}},
+}
+
+// teamStopWords aren't enough for a team name and a job title to match on.
+var teamStopWords = map[string]bool{"team": true, "the": true, "and": true, "org": true, "group": true}
+
+// Recommendation is an open referral request ranked for a referrer, with why it ranks where
+// it does.
+type Recommendation struct {
+	Request            database.ReferralRequest
+	Score              float64
+	MatchedJobFamilies []database.JobFamily // Job families of the title the referrer refers for
+	TeamMatch          bool                 // The title names the referrer's team
+	MatchedLocations   []string             // Locations of the request the referrer refers for
+	ReferralTypeMatch  bool                 // The referrer has claimed requests of this type before
+	Age                time.Duration
+	OtherViewers       int64 // Other referrers who opened the request
+	Reasons            []string
+}
+
+// RecommendReferralRequests ranks the open referral requests at the referrer's company by how
+// well they match the referrer's profile, how long they have waited and how few other referrers
+// have opened them, and returns the best limit of them. A referrer in vacation mode is left out
+// of matching and gets none.
+func (s *Service) RecommendReferralRequests(ctx context.Context, userID uint64, limit int) ([]Recommendation, error) {
+	referrer := s.dbDriver.GetReferrerByUserId(userID)
+	if referrer == nil || referrer.ReferrerId == 0 {
+		return nil, ErrReferrerNotFound
+	}
+	now := time.Now()
+	if !referrer.IsAvailable(now) {
+		return []Recommendation{}, nil
+	}
+
+	requests := s.dbDriver.GetOpenReferralRequestsByCompanyId(referrer.CompanyId)
+	ids := make([]uint64, 0, len(requests))
+	for _, request := range requests {
+		ids = append(ids, request.ReferralRequestId)
+	}
+	viewers, err := s.dbDriver.CountReferralRequestViewers(ids, referrer.ReferrerId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting referral request viewers", "referrer_id", referrer.ReferrerId, "error", err)
+		return nil, fmt.Errorf("failed to count referral request viewers: %w", err)
+	}
+	claimedTypes, err := s.dbDriver.CountReferrerClaimsByType(referrer.ReferrerId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting referrer claims", "referrer_id", referrer.ReferrerId, "error", err)
+		return nil, fmt.Errorf("failed to count referrer claims: %w", err)
+	}
+
+	recommendations := make([]Recommendation, 0, len(requests))
+	for _, request := range requests {
+		if request.Candidate.UserId == referrer.UserId {
+			continue // Referrers can't refer themselves
+		}
+		recommendations = append(recommendations, scoreReferralRequest(referrer, request, viewers[request.ReferralRequestId], claimedTypes, now))
+	}
+	// Requests come oldest first, so ties go to the one that has waited longest
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	if limit > 0 && len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations, nil
+}
+
+// scoreReferralRequest scores how good a pick the request is for the referrer.
+func scoreReferralRequest(referrer *database.Referrer, request database.ReferralRequest, otherViewers int64,
+	claimedTypes map[database.ReferralType]int64, now time.Time) Recommendation {
+	rec := Recommendation{Request: request, Reasons: []string{}}
+	titleWords := words(request.PrimaryJobTitleSeeking)
+
+	for _, family := range jobFamiliesOf(titleWords) {
+		for _, declared := range referrer.JobFamilies {
+			if declared.JobFamily == family {
+				rec.MatchedJobFamilies = append(rec.MatchedJobFamilies, family)
+			}
+		}
+	}
+	if len(rec.MatchedJobFamilies) > 0 {
+		rec.Score += jobFamilyMatchWeight
+		rec.Reasons = append(rec.Reasons, fmt.Sprintf("The job title is in a job family you refer for (%s)", joinJobFamilies(rec.MatchedJobFamilies)))
+	}
+
+	for word := range words(referrer.Team) {
+		if !teamStopWords[word] && len(word) > 2 && titleWords[word] {
+			rec.TeamMatch = true
+			break
+		}
+	}
+	if rec.TeamMatch {
+		rec.Score += teamMatchWeight
+		rec.Reasons = append(rec.Reasons, fmt.Sprintf("The job title mentions your team (%s)", referrer.Team))
+	}
+
+	declaredPlaces := make([]Location, len(referrer.Locations))
+	for i, declared := range referrer.Locations {
+		declaredPlaces[i] = NormalizeLocation(declared.Location)
+	}
+	for _, location := range request.Locations {
+		place := storedLocation(location)
+		for i, declared := range referrer.Locations {
+			if locationsOverlap(place, declaredPlaces[i], location.Location, declared.Location) {
+				rec.MatchedLocations = append(rec.MatchedLocations, location.Location)
+				break
+			}
+		}
+	}
+	if len(rec.MatchedLocations) > 0 {
+		rec.Score += locationMatchWeight
+		rec.Reasons = append(rec.Reasons, fmt.Sprintf("It's in a location you refer for (%s)", strings.Join(rec.MatchedLocations, ", ")))
+	}
+
+	if claimedTypes[request.ReferralType] > 0 {
+		rec.ReferralTypeMatch = true
+		rec.Score += referralTypeMatchWeight
+		rec.Reasons = append(rec.Reasons, fmt.Sprintf("You've referred for %s roles before", request.ReferralType))
+	}
+
+	rec.Age = now.Sub(request.CreatedAt)
+	if rec.Age > 0 {
+		rec.Score += maxAgeWeight * min(1, float64(rec.Age)/float64(ageWeightHorizon))
+	}
+	if days := int(rec.Age / (24 * time.Hour)); days >= 7 {
+		rec.Reasons = append(rec.Reasons, fmt.Sprintf("It has been waiting %d days", days))
+	}
+
+	rec.OtherViewers = otherViewers
+	rec.Score += unseenWeight / float64(1+otherViewers)
+	if otherViewers == 0 {
+		rec.Reasons = append(rec.Reasons, "No other referrer has opened it yet")
+	}
+	return rec
+}
+
+// words returns the lower-case words of s.
+func words(s string) map[string]bool {
+	result := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		result[word] = true
+	}
+	return result
+}
+
+// jobFamiliesOf returns the job families a job title with the given words is in.
+func jobFamiliesOf(titleWords map[string]bool) []database.JobFamily {
+	var families []database.JobFamily
+	for _, entry := range jobFamilyKeywords {
+		for _, keyword := range entry.keywords {
+			if titleWords[keyword] {
+				families = append(families, entry.family)
+				break
+			}
+		}
+	}
+	return families
+}
+
+// locationsOverlap reports whether a request's location is in a place the referrer declared,
+// comparing where NormalizeLocation puts them: the same country, and the same region and city
+// where both name one, so "Toronto" and "Toronto, ON" match and so do "US" and "Austin, TX". A
+// declaration of remote work alone only matches remote requests. Places the gazetteer doesn't
+// know can only match the same text.
+func locationsOverlap(request, declared Location, requestText, declaredText string) bool {
+	remoteOnly := declared.Remote && declared.City == "" && declared.Region == ""
+	if request.Country == "" || declared.Country == "" {
+		if remoteOnly && request.Remote && request.City == "" && request.Region == "" {
+			return true
+		}
+		requestText, declaredText = strings.TrimSpace(requestText), strings.TrimSpace(declaredText)
+		return requestText != "" && strings.EqualFold(requestText, declaredText)
+	}
+	if request.Country != declared.Country {
+		return false
+	}
+	if request.Region != "" && declared.Region != "" && request.Region != declared.Region {
+		return false
+	}
+	if request.City != "" && declared.City != "" && request.City != declared.City {
+		return false
+	}
+	return !remoteOnly || request.Remote
+}
+
+func joinJobFamilies(families []database.JobFamily) string {
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		names = append(names, string(family))
+	}
+	return strings.Join(names, ", ")
+}
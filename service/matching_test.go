@@ -0,0 +1,197 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mock Implementations for Matching ---
+
+func (m *MockDatabaseDriver) GetOpenReferralRequestsByCompanyId(companyID uint64) []database.ReferralRequest {
+	args := m.Called(companyID)
+	return args.Get(0).([]database.ReferralRequest)
+}
+
+func (m *MockDatabaseDriver) CountReferralRequestViewers(referralRequestIDs []uint64, excludeReferrerID uint64) (map[uint64]int64, error) {
+	args := m.Called(referralRequestIDs, excludeReferrerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uint64]int64), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) CountReferrerClaimsByType(referrerID uint64) (map[database.ReferralType]int64, error) {
+	args := m.Called(referrerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[database.ReferralType]int64), args.Error(1)
+}
+
+// --- Helpers ---
+
+func matchingReferrer(userID uint64) *database.Referrer {
+	return &database.Referrer{
+		ReferrerId:  9,
+		UserId:      userID,
+		CompanyId:   5,
+		Team:        "Payments Team",
+		JobFamilies: []database.ReferrerJobFamily{{JobFamily: database.JobFamilyEngineering}},
+		Locations:   []database.ReferrerLocation{{Location: "Toronto"}},
+	}
+}
+
+func openRequest(id uint64, title string, referralType database.ReferralType, createdAt time.Time, locations ...string) database.ReferralRequest {
+	request := newReferralRequest(id, 5, database.ReferralRequested, createdAt)
+	request.PrimaryJobTitleSeeking = title
+	request.ReferralType = referralType
+	request.CreatedAt = createdAt
+	request.Candidate.UserId = 100 + id
+	for _, location := range locations {
+		request.Locations = append(request.Locations, database.ReferralRequestLocationAssociation{ReferralRequestID: id, Location: location})
+	}
+	return request
+}
+
+// --- Test Cases for RecommendReferralRequests ---
+
+func TestRecommendReferralRequests_RanksByMatchAgeAndViews(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	now := time.Now()
+	requests := []database.ReferralRequest{
+		openRequest(1, "Accountant", database.Contract, now.Add(-20*24*time.Hour), "Lagos"),
+		openRequest(2, "Senior Payments Engineer", database.FullTime, now.Add(-time.Hour), "Toronto, ON"),
+		openRequest(3, "Software Developer", database.FullTime, now.Add(-time.Hour), "Remote"),
+		openRequest(4, "Software Developer", database.FullTime, now.Add(-time.Hour), "Remote"),
+	}
+
+	mockDB.On("GetReferrerByUserId", userID).Return(matchingReferrer(userID)).Once()
+	mockDB.On("GetOpenReferralRequestsByCompanyId", uint64(5)).Return(requests).Once()
+	mockDB.On("CountReferralRequestViewers", []uint64{1, 2, 3, 4}, uint64(9)).Return(map[uint64]int64{3: 3}, nil).Once()
+	mockDB.On("CountReferrerClaimsByType", uint64(9)).Return(map[database.ReferralType]int64{database.FullTime: 2}, nil).Once()
+
+	recommendations, err := s.RecommendReferralRequests(context.Background(), userID, 0)
+
+	assert.NoError(t, err)
+	ids := make([]uint64, 0, len(recommendations))
+	for _, rec := range recommendations {
+		ids = append(ids, rec.Request.ReferralRequestId)
+	}
+	assert.Equal(t, []uint64{2, 4, 3, 1}, ids)
+
+	best := recommendations[0]
+	assert.Equal(t, []database.JobFamily{database.JobFamilyEngineering}, best.MatchedJobFamilies)
+	assert.True(t, best.TeamMatch)
+	assert.Equal(t, []string{"Toronto, ON"}, best.MatchedLocations)
+	assert.True(t, best.ReferralTypeMatch)
+	assert.NotEmpty(t, best.Reasons)
+	assert.Equal(t, int64(3), recommendations[2].OtherViewers)
+	assert.Empty(t, recommendations[3].MatchedJobFamilies)
+}
+
+func TestRecommendReferralRequests_MatchesLocationsByPlace(t *testing.T) {
+	for _, tc := range []struct {
+		declared, location string
+		want               bool
+	}{
+		{"US", "Austin, TX", true}, // In the country, not because "us" is in "austin"
+		{"US", "Toronto", false},
+		{"US", "Busan", false}, // Unknown to the gazetteer, so only the same text would match
+		{"CA", "Chicago", false},
+		{"CA", "San Francisco, CA", true},
+		{"Toronto", "Toronto, ON", true},
+		{"NYC", "New York, NY", true},
+		{"Remote US", "Austin, TX", false}, // Only refers for remote roles
+		{"Remote US", "Remote (Austin, TX area)", true},
+		{"Remote", "Remote", true},
+		{"Springfield", "springfield", true},
+	} {
+		s, mockDB, _ := setupServiceWithMocks(nil)
+		referrer := matchingReferrer(3)
+		referrer.Locations = []database.ReferrerLocation{{Location: tc.declared}}
+		mockDB.On("GetReferrerByUserId", uint64(3)).Return(referrer).Once()
+		mockDB.On("GetOpenReferralRequestsByCompanyId", uint64(5)).Return([]database.ReferralRequest{
+			openRequest(1, "Accountant", database.Contract, time.Now(), tc.location),
+		}).Once()
+		mockDB.On("CountReferralRequestViewers", []uint64{1}, uint64(9)).Return(map[uint64]int64{}, nil).Once()
+		mockDB.On("CountReferrerClaimsByType", uint64(9)).Return(map[database.ReferralType]int64{}, nil).Once()
+
+		recommendations, err := s.RecommendReferralRequests(context.Background(), 3, 0)
+
+		require.NoError(t, err)
+		require.Len(t, recommendations, 1)
+		assert.Equal(t, tc.want, len(recommendations[0].MatchedLocations) > 0, "%q declared, request in %q", tc.declared, tc.location)
+	}
+}
+
+func TestRecommendReferralRequests_Limit(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	now := time.Now()
+
+	mockDB.On("GetReferrerByUserId", userID).Return(matchingReferrer(userID)).Once()
+	mockDB.On("GetOpenReferralRequestsByCompanyId", uint64(5)).Return([]database.ReferralRequest{
+		openRequest(1, "Designer", database.FullTime, now),
+		openRequest(2, "Backend Engineer", database.FullTime, now),
+	}).Once()
+	mockDB.On("CountReferralRequestViewers", mock.Anything, uint64(9)).Return(map[uint64]int64{}, nil).Once()
+	mockDB.On("CountReferrerClaimsByType", uint64(9)).Return(map[database.ReferralType]int64{}, nil).Once()
+
+	recommendations, err := s.RecommendReferralRequests(context.Background(), userID, 1)
+
+	assert.NoError(t, err)
+	if assert.Len(t, recommendations, 1) {
+		assert.Equal(t, uint64(2), recommendations[0].Request.ReferralRequestId)
+	}
+}
+
+func TestRecommendReferralRequests_SkipsOwnRequests(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	own := openRequest(1, "Backend Engineer", database.FullTime, time.Now())
+	own.Candidate.UserId = userID
+
+	mockDB.On("GetReferrerByUserId", userID).Return(matchingReferrer(userID)).Once()
+	mockDB.On("GetOpenReferralRequestsByCompanyId", uint64(5)).Return([]database.ReferralRequest{own}).Once()
+	mockDB.On("CountReferralRequestViewers", []uint64{1}, uint64(9)).Return(map[uint64]int64{}, nil).Once()
+	mockDB.On("CountReferrerClaimsByType", uint64(9)).Return(map[database.ReferralType]int64{}, nil).Once()
+
+	recommendations, err := s.RecommendReferralRequests(context.Background(), userID, 0)
+
+	assert.NoError(t, err)
+	assert.Empty(t, recommendations)
+}
+
+func TestRecommendReferralRequests_UnavailableReferrer(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	referrer := matchingReferrer(userID)
+	until := time.Now().Add(time.Hour)
+	referrer.UnavailableUntil = &until
+
+	mockDB.On("GetReferrerByUserId", userID).Return(referrer).Once()
+
+	recommendations, err := s.RecommendReferralRequests(context.Background(), userID, 0)
+
+	assert.NoError(t, err)
+	assert.Empty(t, recommendations)
+	mockDB.AssertNotCalled(t, "GetOpenReferralRequestsByCompanyId", mock.Anything)
+}
+
+func TestRecommendReferralRequests_NotAReferrer(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+
+	mockDB.On("GetReferrerByUserId", uint64(3)).Return(&database.Referrer{}).Once()
+
+	_, err := s.RecommendReferralRequests(context.Background(), 3, 0)
+
+	assert.ErrorIs(t, err, service.ErrReferrerNotFound)
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReferralRequestNotFound       = errors.New("referral request not found")
+	ErrDuplicateReferralRequest      = errors.New("an open referral request already exists for this job link")
+	ErrTooManyOpenRequestsForCompany = errors.New("maximum number of open referral requests reached for this company")
+	ErrTooManyOpenRequests           = errors.New("maximum number of open referral requests reached")
+	ErrReferralRequestCooldown       = errors.New("a recent referral request for this company was rejected; please wait before requesting again")
+	ErrReferralRequestOtherCompany   = errors.New("referral request is for a different company")
+	ErrReferralRequestAlreadyClaimed = errors.New("referral request has already been claimed or is closed")
+	ErrReferrerAtCapacity            = errors.New("weekly referral capacity reached; please try again later or raise your capacity")
+)
+
+// checkReferralRequestLimits enforces the duplicate, cooldown and open-request limits
+// for a candidate's new or updated referral request. When updating, the request being
+// updated is excluded from the counts. It locks the candidate first, so it must run in the
+// transaction that writes the request for concurrent writes not to exceed the limits.
+func (s *Service) checkReferralRequestLimits(ctx context.Context, tx DatabaseOperations, candidateID uint64, request *database.ReferralRequest) error {
+	limits := s.config.ReferralRequests
+	if err := tx.LockCandidate(candidateID); err != nil {
+		return fmt.Errorf("failed to lock candidate: %w", err)
+	}
+	existingRequests := tx.GetReferralRequestsByCandidateId(candidateID)
+
+	requestedLinks := make(map[string]bool, len(request.JobLinks))
+	for _, jobLink := range request.JobLinks {
+		requestedLinks[jobLinkKey(jobLink.JobLink)] = true
+	}
+
+	// Updates that keep the request at the same company don't count against the
+	// company limits again; only duplicate job links are checked for them.
+	checkCompanyLimits := true
+
+	openTotal := 0
+	openForCompany := 0
+	var lastRejection time.Time
+	for _, existing := range existingRequests {
+		if request.ReferralRequestId != 0 && existing.ReferralRequestId == request.ReferralRequestId {
+			checkCompanyLimits = existing.CompanyID != request.CompanyID
+			continue
+		}
+
+		if existing.Status == database.ReferralSubmissionRejected && existing.CompanyID == request.CompanyID {
+			if existing.UpdatedAt.After(lastRejection) {
+				lastRejection = existing.UpdatedAt
+			}
+		}
+
+		if !existing.Status.IsOpen() {
+			continue
+		}
+		openTotal++
+		if existing.CompanyID == request.CompanyID {
+			openForCompany++
+		}
+
+		for _, jobLink := range existing.JobLinks {
+			if requestedLinks[jobLinkKey(jobLink.JobLink)] {
+				slog.InfoContext(ctx, "Candidate attempted to create a duplicate referral request",
+					"candidate_id", candidateID, "job_link", jobLink.JobLink, "existing_request_id", existing.ReferralRequestId)
+				return ErrDuplicateReferralRequest
+			}
+		}
+	}
+
+	if !checkCompanyLimits {
+		return nil
+	}
+
+	if limits.MaxOpenPerCompany > 0 && openForCompany >= limits.MaxOpenPerCompany {
+		slog.InfoContext(ctx, "Candidate reached the open referral request limit for company",
+			"candidate_id", candidateID, "company_id", request.CompanyID, "limit", limits.MaxOpenPerCompany)
+		return ErrTooManyOpenRequestsForCompany
+	}
+
+	if limits.MaxOpenPerCandidate > 0 && openTotal >= limits.MaxOpenPerCandidate {
+		slog.InfoContext(ctx, "Candidate reached the global open referral request limit", "candidate_id", candidateID, "limit", limits.MaxOpenPerCandidate)
+		return ErrTooManyOpenRequests
+	}
+
+	if limits.RejectionCooldown > 0 && !lastRejection.IsZero() {
+		cooldownEnds := lastRejection.Add(limits.RejectionCooldown)
+		if time.Now().Before(cooldownEnds) {
+			slog.InfoContext(ctx, "Candidate is in rejection cooldown for company",
+				"candidate_id", candidateID, "company_id", request.CompanyID, "cooldown_ends", cooldownEnds)
+			return fmt.Errorf("%w (until %s)", ErrReferralRequestCooldown, cooldownEnds.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// canonicalizeReferralRequestJobLinks replaces the request's job links with their canonical
+// form, see job_link.go, and checks that they're for jobs at the request's company.
+func (s *Service) canonicalizeReferralRequestJobLinks(ctx context.Context, request *database.ReferralRequest) error {
+	if len(request.JobLinks) == 0 {
+		return nil
+	}
+	links, err := canonicalizeJobLinks(request.JobLinks, s.dbDriver.GetCompanyById(request.CompanyID))
+	if err != nil {
+		slog.InfoContext(ctx, "Rejected referral request job link", "candidate_id", request.CandidateID, "company_id", request.CompanyID, "error", err)
+		return err
+	}
+	request.JobLinks = links
+	return nil
+}
+
+// CreateReferralRequest creates a new referral request for a candidate after canonicalizing its
+// job links, normalizing its locations and enforcing the configured duplicate and rate rules.
+// New requests always start in the Requested status.
+func (s *Service) CreateReferralRequest(ctx context.Context, candidateID uint64, request *database.ReferralRequest) (*database.ReferralRequest, error) {
+	request.ReferralRequestId = 0
+	request.CandidateID = candidateID
+	request.Status = database.ReferralRequested
+
+	if err := s.canonicalizeReferralRequestJobLinks(ctx, request); err != nil {
+		return nil, err
+	}
+	normalizeReferralRequestLocations(request)
+
+	var createdRequest *database.ReferralRequest
+	err := s.dbDriver.RunInTransaction(func(tx DatabaseOperations) error {
+		if err := s.checkReferralRequestLimits(ctx, tx, candidateID, request); err != nil {
+			return err
+		}
+		created, err := tx.CreateReferralRequest(request)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error creating referral request", "candidate_id", candidateID, "error", err)
+			return fmt.Errorf("failed to create referral request: %w", err)
+		}
+		createdRequest = created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return createdRequest, nil
+}
+
+// UpdateReferralRequest updates an existing referral request owned by the candidate. Its job
+// links are canonicalized and its locations normalized as on creation. Duplicate job links are
+// always rejected, and moving the request to a different company is subject to the same limits
+// as creating a new one.
+func (s *Service) UpdateReferralRequest(ctx context.Context, candidateID uint64, request *database.ReferralRequest) (*database.ReferralRequest, error) {
+	request.CandidateID = candidateID
+
+	if err := s.canonicalizeReferralRequestJobLinks(ctx, request); err != nil {
+		return nil, err
+	}
+	normalizeReferralRequestLocations(request)
+
+	var updatedRequest *database.ReferralRequest
+	err := s.dbDriver.RunInTransaction(func(tx DatabaseOperations) error {
+		if err := s.checkReferralRequestLimits(ctx, tx, candidateID, request); err != nil {
+			return err
+		}
+		updated, err := tx.UpdateReferralRequest(request)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error updating referral request", "referral_request_id", request.ReferralRequestId, "candidate_id", candidateID, "error", err)
+			return fmt.Errorf("failed to update referral request: %w", err)
+		}
+		updatedRequest = updated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updatedRequest, nil
+}
+
+// ClaimReferralRequest lets a referrer take on an open referral request at their company and
+// marks the candidate as referred. If two referrers claim the same request at once, only one
+// succeeds.
+func (s *Service) ClaimReferralRequest(ctx context.Context, userID, referralRequestID uint64) (*database.ReferralRequest, error) {
+	referrer := s.dbDriver.GetReferrerByUserId(userID)
+	if referrer == nil || referrer.ReferrerId == 0 {
+		return nil, ErrReferrerNotFound
+	}
+
+	request := s.dbDriver.GetReferralRequestById(referralRequestID)
+	if request == nil {
+		return nil, ErrReferralRequestNotFound
+	}
+	if request.CompanyID != referrer.CompanyId {
+		slog.WarnContext(ctx, "Referrer attempted to claim a referral request at another company",
+			"referrer_id", referrer.ReferrerId, "referral_request_id", referralRequestID, "company_id", request.CompanyID)
+		return nil, ErrReferralRequestOtherCompany
+	}
+
+	// The capacity is checked under the referrer's lock in the claim's transaction, so that
+	// concurrent claims by the same referrer can't all pass it
+	var claimed *database.ReferralRequest
+	err := s.dbDriver.RunInTransaction(func(tx DatabaseOperations) error {
+		if err := s.checkReferrerCapacity(ctx, tx, referrer); err != nil {
+			return err
+		}
+		result, err := tx.ClaimReferralRequest(referralRequestID, referrer.ReferrerId)
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrReferralRequestNotClaimable):
+				return ErrReferralRequestAlreadyClaimed
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				return ErrReferralRequestNotFound
+			}
+			slog.ErrorContext(ctx, "Error claiming referral request", "referral_request_id", referralRequestID, "referrer_id", referrer.ReferrerId, "error", err)
+			return fmt.Errorf("failed to claim referral request: %w", err)
+		}
+		claimed = result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Referrer claimed referral request", "referral_request_id", referralRequestID, "referrer_id", referrer.ReferrerId)
+	return claimed, nil
+}
+
+// checkReferrerCapacity refuses a claim once the referrer has claimed their weekly capacity of
+// referral requests within the last ClaimWindow. It locks the referrer first, so it must run in
+// the transaction that claims the request.
+func (s *Service) checkReferrerCapacity(ctx context.Context, tx DatabaseOperations, referrer *database.Referrer) error {
+	if referrer.WeeklyCapacity <= 0 {
+		return nil
+	}
+	if err := tx.LockReferrer(referrer.ReferrerId); err != nil {
+		return fmt.Errorf("failed to lock referrer: %w", err)
+	}
+	claims, err := tx.CountReferrerClaimsSince(referrer.ReferrerId, time.Now().Add(-database.ClaimWindow))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting referrer claims", "referrer_id", referrer.ReferrerId, "error", err)
+		return fmt.Errorf("failed to check referral capacity: %w", err)
+	}
+	if claims >= int64(referrer.WeeklyCapacity) {
+		slog.InfoContext(ctx, "Referrer at weekly capacity", "referrer_id", referrer.ReferrerId, "claims", claims, "capacity", referrer.WeeklyCapacity)
+		return ErrReferrerAtCapacity
+	}
+	return nil
+}
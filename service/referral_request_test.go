@@ -0,0 +1,353 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
+	"github.com/Suhaibinator/muslim-referrals-backend/database"
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// --- Mock methods for referral requests ---
+
+func (m *MockDatabaseDriver) CreateReferralRequest(request *database.ReferralRequest) (*database.ReferralRequest, error) {
+	args := m.Called(request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.ReferralRequest), args.Error(1)
+}
+
+func (m *MockDatabaseDriver) UpdateReferralRequest(request *database.ReferralRequest) (*database.ReferralRequest, error) {
+	args := m.Called(request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.ReferralRequest), args.Error(1)
+}
+
+// LockCandidate records the lock; it doesn't go through m.Called so tests that don't care about
+// locking needn't set it up.
+func (m *MockDatabaseDriver) LockCandidate(candidateID uint64) error {
+	m.lockedCandidates = append(m.lockedCandidates, candidateID)
+	return nil
+}
+
+func (m *MockDatabaseDriver) GetReferralRequestsByCandidateId(candidateID uint64) []database.ReferralRequest {
+	args := m.Called(candidateID)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).([]database.ReferralRequest)
+}
+
+func (m *MockDatabaseDriver) GetReferralRequestById(id uint64) *database.ReferralRequest {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*database.ReferralRequest)
+}
+
+func (m *MockDatabaseDriver) ClaimReferralRequest(referralRequestID, referrerID uint64) (*database.ReferralRequest, error) {
+	args := m.Called(referralRequestID, referrerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.ReferralRequest), args.Error(1)
+}
+
+// LockReferrer records the lock, like LockCandidate.
+func (m *MockDatabaseDriver) LockReferrer(referrerID uint64) error {
+	m.lockedReferrers = append(m.lockedReferrers, referrerID)
+	return nil
+}
+
+func (m *MockDatabaseDriver) CountReferrerClaimsSince(referrerID uint64, since time.Time) (int64, error) {
+	args := m.Called(referrerID, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// --- Helpers ---
+
+func newReferralRequest(id, companyID uint64, status database.ReferralStatus, updatedAt time.Time, links ...string) database.ReferralRequest {
+	jobLinks := make([]database.ReferralRequestJobLinksAssociation, 0, len(links))
+	for _, link := range links {
+		jobLinks = append(jobLinks, database.ReferralRequestJobLinksAssociation{ReferralRequestID: id, JobLink: link})
+	}
+	return database.ReferralRequest{
+		ReferralRequestId: id,
+		CandidateID:       1,
+		CompanyID:         companyID,
+		JobLinks:          jobLinks,
+		Status:            status,
+		UpdatedAt:         updatedAt,
+	}
+}
+
+// --- Test Cases for CreateReferralRequest ---
+
+func TestCreateReferralRequest_Success(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{}, "https://boards.greenhouse.io/acme/jobs/123")
+
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralSubmissionAccepted, time.Now(), "https://boards.greenhouse.io/acme/jobs/123"),
+	}).Once()
+	mockDB.On("CreateReferralRequest", mock.MatchedBy(func(r *database.ReferralRequest) bool {
+		return r.CandidateID == candidateID && r.Status == database.ReferralRequested
+	})).Return(&request, nil).Once()
+
+	created, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, created)
+	mockDB.AssertExpectations(t)
+	// The limits are checked under the candidate's lock, in the transaction that creates the request
+	assert.Equal(t, []uint64{candidateID}, mockDB.lockedCandidates)
+	assert.Equal(t, 1, mockDB.committed)
+}
+
+func TestCreateReferralRequest_DuplicateNormalizedJobLink(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{}, "https://Boards.Greenhouse.io/acme/jobs/123/?utm_source=linkedin&gh_src=abc")
+
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 7, database.ReferralRequested, time.Now(), "https://boards.greenhouse.io/acme/jobs/123"),
+	}).Once()
+
+	created, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.Nil(t, created)
+	assert.ErrorIs(t, err, service.ErrDuplicateReferralRequest)
+	mockDB.AssertNotCalled(t, "CreateReferralRequest", mock.Anything)
+}
+
+func TestCreateReferralRequest_TooManyOpenForCompany(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ReferralRequests = config.ReferralRequestsConfig{MaxOpenPerCompany: 1}
+	s, mockDB, _ := setupServiceWithConfig(cfg, nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{}, "https://jobs.lever.co/acme/2")
+
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralSubmissionSent, time.Now(), "https://jobs.lever.co/acme/1"),
+	}).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.ErrorIs(t, err, service.ErrTooManyOpenRequestsForCompany)
+	mockDB.AssertNotCalled(t, "CreateReferralRequest", mock.Anything)
+	assert.Equal(t, 1, mockDB.rolledBack)
+}
+
+func TestCreateReferralRequest_TooManyOpenOverall(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ReferralRequests = config.ReferralRequestsConfig{MaxOpenPerCandidate: 2}
+	s, mockDB, _ := setupServiceWithConfig(cfg, nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 9, "", time.Time{})
+
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralRequested, time.Now()),
+		newReferralRequest(2, 6, database.ReferralRequested, time.Now()),
+		newReferralRequest(3, 7, database.ReferralSubmissionRejected, time.Now()),
+	}).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.ErrorIs(t, err, service.ErrTooManyOpenRequests)
+}
+
+func TestCreateReferralRequest_RejectionCooldown(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ReferralRequests = config.ReferralRequestsConfig{RejectionCooldown: 7 * 24 * time.Hour}
+	s, mockDB, _ := setupServiceWithConfig(cfg, nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{})
+
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralSubmissionRejected, time.Now().Add(-24*time.Hour)),
+	}).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.ErrorIs(t, err, service.ErrReferralRequestCooldown)
+}
+
+func TestCreateReferralRequest_CooldownElapsed(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ReferralRequests = config.ReferralRequestsConfig{RejectionCooldown: 7 * 24 * time.Hour}
+	s, mockDB, _ := setupServiceWithConfig(cfg, nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{})
+
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralSubmissionRejected, time.Now().Add(-8*24*time.Hour)),
+	}).Once()
+	mockDB.On("CreateReferralRequest", mock.AnythingOfType("*database.ReferralRequest")).Return(&request, nil).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestCreateReferralRequest_DbError(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(0, 5, "", time.Time{})
+	dbErr := errors.New("db is down")
+
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{}).Once()
+	mockDB.On("CreateReferralRequest", mock.AnythingOfType("*database.ReferralRequest")).Return(nil, dbErr).Once()
+
+	_, err := s.CreateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.ErrorIs(t, err, dbErr)
+}
+
+// --- Test Cases for UpdateReferralRequest ---
+
+func TestUpdateReferralRequest_SameCompanySkipsCompanyLimits(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ReferralRequests = config.ReferralRequestsConfig{MaxOpenPerCompany: 1}
+	s, mockDB, _ := setupServiceWithConfig(cfg, nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(1, 5, database.ReferralRequested, time.Now(), "https://jobs.lever.co/acme/1")
+
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralRequested, time.Now(), "https://jobs.lever.co/acme/1"),
+		newReferralRequest(2, 5, database.ReferralSubmissionSent, time.Now(), "https://jobs.lever.co/acme/2"),
+	}).Once()
+	mockDB.On("UpdateReferralRequest", &request).Return(&request, nil).Once()
+
+	_, err := s.UpdateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestUpdateReferralRequest_DuplicateOfAnotherRequest(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	candidateID := uint64(1)
+	request := newReferralRequest(1, 5, database.ReferralRequested, time.Now(), "https://jobs.lever.co/acme/2?lever-source=linkedin")
+
+	mockDB.On("GetCompanyById", uint64(5)).Return(acmeCompany())
+	mockDB.On("GetReferralRequestsByCandidateId", candidateID).Return([]database.ReferralRequest{
+		newReferralRequest(1, 5, database.ReferralRequested, time.Now(), "https://jobs.lever.co/acme/1"),
+		newReferralRequest(2, 5, database.ReferralRequested, time.Now(), "https://jobs.lever.co/acme/2"),
+	}).Once()
+
+	_, err := s.UpdateReferralRequest(context.Background(), candidateID, &request)
+
+	assert.ErrorIs(t, err, service.ErrDuplicateReferralRequest)
+	mockDB.AssertNotCalled(t, "UpdateReferralRequest", mock.Anything)
+}
+
+// --- Test Cases for ClaimReferralRequest ---
+
+func TestClaimReferralRequest_Success(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	referrer := &database.Referrer{ReferrerId: 9, UserId: userID, CompanyId: 5}
+	request := newReferralRequest(1, 5, database.ReferralRequested, time.Now())
+	claimed := newReferralRequest(1, 5, database.ReferralSubmissionSent, time.Now())
+
+	mockDB.On("GetReferrerByUserId", userID).Return(referrer).Once()
+	mockDB.On("GetReferralRequestById", uint64(1)).Return(&request).Once()
+	mockDB.On("ClaimReferralRequest", uint64(1), uint64(9)).Return(&claimed, nil).Once()
+
+	result, err := s.ClaimReferralRequest(context.Background(), userID, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, database.ReferralSubmissionSent, result.Status)
+	mockDB.AssertExpectations(t)
+}
+
+func TestClaimReferralRequest_OtherCompany(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	request := newReferralRequest(1, 6, database.ReferralRequested, time.Now())
+
+	mockDB.On("GetReferrerByUserId", userID).Return(&database.Referrer{ReferrerId: 9, UserId: userID, CompanyId: 5}).Once()
+	mockDB.On("GetReferralRequestById", uint64(1)).Return(&request).Once()
+
+	_, err := s.ClaimReferralRequest(context.Background(), userID, 1)
+
+	assert.ErrorIs(t, err, service.ErrReferralRequestOtherCompany)
+	mockDB.AssertNotCalled(t, "ClaimReferralRequest", mock.Anything, mock.Anything)
+}
+
+func TestClaimReferralRequest_AlreadyClaimed(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	request := newReferralRequest(1, 5, database.ReferralRequested, time.Now())
+
+	mockDB.On("GetReferrerByUserId", userID).Return(&database.Referrer{ReferrerId: 9, UserId: userID, CompanyId: 5}).Once()
+	mockDB.On("GetReferralRequestById", uint64(1)).Return(&request).Once()
+	mockDB.On("ClaimReferralRequest", uint64(1), uint64(9)).Return(nil, database.ErrReferralRequestNotClaimable).Once()
+
+	_, err := s.ClaimReferralRequest(context.Background(), userID, 1)
+
+	assert.ErrorIs(t, err, service.ErrReferralRequestAlreadyClaimed)
+}
+
+func TestClaimReferralRequest_NotAReferrer(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+
+	mockDB.On("GetReferrerByUserId", uint64(3)).Return(&database.Referrer{}).Once()
+
+	_, err := s.ClaimReferralRequest(context.Background(), 3, 1)
+
+	assert.ErrorIs(t, err, service.ErrReferrerNotFound)
+}
+
+func TestClaimReferralRequest_AtCapacity(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	request := newReferralRequest(1, 5, database.ReferralRequested, time.Now())
+
+	mockDB.On("GetReferrerByUserId", userID).Return(&database.Referrer{ReferrerId: 9, UserId: userID, CompanyId: 5, WeeklyCapacity: 2}).Once()
+	mockDB.On("GetReferralRequestById", uint64(1)).Return(&request).Once()
+	mockDB.On("CountReferrerClaimsSince", uint64(9), mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) >= database.ClaimWindow && time.Since(since) < database.ClaimWindow+time.Minute
+	})).Return(int64(2), nil).Once()
+
+	_, err := s.ClaimReferralRequest(context.Background(), userID, 1)
+
+	assert.ErrorIs(t, err, service.ErrReferrerAtCapacity)
+	mockDB.AssertNotCalled(t, "ClaimReferralRequest", mock.Anything, mock.Anything)
+}
+
+func TestClaimReferralRequest_UnderCapacity(t *testing.T) {
+	s, mockDB, _ := setupServiceWithMocks(nil)
+	userID := uint64(3)
+	request := newReferralRequest(1, 5, database.ReferralRequested, time.Now())
+	claimed := newReferralRequest(1, 5, database.ReferralSubmissionSent, time.Now())
+
+	mockDB.On("GetReferrerByUserId", userID).Return(&database.Referrer{ReferrerId: 9, UserId: userID, CompanyId: 5, WeeklyCapacity: 2}).Once()
+	mockDB.On("GetReferralRequestById", uint64(1)).Return(&request).Once()
+	mockDB.On("CountReferrerClaimsSince", uint64(9), mock.Anything).Return(int64(1), nil).Once()
+	mockDB.On("ClaimReferralRequest", uint64(1), uint64(9)).Return(&claimed, nil).Once()
+
+	_, err := s.ClaimReferralRequest(context.Background(), userID, 1)
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+	// The claims are counted under the referrer's lock, in the transaction that claims the request
+	assert.Equal(t, []uint64{9}, mockDB.lockedReferrers)
+	assert.Equal(t, 1, mockDB.committed)
+}
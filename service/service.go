@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"log"
+	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/Suhaibinator/muslim-referrals-backend/config"
 	"github.com/Suhaibinator/muslim-referrals-backend/database"
 
 	"github.com/jellydator/ttlcache/v3"
@@ -17,20 +19,68 @@ import (
 // DatabaseOperations defines the interface for database interactions needed by the service.
 // This allows mocking the database layer for unit tests.
 type DatabaseOperations interface {
+	// RunInTransaction runs fn as one unit of work: the calls it makes through tx are committed
+	// together if fn returns nil and rolled back together if it returns an error.
+	RunInTransaction(fn func(tx DatabaseOperations) error) error
+
 	// Email Verification Methods
 	GetActiveVerificationByEmail(email string) (*database.EmailVerification, error)
 	CountActiveVerificationsForUser(userID uint64) (int64, error)
 	CreateEmailVerification(verification *database.EmailVerification) (*database.EmailVerification, error)
 	UpdateEmailVerification(verification *database.EmailVerification) error
 	GetEmailVerificationByCode(code string) (*database.EmailVerification, error)
+	ExpireEmailVerifications(now time.Time) (int64, error)
+	IsEmailVerified(userID uint64, email string) (bool, error)
 
 	// Referrer Methods
 	GetReferrerByUserId(userID uint64) *database.Referrer
 	UpdateReferrer(userID uint64, referrer *database.Referrer) (*database.Referrer, error)
+	LockReferrer(referrerID uint64) error
+	CountReferrerClaimsSince(referrerID uint64, since time.Time) (int64, error)
 
 	// User Methods
 	GetUserByEmail(email string) *database.User
+	GetDeletedUserByEmail(email string) *database.User
+	RevokeUserSessions(userID uint64, at time.Time) error
+	GetSessionsRevokedAt(userID uint64) (*time.Time, error)
 	CreateUser(user *database.User) (*database.User, error)
+	UpdateUser(user *database.User) error
+	GetUserById(id uint64) *database.User
+	GetPersonalData(userID uint64) (*database.PersonalData, error)
+
+	// Account Deletion Methods
+	GetAccountDeletion(userID uint64) (*database.AccountDeletion, error)
+	GetAccountDeletionByCode(code string) (*database.AccountDeletion, error)
+	SaveAccountDeletion(deletion *database.AccountDeletion) error
+	CancelAccountDeletion(userID uint64) error
+	GetDueAccountDeletions(now time.Time) ([]database.AccountDeletion, error)
+	DeleteUnconfirmedAccountDeletions(now time.Time) (int64, error)
+	AnonymizeUser(userID uint64) error
+
+	// Company Methods
+	GetCompanyById(id uint64) *database.Company
+	GetAllCompanies() []database.Company
+	CreateCompany(record *database.Company) (*database.Company, error)
+	MergeCompanies(sourceID, targetID uint64, mergedByUserID *uint64) (*database.CompanyMerge, error)
+
+	// Referral Request Methods
+	LockCandidate(candidateID uint64) error
+	CreateReferralRequest(request *database.ReferralRequest) (*database.ReferralRequest, error)
+	UpdateReferralRequest(request *database.ReferralRequest) (*database.ReferralRequest, error)
+	GetReferralRequestsByCandidateId(candidateID uint64) []database.ReferralRequest
+	GetReferralRequestById(id uint64) *database.ReferralRequest
+	ClaimReferralRequest(referralRequestID, referrerID uint64) (*database.ReferralRequest, error)
+	GetOpenReferralRequestsByCompanyId(companyID uint64) []database.ReferralRequest
+	CountReferralRequestViewers(referralRequestIDs []uint64, excludeReferrerID uint64) (map[uint64]int64, error)
+	CountReferrerClaimsByType(referrerID uint64) (map[database.ReferralType]int64, error)
+	GetReferralRequestTimings(since time.Time) ([]database.ReferralRequestTiming, error)
+	GetUnplacedReferralRequestLocations() ([]database.ReferralRequestLocationAssociation, error)
+	UpdateReferralRequestLocations(locations []database.ReferralRequestLocationAssociation) error
+
+	// Job Posting Methods
+	CreateJobPosting(posting *database.JobPosting) (*database.JobPosting, error)
+	UpdateJobPosting(referrerID uint64, posting *database.JobPosting) (*database.JobPosting, error)
+	GetJobPostingById(id uint64) *database.JobPosting
 	// Add other DB methods used by the service here...
 }
 
@@ -42,34 +92,115 @@ type EmailSender interface {
 }
 
 type Service struct {
+	config        *config.Config
 	oauthConfig   *oauth2.Config
-	userToIdCache *ttlcache.Cache[string, uint64]
-	dbDriver      DatabaseOperations // Use the interface type
-	emailSender   EmailSender        // Use the interface type (can be resend.EmailsSvc)
+	userToIdCache *ttlcache.Cache[string, cachedSession]
+	revokedTokens *ttlcache.Cache[string, struct{}]   // Tokens of deleted accounts, refused instead of looked up
+	exports       *ttlcache.Cache[uint64, *exportJob] // Each user's latest personal data export
+	exportsMu     sync.Mutex                          // Serializes starting exports
+	dbDriver      DatabaseOperations                  // Use the interface type
+	emailSender   EmailSender                         // Use the interface type (can be resend.EmailsSvc)
+
+	workers sync.WaitGroup // Background goroutines started by Start
+	stop    chan struct{}  // Closed by Stop to end the background jobs
+}
+
+// cachedSession is what the token cache holds for a token: its user and when it was looked up.
+type cachedSession struct {
+	userID   uint64
+	cachedAt time.Time
 }
 
 // SetUserIDForToken allows tests to seed the cache with a token to user ID mapping.
 func (s *Service) SetUserIDForToken(token string, userID uint64) {
-	s.userToIdCache.Set(token, userID, ttlcache.DefaultTTL)
+	s.userToIdCache.Set(token, cachedSession{userID: userID, cachedAt: time.Now()}, ttlcache.DefaultTTL)
+}
+
+// CachedUserIDForToken returns the user ID for a token digest if it is already cached.
+// Unlike GetUserIdFromTokenDigest it never calls out to Google or creates users.
+func (s *Service) CachedUserIDForToken(token string) (uint64, bool) {
+	item := s.userToIdCache.Get(token)
+	if item == nil {
+		return 0, false
+	}
+	return item.Value().userID, true
 }
 
 // NewService now accepts interfaces for dependencies, improving testability.
-func NewService(oauthConfig *oauth2.Config, dbDriver DatabaseOperations, emailSender EmailSender) *Service {
-	userToIdCache := ttlcache.New[string, uint64](
-		ttlcache.WithTTL[string, uint64](24 * time.Hour),
+// All settings (OAuth, verification, limits) come from cfg.
+func NewService(cfg *config.Config, dbDriver DatabaseOperations, emailSender EmailSender) *Service {
+	userToIdCache := ttlcache.New[string, cachedSession](
+		ttlcache.WithTTL[string, cachedSession](cfg.Auth.TokenCacheTTL),
 	)
 
 	// Dependencies (dbDriver, emailSender) are now injected.
 	// No need to initialize Resend client here; it's passed in.
 
+	revokedTokens := ttlcache.New[string, struct{}](
+		ttlcache.WithTTL[string, struct{}](cfg.Auth.TokenCacheTTL),
+		ttlcache.WithDisableTouchOnHit[string, struct{}](),
+	)
+
+	// Touching an export on download mustn't extend how long it is kept
+	exports := ttlcache.New[uint64, *exportJob](
+		ttlcache.WithTTL[uint64, *exportJob](cfg.Export.Retention),
+		ttlcache.WithDisableTouchOnHit[uint64, *exportJob](),
+	)
+
 	return &Service{
-		oauthConfig:   oauthConfig,
+		config:        cfg,
+		oauthConfig:   cfg.GoogleOAuthConfig(),
 		userToIdCache: userToIdCache,
+		revokedTokens: revokedTokens,
+		exports:       exports,
 		dbDriver:      dbDriver,    // Assign injected DB interface
 		emailSender:   emailSender, // Assign injected email sender interface
+		stop:          make(chan struct{}),
+	}
+}
+
+// EmailSendingEnabled reports whether an email transport was configured.
+func (s *Service) EmailSendingEnabled() bool {
+	return s.emailSender != nil
+}
+
+// Start launches the service's background workers: evicting expired tokens, revocations and
+// exports from their caches and, every Jobs.ReapInterval, the expiry jobs run by Reap. They run until Stop
+// is called.
+func (s *Service) Start() {
+	s.workers.Add(3)
+	go func() {
+		defer s.workers.Done()
+		s.userToIdCache.Start()
+	}()
+	go func() {
+		defer s.workers.Done()
+		s.revokedTokens.Start()
+	}()
+	go func() {
+		defer s.workers.Done()
+		s.exports.Start()
+	}()
+
+	if interval := s.config.Jobs.ReapInterval; interval > 0 {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			s.runReaper(interval)
+		}()
 	}
 }
 
+// Stop signals the background workers started by Start to exit and waits for them to finish.
+func (s *Service) Stop() {
+	close(s.stop)
+	s.userToIdCache.Stop()
+	s.revokedTokens.Stop()
+	s.exports.Stop()
+	s.workers.Wait()
+	slog.Info("Service background workers stopped")
+}
+
 func (s *Service) GetTokenFromCode(ctx context.Context, code string) (*oauth2.Token, error) {
 	return s.oauthConfig.Exchange(ctx, code)
 }
@@ -77,31 +208,51 @@ func (s *Service) GetTokenFromCode(ctx context.Context, code string) (*oauth2.To
 func (s *Service) GetUserIdFromTokenDigest(ctx context.Context, tokenDigest string) (uint64, bool, error) {
 	result := s.userToIdCache.Get(tokenDigest)
 	if result != nil {
-		log.Printf("[GetUserIdFromTokenDigest] Cache hit for token digest %s", tokenDigest)
-		return result.Value(), false, nil
+		slog.DebugContext(ctx, "Token cache hit", "token_digest", tokenDigest)
+		session := result.Value()
+		// Sessions may have been revoked by another process, such as the reap command
+		revokedAt, err := s.dbDriver.GetSessionsRevokedAt(session.userID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking session revocation", "cached_user_id", session.userID, "error", err)
+			return 0, false, err
+		}
+		if revokedAt != nil && !session.cachedAt.After(*revokedAt) {
+			s.userToIdCache.Delete(tokenDigest)
+			s.revokedTokens.Set(tokenDigest, struct{}{}, ttlcache.DefaultTTL)
+			slog.InfoContext(ctx, "Refused revoked token", "token_digest", tokenDigest)
+			return 0, false, ErrSessionRevoked
+		}
+		return session.userID, false, nil
+	}
+	slog.DebugContext(ctx, "Token cache miss", "token_digest", tokenDigest)
+	if s.revokedTokens.Has(tokenDigest) {
+		slog.InfoContext(ctx, "Refused revoked token", "token_digest", tokenDigest)
+		return 0, false, ErrSessionRevoked
 	}
-	log.Printf("[GetUserIdFromTokenDigest] Cache miss for token digest %s", tokenDigest)
 
 	userInfo, err := s.queryGoogleForEmail(ctx, tokenDigest)
-	log.Printf("[GetUserIdFromTokenDigest] Got user info: %+v", userInfo)
 	if err != nil {
-		log.Printf("[GetUserIdFromTokenDigest] Error getting user info: %v", err)
+		slog.WarnContext(ctx, "Error getting user info from Google", "error", err)
 		return 0, true, err
 	}
 
 	newUser := false
 	user := s.dbDriver.GetUserByEmail(userInfo.Email)
+	if user == nil && s.dbDriver.GetDeletedUserByEmail(userInfo.Email) != nil {
+		slog.InfoContext(ctx, "Refused sign in for a deleted user", "email", userInfo.Email)
+		return 0, false, ErrUserDeleted
+	}
 	if user == nil {
 		newUser = true
 		user, err = s.HandleNewUser(ctx, tokenDigest, userInfo)
 		if err != nil {
-			log.Printf("[GetUserIdFromTokenDigest] Error handling new user: %v", err)
+			slog.ErrorContext(ctx, "Error handling new user", "email", userInfo.Email, "error", err)
 			return 0, newUser, err
 		}
 	}
 
-	s.userToIdCache.Set(tokenDigest, user.Id, ttlcache.DefaultTTL)
-	log.Printf("[GetUserIdFromTokenDigest] Set cache for token digest %s", tokenDigest)
+	s.userToIdCache.Set(tokenDigest, cachedSession{userID: user.Id, cachedAt: time.Now()}, ttlcache.DefaultTTL)
+	slog.DebugContext(ctx, "Cached token for user", "cached_user_id", user.Id, "new_user", newUser)
 
 	return user.Id, newUser, nil
 }
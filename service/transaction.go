@@ -0,0 +1,20 @@
+package service
+
+import "github.com/Suhaibinator/muslim-referrals-backend/database"
+
+// dbOperations adapts *database.DbDriver to DatabaseOperations. The driver's own methods are
+// promoted; RunInTransaction hands fn a dbOperations bound to the open transaction.
+type dbOperations struct {
+	*database.DbDriver
+}
+
+// NewDatabaseOperations wraps the database driver so it can be passed to NewService.
+func NewDatabaseOperations(db *database.DbDriver) DatabaseOperations {
+	return dbOperations{DbDriver: db}
+}
+
+func (d dbOperations) RunInTransaction(fn func(tx DatabaseOperations) error) error {
+	return d.Transaction(func(tx *database.DbDriver) error {
+		return fn(dbOperations{DbDriver: tx})
+	})
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/Suhaibinator/muslim-referrals-backend/database"
 
@@ -29,6 +30,6 @@ func (s *Service) HandleNewUser(ctx context.Context, tokenDigest string, userInf
 	if err != nil {
 		return nil, err
 	}
-	s.userToIdCache.Set(tokenDigest, createdUser.Id, ttlcache.DefaultTTL)
+	s.userToIdCache.Set(tokenDigest, cachedSession{userID: createdUser.Id, cachedAt: time.Now()}, ttlcache.DefaultTTL)
 	return createdUser, nil
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Suhaibinator/muslim-referrals-backend/service"
+)
+
+const userUsage = `usage: muslim-referrals user <promote-admin|demote-admin> [flags] <email>
+
+  promote-admin  grant admin rights to the user with this email
+  demote-admin   revoke them
+
+The user must have signed in at least once. Flags are the same as the server's.`
+
+// runUser implements the user subcommand and returns the process exit code.
+func runUser(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+	if isHelpFlag(args[0]) {
+		fmt.Println(userUsage)
+		return 0
+	}
+	action, args := args[0], args[1:]
+	var isAdmin bool
+	switch action {
+	case "promote-admin":
+		isAdmin = true
+	case "demote-admin":
+		isAdmin = false
+	default:
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("user", flag.ContinueOnError)
+	cfg, code := loadCommandConfig(fs, args, userUsage)
+	if cfg == nil {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+	email := fs.Arg(0)
+
+	ctx := context.Background()
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.CloseDatabase()
+
+	user, err := newCommandService(cfg, db).SetAdmin(ctx, email, isAdmin)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			fmt.Fprintf(os.Stderr, "No user with email %s\n", email)
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to update user: %v\n", err)
+		}
+		return 1
+	}
+	if user.IsAdmin {
+		fmt.Printf("%s (user %d) is an admin\n", user.Email, user.Id)
+	} else {
+		fmt.Printf("%s (user %d) is not an admin\n", user.Email, user.Id)
+	}
+	return 0
+}